@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"errors"
+	"flux-panel/config"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bus 面板实例之间传递消息和共享状态的总线
+// 单实例部署使用内存实现；多实例部署时各实例连接同一个 Redis
+type Bus interface {
+	// Publish 向频道发布消息，所有订阅该频道的实例（包括自身）都会收到
+	Publish(channel string, payload []byte) error
+	// Subscribe 订阅频道，同一订阅的消息按发布顺序依次交给 handler 处理
+	Subscribe(channel string, handler func(payload []byte)) error
+
+	// Set 写入键值，ttl 为 0 时不过期
+	Set(key string, value []byte, ttl time.Duration) error
+	// SetNX 键不存在时写入，返回是否写入成功
+	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
+	// Refresh 键的值仍为 value 时续期，返回是否续期成功
+	Refresh(key string, value []byte, ttl time.Duration) (bool, error)
+	// Get 读取键值，键不存在时返回 ErrNotFound
+	Get(key string) ([]byte, error)
+	// Take 读取并删除键值，用于一次性令牌，键不存在时返回 ErrNotFound
+	Take(key string) ([]byte, error)
+	// DeleteIf 键的值仍为 value 时删除
+	DeleteIf(key string, value []byte) error
+
+	Close() error
+}
+
+// ErrNotFound 键不存在或已过期
+var ErrNotFound = errors.New("键不存在")
+
+// 总线类型
+const (
+	BusMemory = "memory"
+	BusRedis  = "redis"
+)
+
+var (
+	mu         sync.RWMutex
+	current    Bus = NewMemoryBus()
+	instanceID     = uuid.NewString()
+)
+
+// Init 按配置创建总线，未调用时使用内存总线
+func Init(cfg config.ClusterConfig) error {
+	var bus Bus
+	switch cfg.Bus {
+	case "", BusMemory:
+		bus = NewMemoryBus()
+	case BusRedis:
+		redisBus, err := NewRedisBus(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+		if err != nil {
+			return err
+		}
+		bus = redisBus
+	default:
+		return fmt.Errorf("不支持的总线类型: %s", cfg.Bus)
+	}
+
+	mu.Lock()
+	old := current
+	current = bus
+	if cfg.InstanceID != "" {
+		instanceID = cfg.InstanceID
+	}
+	mu.Unlock()
+
+	old.Close()
+	log.Printf("Cluster bus: %s, instance: %s", busName(cfg.Bus), InstanceID())
+	return nil
+}
+
+// Get 返回当前总线
+func Get() Bus {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// InstanceID 当前面板实例的标识
+func InstanceID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return instanceID
+}
+
+// Close 关闭总线
+func Close() error {
+	return Get().Close()
+}
+
+// TryLock 在 ttl 内抢占名为 name 的锁，用于多实例间只需执行一次的任务，锁到期后自动释放
+func TryLock(name string, ttl time.Duration) bool {
+	ok, err := Get().SetNX("lock:"+name, []byte(InstanceID()), ttl)
+	if err != nil {
+		log.Printf("获取集群锁 %s 失败: %v", name, err)
+		return false
+	}
+	return ok
+}
+
+// ExtendLock 延长本实例持有的锁，锁已过期或被其他实例持有时返回 false
+func ExtendLock(name string, ttl time.Duration) bool {
+	ok, err := Get().Refresh("lock:"+name, []byte(InstanceID()), ttl)
+	if err != nil {
+		log.Printf("延长集群锁 %s 失败: %v", name, err)
+		return false
+	}
+	return ok
+}
+
+// Unlock 释放本实例持有的锁
+func Unlock(name string) {
+	if err := Get().DeleteIf("lock:"+name, []byte(InstanceID())); err != nil {
+		log.Printf("释放集群锁 %s 失败: %v", name, err)
+	}
+}
+
+func busName(name string) string {
+	if name == "" {
+		return BusMemory
+	}
+	return name
+}
@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval 内存总线清理过期键的间隔
+const memorySweepInterval = time.Minute
+
+// MemoryBus 进程内总线，用于单实例部署
+type MemoryBus struct {
+	mu        sync.Mutex
+	subs      map[string][]chan []byte
+	values    map[string]memoryValue
+	lastSweep time.Time
+	closed    bool
+}
+
+type memoryValue struct {
+	data   []byte
+	expire time.Time // 零值表示不过期
+}
+
+func (v memoryValue) expired(now time.Time) bool {
+	return !v.expire.IsZero() && now.After(v.expire)
+}
+
+// NewMemoryBus 创建进程内总线
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subs:      make(map[string][]chan []byte),
+		values:    make(map[string]memoryValue),
+		lastSweep: time.Now(),
+	}
+}
+
+func (b *MemoryBus) Publish(channel string, payload []byte) error {
+	b.mu.Lock()
+	subs := append([]chan []byte(nil), b.subs[channel]...)
+	closed := b.closed
+	b.mu.Unlock()
+
+	if closed {
+		return nil
+	}
+	for _, ch := range subs {
+		ch <- payload
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(channel string, handler func(payload []byte)) error {
+	ch := make(chan []byte, 1024)
+
+	b.mu.Lock()
+	b.subs[channel] = append(b.subs[channel], ch)
+	b.mu.Unlock()
+
+	go func() {
+		for payload := range ch {
+			handler(payload)
+		}
+	}()
+	return nil
+}
+
+func (b *MemoryBus) Set(key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.values[key] = newMemoryValue(value, ttl)
+	b.sweep()
+	return nil
+}
+
+func (b *MemoryBus) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if v, ok := b.values[key]; ok && !v.expired(time.Now()) {
+		return false, nil
+	}
+	b.values[key] = newMemoryValue(value, ttl)
+	b.sweep()
+	return true, nil
+}
+
+func (b *MemoryBus) Refresh(key string, value []byte, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	v, ok := b.values[key]
+	if !ok || v.expired(time.Now()) || !bytes.Equal(v.data, value) {
+		return false, nil
+	}
+	b.values[key] = newMemoryValue(value, ttl)
+	return true, nil
+}
+
+func (b *MemoryBus) Get(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	v, ok := b.values[key]
+	if !ok || v.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return v.data, nil
+}
+
+func (b *MemoryBus) Take(key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	v, ok := b.values[key]
+	delete(b.values, key)
+	if !ok || v.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return v.data, nil
+}
+
+func (b *MemoryBus) DeleteIf(key string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if v, ok := b.values[key]; ok && bytes.Equal(v.data, value) {
+		delete(b.values, key)
+	}
+	return nil
+}
+
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 不关闭订阅通道，避免与并发的 Publish 竞争；关闭后不再投递新消息
+	b.closed = true
+	b.subs = make(map[string][]chan []byte)
+	return nil
+}
+
+// sweep 定期清理过期键，调用方需持有锁
+func (b *MemoryBus) sweep() {
+	now := time.Now()
+	if now.Sub(b.lastSweep) < memorySweepInterval {
+		return
+	}
+	b.lastSweep = now
+	for key, v := range b.values {
+		if v.expired(now) {
+			delete(b.values, key)
+		}
+	}
+}
+
+func newMemoryValue(value []byte, ttl time.Duration) memoryValue {
+	v := memoryValue{data: append([]byte(nil), value...)}
+	if ttl > 0 {
+		v.expire = time.Now().Add(ttl)
+	}
+	return v
+}
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisPrefix 面板写入 Redis 的键和频道前缀
+	redisPrefix = "flux:"
+	// redisTimeout 单次 Redis 操作的超时时间
+	redisTimeout = 3 * time.Second
+)
+
+var (
+	// takeScript 读取并删除键，兼容不支持 GETDEL 的 Redis 版本
+	takeScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then redis.call('DEL', KEYS[1]) end
+return v`)
+	refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+	deleteIfScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// RedisBus 基于 Redis 发布订阅和键值的总线，用于多实例部署
+type RedisBus struct {
+	client *redis.Client
+	mu     sync.Mutex
+	subs   []*redis.PubSub
+}
+
+// NewRedisBus 连接 Redis 并创建总线
+func NewRedisBus(addr, password string, db int) (*RedisBus, error) {
+	if addr == "" {
+		return nil, errors.New("未配置 Redis 地址")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
+	}
+	return &RedisBus{client: client}, nil
+}
+
+func (b *RedisBus) Publish(channel string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return b.client.Publish(ctx, redisPrefix+channel, payload).Err()
+}
+
+func (b *RedisBus) Subscribe(channel string, handler func(payload []byte)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	ps := b.client.Subscribe(ctx, redisPrefix+channel)
+	// 等待订阅确认，确保返回后发布的消息不会丢失
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return fmt.Errorf("订阅 %s 失败: %w", channel, err)
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, ps)
+	b.mu.Unlock()
+
+	go func() {
+		// 连接断开后 go-redis 会自动重连并重新订阅
+		for msg := range ps.Channel(redis.WithChannelSize(1024)) {
+			handler([]byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBus) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return b.client.Set(ctx, redisPrefix+key, value, ttl).Err()
+}
+
+func (b *RedisBus) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return b.client.SetNX(ctx, redisPrefix+key, value, ttl).Result()
+}
+
+func (b *RedisBus) Refresh(key string, value []byte, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := refreshScript.Run(ctx, b.client, []string{redisPrefix + key}, value, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (b *RedisBus) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	data, err := b.client.Get(ctx, redisPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (b *RedisBus) Take(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	v, err := takeScript.Run(ctx, b.client, []string{redisPrefix + key}).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v), nil
+}
+
+func (b *RedisBus) DeleteIf(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return deleteIfScript.Run(ctx, b.client, []string{redisPrefix + key}, value).Err()
+}
+
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	for _, ps := range b.subs {
+		if err := ps.Close(); err != nil {
+			log.Printf("关闭 Redis 订阅失败: %v", err)
+		}
+	}
+	b.subs = nil
+	b.mu.Unlock()
+	return b.client.Close()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flux-panel/dto"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client 面板接口客户端，未指定 token 时在首次请求前使用用户名和密码登录
+type client struct {
+	server   string
+	token    string
+	username string
+	password string
+	http     *http.Client
+}
+
+// response 面板接口的统一响应结构
+type response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+func newClient(server, token, username, password string, timeout time.Duration) *client {
+	return &client{
+		server:   strings.TrimRight(server, "/"),
+		token:    strings.TrimPrefix(token, "Bearer "),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// login 使用用户名和密码登录，启用了验证码的面板需改用 token
+func (c *client) login() error {
+	if c.username == "" || c.password == "" {
+		return &exitError{code: exitUsage, err: errors.New("登录需要指定 -user 和 -password")}
+	}
+	resp, err := c.post("/api/v1/user/login", dto.LoginDto{Username: c.username, Password: c.password})
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.Token == "" {
+		return &exitError{code: exitAuth, err: errors.New("登录失败: 响应中没有 token")}
+	}
+	c.token = data.Token
+	return nil
+}
+
+// authenticate 确保已有 token
+func (c *client) authenticate() error {
+	if c.token != "" {
+		return nil
+	}
+	if c.username == "" {
+		return &exitError{code: exitAuth, err: errors.New("未登录: 请通过 -token 或 -user/-password 指定认证信息")}
+	}
+	return c.login()
+}
+
+// call 携带 token 调用需要认证的接口，body 为空时发送空对象
+func (c *client) call(path string, body interface{}) (*response, error) {
+	if err := c.authenticate(); err != nil {
+		return nil, err
+	}
+	return c.post(path, body)
+}
+
+func (c *client) post(path string, body interface{}) (*response, error) {
+	if body == nil {
+		body = struct{}{}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.server+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, &exitError{code: exitUsage, err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, &exitError{code: exitFailed, err: fmt.Errorf("请求失败: %v", err)}
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, &exitError{code: exitFailed, err: fmt.Errorf("读取响应失败: %v", err)}
+	}
+
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, &exitError{code: exitFailed, err: fmt.Errorf("HTTP %d: 无法解析响应", res.StatusCode)}
+	}
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || resp.Code == 401 || resp.Code == 403:
+		return nil, &exitError{code: exitAuth, err: errors.New(resp.Msg)}
+	case res.StatusCode != http.StatusOK:
+		return nil, &exitError{code: exitFailed, err: fmt.Errorf("HTTP %d: %s", res.StatusCode, resp.Msg)}
+	case resp.Code != 0:
+		return nil, &exitError{code: exitFailed, err: errors.New(resp.Msg)}
+	}
+	return &resp, nil
+}
+
+// list 查询列表接口
+func (c *client) list(path string) ([]map[string]interface{}, json.RawMessage, error) {
+	resp, err := c.call(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(resp.Data, &rows); err != nil {
+		return nil, nil, fmt.Errorf("无法解析列表: %v", err)
+	}
+	return rows, resp.Data, nil
+}
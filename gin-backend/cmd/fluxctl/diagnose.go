@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// diagnosisReport 转发与隧道诊断的结果
+type diagnosisReport struct {
+	Results []struct {
+		NodeName    string  `json:"nodeName"`
+		TargetIP    string  `json:"targetIp"`
+		TargetPort  int     `json:"targetPort"`
+		Description string  `json:"description"`
+		Success     bool    `json:"success"`
+		Message     string  `json:"message"`
+		AverageTime float64 `json:"averageTime"`
+		PacketLoss  float64 `json:"packetLoss"`
+		Probe       string  `json:"probe"`
+	} `json:"results"`
+}
+
+// diagnoseForward 诊断转发: diagnose -id N [-protocol tcp|udp|http] [-trace]
+func diagnoseForward(c *client, p *printer, args []string) error {
+	fs := newFlagSet("forward diagnose")
+	id := fs.Uint("id", 0, "转发ID")
+	protocol := fs.String("protocol", "", "探测方式: tcp, udp, http，默认 tcp")
+	trace := fs.Bool("trace", false, "附带路由追踪")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return usageError("缺少 -id")
+	}
+	return diagnose(c, p, "/api/v1/forward/diagnose", map[string]interface{}{
+		"forwardId": *id,
+		"protocol":  *protocol,
+		"trace":     *trace,
+	})
+}
+
+// diagnoseTunnel 诊断隧道: diagnose -id N [-trace]
+func diagnoseTunnel(c *client, p *printer, args []string) error {
+	fs := newFlagSet("tunnel diagnose")
+	id := fs.Uint("id", 0, "隧道ID")
+	trace := fs.Bool("trace", false, "附带路由追踪")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return usageError("缺少 -id")
+	}
+	return diagnose(c, p, "/api/v1/tunnel/diagnose", map[string]interface{}{
+		"tunnelId": *id,
+		"trace":    *trace,
+	})
+}
+
+// diagnose 执行诊断并打印结果，存在未通过的检查项时以 exitCheck 退出
+func diagnose(c *client, p *printer, path string, req map[string]interface{}) error {
+	resp, err := c.call(path, req)
+	if err != nil {
+		return err
+	}
+	var report diagnosisReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return fmt.Errorf("无法解析诊断结果: %v", err)
+	}
+
+	if p.format != formatTable {
+		if err := p.raw(resp.Data); err != nil {
+			return err
+		}
+	} else {
+		rows := make([]map[string]interface{}, 0, len(report.Results))
+		for _, r := range report.Results {
+			result, latency := "ok", "-"
+			if !r.Success {
+				result = "failed"
+			}
+			if r.AverageTime >= 0 {
+				latency = fmt.Sprintf("%.1fms", r.AverageTime)
+			}
+			rows = append(rows, map[string]interface{}{
+				"node":    r.NodeName,
+				"check":   r.Description,
+				"probe":   r.Probe,
+				"target":  r.TargetIP + ":" + strconv.Itoa(r.TargetPort),
+				"result":  result,
+				"latency": latency,
+				"loss":    fmt.Sprintf("%.0f%%", r.PacketLoss),
+				"message": r.Message,
+			})
+		}
+		columns := []column{
+			{"NODE", field("node")},
+			{"CHECK", field("check")},
+			{"PROBE", field("probe")},
+			{"TARGET", field("target")},
+			{"RESULT", field("result")},
+			{"LATENCY", field("latency")},
+			{"LOSS", field("loss")},
+			{"MESSAGE", field("message")},
+		}
+		if err := p.table(columns, rows, nil); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, r := range report.Results {
+		if !r.Success {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &exitError{code: exitCheck, err: fmt.Errorf("%d/%d 项检查未通过", failed, len(report.Results))}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// fluxctl 面板接口的命令行客户端，用于脚本与 CI 中管理节点、隧道、转发、用户和限速规则。
+//
+//	fluxctl [全局参数] <命令> [操作] [参数]
+//
+// 认证信息可通过 -token 或 -user/-password 指定，也可使用环境变量
+// FLUX_SERVER、FLUX_TOKEN、FLUX_USER、FLUX_PASSWORD。
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 退出码
+const (
+	exitOK     = 0
+	exitFailed = 1 // 请求失败或接口返回错误
+	exitUsage  = 2 // 命令或参数错误
+	exitCheck  = 3 // 诊断存在未通过的检查项
+	exitAuth   = 4 // 未登录、token 无效或权限不足
+)
+
+// exitError 携带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func usageError(format string, args ...interface{}) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == nil {
+		return
+	}
+	code := exitFailed
+	var e *exitError
+	if errors.As(err, &e) {
+		code = e.code
+	}
+	if !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+	} else {
+		code = exitOK
+	}
+	os.Exit(code)
+}
+
+// run 解析全局参数并执行命令
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fluxctl", flag.ContinueOnError)
+	server := fs.String("server", envOr("FLUX_SERVER", "http://127.0.0.1:6365"), "面板地址")
+	token := fs.String("token", os.Getenv("FLUX_TOKEN"), "登录 token")
+	username := fs.String("user", os.Getenv("FLUX_USER"), "用户名，未指定 token 时使用用户名和密码登录")
+	password := fs.String("password", os.Getenv("FLUX_PASSWORD"), "密码")
+	output := fs.String("o", "table", "输出格式: table, json, yaml")
+	timeout := fs.Duration("timeout", 30*time.Second, "请求超时时间")
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: exitUsage, err: err}
+	}
+
+	p, err := newPrinter(*output, stdout)
+	if err != nil {
+		return err
+	}
+	c := newClient(*server, *token, *username, *password, *timeout)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageError("缺少命令")
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "login":
+		return loginCommand(c, p)
+	case "help":
+		fs.Usage()
+		return nil
+	}
+	for _, r := range resources {
+		if r.name == cmd {
+			return r.run(c, p, rest)
+		}
+	}
+	return usageError("未知命令 %s，使用 fluxctl help 查看帮助", cmd)
+}
+
+// loginCommand 登录并输出 token，便于在脚本中通过 FLUX_TOKEN 复用
+func loginCommand(c *client, p *printer) error {
+	if err := c.login(); err != nil {
+		return err
+	}
+	if p.format == formatTable {
+		fmt.Fprintln(p.w, c.token)
+		return nil
+	}
+	return p.value(map[string]string{"token": c.token})
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "用法: fluxctl [全局参数] <命令> [操作] [参数]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	fmt.Fprintf(w, "  %-12s %s\n", "login", "登录并输出 token")
+	for _, r := range resources {
+		fmt.Fprintf(w, "  %-12s %s\n", r.name, strings.Join(r.actionNames(), ", "))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "全局参数:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "退出码: 0 成功, 1 请求失败, 2 参数错误, 3 诊断未通过, 4 认证失败")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出格式
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// printer 按输出格式打印结果，json 和 yaml 输出接口返回的完整数据
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// column 表格的一列，value 从一行记录中取值
+type column struct {
+	header string
+	value  func(row map[string]interface{}) string
+}
+
+func newPrinter(format string, w io.Writer) (*printer, error) {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return &printer{format: format, w: w}, nil
+	}
+	return nil, usageError("不支持的输出格式 %s，仅支持 table、json 和 yaml", format)
+}
+
+// table 打印列表，表格格式只输出指定的列
+func (p *printer) table(columns []column, rows []map[string]interface{}, raw json.RawMessage) error {
+	if p.format != formatTable {
+		return p.raw(raw)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.header
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i] = col.value(row)
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// result 打印创建、更新、删除等操作的结果，表格格式只输出提示信息
+func (p *printer) result(resp *response) error {
+	if p.format == formatTable {
+		fmt.Fprintln(p.w, resp.Msg)
+		return nil
+	}
+	return p.raw(resp.Data)
+}
+
+// raw 以 json 或 yaml 打印接口返回的原始数据
+func (p *printer) raw(data json.RawMessage) error {
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+	if p.format == formatJSON {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := p.w.Write(buf.Bytes())
+		return err
+	}
+	// 保留整数精度，避免时间戳等大整数以科学计数法输出
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return p.value(numbers(v))
+}
+
+// numbers 将 json.Number 转换为整数或浮点数
+func numbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = numbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbers(item)
+		}
+	}
+	return v
+}
+
+// value 以 json 或 yaml 打印任意值
+func (p *printer) value(v interface{}) error {
+	if p.format == formatYAML {
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// field 取字段的值，整数不带小数，空值输出 -
+func field(key string) func(map[string]interface{}) string {
+	return func(row map[string]interface{}) string {
+		return text(row[key])
+	}
+}
+
+// enum 按取值映射为名称，未知取值原样输出
+func enum(key string, names map[int]string) func(map[string]interface{}) string {
+	return func(row map[string]interface{}) string {
+		if n, ok := row[key].(float64); ok {
+			if name, ok := names[int(n)]; ok {
+				return name
+			}
+		}
+		return text(row[key])
+	}
+}
+
+// timestamp 将毫秒时间戳格式化为日期，0 输出 -
+func timestamp(key string) func(map[string]interface{}) string {
+	return func(row map[string]interface{}) string {
+		ms, _ := row[key].(float64)
+		if ms <= 0 {
+			return "-"
+		}
+		return time.UnixMilli(int64(ms)).Format("2006-01-02 15:04")
+	}
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resource 一类面板资源，对应接口 path 下的 list、create、update、delete
+type resource struct {
+	name      string
+	path      string
+	columns   []column
+	newCreate func() interface{} // 创建请求的 DTO
+	newUpdate func() interface{} // 更新请求的 DTO
+	// 更新 DTO 需要完整字段时为 true，先读取当前记录再合并修改的字段
+	merge bool
+	// 额外的操作，如诊断、实时状态
+	actions map[string]func(c *client, p *printer, args []string) error
+}
+
+// actionNames 返回支持的操作
+func (r *resource) actionNames() []string {
+	names := []string{"list", "create", "update", "delete"}
+	var extra []string
+	for name := range r.actions {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+func (r *resource) run(c *client, p *printer, args []string) error {
+	if len(args) == 0 {
+		return usageError("缺少操作，%s 支持: %s", r.name, strings.Join(r.actionNames(), ", "))
+	}
+	action, rest := args[0], args[1:]
+	switch action {
+	case "list":
+		return r.list(c, p, rest)
+	case "create":
+		return r.create(c, p, rest)
+	case "update":
+		return r.update(c, p, rest)
+	case "delete":
+		return r.delete(c, p, rest)
+	}
+	if fn, ok := r.actions[action]; ok {
+		return fn(c, p, rest)
+	}
+	return usageError("未知操作 %s，%s 支持: %s", action, r.name, strings.Join(r.actionNames(), ", "))
+}
+
+func (r *resource) list(c *client, p *printer, args []string) error {
+	fs := newFlagSet(r.name + " list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	rows, raw, err := c.list(r.path + "/list")
+	if err != nil {
+		return err
+	}
+	return p.table(r.columns, rows, raw)
+}
+
+// create 创建资源: create -f file | -d '{...}'
+func (r *resource) create(c *client, p *printer, args []string) error {
+	fs := newFlagSet(r.name + " create")
+	file := fs.String("f", "", "JSON 或 YAML 文件，- 表示标准输入")
+	data := fs.String("d", "", "JSON 或 YAML 内容")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fields, err := readInput(*file, *data)
+	if err != nil {
+		return err
+	}
+	req := r.newCreate()
+	if err := decodeFields(fields, req, true); err != nil {
+		return err
+	}
+	if err := checkRequired(req); err != nil {
+		return err
+	}
+	resp, err := c.call(r.path+"/create", req)
+	if err != nil {
+		return err
+	}
+	return p.result(resp)
+}
+
+// update 更新资源，只需提供修改的字段: update -id N -f file | -d '{...}'
+func (r *resource) update(c *client, p *printer, args []string) error {
+	fs := newFlagSet(r.name + " update")
+	id := fs.Uint("id", 0, "ID")
+	file := fs.String("f", "", "JSON 或 YAML 文件，- 表示标准输入")
+	data := fs.String("d", "", "JSON 或 YAML 内容")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return usageError("缺少 -id")
+	}
+	fields, err := readInput(*file, *data)
+	if err != nil {
+		return err
+	}
+	// 先按 DTO 严格校验修改的字段，避免拼错的字段被忽略
+	if err := decodeFields(fields, r.newUpdate(), true); err != nil {
+		return err
+	}
+
+	if r.merge {
+		current, err := r.find(c, *id)
+		if err != nil {
+			return err
+		}
+		for k, v := range fields {
+			current[k] = v
+		}
+		fields = current
+	}
+	fields["id"] = *id
+
+	req := r.newUpdate()
+	if err := decodeFields(fields, req, false); err != nil {
+		return err
+	}
+	if err := checkRequired(req); err != nil {
+		return err
+	}
+	resp, err := c.call(r.path+"/update", req)
+	if err != nil {
+		return err
+	}
+	return p.result(resp)
+}
+
+// delete 删除资源: delete -id N
+func (r *resource) delete(c *client, p *printer, args []string) error {
+	fs := newFlagSet(r.name + " delete")
+	id := fs.Uint("id", 0, "ID")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return usageError("缺少 -id")
+	}
+	resp, err := c.call(r.path+"/delete", map[string]uint{"id": *id})
+	if err != nil {
+		return err
+	}
+	return p.result(resp)
+}
+
+// find 从列表中查找记录
+func (r *resource) find(c *client, id uint) (map[string]interface{}, error) {
+	rows, _, err := c.list(r.path + "/list")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if v, ok := row["id"].(float64); ok && uint(v) == id {
+			return row, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %d 不存在", r.name, id)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("fluxctl "+name, flag.ContinueOnError)
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: exitUsage, err: err}
+	}
+	if fs.NArg() > 0 {
+		return usageError("多余的参数: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// readInput 读取 -f 文件或 -d 内容，JSON 是 YAML 的子集，统一按 YAML 解析
+func readInput(file, data string) (map[string]interface{}, error) {
+	var content []byte
+	switch {
+	case file != "" && data != "":
+		return nil, usageError("-f 和 -d 不能同时使用")
+	case file == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		content = b
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, &exitError{code: exitUsage, err: err}
+		}
+		content = b
+	case data != "":
+		content = []byte(data)
+	default:
+		return nil, usageError("需要通过 -f 或 -d 提供内容")
+	}
+
+	fields := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &fields); err != nil {
+		return nil, usageError("内容格式错误: %v", err)
+	}
+	return fields, nil
+}
+
+// decodeFields 将字段解码为 DTO，strict 时不允许 DTO 中没有的字段
+func decodeFields(fields map[string]interface{}, dst interface{}, strict bool) error {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return usageError("内容格式错误: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return usageError("字段错误: %v", err)
+	}
+	return nil
+}
+
+// checkRequired 按 DTO 的 binding:"required" 检查必填字段，与服务端校验一致
+func checkRequired(v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	var missing []string
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !strings.Contains(f.Tag.Get("binding"), "required") || !rv.Field(i).IsZero() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		return usageError("缺少必填字段: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flux-panel/dto"
+)
+
+// resources 支持的资源，表头使用英文以保证表格对齐
+var resources = []*resource{
+	{
+		name: "node",
+		path: "/api/v1/node",
+		columns: []column{
+			{"ID", field("id")},
+			{"NAME", field("name")},
+			{"SERVER IP", field("serverIp")},
+			{"ENTRY IP", field("ip")},
+			{"PORTS", portRange("portSta", "portEnd")},
+			{"VERSION", field("version")},
+			{"STATUS", enum("status", map[int]string{0: "offline", 1: "online"})},
+		},
+		newCreate: func() interface{} { return &dto.NodeDto{} },
+		newUpdate: func() interface{} { return &dto.NodeUpdateDto{} },
+		actions: map[string]func(*client, *printer, []string) error{
+			"watch": watchNodes,
+		},
+	},
+	{
+		name: "tunnel",
+		path: "/api/v1/tunnel",
+		columns: []column{
+			{"ID", field("id")},
+			{"NAME", field("name")},
+			{"TYPE", enum("type", map[int]string{1: "port", 2: "tunnel"})},
+			{"IN NODE", field("inNodeId")},
+			{"OUT NODE", field("outNodeId")},
+			{"PROTOCOL", field("protocol")},
+			{"RATIO", field("trafficRatio")},
+			{"STATUS", enum("status", map[int]string{0: "disabled", 1: "enabled"})},
+		},
+		newCreate: func() interface{} { return &dto.TunnelDto{} },
+		newUpdate: func() interface{} { return &dto.TunnelUpdateDto{} },
+		actions: map[string]func(*client, *printer, []string) error{
+			"diagnose": diagnoseTunnel,
+		},
+	},
+	{
+		name: "forward",
+		path: "/api/v1/forward",
+		columns: []column{
+			{"ID", field("id")},
+			{"NAME", field("name")},
+			{"USER", field("userName")},
+			{"TUNNEL", field("tunnelName")},
+			{"ENTRY", entry},
+			{"REMOTE", field("remoteAddr")},
+			{"IN FLOW", field("inFlow")},
+			{"OUT FLOW", field("outFlow")},
+			{"STATUS", enum("status", map[int]string{-1: "error", 0: "paused", 1: "running"})},
+		},
+		newCreate: func() interface{} { return &dto.ForwardDto{} },
+		newUpdate: func() interface{} { return &dto.ForwardUpdateDto{} },
+		merge:     true,
+		actions: map[string]func(*client, *printer, []string) error{
+			"diagnose": diagnoseForward,
+		},
+	},
+	{
+		name: "user",
+		path: "/api/v1/user",
+		columns: []column{
+			{"ID", field("id")},
+			{"USER", field("user")},
+			{"FLOW", field("flow")},
+			{"IN FLOW", field("inFlow")},
+			{"OUT FLOW", field("outFlow")},
+			{"NUM", field("num")},
+			{"EXPIRE", timestamp("expTime")},
+			{"STATUS", enum("status", map[int]string{0: "disabled", 1: "enabled"})},
+		},
+		newCreate: func() interface{} { return &dto.UserDto{} },
+		newUpdate: func() interface{} { return &dto.UserUpdateDto{} },
+		merge:     true,
+	},
+	{
+		name: "speed-limit",
+		path: "/api/v1/speed-limit",
+		columns: []column{
+			{"ID", field("id")},
+			{"NAME", field("name")},
+			{"SPEED", field("speed")},
+			{"IN SPEED", field("inSpeed")},
+			{"OUT SPEED", field("outSpeed")},
+			{"TUNNEL", field("tunnelName")},
+		},
+		newCreate: func() interface{} { return &dto.SpeedLimitDto{} },
+		newUpdate: func() interface{} { return &dto.SpeedLimitUpdateDto{} },
+		merge:     true,
+	},
+}
+
+// portRange 输出端口范围
+func portRange(start, end string) func(map[string]interface{}) string {
+	return func(row map[string]interface{}) string {
+		return text(row[start]) + "-" + text(row[end])
+	}
+}
+
+// entry 输出转发的入口地址，端口段转发输出端口范围
+func entry(row map[string]interface{}) string {
+	port := text(row["inPort"])
+	if count, ok := row["portCount"].(float64); ok && count > 1 {
+		if start, ok := row["inPort"].(float64); ok {
+			port = text(start) + "-" + text(start+count-1)
+		}
+	}
+	return text(row["inIp"]) + ":" + port
+}
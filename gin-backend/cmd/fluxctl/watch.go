@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// nodeEvent 面板推送给用户连接的节点消息
+type nodeEvent struct {
+	ID   uint            `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// watchNodes 实时输出节点的上下线与系统信息: watch [-id N] [-status]，Ctrl+C 结束
+func watchNodes(c *client, p *printer, args []string) error {
+	fs := newFlagSet("node watch")
+	id := fs.Uint("id", 0, "只输出指定节点")
+	statusOnly := fs.Bool("status", false, "只输出上下线事件")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	nodes, _, err := c.list("/api/v1/node/list")
+	if err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		if v, ok := node["id"].(float64); ok {
+			names[uint(v)] = text(node["name"])
+		}
+	}
+
+	// 用户 token 与节点密钥使用同一个连接入口，通过请求头携带避免出现在 URL 中
+	url := "ws" + strings.TrimPrefix(c.server, "http") + "/system-info"
+	header := http.Header{"X-Node-Secret": []string{c.token}}
+	conn, res, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusUnauthorized {
+			return &exitError{code: exitAuth, err: fmt.Errorf("连接失败: token 无效")}
+		}
+		return &exitError{code: exitFailed, err: fmt.Errorf("连接失败: %v", err)}
+	}
+	defer conn.Close()
+
+	var interrupted atomic.Bool
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		if _, ok := <-sig; ok {
+			interrupted.Store(true)
+			conn.Close()
+		}
+	}()
+
+	// 先输出当前状态，之后输出变化
+	for _, node := range nodes {
+		nodeID, _ := node["id"].(float64)
+		if *id != 0 && uint(nodeID) != *id {
+			continue
+		}
+		status, _ := json.Marshal(node["status"])
+		if err := printEvent(p, names, nodeEvent{ID: uint(nodeID), Type: "status", Data: status}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if interrupted.Load() {
+				return nil
+			}
+			return &exitError{code: exitFailed, err: fmt.Errorf("连接已断开: %v", err)}
+		}
+		var event nodeEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			continue
+		}
+		if (*id != 0 && event.ID != *id) || (*statusOnly && event.Type != "status") {
+			continue
+		}
+		if err := printEvent(p, names, event); err != nil {
+			return err
+		}
+	}
+}
+
+// printEvent 表格格式每个事件输出一行，json 和 yaml 每个事件输出一个文档
+func printEvent(p *printer, names map[uint]string, event nodeEvent) error {
+	now := time.Now()
+	name := names[event.ID]
+	if name == "" {
+		name = fmt.Sprintf("#%d", event.ID)
+	}
+
+	if p.format != formatTable {
+		var data interface{}
+		json.Unmarshal(event.Data, &data)
+		doc := map[string]interface{}{
+			"time":   now.Format(time.RFC3339),
+			"nodeId": event.ID,
+			"node":   name,
+			"type":   event.Type,
+			"data":   data,
+		}
+		if p.format == formatJSON {
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(p.w, string(b))
+			return err
+		}
+		fmt.Fprintln(p.w, "---")
+		return p.value(doc)
+	}
+
+	var detail string
+	switch event.Type {
+	case "status":
+		detail = "offline"
+		if string(event.Data) == "1" {
+			detail = "online"
+		}
+	case "info":
+		var info struct {
+			CPUUsage    float64 `json:"cpu_usage"`
+			MemoryUsage float64 `json:"memory_usage"`
+			Uptime      uint64  `json:"uptime"`
+		}
+		if err := json.Unmarshal(event.Data, &info); err != nil {
+			return nil
+		}
+		detail = fmt.Sprintf("cpu %.1f%%  mem %.1f%%  uptime %s",
+			info.CPUUsage, info.MemoryUsage, time.Duration(info.Uptime)*time.Second)
+	default:
+		// 遥测等其他消息只在 json 和 yaml 格式中输出
+		return nil
+	}
+	_, err := fmt.Fprintf(p.w, "%s  %-20s  %-6s  %s\n", now.Format("2006-01-02 15:04:05"), name, event.Type, detail)
+	return err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"flux-panel/cluster"
+	"flux-panel/config"
+	"flux-panel/models"
+	"flux-panel/service"
+	"flux-panel/websocket"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// cliActor 命令行操作写入审计日志时使用的操作者
+var cliActor = service.Actor{Name: "cli"}
+
+// runCommand 执行命令行子命令，未知子命令返回 false
+func runCommand(args []string) bool {
+	var run func([]string) error
+	switch args[0] {
+	case "backup":
+		run = backupCommand
+	case "restore":
+		run = restoreCommand
+	default:
+		return false
+	}
+
+	if err := initCommand(); err != nil {
+		log.Fatal(err)
+	}
+	defer models.CloseDB()
+	defer cluster.Close()
+
+	if err := run(args[1:]); err != nil {
+		log.Fatalf("%s failed: %v", args[0], err)
+	}
+	return true
+}
+
+// initCommand 初始化子命令需要的配置、实例间总线与数据库
+func initCommand() error {
+	if err := config.InitConfig(); err != nil {
+		return fmt.Errorf("failed to initialize config: %v", err)
+	}
+	// 恢复后下发的节点命令经总线转发到节点所连接的实例，离线节点的命令进入队列
+	if err := cluster.Init(config.AppConfig.Cluster); err != nil {
+		return fmt.Errorf("failed to initialize cluster bus: %v", err)
+	}
+	if err := websocket.StartCluster(); err != nil {
+		return fmt.Errorf("failed to start cluster routing: %v", err)
+	}
+	if err := models.InitDB(); err != nil {
+		return fmt.Errorf("failed to initialize database: %v", err)
+	}
+	if err := models.AutoMigrate(); err != nil {
+		return fmt.Errorf("failed to auto migrate database: %v", err)
+	}
+	return nil
+}
+
+// backupCommand 导出面板备份: backup [-o file] [-flow]
+func backupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "", "备份文件路径，默认 flux-panel-backup-<时间>.json.gz，- 表示标准输出")
+	includeFlow := fs.Bool("flow", false, "包含流量统计历史")
+	fs.Parse(args)
+
+	archive, err := service.NewBackupService(models.DB).Export(cliActor, *includeFlow)
+	if err != nil {
+		return err
+	}
+
+	path := *output
+	if path == "" {
+		path = fmt.Sprintf("flux-panel-backup-%s.json.gz", time.Now().Format("20060102150405"))
+	}
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		// 备份包含密码哈希和节点密钥，仅允许当前用户读写
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := service.WriteBackup(w, archive); err != nil {
+		return err
+	}
+	if path != "-" {
+		log.Printf("Backup written to %s", path)
+	}
+	return nil
+}
+
+// restoreCommand 恢复面板备份: restore -i file
+func restoreCommand(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := fs.String("i", "", "备份文件路径，- 表示标准输入")
+	fs.Parse(args)
+	if *input == "" {
+		fs.Usage()
+		return fmt.Errorf("missing backup file")
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	archive, err := service.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	result, err := service.NewBackupService(models.DB).Restore(cliActor, archive)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restore finished, created: %v, merged: %v", result.Created, result.Merged)
+	for _, e := range result.PushErrors {
+		log.Printf("Push failed: %s", e)
+	}
+	return nil
+}
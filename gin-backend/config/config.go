@@ -14,6 +14,8 @@ type Config struct {
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Captcha  CaptchaConfig  `mapstructure:"captcha"`
 	Log      LogConfig      `mapstructure:"log"`
+	Security SecurityConfig `mapstructure:"security"`
+	Cluster  ClusterConfig  `mapstructure:"cluster"`
 }
 
 // ServerConfig 服务器配置
@@ -22,6 +24,8 @@ type ServerConfig struct {
 	Mode           string `mapstructure:"mode"`
 	MaxConnections int    `mapstructure:"max_connections"`
 	ShutdownTimeout int   `mapstructure:"shutdown_timeout"`
+	TLSCert        string `mapstructure:"tls_cert"` // TLS 证书文件路径，与 tls_key 同时配置时启用 https/wss
+	TLSKey         string `mapstructure:"tls_key"`  // TLS 私钥文件路径
 }
 
 // DatabaseConfig 数据库配置
@@ -54,6 +58,21 @@ type LogConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+// SecurityConfig 节点通信安全配置
+type SecurityConfig struct {
+	RequireSignedEnvelope bool `mapstructure:"require_signed_envelope"` // 拒绝所有节点的旧格式消息
+	ReplayWindow          int  `mapstructure:"replay_window"`           // 消息时间戳允许的偏差（秒）
+}
+
+// ClusterConfig 多实例部署配置
+type ClusterConfig struct {
+	Bus           string `mapstructure:"bus"`         // 实例间总线: memory（默认，单实例）或 redis
+	InstanceID    string `mapstructure:"instance_id"` // 实例标识，为空时启动时随机生成
+	RedisAddr     string `mapstructure:"redis_addr"`
+	RedisPassword string `mapstructure:"redis_password"`
+	RedisDB       int    `mapstructure:"redis_db"`
+}
+
 var AppConfig *Config
 
 // InitConfig 初始化配置
@@ -109,6 +128,11 @@ func setDefaults() {
 
 	viper.SetDefault("log.dir", "./logs")
 	viper.SetDefault("log.level", "info")
+
+	viper.SetDefault("security.require_signed_envelope", false)
+	viper.SetDefault("security.replay_window", 300)
+
+	viper.SetDefault("cluster.bus", "memory")
 }
 
 // overrideFromEnv 从环境变量覆盖配置
@@ -131,4 +155,22 @@ func overrideFromEnv() {
 	if logDir := os.Getenv("LOG_DIR"); logDir != "" {
 		viper.Set("log.dir", logDir)
 	}
+	if cert := os.Getenv("TLS_CERT"); cert != "" {
+		viper.Set("server.tls_cert", cert)
+	}
+	if key := os.Getenv("TLS_KEY"); key != "" {
+		viper.Set("server.tls_key", key)
+	}
+	if bus := os.Getenv("CLUSTER_BUS"); bus != "" {
+		viper.Set("cluster.bus", bus)
+	}
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		viper.Set("cluster.instance_id", id)
+	}
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		viper.Set("cluster.redis_addr", addr)
+	}
+	if password := os.Getenv("REDIS_PASSWORD"); password != "" {
+		viper.Set("cluster.redis_password", password)
+	}
 }
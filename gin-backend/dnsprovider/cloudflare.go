@@ -0,0 +1,145 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare 通过 Cloudflare API 管理解析记录
+// 配置项: apiToken (需要 Zone.DNS 编辑权限), zoneId, baseUrl (可选，默认官方 API 地址)
+type Cloudflare struct {
+	token   string
+	zoneID  string
+	baseURL string
+	client  *http.Client
+}
+
+// NewCloudflare 创建 Cloudflare 服务商
+func NewCloudflare(cfg map[string]string) (*Cloudflare, error) {
+	if err := requireKeys(cfg, "apiToken", "zoneId"); err != nil {
+		return nil, err
+	}
+	baseURL := strings.TrimRight(cfg["baseUrl"], "/")
+	if baseURL == "" {
+		baseURL = cloudflareAPI
+	}
+	return &Cloudflare{
+		token:   cfg["apiToken"],
+		zoneID:  cfg["zoneId"],
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// SetRecords 保留内容一致的记录，删除多余的记录并创建缺少的记录
+func (c *Cloudflare) SetRecords(ctx context.Context, name string, ips []string, ttl int) error {
+	var existing []cloudflareRecord
+	query := url.Values{"name": {name}, "per_page": {"100"}}
+	if err := c.do(ctx, http.MethodGet, "/dns_records?"+query.Encode(), nil, &existing); err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		wanted[ip] = true
+	}
+
+	for _, record := range existing {
+		if record.Type != "A" && record.Type != "AAAA" {
+			continue
+		}
+		if wanted[record.Content] {
+			delete(wanted, record.Content)
+			continue
+		}
+		if err := c.do(ctx, http.MethodDelete, "/dns_records/"+record.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, ip := range ips {
+		if !wanted[ip] {
+			continue
+		}
+		record := cloudflareRecord{Type: recordType(ip), Name: name, Content: ip, TTL: cloudflareTTL(ttl)}
+		if err := c.do(ctx, http.MethodPost, "/dns_records", record, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloudflareTTL Cloudflare 的 TTL 为 1 (自动) 或 60-86400 秒
+func cloudflareTTL(ttl int) int {
+	if ttl <= 0 {
+		return 1
+	}
+	return min(max(ttl, 60), 86400)
+}
+
+func (c *Cloudflare) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/zones/"+url.PathEscape(c.zoneID)+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Cloudflare 失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cfResp); err != nil {
+		return fmt.Errorf("Cloudflare 响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if !cfResp.Success {
+		messages := make([]string, 0, len(cfResp.Errors))
+		for _, e := range cfResp.Errors {
+			messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("Cloudflare 返回错误 (HTTP %d): %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+	if result != nil && len(cfResp.Result) > 0 {
+		return json.Unmarshal(cfResp.Result, result)
+	}
+	return nil
+}
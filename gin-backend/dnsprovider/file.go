@@ -0,0 +1,76 @@
+package dnsprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileMu 串行化对记录文件的读写
+var fileMu sync.Mutex
+
+// File 将解析记录写入本地 JSON 文件 ({"域名": ["IP", ...]})，用于测试或由外部程序同步到 DNS
+// 配置项: path
+type File struct {
+	path string
+}
+
+// NewFile 创建文件服务商
+func NewFile(cfg map[string]string) (*File, error) {
+	if err := requireKeys(cfg, "path"); err != nil {
+		return nil, err
+	}
+	return &File{path: cfg["path"]}, nil
+}
+
+// SetRecords 写入域名的记录，ips 为空时删除该域名
+func (f *File) SetRecords(ctx context.Context, name string, ips []string, ttl int) error {
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	records, err := f.Records()
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		delete(records, name)
+	} else {
+		records[name] = ips
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(f.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// Records 读取文件中的全部记录，文件不存在时返回空记录
+func (f *File) Records() (map[string][]string, error) {
+	records := make(map[string][]string)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
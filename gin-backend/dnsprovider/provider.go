@@ -0,0 +1,80 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Provider 管理域名解析记录的 DNS 服务商
+type Provider interface {
+	// SetRecords 将域名的 A/AAAA 记录替换为 ips，ips 为空时删除该域名的全部 A/AAAA 记录
+	SetRecords(ctx context.Context, name string, ips []string, ttl int) error
+}
+
+// 服务商类型
+const (
+	TypeCloudflare = "cloudflare"
+	TypeRFC2136    = "rfc2136"
+	TypeFile       = "file"
+)
+
+// Types 支持的服务商类型
+var Types = []string{TypeCloudflare, TypeRFC2136, TypeFile}
+
+// SecretKeys 配置中的敏感字段，查询时不返回明文
+var SecretKeys = []string{"apiToken", "tsigSecret"}
+
+// New 按类型和配置创建服务商
+func New(typ string, cfg map[string]string) (Provider, error) {
+	switch typ {
+	case TypeCloudflare:
+		return NewCloudflare(cfg)
+	case TypeRFC2136:
+		return NewRFC2136(cfg)
+	case TypeFile:
+		return NewFile(cfg)
+	default:
+		return nil, fmt.Errorf("不支持的 DNS 服务商类型: %s", typ)
+	}
+}
+
+// NormalizeIPs 过滤无效地址，去重并排序，返回可写入解析记录的IP
+func NormalizeIPs(ips []string) []string {
+	seen := make(map[string]bool, len(ips))
+	result := make([]string, 0, len(ips))
+	for _, s := range ips {
+		ip := net.ParseIP(strings.Trim(strings.TrimSpace(s), "[]"))
+		if ip == nil {
+			continue
+		}
+		key := ip.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// recordType 返回IP对应的记录类型
+func recordType(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "AAAA"
+	}
+	return "A"
+}
+
+// requireKeys 校验配置中的必填项
+func requireKeys(cfg map[string]string, keys ...string) error {
+	for _, key := range keys {
+		if strings.TrimSpace(cfg[key]) == "" {
+			return fmt.Errorf("DNS 服务商配置缺少 %s", key)
+		}
+	}
+	return nil
+}
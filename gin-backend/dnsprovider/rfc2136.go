@@ -0,0 +1,98 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RFC2136 通过 DNS 动态更新 (RFC 2136) 管理解析记录，可选 TSIG 签名
+// 配置项: server (主 DNS 服务器地址，默认端口 53), zone, tsigKey, tsigSecret (base64),
+// tsigAlgorithm (默认 hmac-sha256), net (udp 或 tcp，默认 udp)
+type RFC2136 struct {
+	server    string
+	zone      string
+	tsigKey   string
+	tsigAlg   string
+	tsigValue string
+	net       string
+}
+
+// NewRFC2136 创建 RFC 2136 服务商
+func NewRFC2136(cfg map[string]string) (*RFC2136, error) {
+	if err := requireKeys(cfg, "server", "zone"); err != nil {
+		return nil, err
+	}
+	server := cfg["server"]
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	if (cfg["tsigKey"] == "") != (cfg["tsigSecret"] == "") {
+		return nil, fmt.Errorf("TSIG 密钥名称与密钥需同时配置")
+	}
+	alg := cfg["tsigAlgorithm"]
+	if alg == "" {
+		alg = dns.HmacSHA256
+	}
+	return &RFC2136{
+		server:    server,
+		zone:      dns.Fqdn(cfg["zone"]),
+		tsigKey:   cfg["tsigKey"],
+		tsigAlg:   dns.Fqdn(alg),
+		tsigValue: cfg["tsigSecret"],
+		net:       cfg["net"],
+	}, nil
+}
+
+// SetRecords 在同一个更新请求中删除域名原有的 A/AAAA 记录并写入新记录
+func (r *RFC2136) SetRecords(ctx context.Context, name string, ips []string, ttl int) error {
+	fqdn := dns.Fqdn(name)
+	if !dns.IsSubDomain(r.zone, fqdn) {
+		return fmt.Errorf("域名 %s 不在区域 %s 内", name, r.zone)
+	}
+	if ttl <= 0 {
+		ttl = 60
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(r.zone)
+	m.RemoveRRset([]dns.RR{
+		&dns.A{Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeA, Class: dns.ClassINET}},
+		&dns.AAAA{Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeAAAA, Class: dns.ClassINET}},
+	})
+
+	var rrs []dns.RR
+	for _, ip := range ips {
+		hdr := dns.RR_Header{Name: fqdn, Class: dns.ClassINET, Ttl: uint32(ttl)}
+		if recordType(ip) == "AAAA" {
+			hdr.Rrtype = dns.TypeAAAA
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(ip)})
+		} else {
+			hdr.Rrtype = dns.TypeA
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: net.ParseIP(ip).To4()})
+		}
+	}
+	if len(rrs) > 0 {
+		m.Insert(rrs)
+	}
+
+	client := &dns.Client{Net: r.net, Timeout: 10 * time.Second}
+	if r.tsigKey != "" {
+		key := dns.Fqdn(r.tsigKey)
+		client.TsigSecret = map[string]string{key: r.tsigValue}
+		m.SetTsig(key, r.tsigAlg, 300, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, m, r.server)
+	if err != nil {
+		return fmt.Errorf("DNS 动态更新失败: %v", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("DNS 动态更新被拒绝: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
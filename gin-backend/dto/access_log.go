@@ -0,0 +1,21 @@
+package dto
+
+import "flux-panel/models"
+
+// AccessLogQueryDto 连接日志查询请求
+type AccessLogQueryDto struct {
+	ForwardID uint   `json:"forwardId"`
+	ClientIP  string `json:"clientIp"`
+	Target    string `json:"target"`
+	StartTime int64  `json:"startTime"` // 起始时间戳（毫秒）
+	EndTime   int64  `json:"endTime"`   // 结束时间戳（毫秒）
+	OnlyError bool   `json:"onlyError"` // 仅查询失败的连接
+	Page      int    `json:"page"`
+	Size      int    `json:"size"`
+}
+
+// AccessLogPageDto 连接日志分页结果
+type AccessLogPageDto struct {
+	List  []models.AccessLog `json:"list"`
+	Total int64              `json:"total"`
+}
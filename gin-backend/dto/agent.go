@@ -0,0 +1,43 @@
+package dto
+
+// AgentReleaseDto 登记节点程序发布包
+type AgentReleaseDto struct {
+	Version   string `json:"version" binding:"required"`
+	OS        string `json:"os" binding:"required"`
+	Arch      string `json:"arch" binding:"required"`
+	URL       string `json:"url" binding:"required"`
+	SHA256    string `json:"sha256" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+	Remark    string `json:"remark"`
+}
+
+// AgentUpgradeDto 升级单个节点
+type AgentUpgradeDto struct {
+	NodeID  uint   `json:"nodeId" binding:"required"`
+	Version string `json:"version" binding:"required"`
+	Timeout int    `json:"timeout"`
+}
+
+// AgentRolloutDto 创建分批升级任务，NodeIDs 为空时升级所有不在目标版本的节点
+type AgentRolloutDto struct {
+	Version   string `json:"version" binding:"required"`
+	NodeIDs   []uint `json:"nodeIds"`
+	BatchSize int    `json:"batchSize"`
+	Timeout   int    `json:"timeout"`
+}
+
+// AgentFleetNodeDto 节点版本信息
+type AgentFleetNodeDto struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Online  bool   `json:"online"`
+}
+
+// AgentFleetDto 节点版本总览
+type AgentFleetDto struct {
+	Nodes    []AgentFleetNodeDto `json:"nodes"`
+	Versions map[string]int      `json:"versions"` // 各版本的节点数
+}
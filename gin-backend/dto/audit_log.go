@@ -0,0 +1,22 @@
+package dto
+
+import "flux-panel/models"
+
+// AuditLogQueryDto 审计日志查询请求
+type AuditLogQueryDto struct {
+	ActorID    *int   `json:"actorId"` // 0 表示系统操作
+	Action     string `json:"action"`  // 支持前缀匹配，如 forward.
+	TargetType string `json:"targetType"`
+	TargetID   uint   `json:"targetId"`
+	Success    *bool  `json:"success"`
+	StartTime  int64  `json:"startTime"` // 起始时间戳（毫秒）
+	EndTime    int64  `json:"endTime"`   // 结束时间戳（毫秒）
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+}
+
+// AuditLogPageDto 审计日志分页结果
+type AuditLogPageDto struct {
+	List  []models.AuditLog `json:"list"`
+	Total int64             `json:"total"`
+}
@@ -0,0 +1,46 @@
+package dto
+
+import "flux-panel/models"
+
+// BackupVersion 备份文件格式版本，格式变化不兼容时递增
+const BackupVersion = 1
+
+// BackupArchive 面板备份，恢复时按备份中的ID重建各数据之间的关联
+type BackupArchive struct {
+	Version         int                     `json:"version"`
+	CreatedTime     int64                   `json:"createdTime"`
+	Users           []BackupUser            `json:"users"`
+	Nodes           []models.Node           `json:"nodes"`
+	NodeGroups      []models.NodeGroup      `json:"nodeGroups"` // nodeIds 为成员节点
+	DNSProviders    []BackupDNSProvider     `json:"dnsProviders"`
+	Tunnels         []models.Tunnel         `json:"tunnels"`
+	UserTunnels     []models.UserTunnel     `json:"userTunnels"`
+	Forwards        []models.Forward        `json:"forwards"`
+	SpeedLimits     []models.SpeedLimit     `json:"speedLimits"`
+	Configs         []models.ViteConfig     `json:"configs"`
+	StatisticsFlows []models.StatisticsFlow `json:"statisticsFlows,omitempty"` // 仅在备份时选择包含流量历史
+}
+
+// BackupUser 备份中的用户，包含密码哈希
+type BackupUser struct {
+	models.User
+	Pwd string `json:"pwd"`
+}
+
+// BackupDNSProvider 备份中的 DNS 服务商，包含完整配置
+type BackupDNSProvider struct {
+	models.DNSProvider
+	RawConfig string `json:"rawConfig"`
+}
+
+// BackupExportDto 备份请求
+type BackupExportDto struct {
+	IncludeFlow bool `json:"includeFlow"` // 是否包含流量统计历史
+}
+
+// RestoreResultDto 恢复结果
+type RestoreResultDto struct {
+	Created    map[string]int `json:"created"`    // 各类数据新建的条数
+	Merged     map[string]int `json:"merged"`     // 与目标库中同名数据合并的条数
+	PushErrors []string       `json:"pushErrors"` // 下发到节点失败的隧道
+}
@@ -0,0 +1,12 @@
+package dto
+
+// BandwidthTestDto 发起隧道带宽测试
+type BandwidthTestDto struct {
+	TunnelID  uint   `json:"tunnelId" binding:"required"`
+	Mode      string `json:"mode"`      // tcp(默认), udp
+	Direction string `json:"direction"` // upload(默认): 入口->出口, download: 出口->入口，仅 tcp 支持
+	Duration  int    `json:"duration"`  // 秒，默认 10，最长 20
+	Bandwidth int    `json:"bandwidth"` // UDP 发送速率(Mbps)，默认 10
+	InNodeID  uint   `json:"inNodeId"`  // 入口为节点组时测试的成员节点，默认第一个成员
+	OutNodeID uint   `json:"outNodeId"` // 出口为节点组时测试的成员节点，默认第一个成员
+}
@@ -0,0 +1,62 @@
+package dto
+
+// 批量导入导出的文件格式
+const (
+	BulkFormatCSV  = "csv"
+	BulkFormatJSON = "json"
+)
+
+// BulkImportDto 批量导入请求，content 为 CSV 文本或 JSON 数组，与导出的文件格式相同
+type BulkImportDto struct {
+	Format  string `json:"format"` // csv 或 json，默认 csv
+	Content string `json:"content" binding:"required"`
+	DryRun  bool   `json:"dryRun"` // 只校验并返回报告，不创建
+}
+
+// BulkExportDto 批量导出请求
+type BulkExportDto struct {
+	Format string `json:"format"` // csv 或 json，默认 csv
+}
+
+// BulkForwardRow 批量导入导出的转发
+type BulkForwardRow struct {
+	User       string `json:"user"`   // 所属用户名，为空时属于当前用户
+	Tunnel     string `json:"tunnel"` // 隧道名称
+	Name       string `json:"name"`
+	RemoteAddr string `json:"remoteAddr"`
+	InPort     int    `json:"inPort"`    // 入口端口，0 表示自动分配
+	InPortEnd  int    `json:"inPortEnd"` // 端口段结束端口，0 表示单端口转发
+	Strategy   string `json:"strategy"`
+}
+
+// BulkUserRow 批量导入导出的用户，导出时不包含密码，导入时为空则生成初始密码
+type BulkUserRow struct {
+	User          string   `json:"user"`
+	Pwd           string   `json:"pwd"`
+	Flow          int64    `json:"flow"`
+	Num           int      `json:"num"`
+	ExpTime       int64    `json:"expTime"`
+	FlowResetTime int64    `json:"flowResetTime"`
+	Status        *int     `json:"status"`  // 为空时默认启用
+	Tunnels       []string `json:"tunnels"` // 授权的隧道名称，流量、到期时间等沿用用户的配置
+}
+
+// BulkRowResultDto 单行的校验结果
+type BulkRowResultDto struct {
+	Row    int      `json:"row"`          // 数据行序号，从 1 开始，不含表头
+	ID     uint     `json:"id,omitempty"` // 创建后的ID
+	Name   string   `json:"name"`
+	InPort int      `json:"inPort,omitempty"` // 转发分配的入口端口
+	Pwd    string   `json:"pwd,omitempty"`    // 未提供密码的用户生成的初始密码
+	Errors []string `json:"errors"`
+}
+
+// BulkImportResultDto 批量导入结果，存在校验失败的行时不创建任何数据
+type BulkImportResultDto struct {
+	DryRun     bool               `json:"dryRun"`
+	Total      int                `json:"total"`
+	Invalid    int                `json:"invalid"`
+	Created    int                `json:"created"`
+	Rows       []BulkRowResultDto `json:"rows"`
+	PushErrors []string           `json:"pushErrors"`
+}
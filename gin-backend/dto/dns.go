@@ -0,0 +1,17 @@
+package dto
+
+// DNSProviderDto 创建 DNS 服务商请求
+type DNSProviderDto struct {
+	Name   string            `json:"name" binding:"required"`
+	Type   string            `json:"type" binding:"required"` // cloudflare, rfc2136, file
+	Config map[string]string `json:"config"`
+	TTL    int               `json:"ttl"` // 0 表示默认 60 秒
+}
+
+// DNSProviderUpdateDto 更新 DNS 服务商请求，配置中的密钥为脱敏值时保留原值
+type DNSProviderUpdateDto struct {
+	ID     uint              `json:"id" binding:"required"`
+	Name   *string           `json:"name"`
+	Config map[string]string `json:"config"`
+	TTL    *int              `json:"ttl"`
+}
@@ -7,20 +7,44 @@ type FlowDto struct {
 	D int64  `json:"d"` // 下载流量
 }
 
+// LimitHitDto 连接限制命中上报数据
+type LimitHitDto struct {
+	N string `json:"n"` // 限流器名称 (与服务名称一致)
+	C int64  `json:"c"` // 并发连接数限制命中次数
+	R int64  `json:"r"` // 新建连接速率限制命中次数
+}
+
 // GostConfigDto Gost配置数据
 type GostConfigDto struct {
-	Limiters []ConfigItem `json:"limiters"`
-	Chains   []ConfigItem `json:"chains"`
-	Services []ConfigItem `json:"services"`
+	Limiters  []ConfigItem `json:"limiters"`
+	CLimiters []ConfigItem `json:"climiters"`
+	RLimiters []ConfigItem `json:"rlimiters"`
+	Resolvers []ConfigItem `json:"resolvers"`
+	Chains    []ConfigItem `json:"chains"`
+	Services  []ConfigItem `json:"services"`
 }
 
 // ConfigItem 配置项
 type ConfigItem struct {
-	Name string `json:"name"`
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Paused 服务是否被标记为暂停
+func (c ConfigItem) Paused() bool {
+	paused, _ := c.Metadata["paused"].(bool)
+	return paused
 }
 
-// EncryptedMessage 加密消息
-type EncryptedMessage struct {
-	Encrypted bool   `json:"encrypted"`
-	Data      string `json:"data"`
+// AccessLogItemDto 连接日志上报项（压缩格式）
+type AccessLogItemDto struct {
+	N string `json:"n"` // 服务名
+	W string `json:"w"` // 网络类型
+	C string `json:"c"` // 客户端IP
+	H string `json:"h"` // 目标地址
+	I int64  `json:"i"` // 入站字节数
+	O int64  `json:"o"` // 出站字节数
+	E string `json:"e"` // 错误信息
+	S int64  `json:"s"` // 开始时间（毫秒）
+	T int64  `json:"t"` // 结束时间（毫秒）
 }
@@ -4,10 +4,14 @@ package dto
 type ForwardDto struct {
 	Name          string `json:"name" binding:"required"`
 	TunnelID      int    `json:"tunnelId" binding:"required"`
+	InNodeID      uint   `json:"inNodeId"` // 隧道入口为节点组时部署的成员节点，为空表示部署到所有成员
 	RemoteAddr    string `json:"remoteAddr" binding:"required"`
 	Strategy      string `json:"strategy"`
+	IPPreference  string `json:"ipPreference"` // ipv4, ipv6，为空表示默认
 	InPort        *int   `json:"inPort"`
+	InPortEnd     *int   `json:"inPortEnd"` // 端口段结束端口，为空表示单端口转发
 	InterfaceName string `json:"interfaceName"`
+	AccessLog     int    `json:"accessLog"` // 是否记录连接日志: 0 关闭, 1 开启
 }
 
 // ForwardUpdateDto 更新转发请求
@@ -15,10 +19,14 @@ type ForwardUpdateDto struct {
 	ID            uint   `json:"id" binding:"required"`
 	Name          string `json:"name" binding:"required"`
 	TunnelID      int    `json:"tunnelId" binding:"required"`
+	InNodeID      *uint  `json:"inNodeId"`
 	RemoteAddr    string `json:"remoteAddr" binding:"required"`
 	Strategy      string `json:"strategy"`
+	IPPreference  string `json:"ipPreference"`
 	InPort        *int   `json:"inPort"`
+	InPortEnd     *int   `json:"inPortEnd"`
 	InterfaceName string `json:"interfaceName"`
+	AccessLog     int    `json:"accessLog"` // 是否记录连接日志: 0 关闭, 1 开启
 }
 
 // ForwardOrderItem 转发排序项
@@ -28,3 +28,21 @@ type NodeUpdateDto struct {
 	TLS      *int    `json:"tls"`
 	Socks    *int    `json:"socks"`
 }
+
+// NodeCommandQueryDto 查询节点命令队列
+type NodeCommandQueryDto struct {
+	NodeID uint `json:"nodeId" binding:"required"`
+	State  *int `json:"state"` // 0 待下发, 1 已生效, 2 下发失败, 3 已被取代，为空时不限
+	Limit  int  `json:"limit"` // 默认 100
+}
+
+// NodeSecretRotateDto 轮换节点密钥
+type NodeSecretRotateDto struct {
+	ID    uint `json:"id" binding:"required"`
+	Grace int  `json:"grace"` // 旧密钥的宽限期(秒)，默认 3600，范围 60-604800
+}
+
+// NodeSecretRotationQueryDto 查询密钥轮换记录
+type NodeSecretRotationQueryDto struct {
+	NodeID uint `json:"nodeId"` // 为 0 时返回全部节点的记录
+}
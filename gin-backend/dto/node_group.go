@@ -0,0 +1,16 @@
+package dto
+
+// NodeGroupDto 创建节点组请求
+type NodeGroupDto struct {
+	Name    string   `json:"name" binding:"required"`
+	Tags    []string `json:"tags"`
+	NodeIDs []uint   `json:"nodeIds"`
+}
+
+// NodeGroupUpdateDto 更新节点组请求，NodeIDs 不为空时替换全部成员
+type NodeGroupUpdateDto struct {
+	ID      uint     `json:"id" binding:"required"`
+	Name    *string  `json:"name"`
+	Tags    []string `json:"tags"`
+	NodeIDs *[]uint  `json:"nodeIds"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+// NodeLogQueryDto 查看节点日志
+type NodeLogQueryDto struct {
+	NodeID   uint   `json:"nodeId" form:"nodeId" binding:"required"`
+	Level    string `json:"level" form:"level"`       // 最低级别: trace, debug, info, warn, error
+	Service  string `json:"service" form:"service"`   // 服务名或服务名前缀
+	Lines    int    `json:"lines" form:"lines"`       // 历史条数，默认 200
+	Duration int    `json:"duration" form:"duration"` // 实时推送时长(秒)，默认 300
+}
+
+// NodeLogLevelDto 临时调整节点上单个服务的日志级别
+type NodeLogLevelDto struct {
+	NodeID   uint   `json:"nodeId" binding:"required"`
+	Service  string `json:"service" binding:"required"`
+	Level    string `json:"level"`    // 默认 debug
+	Duration int    `json:"duration"` // 秒，为 0 时恢复默认级别
+}
@@ -0,0 +1,20 @@
+package dto
+
+// NodeSettingsDto 更新节点程序设置，数值为 0 时使用节点默认值
+type NodeSettingsDto struct {
+	NodeID         uint     `json:"nodeId" binding:"required"`
+	Addrs          []string `json:"addrs"`          // 面板地址列表 host:port，为空时沿用节点当前地址
+	PingInterval   int      `json:"pingInterval"`   // 系统信息上报间隔(秒)，1-60
+	ConfigInterval int      `json:"configInterval"` // 配置上报间隔(秒)，60-86400
+	ObserverPeriod int      `json:"observerPeriod"` // 流量统计上报周期(秒)，1-300
+	LogLevel       string   `json:"logLevel"`       // trace, debug, info, warn, error
+	MetricsAddr    string   `json:"metricsAddr"`    // 为空时关闭指标服务
+	MetricsPath    string   `json:"metricsPath"`
+	APIAddr        string   `json:"apiAddr"` // 为空时关闭 Web API
+	APIPath        string   `json:"apiPath"`
+	APIUsername    string   `json:"apiUsername"`
+	APIPassword    string   `json:"apiPassword"`
+	HTTP           int      `json:"http"` // 协议屏蔽开关 0/1
+	TLS            int      `json:"tls"`
+	Socks          int      `json:"socks"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+// NodeProbeDto 从节点发起远程诊断
+type NodeProbeDto struct {
+	NodeID     uint   `json:"nodeId" binding:"required"`
+	Type       string `json:"type" binding:"required"`   // tcp, udp, http, trace, mtr, dns
+	Target     string `json:"target" binding:"required"` // IP/域名，http 探测为完整 URL
+	Port       int    `json:"port"`
+	Count      int    `json:"count"`
+	Timeout    int    `json:"timeout"`    // 毫秒
+	RecordType string `json:"recordType"` // DNS 记录类型，默认 A
+	Server     string `json:"server"`     // DNS 服务器，为空使用节点系统配置
+	Insecure   bool   `json:"insecure"`   // HTTP 探测跳过证书校验
+}
@@ -0,0 +1,47 @@
+package dto
+
+import "encoding/json"
+
+// TelemetryReportDto 节点上报的扩展遥测
+type TelemetryReportDto struct {
+	V          int             `json:"v"`
+	Time       int64           `json:"time"` // 采集时间（秒）
+	Load1      float64         `json:"load1"`
+	Load5      float64         `json:"load5"`
+	Load15     float64         `json:"load15"`
+	Disks      json.RawMessage `json:"disks"`
+	TCPStates  map[string]int  `json:"tcp_states"`
+	Interfaces json.RawMessage `json:"interfaces"`
+	Conntrack  *struct {
+		Count int64 `json:"count"`
+		Max   int64 `json:"max"`
+	} `json:"conntrack"`
+	FDs *struct {
+		Open  int32  `json:"open"`
+		Limit uint64 `json:"limit"`
+	} `json:"fds"`
+}
+
+// TelemetryQueryDto 查询节点遥测历史
+type TelemetryQueryDto struct {
+	NodeID    uint  `json:"nodeId" binding:"required"`
+	StartTime int64 `json:"startTime"` // 毫秒，默认最近24小时
+	EndTime   int64 `json:"endTime"`   // 毫秒
+}
+
+// NodeTelemetryDto 节点遥测记录
+type NodeTelemetryDto struct {
+	CollectedTime  int64           `json:"collectedTime"`
+	Version        int             `json:"version"`
+	Load1          float64         `json:"load1"`
+	Load5          float64         `json:"load5"`
+	Load15         float64         `json:"load15"`
+	Disks          json.RawMessage `json:"disks"`
+	TCPStates      json.RawMessage `json:"tcpStates"`
+	TCPTotal       int             `json:"tcpTotal"`
+	Interfaces     json.RawMessage `json:"interfaces"`
+	ConntrackCount int64           `json:"conntrackCount"`
+	ConntrackMax   int64           `json:"conntrackMax"`
+	FDOpen         int32           `json:"fdOpen"`
+	FDLimit        uint64          `json:"fdLimit"`
+}
@@ -5,6 +5,8 @@ type TunnelDto struct {
 	Name          string   `json:"name" binding:"required"`
 	InNodeID      uint     `json:"inNodeId"`
 	OutNodeID     uint     `json:"outNodeId"`
+	InGroupID     uint     `json:"inGroupId"`  // 入口节点组，指定后忽略入口节点
+	OutGroupID    uint     `json:"outGroupId"` // 出口节点组，指定后忽略出口节点
 	Type          int      `json:"type"`
 	Flow          int      `json:"flow"`
 	Protocol      string   `json:"protocol"`
@@ -12,6 +14,11 @@ type TunnelDto struct {
 	TCPListenAddr string   `json:"tcpListenAddr"`
 	UDPListenAddr string   `json:"udpListenAddr"`
 	InterfaceName string   `json:"interfaceName"`
+	ResolverType  string   `json:"resolverType"`
+	ResolverAddr  string   `json:"resolverAddr"`
+	ResolverTTL   int      `json:"resolverTtl"`
+	Hostname      string   `json:"hostname"`
+	DNSProviderID uint     `json:"dnsProviderId"`
 }
 
 // TunnelUpdateDto 更新隧道请求
@@ -20,6 +27,8 @@ type TunnelUpdateDto struct {
 	Name          *string  `json:"name"`
 	InNodeID      *uint    `json:"inNodeId"`
 	OutNodeID     *uint    `json:"outNodeId"`
+	InGroupID     *uint    `json:"inGroupId"`
+	OutGroupID    *uint    `json:"outGroupId"`
 	Type          *int     `json:"type"`
 	Flow          *int     `json:"flow"`
 	Protocol      *string  `json:"protocol"`
@@ -27,6 +36,11 @@ type TunnelUpdateDto struct {
 	TCPListenAddr *string  `json:"tcpListenAddr"`
 	UDPListenAddr *string  `json:"udpListenAddr"`
 	InterfaceName *string  `json:"interfaceName"`
+	ResolverType  *string  `json:"resolverType"`
+	ResolverAddr  *string  `json:"resolverAddr"`
+	ResolverTTL   *int     `json:"resolverTtl"`
+	Hostname      *string  `json:"hostname"`
+	DNSProviderID *uint    `json:"dnsProviderId"`
 }
 
 // UserTunnelDto 分配用户隧道请求
@@ -37,6 +51,10 @@ type UserTunnelDto struct {
 	Flow          int64 `json:"flow"`
 	FlowResetTime int64 `json:"flowResetTime"` // 0表示不重置
 	Num           int   `json:"num"`
+	ConnLimit     int   `json:"connLimit"`   // 0表示不限制
+	IPConnLimit   int   `json:"ipConnLimit"` // 0表示不限制
+	ConnRate      int   `json:"connRate"`    // 0表示不限制
+	IPConnRate    int   `json:"ipConnRate"`  // 0表示不限制
 }
 
 // UserTunnelQueryDto 查询用户隧道请求
@@ -52,6 +70,10 @@ type UserTunnelUpdateDto struct {
 	Flow          *int64 `json:"flow"`
 	FlowResetTime *int64 `json:"flowResetTime"`
 	Num           *int   `json:"num"`
+	ConnLimit     *int   `json:"connLimit"`
+	IPConnLimit   *int   `json:"ipConnLimit"`
+	ConnRate      *int   `json:"connRate"`
+	IPConnRate    *int   `json:"ipConnRate"`
 }
 
 // UserTunnelResponseDto 用户隧道权限响应
@@ -67,4 +89,8 @@ type UserTunnelResponseDto struct {
 	FlowResetTime int64  `json:"flowResetTime"`
 	Num           int    `json:"num"`
 	SpeedID       int    `json:"speedId"`
+	ConnLimit     int    `json:"connLimit"`
+	IPConnLimit   int    `json:"ipConnLimit"`
+	ConnRate      int    `json:"connRate"`
+	IPConnRate    int    `json:"ipConnRate"`
 }
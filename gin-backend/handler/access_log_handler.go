@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AccessLogHandler struct {
+	service *service.AccessLogService
+}
+
+func NewAccessLogHandler(db *gorm.DB) *AccessLogHandler {
+	return &AccessLogHandler{
+		service: service.NewAccessLogService(db),
+	}
+}
+
+// GetAccessLogs 查询连接日志
+func (h *AccessLogHandler) GetAccessLogs(c *gin.Context) {
+	var query dto.AccessLogQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	roleID, _ := c.Get("role_id")
+
+	result, err := h.service.GetAccessLogs(userID.(int), roleID.(int), &query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	utils.Success(c, result)
+}
+
+// ExportAccessLogs 导出连接日志为CSV
+func (h *AccessLogHandler) ExportAccessLogs(c *gin.Context) {
+	var query dto.AccessLogQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	roleID, _ := c.Get("role_id")
+
+	logs, err := h.service.ExportAccessLogs(userID.(int), roleID.(int), &query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "forward_id", "user_id", "node_id", "network", "client_ip", "target",
+		"in_bytes", "out_bytes", "start_time", "end_time", "err"})
+	for _, l := range logs {
+		w.Write([]string{
+			strconv.FormatUint(uint64(l.ID), 10),
+			strconv.FormatUint(uint64(l.ForwardID), 10),
+			strconv.Itoa(l.UserID),
+			strconv.FormatUint(uint64(l.NodeID), 10),
+			l.Network,
+			l.ClientIP,
+			l.Target,
+			strconv.FormatInt(l.InBytes, 10),
+			strconv.FormatInt(l.OutBytes, 10),
+			time.UnixMilli(l.StartTime).Format(time.RFC3339),
+			time.UnixMilli(l.EndTime).Format(time.RFC3339),
+			l.Err,
+		})
+	}
+	w.Flush()
+
+	filename := fmt.Sprintf("access_log_%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AgentHandler struct {
+	service *service.AgentService
+}
+
+func NewAgentHandler(db *gorm.DB) *AgentHandler {
+	return &AgentHandler{
+		service: service.NewAgentService(db),
+	}
+}
+
+// CreateRelease 登记发布包
+func (h *AgentHandler) CreateRelease(c *gin.Context) {
+	var releaseDto dto.AgentReleaseDto
+	if err := c.ShouldBindJSON(&releaseDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.CreateRelease(&releaseDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// GetReleases 获取发布包列表
+func (h *AgentHandler) GetReleases(c *gin.Context) {
+	releases, err := h.service.GetReleases()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, releases)
+}
+
+// DeleteRelease 删除发布包
+func (h *AgentHandler) DeleteRelease(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseNodeID(req["id"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.DeleteRelease(id); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// GetFleet 获取节点版本总览
+func (h *AgentHandler) GetFleet(c *gin.Context) {
+	fleet, err := h.service.GetFleet()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, fleet)
+}
+
+// UpgradeNode 升级单个节点
+func (h *AgentHandler) UpgradeNode(c *gin.Context) {
+	var upgradeDto dto.AgentUpgradeDto
+	if err := c.ShouldBindJSON(&upgradeDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.UpgradeNode(&upgradeDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// StartRollout 创建分批升级任务
+func (h *AgentHandler) StartRollout(c *gin.Context) {
+	var rolloutDto dto.AgentRolloutDto
+	if err := c.ShouldBindJSON(&rolloutDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	rollout, err := h.service.StartRollout(&rolloutDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, rollout)
+}
+
+// GetRollouts 获取升级任务列表
+func (h *AgentHandler) GetRollouts(c *gin.Context) {
+	rollouts, err := h.service.GetRollouts()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, rollouts)
+}
+
+// CancelRollout 取消升级任务
+func (h *AgentHandler) CancelRollout(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseNodeID(req["id"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.CancelRollout(id); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
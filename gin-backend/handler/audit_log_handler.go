@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuditLogHandler struct {
+	service *service.AuditService
+}
+
+func NewAuditLogHandler(db *gorm.DB) *AuditLogHandler {
+	return &AuditLogHandler{
+		service: service.NewAuditService(db),
+	}
+}
+
+// GetAuditLogs 查询审计日志
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	var query dto.AuditLogQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	result, err := h.service.GetAuditLogs(&query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	utils.Success(c, result)
+}
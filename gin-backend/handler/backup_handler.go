@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBackupSize 上传备份文件的大小上限
+const maxBackupSize = 512 << 20
+
+type BackupHandler struct {
+	service *service.BackupService
+}
+
+func NewBackupHandler(db *gorm.DB) *BackupHandler {
+	return &BackupHandler{
+		service: service.NewBackupService(db),
+	}
+}
+
+// ExportBackup 导出面板备份，返回 gzip 压缩的 JSON 文件
+func (h *BackupHandler) ExportBackup(c *gin.Context) {
+	var req dto.BackupExportDto
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.Error(c, "参数错误")
+			return
+		}
+	}
+
+	archive, err := h.service.Export(currentActor(c), req.IncludeFlow)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := service.WriteBackup(&buf, archive); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("flux-panel-backup-%s.json.gz", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(200, "application/gzip", buf.Bytes())
+}
+
+// RestoreBackup 上传备份文件并恢复，请求体为备份文件内容
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	archive, err := service.ReadBackup(http.MaxBytesReader(c.Writer, c.Request.Body, maxBackupSize))
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	result, err := h.service.Restore(currentActor(c), archive)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	utils.Success(c, result)
+}
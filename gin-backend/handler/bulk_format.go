@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/utils"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 批量导入导出 CSV 的列，导入时按表头匹配，列的顺序不限
+var (
+	forwardCSVColumns = []string{"user", "tunnel", "name", "remote_addr", "in_port", "in_port_end", "strategy"}
+	userCSVColumns    = []string{"user", "pwd", "flow", "num", "exp_time", "flow_reset_time", "status", "tunnels"}
+)
+
+// bulkTunnelSeparator CSV 中多个隧道名称的分隔符
+const bulkTunnelSeparator = ";"
+
+// bulkFormat 校验并返回文件格式，默认 CSV
+func bulkFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", dto.BulkFormatCSV:
+		return dto.BulkFormatCSV, nil
+	case dto.BulkFormatJSON:
+		return dto.BulkFormatJSON, nil
+	}
+	return "", fmt.Errorf("不支持的格式 %s，仅支持 csv 和 json", format)
+}
+
+// bindBulkExport 读取导出请求，请求体为空时使用默认格式
+func bindBulkExport(c *gin.Context) (string, error) {
+	var req dto.BulkExportDto
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return "", errors.New("参数错误")
+		}
+	}
+	return bulkFormat(req.Format)
+}
+
+// sendBulkExport 以附件形式返回导出的文件
+func sendBulkExport(c *gin.Context, name, format string, data []byte) {
+	contentType := "text/csv; charset=utf-8"
+	if format == dto.BulkFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(200, contentType, data)
+}
+
+// readCSVRecords 读取带表头的 CSV，返回每行按列名索引的值
+func readCSVRecords(content string, columns []string) ([]map[string]string, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff")))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("导入内容为空")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV 格式错误: %v", err)
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("未知的列 %s，支持的列: %s", header[i], strings.Join(columns, ","))
+		}
+	}
+	r.FieldsPerRecord = len(header)
+
+	var records []map[string]string
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV 格式错误: %v", err)
+		}
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			record[column] = strings.TrimSpace(fields[i])
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// writeCSV 写入表头与各行
+func writeCSV(columns []string, rows [][]string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(columns)
+	w.WriteAll(rows)
+	return buf.Bytes()
+}
+
+// csvInt 解析整数列，空值为 0
+func csvInt(record map[string]string, column string, row int) (int64, error) {
+	value := record[column]
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("第 %d 行 %s 不是有效的整数: %s", row, column, value)
+	}
+	return n, nil
+}
+
+// csvTime 解析时间列，支持 RFC3339、日期 (2006-01-02) 和毫秒时间戳，空值为 0
+func csvTime(record map[string]string, column string, row int) (int64, error) {
+	value := record[column]
+	if value == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UnixMilli(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.UnixMilli(), nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return 0, fmt.Errorf("第 %d 行 %s 不是有效的时间: %s", row, column, value)
+}
+
+// formatCSVTime 导出时间列，0 表示不限制时为空
+func formatCSVTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
+
+// decodeBulkForwards 解析转发导入内容
+func decodeBulkForwards(format, content string) ([]dto.BulkForwardRow, error) {
+	if format == dto.BulkFormatJSON {
+		var rows []dto.BulkForwardRow
+		if err := json.Unmarshal([]byte(content), &rows); err != nil {
+			return nil, fmt.Errorf("JSON 格式错误: %v", err)
+		}
+		return rows, nil
+	}
+
+	records, err := readCSVRecords(content, forwardCSVColumns)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]dto.BulkForwardRow, 0, len(records))
+	for i, record := range records {
+		inPort, err := csvInt(record, "in_port", i+1)
+		if err != nil {
+			return nil, err
+		}
+		inPortEnd, err := csvInt(record, "in_port_end", i+1)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, dto.BulkForwardRow{
+			User:       record["user"],
+			Tunnel:     record["tunnel"],
+			Name:       record["name"],
+			RemoteAddr: record["remote_addr"],
+			InPort:     int(inPort),
+			InPortEnd:  int(inPortEnd),
+			Strategy:   record["strategy"],
+		})
+	}
+	return rows, nil
+}
+
+// encodeBulkForwards 按导入格式导出转发
+func encodeBulkForwards(format string, rows []dto.BulkForwardRow) ([]byte, error) {
+	if format == dto.BulkFormatJSON {
+		return json.MarshalIndent(rows, "", "  ")
+	}
+
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		inPortEnd := ""
+		if row.InPortEnd > 0 {
+			inPortEnd = strconv.Itoa(row.InPortEnd)
+		}
+		records = append(records, []string{
+			row.User,
+			row.Tunnel,
+			row.Name,
+			row.RemoteAddr,
+			strconv.Itoa(row.InPort),
+			inPortEnd,
+			row.Strategy,
+		})
+	}
+	return writeCSV(forwardCSVColumns, records), nil
+}
+
+// decodeBulkUsers 解析用户导入内容
+func decodeBulkUsers(format, content string) ([]dto.BulkUserRow, error) {
+	if format == dto.BulkFormatJSON {
+		var rows []dto.BulkUserRow
+		if err := json.Unmarshal([]byte(content), &rows); err != nil {
+			return nil, fmt.Errorf("JSON 格式错误: %v", err)
+		}
+		return rows, nil
+	}
+
+	records, err := readCSVRecords(content, userCSVColumns)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]dto.BulkUserRow, 0, len(records))
+	for i, record := range records {
+		row := dto.BulkUserRow{User: record["user"], Pwd: record["pwd"]}
+		if row.Flow, err = csvInt(record, "flow", i+1); err != nil {
+			return nil, err
+		}
+		num, err := csvInt(record, "num", i+1)
+		if err != nil {
+			return nil, err
+		}
+		row.Num = int(num)
+		if row.ExpTime, err = csvTime(record, "exp_time", i+1); err != nil {
+			return nil, err
+		}
+		if row.FlowResetTime, err = csvInt(record, "flow_reset_time", i+1); err != nil {
+			return nil, err
+		}
+		if record["status"] != "" {
+			status, err := csvInt(record, "status", i+1)
+			if err != nil {
+				return nil, err
+			}
+			s := int(status)
+			row.Status = &s
+		}
+		if record["tunnels"] != "" {
+			row.Tunnels = strings.Split(record["tunnels"], bulkTunnelSeparator)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// encodeBulkUsers 按导入格式导出用户，密码为空，导入前需填写
+func encodeBulkUsers(format string, rows []dto.BulkUserRow) ([]byte, error) {
+	if format == dto.BulkFormatJSON {
+		return json.MarshalIndent(rows, "", "  ")
+	}
+
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		status := ""
+		if row.Status != nil {
+			status = strconv.Itoa(*row.Status)
+		}
+		records = append(records, []string{
+			row.User,
+			row.Pwd,
+			strconv.FormatInt(row.Flow, 10),
+			strconv.Itoa(row.Num),
+			formatCSVTime(row.ExpTime),
+			strconv.FormatInt(row.FlowResetTime, 10),
+			status,
+			strings.Join(row.Tunnels, bulkTunnelSeparator),
+		})
+	}
+	return writeCSV(userCSVColumns, records), nil
+}
+
+// sendBulkImportResult 返回导入结果，存在校验失败的行时提示未导入
+func sendBulkImportResult(c *gin.Context, result *dto.BulkImportResultDto) {
+	switch {
+	case result.Invalid > 0:
+		utils.SuccessWithMsg(c, fmt.Sprintf("%d 行校验失败，未导入任何数据", result.Invalid), result)
+	case result.DryRun:
+		utils.SuccessWithMsg(c, "校验通过", result)
+	default:
+		utils.Success(c, result)
+	}
+}
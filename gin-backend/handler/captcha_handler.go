@@ -2,14 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"flux-panel/cluster"
 	"flux-panel/service"
 	"flux-panel/utils"
 	"fmt"
 	"image/color"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,26 +23,44 @@ type CaptchaHandler struct {
 	configService *service.ConfigService
 }
 
-// 验证码存储
+// 验证码存储，答案和二次验证 token 保存在集群总线中，多个面板实例共享
 var (
-	captchaStore  = base64Captcha.DefaultMemStore
-	captchaDriver = newSliderDriver()
-	captchaMutex  sync.RWMutex
-	// 二次验证 token 存储 (用于登录验证)
-	validTokens      = make(map[string]int64) // token -> expireTime
-	tokenCleanupOnce sync.Once
+	captchaStore  base64Captcha.Store = clusterCaptchaStore{}
+	captchaDriver                     = newSliderDriver()
 )
 
 const (
 	tokenExpireSeconds = 120 // 验证码有效期 120 秒
+	captchaExpire      = 10 * time.Minute
 )
 
-func NewCaptchaHandler(db *gorm.DB) *CaptchaHandler {
-	// 启动 token 清理协程
-	tokenCleanupOnce.Do(func() {
-		go cleanupExpiredTokens()
-	})
+// clusterCaptchaStore 基于集群总线的验证码答案存储
+type clusterCaptchaStore struct{}
+
+func (clusterCaptchaStore) Set(id string, value string) error {
+	return cluster.Get().Set("captcha:answer:"+id, []byte(value), captchaExpire)
+}
+
+func (clusterCaptchaStore) Get(id string, clear bool) string {
+	var value []byte
+	var err error
+	if clear {
+		value, err = cluster.Get().Take("captcha:answer:" + id)
+	} else {
+		value, err = cluster.Get().Get("captcha:answer:" + id)
+	}
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
 
+func (s clusterCaptchaStore) Verify(id, answer string, clear bool) bool {
+	value := strings.TrimSpace(s.Get(id, clear))
+	return value != "" && value == strings.TrimSpace(answer)
+}
+
+func NewCaptchaHandler(db *gorm.DB) *CaptchaHandler {
 	return &CaptchaHandler{
 		configService: service.NewConfigService(db),
 	}
@@ -181,53 +201,15 @@ func ValidateCaptchaToken(captchaID string) bool {
 		return false
 	}
 
-	captchaMutex.RLock()
-	expireTime, exists := validTokens[captchaID]
-	captchaMutex.RUnlock()
-
-	if !exists {
-		return false
-	}
-
-	// 检查是否过期
-	if time.Now().Unix() > expireTime {
-		// 删除过期 token
-		captchaMutex.Lock()
-		delete(validTokens, captchaID)
-		captchaMutex.Unlock()
-		return false
-	}
-
-	// 验证成功后删除 token（一次性使用）
-	captchaMutex.Lock()
-	delete(validTokens, captchaID)
-	captchaMutex.Unlock()
-
-	return true
+	// 验证成功后删除 token（一次性使用），过期的 token 由总线自动清除
+	_, err := cluster.Get().Take("captcha:token:" + captchaID)
+	return err == nil
 }
 
 // storeValidToken 存储已验证的 token
 func storeValidToken(captchaID string) {
-	captchaMutex.Lock()
-	defer captchaMutex.Unlock()
-
-	expireTime := time.Now().Unix() + tokenExpireSeconds
-	validTokens[captchaID] = expireTime
-}
-
-// cleanupExpiredTokens 清理过期的 token
-func cleanupExpiredTokens() {
-	ticker := time.NewTicker(time.Minute)
-	for range ticker.C {
-		now := time.Now().Unix()
-
-		captchaMutex.Lock()
-		for id, expireTime := range validTokens {
-			if now > expireTime {
-				delete(validTokens, id)
-			}
-		}
-		captchaMutex.Unlock()
+	if err := cluster.Get().Set("captcha:token:"+captchaID, []byte("1"), tokenExpireSeconds*time.Second); err != nil {
+		log.Printf("保存验证码 token 失败: %v", err)
 	}
 }
 
@@ -53,7 +53,7 @@ func (h *ConfigHandler) UpdateConfigs(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateConfigs(configMap); err != nil {
+	if err := h.service.UpdateConfigs(currentActor(c), configMap); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -71,7 +71,7 @@ func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
 	name := req["name"]
 	value := req["value"]
 
-	if err := h.service.UpdateConfig(name, value); err != nil {
+	if err := h.service.UpdateConfig(currentActor(c), name, value); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DNSHandler struct {
+	service *service.DNSService
+}
+
+func NewDNSHandler(db *gorm.DB) *DNSHandler {
+	return &DNSHandler{
+		service: service.NewDNSService(db),
+	}
+}
+
+// CreateProvider 创建 DNS 服务商
+func (h *DNSHandler) CreateProvider(c *gin.Context) {
+	var providerDto dto.DNSProviderDto
+	if err := c.ShouldBindJSON(&providerDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.CreateProvider(currentActor(c), &providerDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "DNS 服务商创建成功")
+}
+
+// GetAllProviders 获取所有 DNS 服务商
+func (h *DNSHandler) GetAllProviders(c *gin.Context) {
+	providers, err := h.service.GetAllProviders()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, providers)
+}
+
+// UpdateProvider 更新 DNS 服务商
+func (h *DNSHandler) UpdateProvider(c *gin.Context) {
+	var updateDto dto.DNSProviderUpdateDto
+	if err := c.ShouldBindJSON(&updateDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.UpdateProvider(currentActor(c), &updateDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "DNS 服务商更新成功")
+}
+
+// DeleteProvider 删除 DNS 服务商
+func (h *DNSHandler) DeleteProvider(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseID(req["id"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.DeleteProvider(currentActor(c), id); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "DNS 服务商删除成功")
+}
+
+// GetRecords 获取隧道入口域名的解析记录
+func (h *DNSHandler) GetRecords(c *gin.Context) {
+	records, err := h.service.GetRecords()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, records)
+}
+
+// SyncRecords 立即同步解析记录，指定 tunnelId 时只同步该隧道
+func (h *DNSHandler) SyncRecords(c *gin.Context) {
+	var req map[string]interface{}
+	_ = c.ShouldBindJSON(&req)
+
+	var err error
+	if tunnelID := parseID(req["tunnelId"]); tunnelID > 0 {
+		err = h.service.ForceSyncTunnel(tunnelID)
+	} else {
+		err = h.service.SyncAll(true)
+	}
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "解析记录同步成功")
+}
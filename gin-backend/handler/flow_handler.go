@@ -9,7 +9,6 @@ import (
 	"log"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,14 +19,6 @@ type FlowHandler struct {
 	db *gorm.DB
 }
 
-// 用于同步相同用户和隧道的流量更新操作
-var (
-	userLocks    = make(map[string]*sync.Mutex)
-	tunnelLocks  = make(map[string]*sync.Mutex)
-	forwardLocks = make(map[string]*sync.Mutex)
-	locksMutex   sync.Mutex
-)
-
 const (
 	successResponse     = "ok"
 	defaultUserTunnelID = "0"
@@ -40,11 +31,11 @@ func NewFlowHandler(db *gorm.DB) *FlowHandler {
 
 // Config 接收节点配置数据
 func (h *FlowHandler) Config(c *gin.Context) {
-	secret := c.Query("secret")
+	secret := utils.NodeSecret(c)
 
 	// 验证节点
 	var node models.Node
-	if err := h.db.Where("secret = ?", secret).First(&node).Error; err != nil {
+	if err := h.db.Scopes(utils.NodeBySecret(secret)).First(&node).Error; err != nil {
 		c.String(200, successResponse)
 		return
 	}
@@ -56,16 +47,29 @@ func (h *FlowHandler) Config(c *gin.Context) {
 		return
 	}
 
+	// 解密数据（如果加密）
+	decryptedData, err := h.decryptIfNeeded(rawData, node.ID, secret)
+	if err != nil {
+		log.Printf("解密数据失败: %v", err)
+		c.String(200, successResponse)
+		return
+	}
+
 	// 解析配置数据
 	var gostConfig dto.GostConfigDto
-	if err := json.Unmarshal(rawData, &gostConfig); err != nil {
+	if err := json.Unmarshal(decryptedData, &gostConfig); err != nil {
 		log.Printf("解析节点 %d 配置数据失败: %v", node.ID, err)
 		c.String(200, successResponse)
 		return
 	}
 
-	// 异步清理孤立配置
-	go h.cleanOrphanedConfigs(node.ID, &gostConfig)
+	// 异步重放离线期间排队的命令，再清理孤立配置，并补齐缺失的服务和暂停状态
+	go func() {
+		service.NewNodeCommandService(h.db).Replay(node.ID)
+		h.cleanOrphanedConfigs(node.ID, &gostConfig)
+		service.NewForwardService(h.db).ReconcileNode(node.ID, &gostConfig)
+		service.NewNodeSettingsService(h.db).SyncPending(node.ID)
+	}()
 
 	log.Printf("节点 %d 配置数据接收成功", node.ID)
 	c.String(200, successResponse)
@@ -78,11 +82,11 @@ func (h *FlowHandler) Test(c *gin.Context) {
 
 // Upload 处理流量数据上报
 func (h *FlowHandler) Upload(c *gin.Context) {
-	secret := c.Query("secret")
+	secret := utils.NodeSecret(c)
 
 	// 验证节点
 	var node models.Node
-	if err := h.db.Where("secret = ?", secret).First(&node).Error; err != nil {
+	if err := h.db.Scopes(utils.NodeBySecret(secret)).First(&node).Error; err != nil {
 		c.String(200, successResponse)
 		return
 	}
@@ -95,7 +99,7 @@ func (h *FlowHandler) Upload(c *gin.Context) {
 	}
 
 	// 解密数据（如果加密）
-	decryptedData, err := h.decryptIfNeeded(rawData, secret)
+	decryptedData, err := h.decryptIfNeeded(rawData, node.ID, secret)
 	if err != nil {
 		log.Printf("解密数据失败: %v", err)
 		c.String(200, successResponse)
@@ -124,35 +128,116 @@ func (h *FlowHandler) Upload(c *gin.Context) {
 	c.String(200, successResponse)
 }
 
-// decryptIfNeeded 检测并解密加密消息
-func (h *FlowHandler) decryptIfNeeded(rawData []byte, secret string) ([]byte, error) {
-	// 尝试解析为加密消息
-	var encMsg dto.EncryptedMessage
-	if err := json.Unmarshal(rawData, &encMsg); err != nil {
-		// 不是加密消息格式，直接返回原始数据
-		return rawData, nil
+// Limit 处理连接限制命中上报
+func (h *FlowHandler) Limit(c *gin.Context) {
+	secret := utils.NodeSecret(c)
+
+	// 验证节点
+	var node models.Node
+	if err := h.db.Scopes(utils.NodeBySecret(secret)).First(&node).Error; err != nil {
+		c.String(200, successResponse)
+		return
 	}
 
-	// 检查是否标记为加密
-	if !encMsg.Encrypted || encMsg.Data == "" {
-		return rawData, nil
+	// 读取原始数据
+	rawData, err := c.GetRawData()
+	if err != nil {
+		c.String(200, successResponse)
+		return
 	}
 
-	// 获取加密器并解密
-	crypto, err := utils.GetOrCreateCrypto(secret)
+	// 解密数据（如果加密）
+	decryptedData, err := h.decryptIfNeeded(rawData, node.ID, secret)
 	if err != nil {
-		return nil, err
+		log.Printf("解密数据失败: %v", err)
+		c.String(200, successResponse)
+		return
+	}
+
+	var hits []dto.LimitHitDto
+	if err := json.Unmarshal(decryptedData, &hits); err != nil {
+		log.Printf("解析限制命中数据失败: %v", err)
+		c.String(200, successResponse)
+		return
+	}
+
+	for _, hit := range hits {
+		parts := strings.Split(hit.N, "_")
+		if len(parts) < 3 || (hit.C == 0 && hit.R == 0) {
+			continue
+		}
+		forwardID, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		h.db.Model(&models.Forward{}).
+			Where("id = ?", forwardID).
+			UpdateColumns(map[string]interface{}{
+				"conn_limit_hits": gorm.Expr("conn_limit_hits + ?", hit.C),
+				"rate_limit_hits": gorm.Expr("rate_limit_hits + ?", hit.R),
+			})
+	}
+
+	c.String(200, successResponse)
+}
+
+// Access 接收节点上报的连接日志
+func (h *FlowHandler) Access(c *gin.Context) {
+	secret := utils.NodeSecret(c)
+
+	// 验证节点
+	var node models.Node
+	if err := h.db.Scopes(utils.NodeBySecret(secret)).First(&node).Error; err != nil {
+		c.String(200, successResponse)
+		return
 	}
 
-	decrypted, err := crypto.DecryptString(encMsg.Data)
+	// 读取原始数据
+	rawData, err := c.GetRawData()
 	if err != nil {
-		return nil, err
+		c.String(200, successResponse)
+		return
+	}
+
+	// 解密数据（如果加密）
+	decryptedData, err := h.decryptIfNeeded(rawData, node.ID, secret)
+	if err != nil {
+		log.Printf("解密数据失败: %v", err)
+		c.String(200, successResponse)
+		return
 	}
 
-	return []byte(decrypted), nil
+	var items []dto.AccessLogItemDto
+	if err := json.Unmarshal(decryptedData, &items); err != nil {
+		log.Printf("解析连接日志数据失败: %v", err)
+		c.String(200, successResponse)
+		return
+	}
+
+	if err := service.NewAccessLogService(h.db).SaveReport(node.ID, items); err != nil {
+		log.Printf("保存连接日志失败: %v", err)
+	}
+
+	c.String(200, successResponse)
+}
+
+// decryptIfNeeded 检测并解密加密消息，签名信封会校验时间戳并拒绝重放
+// 使用节点请求携带的密钥解密，密钥轮换的宽限期内节点可能仍在使用旧密钥
+func (h *FlowHandler) decryptIfNeeded(rawData []byte, nodeID uint, secret string) ([]byte, error) {
+	crypto, err := utils.GetOrCreateCrypto(secret)
+	if err != nil {
+		return nil, err
+	}
+	return utils.OpenNodeEnvelope(nodeID, crypto, utils.NodeReplayGuard(nodeID), rawData, utils.LegacyEnvelopeAllowed(nodeID))
 }
 
 func (h *FlowHandler) processFlowData(flowData *dto.FlowDto) {
+	// 带宽测试的流量不计入用户
+	if strings.HasPrefix(flowData.N, service.BandwidthTestServicePrefix) {
+		return
+	}
+
 	// 解析服务名称
 	parts := strings.Split(flowData.N, "_")
 	if len(parts) < 3 {
@@ -194,8 +279,8 @@ func (h *FlowHandler) processFlowData(flowData *dto.FlowDto) {
 
 	// 非管理员转发检查限制
 	if userTunnelID != defaultUserTunnelID {
-		h.checkUserLimits(userID, flowData.N)
-		h.checkUserTunnelLimits(userTunnelID, userID, flowData.N)
+		h.checkUserLimits(userID)
+		h.checkUserTunnelLimits(userTunnelID, userID)
 	}
 }
 
@@ -208,11 +293,9 @@ func (h *FlowHandler) getFlowType(forward *models.Forward) int {
 	return tunnel.Flow
 }
 
+// updateForwardFlow 累加转发流量；流量累加在 SQL 中完成 (in_flow = in_flow + ?)，
+// 多个面板实例并发上报时无需额外加锁，用户和用户隧道流量同理
 func (h *FlowHandler) updateForwardFlow(forwardID string, inFlow, outFlow int64) {
-	lock := getLock(forwardLocks, forwardID)
-	lock.Lock()
-	defer lock.Unlock()
-
 	h.db.Model(&models.Forward{}).Where("id = ?", forwardID).
 		UpdateColumns(map[string]interface{}{
 			"in_flow":  gorm.Expr("in_flow + ?", inFlow),
@@ -221,10 +304,6 @@ func (h *FlowHandler) updateForwardFlow(forwardID string, inFlow, outFlow int64)
 }
 
 func (h *FlowHandler) updateUserFlow(userID string, inFlow, outFlow int64) {
-	lock := getLock(userLocks, userID)
-	lock.Lock()
-	defer lock.Unlock()
-
 	h.db.Model(&models.User{}).Where("id = ?", userID).
 		UpdateColumns(map[string]interface{}{
 			"in_flow":  gorm.Expr("in_flow + ?", inFlow),
@@ -237,10 +316,6 @@ func (h *FlowHandler) updateUserTunnelFlow(userTunnelID string, inFlow, outFlow
 		return
 	}
 
-	lock := getLock(tunnelLocks, userTunnelID)
-	lock.Lock()
-	defer lock.Unlock()
-
 	h.db.Model(&models.UserTunnel{}).Where("id = ?", userTunnelID).
 		UpdateColumns(map[string]interface{}{
 			"in_flow":  gorm.Expr("in_flow + ?", inFlow),
@@ -248,7 +323,7 @@ func (h *FlowHandler) updateUserTunnelFlow(userTunnelID string, inFlow, outFlow
 		})
 }
 
-func (h *FlowHandler) checkUserLimits(userID, serviceName string) {
+func (h *FlowHandler) checkUserLimits(userID string) {
 	var user models.User
 	if err := h.db.First(&user, userID).Error; err != nil {
 		return
@@ -258,23 +333,23 @@ func (h *FlowHandler) checkUserLimits(userID, serviceName string) {
 	userFlowLimit := user.Flow * bytesToGB
 	userCurrentFlow := user.InFlow + user.OutFlow
 	if userFlowLimit < userCurrentFlow {
-		h.pauseUserServices(userID, serviceName)
+		h.pauseUserServices(userID, "用户流量已超限")
 		return
 	}
 
 	// 检查到期时间
 	if user.ExpTime > 0 && user.ExpTime <= time.Now().UnixMilli() {
-		h.pauseUserServices(userID, serviceName)
+		h.pauseUserServices(userID, "用户已到期")
 		return
 	}
 
 	// 检查用户状态
 	if user.Status != 1 {
-		h.pauseUserServices(userID, serviceName)
+		h.pauseUserServices(userID, "用户已禁用")
 	}
 }
 
-func (h *FlowHandler) checkUserTunnelLimits(userTunnelID, userID, serviceName string) {
+func (h *FlowHandler) checkUserTunnelLimits(userTunnelID, userID string) {
 	var userTunnel models.UserTunnel
 	if err := h.db.First(&userTunnel, userTunnelID).Error; err != nil {
 		return
@@ -283,54 +358,41 @@ func (h *FlowHandler) checkUserTunnelLimits(userTunnelID, userID, serviceName st
 	// 检查流量限制
 	flow := userTunnel.InFlow + userTunnel.OutFlow
 	if flow >= userTunnel.Flow*bytesToGB {
-		h.pauseTunnelServices(userTunnel.TunnelID, userID, serviceName)
+		h.pauseTunnelServices(userTunnel.TunnelID, userID, "隧道流量已超限")
 		return
 	}
 
 	// 检查到期时间
 	if userTunnel.ExpTime > 0 && userTunnel.ExpTime <= time.Now().UnixMilli() {
-		h.pauseTunnelServices(userTunnel.TunnelID, userID, serviceName)
+		h.pauseTunnelServices(userTunnel.TunnelID, userID, "隧道权限已到期")
 		return
 	}
 
 	// 检查隧道状态
 	if userTunnel.Status != 1 {
-		h.pauseTunnelServices(userTunnel.TunnelID, userID, serviceName)
+		h.pauseTunnelServices(userTunnel.TunnelID, userID, "隧道权限已禁用")
 	}
 }
 
-func (h *FlowHandler) pauseUserServices(userID, serviceName string) {
+func (h *FlowHandler) pauseUserServices(userID, reason string) {
 	var forwards []models.Forward
 	h.db.Where("user_id = ?", userID).Find(&forwards)
 
-	h.pauseForwards(forwards, serviceName)
+	h.pauseForwards(forwards, reason)
 }
 
-func (h *FlowHandler) pauseTunnelServices(tunnelID uint, userID, serviceName string) {
+func (h *FlowHandler) pauseTunnelServices(tunnelID uint, userID, reason string) {
 	var forwards []models.Forward
 	h.db.Where("tunnel_id = ? AND user_id = ?", tunnelID, userID).Find(&forwards)
 
-	h.pauseForwards(forwards, serviceName)
+	h.pauseForwards(forwards, reason)
 }
 
-func (h *FlowHandler) pauseForwards(forwards []models.Forward, serviceName string) {
-	for _, forward := range forwards {
-		var tunnel models.Tunnel
-		if err := h.db.First(&tunnel, forward.TunnelID).Error; err != nil {
-			continue
-		}
-
-		// ... (Inside pauseForwards)
-		// 暂停入口节点服务
-		service.PauseService(uint(tunnel.InNodeID), serviceName)
-
-		// 隧道类型(2)也需要暂停出口节点
-		if tunnel.Type == 2 {
-			service.PauseRemoteService(uint(tunnel.OutNodeID), serviceName)
-		}
-
-		// 更新转发状态
-		h.db.Model(&models.Forward{}).Where("id = ?", forward.ID).Update("status", 0)
+func (h *FlowHandler) pauseForwards(forwards []models.Forward, reason string) {
+	forwardService := service.NewForwardService(h.db)
+	for i := range forwards {
+		// 暂停转发在入口与出口节点（含节点组全部成员）上的服务并更新转发状态
+		forwardService.AutoPauseForward(&forwards[i], reason)
 	}
 }
 
@@ -347,19 +409,18 @@ func (h *FlowHandler) cleanOrphanedConfigs(nodeID uint, gostConfig *dto.GostConf
 			continue
 		}
 
+		// 名称格式: 转发ID_用户ID_用户隧道ID[_端口偏移]_类型
 		forwardID := parts[0]
-		userID := parts[1]
-		userTunnelID := parts[2]
-		serviceType := parts[3]
+		serviceType := parts[len(parts)-1]
 
 		var forward models.Forward
 		if err := h.db.First(&forward, forwardID).Error; err != nil {
 			// 转发不存在，删除服务
-			serviceName := forwardID + "_" + userID + "_" + userTunnelID
+			serviceName := strings.Join(parts[:len(parts)-1], "_")
 			if serviceType == "tcp" || serviceType == "udp" {
-				service.DeleteService(nodeID, serviceName)
+				service.DeleteService(nodeID, serviceName, 1)
 			} else if serviceType == "tls" {
-				service.DeleteRemoteService(nodeID, serviceName)
+				service.DeleteRemoteService(nodeID, serviceName, 1)
 			}
 			log.Printf("删除孤立的服务: %s (节点: %d)", svc.Name, nodeID)
 		}
@@ -376,7 +437,7 @@ func (h *FlowHandler) cleanOrphanedConfigs(nodeID uint, gostConfig *dto.GostConf
 
 		var forward models.Forward
 		if err := h.db.First(&forward, forwardID).Error; err != nil {
-			service.DeleteChains(nodeID, chain.Name)
+			service.DeleteChains(nodeID, strings.TrimSuffix(chain.Name, "_chains"), 1)
 			log.Printf("删除孤立的链: %s (节点: %d)", chain.Name, nodeID)
 		}
 	}
@@ -394,17 +455,37 @@ func (h *FlowHandler) cleanOrphanedConfigs(nodeID uint, gostConfig *dto.GostConf
 			log.Printf("删除孤立的限流器: %s (节点: %d)", limiter.Name, nodeID)
 		}
 	}
-}
 
-func getLock(locks map[string]*sync.Mutex, key string) *sync.Mutex {
-	locksMutex.Lock()
-	defer locksMutex.Unlock()
+	// 清理孤立的连接数限制器
+	for _, limiter := range gostConfig.CLimiters {
+		if h.isOrphanedForwardName(limiter.Name) {
+			service.DeleteConnLimiters(nodeID, limiter.Name)
+			log.Printf("删除孤立的连接数限制器: %s (节点: %d)", limiter.Name, nodeID)
+		}
+	}
+	for _, limiter := range gostConfig.RLimiters {
+		if h.isOrphanedForwardName(limiter.Name) {
+			service.DeleteRateLimiters(nodeID, limiter.Name)
+			log.Printf("删除孤立的连接速率限制器: %s (节点: %d)", limiter.Name, nodeID)
+		}
+	}
 
-	if lock, exists := locks[key]; exists {
-		return lock
+	// 清理孤立的解析器
+	for _, resolver := range gostConfig.Resolvers {
+		if h.isOrphanedForwardName(resolver.Name) {
+			service.DeleteResolvers(nodeID, resolver.Name)
+			log.Printf("删除孤立的解析器: %s (节点: %d)", resolver.Name, nodeID)
+		}
 	}
+}
 
-	lock := &sync.Mutex{}
-	locks[key] = lock
-	return lock
+// isOrphanedForwardName 判断以转发服务名命名的配置是否已无对应转发
+func (h *FlowHandler) isOrphanedForwardName(name string) bool {
+	parts := strings.Split(name, "_")
+	if len(parts) != 3 {
+		return false
+	}
+
+	var forward models.Forward
+	return h.db.First(&forward, parts[0]).Error != nil
 }
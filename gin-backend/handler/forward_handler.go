@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"flux-panel/dto"
 	"flux-panel/service"
 	"flux-panel/utils"
@@ -28,25 +29,34 @@ func (h *ForwardHandler) CreateForward(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
 	userName, _ := c.Get("user")
 
-	userIDInt := userID.(int)
 	userNameStr := ""
 	if userName != nil {
 		userNameStr = userName.(string)
 	}
 
-	if err := h.service.CreateForward(userIDInt, userNameStr, &forwardDto); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.CreateForward(currentActor(c), userNameStr, &forwardDto); err != nil {
+		forwardError(c, err)
 		return
 	}
 
 	utils.Success(c, "转发创建成功")
 }
 
-// GetAllForwards 获取所有转发
+// GetAllForwards 获取转发列表，普通用户只返回自己的转发
 func (h *ForwardHandler) GetAllForwards(c *gin.Context) {
+	actor := currentActor(c)
+	if !actor.IsAdmin() {
+		forwards, err := h.service.GetForwardsByUserID(actor.UserID)
+		if err != nil {
+			utils.Error(c, err.Error())
+			return
+		}
+		utils.Success(c, forwards)
+		return
+	}
+
 	forwards, err := h.service.GetAllForwards()
 	if err != nil {
 		utils.Error(c, err.Error())
@@ -63,8 +73,8 @@ func (h *ForwardHandler) UpdateForward(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateForward(&updateDto); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.UpdateForward(currentActor(c), &updateDto); err != nil {
+		forwardError(c, err)
 		return
 	}
 
@@ -85,8 +95,8 @@ func (h *ForwardHandler) DeleteForward(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteForward(id); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.DeleteForward(currentActor(c), id); err != nil {
+		forwardError(c, err)
 		return
 	}
 
@@ -107,8 +117,8 @@ func (h *ForwardHandler) ForceDeleteForward(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ForceDeleteForward(id); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.ForceDeleteForward(currentActor(c), id); err != nil {
+		forwardError(c, err)
 		return
 	}
 
@@ -129,14 +139,37 @@ func (h *ForwardHandler) PauseForward(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.PauseForward(id); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.PauseForward(currentActor(c), id); err != nil {
+		forwardError(c, err)
 		return
 	}
 
 	utils.Success(c, "转发已暂停")
 }
 
+// GetForwardCommands 获取转发在节点上的命令及其下发状态
+func (h *ForwardHandler) GetForwardCommands(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseID(req["id"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	commands, err := h.service.GetForwardCommands(currentActor(c), id)
+	if err != nil {
+		forwardError(c, err)
+		return
+	}
+
+	utils.Success(c, commands)
+}
+
 // ResumeForward 恢复转发
 func (h *ForwardHandler) ResumeForward(c *gin.Context) {
 	var req map[string]interface{}
@@ -151,8 +184,8 @@ func (h *ForwardHandler) ResumeForward(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ResumeForward(id); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.ResumeForward(currentActor(c), id); err != nil {
+		forwardError(c, err)
 		return
 	}
 
@@ -173,9 +206,11 @@ func (h *ForwardHandler) DiagnoseForward(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.DiagnoseForward(id)
+	protocol, _ := req["protocol"].(string)
+	trace, _ := req["trace"].(bool)
+	result, err := h.service.DiagnoseForward(currentActor(c), id, protocol, trace)
 	if err != nil {
-		utils.Error(c, err.Error())
+		forwardError(c, err)
 		return
 	}
 
@@ -190,14 +225,38 @@ func (h *ForwardHandler) UpdateForwardOrder(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateForwardOrder(&orderDto); err != nil {
-		utils.Error(c, err.Error())
+	if err := h.service.UpdateForwardOrder(currentActor(c), &orderDto); err != nil {
+		forwardError(c, err)
 		return
 	}
 
 	utils.Success(c, "排序更新成功")
 }
 
+// currentActor 从 JWTAuth 写入的上下文构造当前用户
+func currentActor(c *gin.Context) service.Actor {
+	userID, _ := c.Get("user_id")
+	roleID, _ := c.Get("role_id")
+	username, _ := c.Get("user")
+	id, _ := userID.(int)
+	role, ok := roleID.(int)
+	if !ok {
+		// 缺少角色信息时按普通用户处理，避免误判为管理员
+		role = 1
+	}
+	name, _ := username.(string)
+	return service.Actor{UserID: id, RoleID: role, Name: name, IP: c.ClientIP()}
+}
+
+// forwardError 输出转发操作错误，越权操作返回 403
+func forwardError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrForbidden) {
+		utils.Forbidden(c, err.Error())
+		return
+	}
+	utils.Error(c, err.Error())
+}
+
 // parseID 解析ID参数
 func parseID(val interface{}) uint {
 	if val == nil {
@@ -215,3 +274,50 @@ func parseID(val interface{}) uint {
 		return 0
 	}
 }
+
+// ImportForwards 批量导入转发，支持 CSV 与 JSON，dryRun 时只返回校验报告
+func (h *ForwardHandler) ImportForwards(c *gin.Context) {
+	var req dto.BulkImportDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+	format, err := bulkFormat(req.Format)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	rows, err := decodeBulkForwards(format, req.Content)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	result, err := h.service.ImportForwards(currentActor(c), rows, req.DryRun)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	sendBulkImportResult(c, result)
+}
+
+// ExportForwards 导出转发，格式与批量导入相同，普通用户只导出自己的转发
+func (h *ForwardHandler) ExportForwards(c *gin.Context) {
+	format, err := bindBulkExport(c)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	rows, err := h.service.ExportForwards(currentActor(c))
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	data, err := encodeBulkForwards(format, rows)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	sendBulkExport(c, "forward", format, data)
+}
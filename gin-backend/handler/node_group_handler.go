@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"flux-panel/dto"
+	"flux-panel/service"
+	"flux-panel/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NodeGroupHandler struct {
+	service *service.NodeGroupService
+}
+
+func NewNodeGroupHandler(db *gorm.DB) *NodeGroupHandler {
+	return &NodeGroupHandler{
+		service: service.NewNodeGroupService(db),
+	}
+}
+
+// CreateGroup 创建节点组
+func (h *NodeGroupHandler) CreateGroup(c *gin.Context) {
+	var groupDto dto.NodeGroupDto
+	if err := c.ShouldBindJSON(&groupDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.CreateGroup(currentActor(c), &groupDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "节点组创建成功")
+}
+
+// GetAllGroups 获取所有节点组
+func (h *NodeGroupHandler) GetAllGroups(c *gin.Context) {
+	groups, err := h.service.GetAllGroups()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, groups)
+}
+
+// UpdateGroup 更新节点组，成员变更时重新部署使用该组的转发
+func (h *NodeGroupHandler) UpdateGroup(c *gin.Context) {
+	var updateDto dto.NodeGroupUpdateDto
+	if err := c.ShouldBindJSON(&updateDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.UpdateGroup(currentActor(c), &updateDto); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "节点组更新成功")
+}
+
+// DeleteGroup 删除节点组
+func (h *NodeGroupHandler) DeleteGroup(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseID(req["id"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	if err := h.service.DeleteGroup(currentActor(c), id); err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "节点组删除成功")
+}
@@ -4,19 +4,33 @@ import (
 	"flux-panel/dto"
 	"flux-panel/service"
 	"flux-panel/utils"
+	"io"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type NodeHandler struct {
-	service *service.NodeService
+	service          *service.NodeService
+	telemetryService *service.TelemetryService
+	probeService     *service.ProbeService
+	logService       *service.LogService
+	settingsService  *service.NodeSettingsService
+	commandService   *service.NodeCommandService
+	secretService    *service.NodeSecretService
 }
 
 func NewNodeHandler(db *gorm.DB) *NodeHandler {
 	return &NodeHandler{
-		service: service.NewNodeService(db),
+		service:          service.NewNodeService(db),
+		telemetryService: service.NewTelemetryService(db),
+		probeService:     service.NewProbeService(db),
+		logService:       service.NewLogService(db),
+		settingsService:  service.NewNodeSettingsService(db),
+		commandService:   service.NewNodeCommandService(db),
+		secretService:    service.NewNodeSecretService(db),
 	}
 }
 
@@ -28,7 +42,7 @@ func (h *NodeHandler) CreateNode(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CreateNode(&nodeDto); err != nil {
+	if err := h.service.CreateNode(currentActor(c), &nodeDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -55,7 +69,7 @@ func (h *NodeHandler) UpdateNode(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateNode(&updateDto); err != nil {
+	if err := h.service.UpdateNode(currentActor(c), &updateDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -87,7 +101,7 @@ func (h *NodeHandler) DeleteNode(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteNode(uint(id)); err != nil {
+	if err := h.service.DeleteNode(currentActor(c), uint(id)); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -148,6 +162,11 @@ func (h *NodeHandler) CheckNodeStatus(c *gin.Context) {
 	utils.Success(c, result)
 }
 
+// GetEnvelopeStats 获取各节点消息签名校验和重放拦截统计
+func (h *NodeHandler) GetEnvelopeStats(c *gin.Context) {
+	utils.Success(c, utils.GetEnvelopeStats())
+}
+
 func parseNodeID(val interface{}) uint {
 	if val == nil {
 		return 0
@@ -164,3 +183,201 @@ func parseNodeID(val interface{}) uint {
 		return 0
 	}
 }
+
+// GetTelemetry 获取节点扩展遥测历史
+func (h *NodeHandler) GetTelemetry(c *gin.Context) {
+	var query dto.TelemetryQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	history, err := h.telemetryService.GetHistory(&query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, history)
+}
+
+// Probe 从节点发起远程诊断 (TCP/UDP/HTTP/路由追踪/DNS)
+func (h *NodeHandler) Probe(c *gin.Context) {
+	var probeDto dto.NodeProbeDto
+	if err := c.ShouldBindJSON(&probeDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	result, err := h.probeService.Probe(&probeDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
+
+// GetLogs 获取节点内存中最近的日志
+func (h *NodeHandler) GetLogs(c *gin.Context) {
+	var query dto.NodeLogQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	logs, err := h.logService.GetLogs(&query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, logs)
+}
+
+// StreamLogs 以 SSE 实时推送节点日志，先推送 history 事件，之后每批新日志推送一个 logs 事件
+func (h *NodeHandler) StreamLogs(c *gin.Context) {
+	var query dto.NodeLogQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	tail, history, err := h.logService.StartTail(&query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	defer h.logService.StopTail(tail)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("history", history)
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case lines := <-tail.Lines:
+			c.SSEvent("logs", lines)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().UnixMilli())
+			return true
+		case <-tail.Done():
+			c.SSEvent("end", "日志推送已结束")
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// SetLogLevel 临时调整节点上单个服务的日志级别
+func (h *NodeHandler) SetLogLevel(c *gin.Context) {
+	var levelDto dto.NodeLogLevelDto
+	if err := c.ShouldBindJSON(&levelDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	levels, err := h.logService.SetLogLevel(&levelDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, levels)
+}
+
+// GetSettings 获取节点程序设置
+func (h *NodeHandler) GetSettings(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseID(req["nodeId"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	settings, err := h.settingsService.GetSettings(id)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, settings)
+}
+
+// UpdateSettings 更新并下发节点程序设置
+func (h *NodeHandler) UpdateSettings(c *gin.Context) {
+	var settingsDto dto.NodeSettingsDto
+	if err := c.ShouldBindJSON(&settingsDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	settings, err := h.settingsService.UpdateSettings(&settingsDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, settings)
+}
+
+// GetCommands 获取节点的命令队列，包括节点离线时排队等待下发的命令
+func (h *NodeHandler) GetCommands(c *gin.Context) {
+	var query dto.NodeCommandQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	commands, err := h.commandService.GetCommands(&query)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, commands)
+}
+
+// RotateSecret 轮换节点密钥，宽限期内新旧密钥均可用于认证
+func (h *NodeHandler) RotateSecret(c *gin.Context) {
+	var rotateDto dto.NodeSecretRotateDto
+	if err := c.ShouldBindJSON(&rotateDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	rotation, err := h.secretService.RotateSecret(currentActor(c), &rotateDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, rotation)
+}
+
+// GetSecretRotations 获取节点的密钥轮换记录
+func (h *NodeHandler) GetSecretRotations(c *gin.Context) {
+	var query dto.NodeSecretRotationQueryDto
+	if err := c.ShouldBindJSON(&query); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	rotations, err := h.secretService.GetRotations(query.NodeID)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, rotations)
+}
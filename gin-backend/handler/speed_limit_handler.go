@@ -29,7 +29,7 @@ func (h *SpeedLimitHandler) CreateSpeedLimit(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CreateSpeedLimit(&limitDto); err != nil {
+	if err := h.service.CreateSpeedLimit(currentActor(c), &limitDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -55,7 +55,7 @@ func (h *SpeedLimitHandler) UpdateSpeedLimit(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateSpeedLimit(&updateDto); err != nil {
+	if err := h.service.UpdateSpeedLimit(currentActor(c), &updateDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -77,7 +77,7 @@ func (h *SpeedLimitHandler) DeleteSpeedLimit(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteSpeedLimit(id); err != nil {
+	if err := h.service.DeleteSpeedLimit(currentActor(c), id); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
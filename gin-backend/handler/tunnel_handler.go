@@ -11,12 +11,14 @@ import (
 )
 
 type TunnelHandler struct {
-	service *service.TunnelService
+	service          *service.TunnelService
+	bandwidthService *service.BandwidthService
 }
 
 func NewTunnelHandler(db *gorm.DB) *TunnelHandler {
 	return &TunnelHandler{
-		service: service.NewTunnelService(db),
+		service:          service.NewTunnelService(db),
+		bandwidthService: service.NewBandwidthService(db),
 	}
 }
 
@@ -28,7 +30,7 @@ func (h *TunnelHandler) CreateTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CreateTunnel(&tunnelDto); err != nil {
+	if err := h.service.CreateTunnel(currentActor(c), &tunnelDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -55,7 +57,7 @@ func (h *TunnelHandler) UpdateTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateTunnel(&updateDto); err != nil {
+	if err := h.service.UpdateTunnel(currentActor(c), &updateDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -87,7 +89,7 @@ func (h *TunnelHandler) DeleteTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteTunnel(uint(id)); err != nil {
+	if err := h.service.DeleteTunnel(currentActor(c), uint(id)); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -103,7 +105,7 @@ func (h *TunnelHandler) AssignUserTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.AssignUserTunnel(&assignDto); err != nil {
+	if err := h.service.AssignUserTunnel(currentActor(c), &assignDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -152,7 +154,7 @@ func (h *TunnelHandler) RemoveUserTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.RemoveUserTunnel(uint(id)); err != nil {
+	if err := h.service.RemoveUserTunnel(currentActor(c), uint(id)); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -168,7 +170,7 @@ func (h *TunnelHandler) UpdateUserTunnel(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateUserTunnel(&updateDto); err != nil {
+	if err := h.service.UpdateUserTunnel(currentActor(c), &updateDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -262,7 +264,8 @@ func (h *TunnelHandler) DiagnoseTunnel(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.DiagnoseTunnel(id)
+	trace, _ := req["trace"].(bool)
+	result, err := h.service.DiagnoseTunnel(id, trace)
 	if err != nil {
 		utils.Error(c, err.Error())
 		return
@@ -270,3 +273,43 @@ func (h *TunnelHandler) DiagnoseTunnel(c *gin.Context) {
 
 	utils.Success(c, result)
 }
+
+// BandwidthTest 在隧道入口与出口节点之间执行带宽测试
+func (h *TunnelHandler) BandwidthTest(c *gin.Context) {
+	var testDto dto.BandwidthTestDto
+	if err := c.ShouldBindJSON(&testDto); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	result, err := h.bandwidthService.RunTest(&testDto)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
+
+// GetBandwidthTests 获取隧道最近的带宽测试记录
+func (h *TunnelHandler) GetBandwidthTests(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	id := parseID(req["tunnelId"])
+	if id == 0 {
+		utils.Error(c, "参数错误")
+		return
+	}
+
+	tests, err := h.bandwidthService.GetTests(id)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	utils.Success(c, tests)
+}
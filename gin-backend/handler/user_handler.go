@@ -45,7 +45,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.CreateUser(&userDto); err != nil {
+	if err := h.service.CreateUser(currentActor(c), &userDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -72,7 +72,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdateUser(&updateDto); err != nil {
+	if err := h.service.UpdateUser(currentActor(c), &updateDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -104,7 +104,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteUser(uint(id)); err != nil {
+	if err := h.service.DeleteUser(currentActor(c), uint(id)); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -143,7 +143,7 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.UpdatePassword(uint(userID.(int)), &changeDto); err != nil {
+	if err := h.service.UpdatePassword(currentActor(c), uint(userID.(int)), &changeDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -159,7 +159,7 @@ func (h *UserHandler) ResetFlow(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ResetFlow(&resetDto); err != nil {
+	if err := h.service.ResetFlow(currentActor(c), &resetDto); err != nil {
 		utils.Error(c, err.Error())
 		return
 	}
@@ -175,7 +175,7 @@ func (h *UserHandler) ToggleUserStatus(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.ToggleUserStatus(&toggleDto)
+	user, err := h.service.ToggleUserStatus(currentActor(c), &toggleDto)
 	if err != nil {
 		utils.Error(c, err.Error())
 		return
@@ -183,3 +183,50 @@ func (h *UserHandler) ToggleUserStatus(c *gin.Context) {
 
 	utils.Success(c, user)
 }
+
+// ImportUsers 批量导入用户及隧道权限，支持 CSV 与 JSON，dryRun 时只返回校验报告
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	var req dto.BulkImportDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, "参数错误")
+		return
+	}
+	format, err := bulkFormat(req.Format)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	rows, err := decodeBulkUsers(format, req.Content)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	result, err := h.service.ImportUsers(currentActor(c), rows, req.DryRun)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	sendBulkImportResult(c, result)
+}
+
+// ExportUsers 导出用户及隧道权限，格式与批量导入相同，不包含密码，重新导入时生成初始密码
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	format, err := bindBulkExport(c)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+
+	rows, err := h.service.ExportUsers()
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	data, err := encodeBulkUsers(format, rows)
+	if err != nil {
+		utils.Error(c, err.Error())
+		return
+	}
+	sendBulkExport(c, "user", format, data)
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flux-panel/cluster"
 	"flux-panel/config"
 	"flux-panel/models"
 	"flux-panel/router"
+	"flux-panel/service"
 	"flux-panel/task"
+	"flux-panel/websocket"
 	"fmt"
 	"log"
 	"os"
@@ -16,11 +19,24 @@ import (
 )
 
 func main() {
+	// 命令行子命令，如 backup、restore
+	if len(os.Args) > 1 && runCommand(os.Args[1:]) {
+		return
+	}
+
 	// 加载配置
 	if err := config.InitConfig(); err != nil {
 		log.Fatalf("Failed to initialize config: %v", err)
 	}
 
+	// 初始化实例间总线，多实例部署时节点命令、浏览器推送和验证码状态经总线共享
+	if err := cluster.Init(config.AppConfig.Cluster); err != nil {
+		log.Fatalf("Failed to initialize cluster bus: %v", err)
+	}
+	if err := websocket.StartCluster(); err != nil {
+		log.Fatalf("Failed to start cluster routing: %v", err)
+	}
+
 	// 初始化数据库
 	if err := models.InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -31,6 +47,25 @@ func main() {
 		log.Fatalf("Failed to auto migrate database: %v", err)
 	}
 
+	// 运行实例已退出的升级任务已无法继续，标记为失败
+	if err := service.NewAgentService(models.DB).InterruptRollouts(); err != nil {
+		log.Printf("Failed to interrupt agent rollouts: %v", err)
+	}
+
+	// 节点上报的扩展遥测写入历史记录
+	websocket.SetTelemetryHandler(service.NewTelemetryService(models.DB).SaveReport)
+
+	// 节点上线、离线后同步入口域名的解析记录
+	websocket.SetNodeStatusHandler(service.NewDNSService(models.DB).OnNodeStatus)
+
+	// 节点使用轮换后的新密钥连接时确认轮换
+	websocket.SetAuthHandler(service.NewNodeSecretService(models.DB).OnAuthenticated)
+
+	// 执行实例已退出的带宽测试已无法继续，标记为失败
+	if err := service.NewBandwidthService(models.DB).InterruptTests(); err != nil {
+		log.Printf("Failed to interrupt bandwidth tests: %v", err)
+	}
+
 	// 初始化定时任务
 	task.InitScheduler(models.DB)
 
@@ -48,10 +83,18 @@ func main() {
 		port = "6365"
 	}
 
-	log.Printf("Starting server on port %s...", port)
-
 	// 优雅关闭
 	go func() {
+		certFile, keyFile := config.AppConfig.Server.TLSCert, config.AppConfig.Server.TLSKey
+		if certFile != "" && keyFile != "" {
+			log.Printf("Starting TLS server on port %s...", port)
+			if err := r.RunTLS(":"+port, certFile, keyFile); err != nil {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+			return
+		}
+
+		log.Printf("Starting server on port %s...", port)
 		if err := r.Run(":" + port); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -72,6 +115,11 @@ func main() {
 		log.Printf("Error closing database: %v", err)
 	}
 
+	// 关闭实例间总线
+	if err := cluster.Close(); err != nil {
+		log.Printf("Error closing cluster bus: %v", err)
+	}
+
 	// 给一些时间完成清理
 	time.Sleep(time.Second * 2)
 	fmt.Println("Server exited")
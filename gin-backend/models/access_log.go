@@ -0,0 +1,23 @@
+package models
+
+// AccessLog 连接日志模型
+type AccessLog struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	ForwardID   uint   `gorm:"column:forward_id;index" json:"forwardId"`
+	UserID      int    `gorm:"column:user_id;index" json:"userId"`
+	NodeID      uint   `gorm:"column:node_id" json:"nodeId"`
+	Network     string `gorm:"column:network;type:varchar(10)" json:"network"`
+	ClientIP    string `gorm:"column:client_ip;type:varchar(64);index" json:"clientIp"`
+	Target      string `gorm:"column:target;type:varchar(255)" json:"target"` // 实际连接的目标地址
+	InBytes     int64  `gorm:"column:in_bytes;default:0" json:"inBytes"`
+	OutBytes    int64  `gorm:"column:out_bytes;default:0" json:"outBytes"`
+	Err         string `gorm:"column:err;type:varchar(255)" json:"err"`
+	StartTime   int64  `gorm:"column:start_time;index" json:"startTime"` // 连接建立时间戳（毫秒）
+	EndTime     int64  `gorm:"column:end_time" json:"endTime"`           // 连接关闭时间戳（毫秒）
+	CreatedTime int64  `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+}
+
+// TableName 指定表名
+func (AccessLog) TableName() string {
+	return "access_log"
+}
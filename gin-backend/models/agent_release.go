@@ -0,0 +1,43 @@
+package models
+
+// AgentRelease 节点程序发布包，每个版本按 GOOS/GOARCH 各登记一个
+type AgentRelease struct {
+	BaseModel
+	Version   string `gorm:"column:version;type:varchar(50);not null;index" json:"version"`
+	OS        string `gorm:"column:os;type:varchar(20);not null" json:"os"`
+	Arch      string `gorm:"column:arch;type:varchar(20);not null" json:"arch"`
+	URL       string `gorm:"column:url;type:varchar(500);not null" json:"url"`             // 二进制下载地址
+	SHA256    string `gorm:"column:sha256;type:varchar(64);not null" json:"sha256"`        // 二进制文件的 sha256（hex）
+	Signature string `gorm:"column:signature;type:varchar(255);not null" json:"signature"` // 对清单 "version|os|arch|sha256" 的 ed25519 签名（base64）
+	Remark    string `gorm:"column:remark;type:varchar(255)" json:"remark"`
+}
+
+// TableName 指定表名
+func (AgentRelease) TableName() string {
+	return "agent_release"
+}
+
+// 升级发布状态
+const (
+	RolloutRunning   = 0
+	RolloutDone      = 1
+	RolloutFailed    = 2
+	RolloutCancelled = 3
+)
+
+// AgentRollout 分批升级任务，Status 取值见 Rollout* 常量
+type AgentRollout struct {
+	BaseModel
+	Version   string `gorm:"column:version;type:varchar(50);not null" json:"version"`
+	NodeIDs   string `gorm:"column:node_ids;type:text" json:"nodeIds"` // 逗号分隔的节点ID，按升级顺序排列
+	BatchSize int    `gorm:"column:batch_size" json:"batchSize"`
+	Timeout   int    `gorm:"column:timeout" json:"timeout"` // 单个节点重连期限（秒）
+	Succeeded int    `gorm:"column:succeeded;default:0" json:"succeeded"`
+	Failed    int    `gorm:"column:failed;default:0" json:"failed"`
+	Message   string `gorm:"column:message;type:varchar(500)" json:"message"`
+}
+
+// TableName 指定表名
+func (AgentRollout) TableName() string {
+	return "agent_rollout"
+}
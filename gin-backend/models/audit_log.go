@@ -0,0 +1,22 @@
+package models
+
+// AuditLog 审计日志，只追加不修改，仅按保留策略清理
+type AuditLog struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	ActorID     int    `gorm:"column:actor_id;index" json:"actorId"` // 0 且 actorName 为 system 时表示系统操作
+	ActorName   string `gorm:"column:actor_name;type:varchar(100)" json:"actorName"`
+	IP          string `gorm:"column:ip;type:varchar(64)" json:"ip"`
+	Action      string `gorm:"column:action;type:varchar(50);index" json:"action"` // 如 tunnel.create、forward.pause
+	TargetType  string `gorm:"column:target_type;type:varchar(30);index:idx_audit_target" json:"targetType"`
+	TargetID    uint   `gorm:"column:target_id;index:idx_audit_target" json:"targetId"`
+	TargetName  string `gorm:"column:target_name;type:varchar(255)" json:"targetName"`
+	Diff        string `gorm:"column:diff;type:text" json:"diff"`               // JSON: {字段: {before, after}}
+	Success     bool   `gorm:"column:success" json:"success"`                   // 操作结果
+	Message     string `gorm:"column:message;type:varchar(500)" json:"message"` // 失败原因或附加说明
+	CreatedTime int64  `gorm:"column:created_time;autoCreateTime:milli;index" json:"createdTime"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,39 @@
+package models
+
+// 带宽测试状态
+const (
+	BandwidthTestRunning = 0
+	BandwidthTestDone    = 1
+	BandwidthTestFailed  = 2
+)
+
+// BandwidthTest 隧道入口与出口节点之间的带宽测试记录
+type BandwidthTest struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	TunnelID    uint    `gorm:"column:tunnel_id;index" json:"tunnelId"`
+	InNodeID    uint    `gorm:"column:in_node_id" json:"inNodeId"`
+	OutNodeID   uint    `gorm:"column:out_node_id" json:"outNodeId"`
+	Protocol    string  `gorm:"column:protocol;type:varchar(50)" json:"protocol"`   // 测试使用的隧道协议
+	Mode        string  `gorm:"column:mode;type:varchar(10)" json:"mode"`           // tcp, udp
+	Direction   string  `gorm:"column:direction;type:varchar(10)" json:"direction"` // upload: 入口->出口, download: 出口->入口
+	Duration    int     `gorm:"column:duration" json:"duration"`                    // 测试时长(秒)
+	Bandwidth   int     `gorm:"column:bandwidth" json:"bandwidth"`                  // UDP 发送速率(Mbps)
+	State       int     `gorm:"column:state;default:0" json:"state"`                // 0 进行中, 1 完成, 2 失败
+	Message     string  `gorm:"column:message;type:varchar(500)" json:"message"`
+	Latency     float64 `gorm:"column:latency" json:"latency"`          // 经隧道的往返时延(ms)
+	Throughput  float64 `gorm:"column:throughput" json:"throughput"`    // 接收端测得的吞吐(bit/s)
+	ClientBytes int64   `gorm:"column:client_bytes" json:"clientBytes"` // 入口节点收发的字节数
+	ClientBps   float64 `gorm:"column:client_bps" json:"clientBps"`     // 入口节点测得的速率(bit/s)
+	ServerBytes int64   `gorm:"column:server_bytes" json:"serverBytes"` // 出口节点接收的字节数
+	ServerBps   float64 `gorm:"column:server_bps" json:"serverBps"`     // 出口节点测得的速率(bit/s)
+	Sent        int64   `gorm:"column:sent" json:"sent"`                // UDP 发送报文数
+	Received    int64   `gorm:"column:received" json:"received"`        // UDP 接收报文数
+	PacketLoss  float64 `gorm:"column:packet_loss" json:"packetLoss"`   // UDP 丢包率(%)
+	Jitter      float64 `gorm:"column:jitter" json:"jitter"`            // UDP 抖动(ms)
+	CreatedTime int64   `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+}
+
+// TableName 指定表名
+func (BandwidthTest) TableName() string {
+	return "tunnel_bandwidth_test"
+}
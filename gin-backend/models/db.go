@@ -64,6 +64,19 @@ func AutoMigrate() error {
 		&UserTunnel{},
 		&StatisticsFlow{},
 		&ViteConfig{},
+		&AccessLog{},
+		&AgentRelease{},
+		&AgentRollout{},
+		&NodeTelemetry{},
+		&BandwidthTest{},
+		&NodeSettings{},
+		&NodeCommand{},
+		&NodeGroup{},
+		&NodeGroupMember{},
+		&DNSProvider{},
+		&DNSRecord{},
+		&NodeSecretRotation{},
+		&AuditLog{},
 	)
 }
 
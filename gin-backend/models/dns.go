@@ -0,0 +1,34 @@
+package models
+
+// DNSProvider DNS 服务商，用于维护隧道入口域名的解析记录
+type DNSProvider struct {
+	ID          uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string            `gorm:"column:name;type:varchar(100);not null;uniqueIndex" json:"name"`
+	Type        string            `gorm:"column:type;type:varchar(20);not null" json:"type"` // cloudflare, rfc2136, file
+	Config      string            `gorm:"column:config;type:text" json:"-"`                  // 服务商配置 (JSON)
+	TTL         int               `gorm:"column:ttl;default:60" json:"ttl"`                  // 记录TTL(秒)
+	CreatedTime int64             `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+	UpdatedTime int64             `gorm:"column:updated_time;autoUpdateTime:milli" json:"updatedTime"`
+	ConfigMap   map[string]string `gorm:"-" json:"config"` // 返回给前端的配置，密钥已脱敏
+}
+
+// TableName 指定表名
+func (DNSProvider) TableName() string {
+	return "dns_provider"
+}
+
+// DNSRecord 隧道入口域名最近一次同步的解析结果
+type DNSRecord struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TunnelID   uint   `gorm:"column:tunnel_id;uniqueIndex" json:"tunnelId"`
+	ProviderID uint   `gorm:"column:provider_id;index" json:"providerId"`
+	Hostname   string `gorm:"column:hostname;type:varchar(255)" json:"hostname"`
+	IPs        string `gorm:"column:ips;type:varchar(1024)" json:"ips"`     // 当前解析到的IP，逗号分隔
+	Error      string `gorm:"column:error;type:varchar(1024)" json:"error"` // 最近一次同步失败的原因，成功时为空
+	SyncedTime int64  `gorm:"column:synced_time" json:"syncedTime"`
+}
+
+// TableName 指定表名
+func (DNSRecord) TableName() string {
+	return "dns_record"
+}
@@ -7,16 +7,31 @@ type Forward struct {
 	UserName      string `gorm:"column:user_name;type:varchar(100)" json:"userName"`
 	Name          string `gorm:"column:name;type:varchar(100)" json:"name"`
 	TunnelID      int    `gorm:"column:tunnel_id" json:"tunnelId"`
+	InNodeID      uint   `gorm:"column:in_node_id;default:0" json:"inNodeId"` // 入口为节点组时部署的成员节点，0 表示部署到所有成员
 	InPort        int    `gorm:"column:in_port" json:"inPort"`
 	OutPort       int    `gorm:"column:out_port" json:"outPort"`
+	PortCount     int    `gorm:"column:port_count;default:1" json:"portCount"` // 端口段转发占用的连续端口数量
 	RemoteAddr    string `gorm:"column:remote_addr;type:varchar(255)" json:"remoteAddr"`
 	InterfaceName string `gorm:"column:interface_name;type:varchar(100)" json:"interfaceName"`
 	Strategy      string `gorm:"column:strategy;type:varchar(50)" json:"strategy"`
+	IPPreference  string `gorm:"column:ip_preference;type:varchar(10)" json:"ipPreference"` // 目标地址解析优先级: ipv4, ipv6，仅在隧道配置了解析器时生效
 	InFlow        int64  `gorm:"column:in_flow;default:0" json:"inFlow"`
 	OutFlow       int64  `gorm:"column:out_flow;default:0" json:"outFlow"`
 	Inx           int    `gorm:"column:inx" json:"inx"`
+	ConnLimitHits int64  `gorm:"column:conn_limit_hits;default:0" json:"connLimitHits"` // 并发连接数限制命中次数
+	RateLimitHits int64  `gorm:"column:rate_limit_hits;default:0" json:"rateLimitHits"` // 新建连接速率限制命中次数
+	AccessLog     int    `gorm:"column:access_log;default:0" json:"accessLog"`          // 是否记录连接日志: 0 关闭, 1 开启
 	TunnelName    string `gorm:"-" json:"tunnelName"`
 	InIP          string `gorm:"-" json:"inIp"`
+	Pending       int    `gorm:"-" json:"pendingCommands"` // 等待节点上线后下发的命令数量
+}
+
+// Ports 返回转发占用的端口数量 (单端口转发为1)
+func (f *Forward) Ports() int {
+	if f.PortCount < 1 {
+		return 1
+	}
+	return f.PortCount
 }
 
 // TableName 指定表名
@@ -8,11 +8,28 @@ type Node struct {
 	IP       string `gorm:"column:ip;type:varchar(100)" json:"ip"`
 	ServerIP string `gorm:"column:server_ip;type:varchar(100)" json:"serverIp"`
 	Version  string `gorm:"column:version;type:varchar(50)" json:"version"`
+	OS       string `gorm:"column:os;type:varchar(20)" json:"os"`
+	Arch     string `gorm:"column:arch;type:varchar(20)" json:"arch"`
 	PortSta  int    `gorm:"column:port_sta" json:"portSta"`      // 端口起始
 	PortEnd  int    `gorm:"column:port_end" json:"portEnd"`      // 端口结束
 	HTTP     int    `gorm:"column:http;default:0" json:"http"`   // HTTP端口
 	TLS      int    `gorm:"column:tls;default:0" json:"tls"`     // TLS端口
 	Socks    int    `gorm:"column:socks;default:0" json:"socks"` // Socks端口
+
+	// 节点握手时声明的命令协议版本、支持的命令和能力（逗号分隔），旧版本节点协议版本为 0
+	ProtocolVersion int    `gorm:"column:protocol_version;default:0" json:"protocolVersion"`
+	Commands        string `gorm:"column:commands;type:text" json:"commands"`
+	Features        string `gorm:"column:features;type:varchar(255)" json:"features"`
+
+	// 密钥轮换后、节点使用新密钥连接前，轮换前的密钥在 PrevSecretExpire (毫秒时间戳) 之前仍可用于认证
+	PrevSecret       string `gorm:"column:prev_secret;type:varchar(255)" json:"-"`
+	PrevSecretExpire int64  `gorm:"column:prev_secret_expire;default:0" json:"prevSecretExpire"`
+
+	// 节点发送过签名信封后置为 true，此后不再接受该节点的旧格式消息，防止降级
+	SignedEnvelope bool `gorm:"column:signed_envelope;default:false" json:"signedEnvelope"`
+
+	Compatibility   string   `gorm:"-" json:"compatibility"`             // 兼容状态，查询时计算
+	MissingCommands []string `gorm:"-" json:"missingCommands,omitempty"` // 节点不支持的面板命令
 }
 
 // TableName 指定表名
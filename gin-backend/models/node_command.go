@@ -0,0 +1,29 @@
+package models
+
+// 节点命令状态
+const (
+	NodeCommandPending    = 0 // 节点离线或前序命令未完成，等待节点上线后按序下发
+	NodeCommandApplied    = 1
+	NodeCommandFailed     = 2
+	NodeCommandSuperseded = 3 // 下发前已被同一配置的后续操作取代
+)
+
+// NodeCommand 下发给节点的配置命令，节点离线时保存在队列中，上线后按序重放
+type NodeCommand struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	NodeID      uint   `gorm:"column:node_id;index:idx_node_command_state" json:"nodeId"`
+	ForwardID   uint   `gorm:"column:forward_id;index" json:"forwardId"`          // 命令所属转发，与转发无关时为 0
+	Type        string `gorm:"column:type;type:varchar(50)" json:"type"`          // 消息类型，如 PauseService
+	Resource    string `gorm:"column:resource;type:varchar(150)" json:"resource"` // 命令作用的配置，如 service:1_2_3，用于合并被取代的命令
+	Payload     string `gorm:"column:payload;type:text" json:"-"`
+	State       int    `gorm:"column:state;default:0;index:idx_node_command_state" json:"state"` // 0 待下发, 1 已生效, 2 下发失败, 3 已被取代
+	Message     string `gorm:"column:message;type:varchar(500)" json:"message"`
+	Attempts    int    `gorm:"column:attempts;default:0" json:"attempts"`
+	CreatedTime int64  `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+	UpdatedTime int64  `gorm:"column:updated_time;autoUpdateTime:milli" json:"updatedTime"`
+}
+
+// TableName 指定表名
+func (NodeCommand) TableName() string {
+	return "node_command"
+}
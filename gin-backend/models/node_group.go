@@ -0,0 +1,30 @@
+package models
+
+// NodeGroup 节点组，隧道可以指定节点组作为入口或出口
+// 入口组的每个成员都部署转发（或由转发指定其中一个成员），出口组的成员在转发链中轮询负载均衡
+type NodeGroup struct {
+	ID          uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string   `gorm:"column:name;type:varchar(100);not null;uniqueIndex" json:"name"`
+	Tags        string   `gorm:"column:tags;type:varchar(255)" json:"-"` // 标签，逗号分隔
+	CreatedTime int64    `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+	UpdatedTime int64    `gorm:"column:updated_time;autoUpdateTime:milli" json:"updatedTime"`
+	TagList     []string `gorm:"-" json:"tags"`
+	NodeIDs     []uint   `gorm:"-" json:"nodeIds"`
+}
+
+// TableName 指定表名
+func (NodeGroup) TableName() string {
+	return "node_group"
+}
+
+// NodeGroupMember 节点组成员，一个节点可以属于多个节点组
+type NodeGroupMember struct {
+	ID      uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	GroupID uint `gorm:"column:group_id;uniqueIndex:idx_node_group_member" json:"groupId"`
+	NodeID  uint `gorm:"column:node_id;uniqueIndex:idx_node_group_member;index" json:"nodeId"`
+}
+
+// TableName 指定表名
+func (NodeGroupMember) TableName() string {
+	return "node_group_member"
+}
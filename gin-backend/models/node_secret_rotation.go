@@ -0,0 +1,25 @@
+package models
+
+// 节点密钥轮换状态
+const (
+	NodeSecretRotationPending = 0 // 新密钥已下发但节点未确认，节点使用新密钥连接后确认
+	NodeSecretRotationApplied = 1
+	NodeSecretRotationFailed  = 2 // 节点拒绝或未能确认新密钥，已恢复旧密钥
+)
+
+// NodeSecretRotation 节点密钥轮换记录
+type NodeSecretRotation struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	NodeID      uint   `gorm:"column:node_id;index" json:"nodeId"`
+	OperatorID  int    `gorm:"column:operator_id" json:"operatorId"` // 发起轮换的管理员
+	State       int    `gorm:"column:state;default:0" json:"state"`  // 0 待确认, 1 已生效, 2 已失败
+	Message     string `gorm:"column:message;type:varchar(500)" json:"message"`
+	GraceUntil  int64  `gorm:"column:grace_until" json:"graceUntil"` // 旧密钥失效时间
+	CreatedTime int64  `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+	UpdatedTime int64  `gorm:"column:updated_time;autoUpdateTime:milli" json:"updatedTime"`
+}
+
+// TableName 指定表名
+func (NodeSecretRotation) TableName() string {
+	return "node_secret_rotation"
+}
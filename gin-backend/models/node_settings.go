@@ -0,0 +1,35 @@
+package models
+
+// 节点设置下发状态
+const (
+	NodeSettingsPending = 0 // 已保存，等待节点上线后下发
+	NodeSettingsApplied = 1
+	NodeSettingsFailed  = 2
+)
+
+// NodeSettings 面板管理的节点程序设置，协议屏蔽开关保存在节点表中
+type NodeSettings struct {
+	ID             uint     `gorm:"primaryKey" json:"id"`
+	NodeID         uint     `gorm:"column:node_id;uniqueIndex" json:"nodeId"`
+	Addrs          string   `gorm:"column:addrs;type:text" json:"-"`                   // 面板地址列表（逗号分隔）
+	AddrList       []string `gorm:"-" json:"addrs"`                                    // 面板地址列表，连接失败时依次切换
+	PingInterval   int      `gorm:"column:ping_interval" json:"pingInterval"`          // 系统信息上报间隔(秒)，0 为默认 2 秒
+	ConfigInterval int      `gorm:"column:config_interval" json:"configInterval"`      // 配置上报间隔(秒)，0 为默认 600 秒
+	ObserverPeriod int      `gorm:"column:observer_period" json:"observerPeriod"`      // 流量统计上报周期(秒)，0 为默认 5 秒
+	LogLevel       string   `gorm:"column:log_level;type:varchar(10)" json:"logLevel"` // 为空时沿用节点配置
+	MetricsAddr    string   `gorm:"column:metrics_addr;type:varchar(100)" json:"metricsAddr"`
+	MetricsPath    string   `gorm:"column:metrics_path;type:varchar(100)" json:"metricsPath"`
+	APIAddr        string   `gorm:"column:api_addr;type:varchar(100)" json:"apiAddr"`
+	APIPath        string   `gorm:"column:api_path;type:varchar(100)" json:"apiPath"`
+	APIUsername    string   `gorm:"column:api_username;type:varchar(100)" json:"apiUsername"`
+	APIPassword    string   `gorm:"column:api_password;type:varchar(100)" json:"apiPassword"`
+	State          int      `gorm:"column:state;default:0" json:"state"` // 0 待下发, 1 已生效, 2 下发失败
+	Message        string   `gorm:"column:message;type:varchar(500)" json:"message"`
+	AppliedTime    int64    `gorm:"column:applied_time" json:"appliedTime"`
+	UpdatedTime    int64    `gorm:"column:updated_time;autoUpdateTime:milli" json:"updatedTime"`
+}
+
+// TableName 指定表名
+func (NodeSettings) TableName() string {
+	return "node_settings"
+}
@@ -0,0 +1,26 @@
+package models
+
+// NodeTelemetry 节点扩展遥测记录
+type NodeTelemetry struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	NodeID         uint    `gorm:"column:node_id;index:idx_node_telemetry_node_time" json:"nodeId"`
+	Version        int     `gorm:"column:version" json:"version"` // 遥测消息格式版本
+	Load1          float64 `gorm:"column:load1" json:"load1"`
+	Load5          float64 `gorm:"column:load5" json:"load5"`
+	Load15         float64 `gorm:"column:load15" json:"load15"`
+	Disks          string  `gorm:"column:disks;type:text" json:"-"`      // 分区使用情况（JSON）
+	TCPStates      string  `gorm:"column:tcp_states;type:text" json:"-"` // 按状态统计的 TCP 连接数（JSON）
+	Interfaces     string  `gorm:"column:interfaces;type:text" json:"-"` // 各网卡累计流量（JSON）
+	TCPTotal       int     `gorm:"column:tcp_total" json:"tcpTotal"`     // TCP 连接总数
+	ConntrackCount int64   `gorm:"column:conntrack_count" json:"conntrackCount"`
+	ConntrackMax   int64   `gorm:"column:conntrack_max" json:"conntrackMax"`
+	FDOpen         int32   `gorm:"column:fd_open" json:"fdOpen"`
+	FDLimit        uint64  `gorm:"column:fd_limit" json:"fdLimit"`
+	CollectedTime  int64   `gorm:"column:collected_time;index:idx_node_telemetry_node_time" json:"collectedTime"` // 节点采集时间戳（毫秒）
+	CreatedTime    int64   `gorm:"column:created_time;autoCreateTime:milli" json:"createdTime"`
+}
+
+// TableName 指定表名
+func (NodeTelemetry) TableName() string {
+	return "node_telemetry"
+}
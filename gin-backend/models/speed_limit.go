@@ -3,11 +3,17 @@ package models
 // SpeedLimit 限速模型
 type SpeedLimit struct {
 	BaseModel
-	Name       string `gorm:"column:name;type:varchar(100)" json:"name"`
-	Speed      int    `gorm:"column:speed" json:"speed"`
-	TunnelID   int64  `gorm:"column:tunnel_id" json:"tunnelId"`
-	TunnelName string `gorm:"column:tunnel_name;type:varchar(100)" json:"tunnelName"`
-	Status     int    `gorm:"column:status;default:0" json:"status"` // 0: 正常, 1: 删除
+	Name         string `gorm:"column:name;type:varchar(100)" json:"name"`
+	Speed        int    `gorm:"column:speed" json:"speed"`                           // 默认速率 (MB/s)，上下行未单独设置时使用
+	InSpeed      int    `gorm:"column:in_speed;default:0" json:"inSpeed"`            // 上行速率 (MB/s)
+	OutSpeed     int    `gorm:"column:out_speed;default:0" json:"outSpeed"`          // 下行速率 (MB/s)
+	InBurst      int    `gorm:"column:in_burst;default:0" json:"inBurst"`            // 上行突发 (MB)
+	OutBurst     int    `gorm:"column:out_burst;default:0" json:"outBurst"`          // 下行突发 (MB)
+	ConnInSpeed  int    `gorm:"column:conn_in_speed;default:0" json:"connInSpeed"`   // 单连接上行速率 (MB/s)
+	ConnOutSpeed int    `gorm:"column:conn_out_speed;default:0" json:"connOutSpeed"` // 单连接下行速率 (MB/s)
+	TunnelID     int64  `gorm:"column:tunnel_id" json:"tunnelId"`
+	TunnelName   string `gorm:"column:tunnel_name;type:varchar(100)" json:"tunnelName"`
+	Status       int    `gorm:"column:status;default:0" json:"status"` // 0: 正常, 1: 删除
 }
 
 // TableName 指定表名
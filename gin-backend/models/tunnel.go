@@ -5,9 +5,11 @@ type Tunnel struct {
 	BaseModel
 	Name          string  `gorm:"column:name;type:varchar(100);not null" json:"name"`
 	InNodeID      uint    `gorm:"column:in_node_id" json:"inNodeId"`            // 入口节点ID
-	InIP          string  `gorm:"column:in_ip;type:varchar(100)" json:"inIp"`   // 入口IP (兼容)
+	InIP          string  `gorm:"column:in_ip;type:varchar(255)" json:"inIp"`   // 入口IP (兼容)，入口为节点组时为全部成员的IP
 	OutNodeID     uint    `gorm:"column:out_node_id" json:"outNodeId"`          // 出口节点ID
-	OutIP         string  `gorm:"column:out_ip;type:varchar(100)" json:"outIp"` // 出口IP (兼容)
+	OutIP         string  `gorm:"column:out_ip;type:varchar(255)" json:"outIp"` // 出口IP (兼容)，出口为节点组时为全部成员的IP
+	InGroupID     uint    `gorm:"column:in_group_id" json:"inGroupId"`          // 入口节点组ID，指定后忽略入口节点
+	OutGroupID    uint    `gorm:"column:out_group_id" json:"outGroupId"`        // 出口节点组ID，指定后忽略出口节点
 	Type          int     `gorm:"column:type;default:1" json:"type"`            // 1: 端口转发, 2: 隧道转发
 	Flow          int     `gorm:"column:flow;default:2" json:"flow"`            // 1: 单向上传, 2: 双向
 	Protocol      string  `gorm:"column:protocol;type:varchar(50)" json:"protocol"`
@@ -15,6 +17,24 @@ type Tunnel struct {
 	TCPListenAddr string  `gorm:"column:tcp_listen_addr;type:varchar(255)" json:"tcpListenAddr"`
 	UDPListenAddr string  `gorm:"column:udp_listen_addr;type:varchar(255)" json:"udpListenAddr"`
 	InterfaceName string  `gorm:"column:interface_name;type:varchar(100)" json:"interfaceName"`
+	ResolverType  string  `gorm:"column:resolver_type;type:varchar(20)" json:"resolverType"`  // 目标域名解析方式: system(默认), dns, doh, dot
+	ResolverAddr  string  `gorm:"column:resolver_addr;type:varchar(255)" json:"resolverAddr"` // 解析服务器地址
+	ResolverTTL   int     `gorm:"column:resolver_ttl;default:0" json:"resolverTtl"`           // 重新解析间隔(秒)，0表示按记录TTL
+	Hostname      string  `gorm:"column:hostname;type:varchar(255)" json:"hostname"`          // 入口域名，配置后由 DNS 服务商解析到在线的入口节点
+	DNSProviderID uint    `gorm:"column:dns_provider_id" json:"dnsProviderId"`                // 管理入口域名的 DNS 服务商ID
+}
+
+// UsesResolver 是否为目标地址配置了独立的解析器
+func (t *Tunnel) UsesResolver() bool {
+	return t.ResolverType != "" && t.ResolverType != "system" && t.ResolverAddr != ""
+}
+
+// TargetNodeID 返回连接转发目标的节点 (端口转发为入口节点，隧道转发为出口节点)
+func (t *Tunnel) TargetNodeID() uint {
+	if t.Type == 2 {
+		return t.OutNodeID
+	}
+	return t.InNodeID
 }
 
 // TableName 指定表名
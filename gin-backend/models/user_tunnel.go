@@ -5,13 +5,17 @@ type UserTunnel struct {
 	BaseModel
 	UserID        uint  `gorm:"column:user_id;not null;index:idx_user_tunnel" json:"userId"`
 	TunnelID      uint  `gorm:"column:tunnel_id;not null;index:idx_user_tunnel" json:"tunnelId"`
-	ExpTime       int64 `gorm:"column:exp_time" json:"expTime"`              // 到期时间
-	Flow          int64 `gorm:"column:flow" json:"flow"`                     // 总流量
-	InFlow        int64 `gorm:"column:in_flow;default:0" json:"inFlow"`      // 已用入流量
-	OutFlow       int64 `gorm:"column:out_flow;default:0" json:"outFlow"`    // 已用出流量
-	FlowResetTime int64 `gorm:"column:flow_reset_time" json:"flowResetTime"` // 流量重置时间
-	Num           int   `gorm:"column:num;default:0" json:"num"`             // 转发数量限制
-	SpeedID       int   `gorm:"column:speed_id" json:"speedId"`              // 限速ID
+	ExpTime       int64 `gorm:"column:exp_time" json:"expTime"`                    // 到期时间
+	Flow          int64 `gorm:"column:flow" json:"flow"`                           // 总流量
+	InFlow        int64 `gorm:"column:in_flow;default:0" json:"inFlow"`            // 已用入流量
+	OutFlow       int64 `gorm:"column:out_flow;default:0" json:"outFlow"`          // 已用出流量
+	FlowResetTime int64 `gorm:"column:flow_reset_time" json:"flowResetTime"`       // 流量重置时间
+	Num           int   `gorm:"column:num;default:0" json:"num"`                   // 转发数量限制
+	SpeedID       int   `gorm:"column:speed_id" json:"speedId"`                    // 限速ID
+	ConnLimit     int   `gorm:"column:conn_limit;default:0" json:"connLimit"`      // 单转发最大并发连接数
+	IPConnLimit   int   `gorm:"column:ip_conn_limit;default:0" json:"ipConnLimit"` // 单来源IP最大并发连接数
+	ConnRate      int   `gorm:"column:conn_rate;default:0" json:"connRate"`        // 单转发每秒新建连接数
+	IPConnRate    int   `gorm:"column:ip_conn_rate;default:0" json:"ipConnRate"`   // 单来源IP每秒新建连接数
 }
 
 // TableName 指定表名
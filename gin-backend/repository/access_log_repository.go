@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type AccessLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessLogRepository(db *gorm.DB) *AccessLogRepository {
+	return &AccessLogRepository{db: db}
+}
+
+func (r *AccessLogRepository) CreateBatch(logs []models.AccessLog) error {
+	return r.db.CreateInBatches(logs, 500).Error
+}
+
+// filter 构造查询条件，userID 为 nil 时不按用户过滤
+func (r *AccessLogRepository) filter(userID *int, query *dto.AccessLogQueryDto) *gorm.DB {
+	db := r.db.Model(&models.AccessLog{})
+	if userID != nil {
+		db = db.Where("user_id = ?", *userID)
+	}
+	if query.ForwardID > 0 {
+		db = db.Where("forward_id = ?", query.ForwardID)
+	}
+	if query.ClientIP != "" {
+		db = db.Where("client_ip = ?", query.ClientIP)
+	}
+	if query.Target != "" {
+		db = db.Where("target LIKE ?", "%"+query.Target+"%")
+	}
+	if query.StartTime > 0 {
+		db = db.Where("start_time >= ?", query.StartTime)
+	}
+	if query.EndTime > 0 {
+		db = db.Where("start_time <= ?", query.EndTime)
+	}
+	if query.OnlyError {
+		db = db.Where("err <> ''")
+	}
+	return db
+}
+
+func (r *AccessLogRepository) Find(userID *int, query *dto.AccessLogQueryDto, offset, limit int) ([]models.AccessLog, int64, error) {
+	var logs []models.AccessLog
+	var total int64
+	if err := r.filter(userID, query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.filter(userID, query).Order("id DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
+
+func (r *AccessLogRepository) DeleteBefore(startTime int64) (int64, error) {
+	result := r.db.Where("start_time < ?", startTime).Delete(&models.AccessLog{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExceeding 只保留最新的 keep 条记录
+func (r *AccessLogRepository) DeleteExceeding(keep int) (int64, error) {
+	var boundary models.AccessLog
+	err := r.db.Select("id").Order("id DESC").Offset(keep).Limit(1).Take(&boundary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	result := r.db.Where("id <= ?", boundary.ID).Delete(&models.AccessLog{})
+	return result.RowsAffected, result.Error
+}
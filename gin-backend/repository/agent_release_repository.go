@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type AgentReleaseRepository struct {
+	db *gorm.DB
+}
+
+func NewAgentReleaseRepository(db *gorm.DB) *AgentReleaseRepository {
+	return &AgentReleaseRepository{db: db}
+}
+
+func (r *AgentReleaseRepository) Create(release *models.AgentRelease) error {
+	return r.db.Create(release).Error
+}
+
+// FindAll 获取所有发布包，新版本在前
+func (r *AgentReleaseRepository) FindAll() ([]models.AgentRelease, error) {
+	var releases []models.AgentRelease
+	err := r.db.Order("id DESC").Find(&releases).Error
+	return releases, err
+}
+
+// FindByVersion 获取某个版本的所有平台发布包
+func (r *AgentReleaseRepository) FindByVersion(version string) ([]models.AgentRelease, error) {
+	var releases []models.AgentRelease
+	err := r.db.Where("version = ?", version).Find(&releases).Error
+	return releases, err
+}
+
+// FindByPlatform 获取指定版本和平台的发布包
+func (r *AgentReleaseRepository) FindByPlatform(version, goos, goarch string) (*models.AgentRelease, error) {
+	var release models.AgentRelease
+	err := r.db.Where("version = ? AND os = ? AND arch = ?", version, goos, goarch).First(&release).Error
+	return &release, err
+}
+
+func (r *AgentReleaseRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AgentRelease{}, id).Error
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type AgentRolloutRepository struct {
+	db *gorm.DB
+}
+
+func NewAgentRolloutRepository(db *gorm.DB) *AgentRolloutRepository {
+	return &AgentRolloutRepository{db: db}
+}
+
+func (r *AgentRolloutRepository) Create(rollout *models.AgentRollout) error {
+	return r.db.Create(rollout).Error
+}
+
+func (r *AgentRolloutRepository) FindByID(id uint) (*models.AgentRollout, error) {
+	var rollout models.AgentRollout
+	err := r.db.Where("id = ?", id).First(&rollout).Error
+	return &rollout, err
+}
+
+// FindAll 获取所有升级任务，最新的在前
+func (r *AgentRolloutRepository) FindAll() ([]models.AgentRollout, error) {
+	var rollouts []models.AgentRollout
+	err := r.db.Order("id DESC").Find(&rollouts).Error
+	return rollouts, err
+}
+
+func (r *AgentRolloutRepository) Update(rollout *models.AgentRollout) error {
+	return r.db.Save(rollout).Error
+}
+
+// FindRunning 获取运行中的升级任务
+func (r *AgentRolloutRepository) FindRunning() ([]models.AgentRollout, error) {
+	var rollouts []models.AgentRollout
+	err := r.db.Where("status = ?", models.RolloutRunning).Find(&rollouts).Error
+	return rollouts, err
+}
+
+// Interrupt 将仍处于运行中的指定任务标记为结束
+func (r *AgentRolloutRepository) Interrupt(ids []uint, status int, message string) error {
+	return r.db.Model(&models.AgentRollout{}).
+		Where("id IN ? AND status = ?", ids, models.RolloutRunning).
+		Updates(map[string]interface{}{"status": status, "message": message}).Error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository 审计日志只提供追加和按保留策略清理，不提供修改或单条删除
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(auditLog *models.AuditLog) error {
+	return r.db.Create(auditLog).Error
+}
+
+func (r *AuditLogRepository) filter(query *dto.AuditLogQueryDto) *gorm.DB {
+	db := r.db.Model(&models.AuditLog{})
+	if query.ActorID != nil {
+		db = db.Where("actor_id = ?", *query.ActorID)
+	}
+	if query.Action != "" {
+		db = db.Where("action LIKE ?", query.Action+"%")
+	}
+	if query.TargetType != "" {
+		db = db.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID > 0 {
+		db = db.Where("target_id = ?", query.TargetID)
+	}
+	if query.Success != nil {
+		db = db.Where("success = ?", *query.Success)
+	}
+	if query.StartTime > 0 {
+		db = db.Where("created_time >= ?", query.StartTime)
+	}
+	if query.EndTime > 0 {
+		db = db.Where("created_time <= ?", query.EndTime)
+	}
+	return db
+}
+
+func (r *AuditLogRepository) Find(query *dto.AuditLogQueryDto, offset, limit int) ([]models.AuditLog, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+	if err := r.filter(query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.filter(query).Order("id DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
+
+func (r *AuditLogRepository) DeleteBefore(createdTime int64) (int64, error) {
+	result := r.db.Where("created_time < ?", createdTime).Delete(&models.AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExceeding 只保留最新的 keep 条记录
+func (r *AuditLogRepository) DeleteExceeding(keep int) (int64, error) {
+	var boundary models.AuditLog
+	err := r.db.Select("id").Order("id DESC").Offset(keep).Limit(1).Take(&boundary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	result := r.db.Where("id <= ?", boundary.ID).Delete(&models.AuditLog{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type BandwidthTestRepository struct {
+	db *gorm.DB
+}
+
+func NewBandwidthTestRepository(db *gorm.DB) *BandwidthTestRepository {
+	return &BandwidthTestRepository{db: db}
+}
+
+func (r *BandwidthTestRepository) Create(test *models.BandwidthTest) error {
+	return r.db.Create(test).Error
+}
+
+func (r *BandwidthTestRepository) Update(test *models.BandwidthTest) error {
+	return r.db.Save(test).Error
+}
+
+// FindByTunnel 按时间倒序获取隧道最近的测试记录
+func (r *BandwidthTestRepository) FindByTunnel(tunnelID uint, limit int) ([]models.BandwidthTest, error) {
+	var tests []models.BandwidthTest
+	err := r.db.Where("tunnel_id = ?", tunnelID).Order("id DESC").Limit(limit).Find(&tests).Error
+	return tests, err
+}
+
+func (r *BandwidthTestRepository) DeleteByTunnel(tunnelID uint) error {
+	return r.db.Where("tunnel_id = ?", tunnelID).Delete(&models.BandwidthTest{}).Error
+}
+
+// FindRunning 获取未完成的测试
+func (r *BandwidthTestRepository) FindRunning() ([]models.BandwidthTest, error) {
+	var tests []models.BandwidthTest
+	err := r.db.Where("state = ?", models.BandwidthTestRunning).Find(&tests).Error
+	return tests, err
+}
+
+// Interrupt 将仍未完成的指定测试标记为失败
+func (r *BandwidthTestRepository) Interrupt(ids []uint, message string) error {
+	return r.db.Model(&models.BandwidthTest{}).
+		Where("id IN ? AND state = ?", ids, models.BandwidthTestRunning).
+		Updates(map[string]interface{}{"state": models.BandwidthTestFailed, "message": message}).Error
+}
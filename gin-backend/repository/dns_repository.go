@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type DNSRepository struct {
+	db *gorm.DB
+}
+
+func NewDNSRepository(db *gorm.DB) *DNSRepository {
+	return &DNSRepository{db: db}
+}
+
+func (r *DNSRepository) CreateProvider(provider *models.DNSProvider) error {
+	return r.db.Create(provider).Error
+}
+
+func (r *DNSRepository) FindProviderByID(id uint) (*models.DNSProvider, error) {
+	var provider models.DNSProvider
+	err := r.db.Where("id = ?", id).First(&provider).Error
+	return &provider, err
+}
+
+func (r *DNSRepository) FindProviderByName(name string) (*models.DNSProvider, error) {
+	var provider models.DNSProvider
+	err := r.db.Where("name = ?", name).First(&provider).Error
+	return &provider, err
+}
+
+func (r *DNSRepository) FindAllProviders() ([]models.DNSProvider, error) {
+	var providers []models.DNSProvider
+	err := r.db.Order("id ASC").Find(&providers).Error
+	return providers, err
+}
+
+func (r *DNSRepository) UpdateProvider(provider *models.DNSProvider) error {
+	return r.db.Save(provider).Error
+}
+
+func (r *DNSRepository) DeleteProvider(id uint) error {
+	return r.db.Delete(&models.DNSProvider{}, id).Error
+}
+
+// FindRecord 获取隧道的解析记录
+func (r *DNSRepository) FindRecord(tunnelID uint) (*models.DNSRecord, error) {
+	var record models.DNSRecord
+	err := r.db.Where("tunnel_id = ?", tunnelID).First(&record).Error
+	return &record, err
+}
+
+func (r *DNSRepository) FindAllRecords() ([]models.DNSRecord, error) {
+	var records []models.DNSRecord
+	err := r.db.Order("tunnel_id ASC").Find(&records).Error
+	return records, err
+}
+
+func (r *DNSRepository) SaveRecord(record *models.DNSRecord) error {
+	return r.db.Save(record).Error
+}
+
+func (r *DNSRepository) DeleteRecord(tunnelID uint) error {
+	return r.db.Where("tunnel_id = ?", tunnelID).Delete(&models.DNSRecord{}).Error
+}
@@ -24,6 +24,12 @@ func (r *ForwardRepository) FindByID(id uint) (*models.Forward, error) {
 	return &forward, err
 }
 
+func (r *ForwardRepository) FindByIDs(ids []uint) ([]models.Forward, error) {
+	var forwards []models.Forward
+	err := r.db.Where("id IN ?", ids).Find(&forwards).Error
+	return forwards, err
+}
+
 func (r *ForwardRepository) FindByUserID(userID int) ([]models.Forward, error) {
 	var forwards []models.Forward
 	err := r.db.Where("user_id = ?", userID).Order("inx ASC").Find(&forwards).Error
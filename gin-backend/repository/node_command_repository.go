@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type NodeCommandRepository struct {
+	db *gorm.DB
+}
+
+func NewNodeCommandRepository(db *gorm.DB) *NodeCommandRepository {
+	return &NodeCommandRepository{db: db}
+}
+
+func (r *NodeCommandRepository) Create(command *models.NodeCommand) error {
+	return r.db.Create(command).Error
+}
+
+// HasPending 节点是否有待下发的命令
+func (r *NodeCommandRepository) HasPending(nodeID uint) bool {
+	var count int64
+	r.db.Model(&models.NodeCommand{}).
+		Where("node_id = ? AND state = ?", nodeID, models.NodeCommandPending).
+		Limit(1).Count(&count)
+	return count > 0
+}
+
+// NextPending 获取节点最早的待下发命令
+func (r *NodeCommandRepository) NextPending(nodeID uint) (*models.NodeCommand, error) {
+	var command models.NodeCommand
+	err := r.db.Where("node_id = ? AND state = ?", nodeID, models.NodeCommandPending).
+		Order("id ASC").First(&command).Error
+	if err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// FindPendingByResource 获取节点上作用于同一配置的待下发命令
+func (r *NodeCommandRepository) FindPendingByResource(nodeID uint, resource string) ([]models.NodeCommand, error) {
+	var commands []models.NodeCommand
+	err := r.db.Where("node_id = ? AND resource = ? AND state = ?", nodeID, resource, models.NodeCommandPending).
+		Order("id ASC").Find(&commands).Error
+	return commands, err
+}
+
+// Find 按节点、转发和状态查询命令，按时间倒序
+func (r *NodeCommandRepository) Find(nodeID, forwardID uint, state *int, limit int) ([]models.NodeCommand, error) {
+	query := r.db.Model(&models.NodeCommand{})
+	if nodeID > 0 {
+		query = query.Where("node_id = ?", nodeID)
+	}
+	if forwardID > 0 {
+		query = query.Where("forward_id = ?", forwardID)
+	}
+	if state != nil {
+		query = query.Where("state = ?", *state)
+	}
+
+	var commands []models.NodeCommand
+	err := query.Order("id DESC").Limit(limit).Find(&commands).Error
+	return commands, err
+}
+
+// CountPendingByForward 统计各转发待下发的命令数量
+func (r *NodeCommandRepository) CountPendingByForward() (map[uint]int, error) {
+	var rows []struct {
+		ForwardID uint
+		Count     int
+	}
+	err := r.db.Model(&models.NodeCommand{}).
+		Select("forward_id, COUNT(*) AS count").
+		Where("state = ? AND forward_id > 0", models.NodeCommandPending).
+		Group("forward_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.ForwardID] = row.Count
+	}
+	return counts, nil
+}
+
+// UpdateState 更新命令状态，仅更新仍处于 fromState 的命令，返回是否更新
+func (r *NodeCommandRepository) UpdateState(id uint, fromState, state int, message string) (bool, error) {
+	result := r.db.Model(&models.NodeCommand{}).Where("id = ? AND state = ?", id, fromState).
+		Updates(map[string]interface{}{
+			"state":    state,
+			"message":  message,
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Supersede 将待下发的命令标记为已被取代
+func (r *NodeCommandRepository) Supersede(ids []uint, message string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.NodeCommand{}).
+		Where("id IN ? AND state = ?", ids, models.NodeCommandPending).
+		Updates(map[string]interface{}{"state": models.NodeCommandSuperseded, "message": message}).Error
+}
+
+// DeleteFinishedBefore 删除指定时间前已结束的命令
+func (r *NodeCommandRepository) DeleteFinishedBefore(updatedTime int64) (int64, error) {
+	result := r.db.Where("state <> ? AND updated_time < ?", models.NodeCommandPending, updatedTime).
+		Delete(&models.NodeCommand{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByNode 删除节点的全部命令
+func (r *NodeCommandRepository) DeleteByNode(nodeID uint) error {
+	return r.db.Where("node_id = ?", nodeID).Delete(&models.NodeCommand{}).Error
+}
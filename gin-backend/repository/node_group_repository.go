@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type NodeGroupRepository struct {
+	db *gorm.DB
+}
+
+func NewNodeGroupRepository(db *gorm.DB) *NodeGroupRepository {
+	return &NodeGroupRepository{db: db}
+}
+
+func (r *NodeGroupRepository) Create(group *models.NodeGroup) error {
+	return r.db.Create(group).Error
+}
+
+func (r *NodeGroupRepository) FindByID(id uint) (*models.NodeGroup, error) {
+	var group models.NodeGroup
+	err := r.db.Where("id = ?", id).First(&group).Error
+	return &group, err
+}
+
+func (r *NodeGroupRepository) FindByName(name string) (*models.NodeGroup, error) {
+	var group models.NodeGroup
+	err := r.db.Where("name = ?", name).First(&group).Error
+	return &group, err
+}
+
+// FindAll 获取所有节点组
+func (r *NodeGroupRepository) FindAll() ([]models.NodeGroup, error) {
+	var groups []models.NodeGroup
+	err := r.db.Order("id ASC").Find(&groups).Error
+	return groups, err
+}
+
+func (r *NodeGroupRepository) Update(group *models.NodeGroup) error {
+	return r.db.Save(group).Error
+}
+
+// Delete 删除节点组及其成员关系
+func (r *NodeGroupRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.NodeGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.NodeGroup{}, id).Error
+	})
+}
+
+// MemberIDs 获取节点组的成员节点ID，按加入顺序排列
+func (r *NodeGroupRepository) MemberIDs(groupID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.NodeGroupMember{}).
+		Where("group_id = ?", groupID).
+		Order("id ASC").
+		Pluck("node_id", &ids).Error
+	return ids, err
+}
+
+// FindMembers 获取所有节点组的成员关系
+func (r *NodeGroupRepository) FindMembers() ([]models.NodeGroupMember, error) {
+	var members []models.NodeGroupMember
+	err := r.db.Order("id ASC").Find(&members).Error
+	return members, err
+}
+
+// GroupIDsOfNode 获取节点所属的节点组ID
+func (r *NodeGroupRepository) GroupIDsOfNode(nodeID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.NodeGroupMember{}).
+		Where("node_id = ?", nodeID).
+		Pluck("group_id", &ids).Error
+	return ids, err
+}
+
+// AddMembers 向节点组添加成员
+func (r *NodeGroupRepository) AddMembers(groupID uint, nodeIDs []uint) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	members := make([]models.NodeGroupMember, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		members = append(members, models.NodeGroupMember{GroupID: groupID, NodeID: id})
+	}
+	return r.db.Create(&members).Error
+}
+
+// RemoveMembers 从节点组移除成员
+func (r *NodeGroupRepository) RemoveMembers(groupID uint, nodeIDs []uint) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("group_id = ? AND node_id IN ?", groupID, nodeIDs).
+		Delete(&models.NodeGroupMember{}).Error
+}
@@ -40,3 +40,19 @@ func (r *NodeRepository) Update(node *models.Node) error {
 func (r *NodeRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Node{}, id).Error
 }
+
+// FindExpiredPrevSecrets 获取密钥轮换宽限期已结束但仍保留旧密钥的节点
+func (r *NodeRepository) FindExpiredPrevSecrets(now int64) ([]models.Node, error) {
+	var nodes []models.Node
+	err := r.db.Where("prev_secret <> '' AND prev_secret_expire <= ?", now).Find(&nodes).Error
+	return nodes, err
+}
+
+// UpdateSecret 只更新节点的当前密钥与轮换前的旧密钥
+func (r *NodeRepository) UpdateSecret(id uint, secret, prevSecret string, prevExpire int64) error {
+	return r.db.Model(&models.Node{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"secret":             secret,
+		"prev_secret":        prevSecret,
+		"prev_secret_expire": prevExpire,
+	}).Error
+}
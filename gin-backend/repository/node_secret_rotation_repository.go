@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type NodeSecretRotationRepository struct {
+	db *gorm.DB
+}
+
+func NewNodeSecretRotationRepository(db *gorm.DB) *NodeSecretRotationRepository {
+	return &NodeSecretRotationRepository{db: db}
+}
+
+func (r *NodeSecretRotationRepository) Create(rotation *models.NodeSecretRotation) error {
+	return r.db.Create(rotation).Error
+}
+
+// FindLatest 获取节点最近一次密钥轮换
+func (r *NodeSecretRotationRepository) FindLatest(nodeID uint) (*models.NodeSecretRotation, error) {
+	var rotation models.NodeSecretRotation
+	err := r.db.Where("node_id = ?", nodeID).Order("id DESC").First(&rotation).Error
+	return &rotation, err
+}
+
+// FindByNode 获取节点的密钥轮换记录，nodeID 为 0 时返回全部节点的记录
+func (r *NodeSecretRotationRepository) FindByNode(nodeID uint, limit int) ([]models.NodeSecretRotation, error) {
+	var rotations []models.NodeSecretRotation
+	query := r.db.Order("id DESC").Limit(limit)
+	if nodeID > 0 {
+		query = query.Where("node_id = ?", nodeID)
+	}
+	err := query.Find(&rotations).Error
+	return rotations, err
+}
+
+func (r *NodeSecretRotationRepository) UpdateState(id uint, state int, message string) error {
+	return r.db.Model(&models.NodeSecretRotation{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"state": state, "message": message}).Error
+}
+
+func (r *NodeSecretRotationRepository) DeleteByNode(nodeID uint) error {
+	return r.db.Where("node_id = ?", nodeID).Delete(&models.NodeSecretRotation{}).Error
+}
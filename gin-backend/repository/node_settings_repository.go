@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type NodeSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewNodeSettingsRepository(db *gorm.DB) *NodeSettingsRepository {
+	return &NodeSettingsRepository{db: db}
+}
+
+func (r *NodeSettingsRepository) FindByNode(nodeID uint) (*models.NodeSettings, error) {
+	var settings models.NodeSettings
+	err := r.db.Where("node_id = ?", nodeID).First(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *NodeSettingsRepository) Save(settings *models.NodeSettings) error {
+	return r.db.Save(settings).Error
+}
+
+// UpdateState 更新下发状态
+func (r *NodeSettingsRepository) UpdateState(id uint, state int, message string, appliedTime int64) error {
+	updates := map[string]interface{}{"state": state, "message": message}
+	if appliedTime > 0 {
+		updates["applied_time"] = appliedTime
+	}
+	return r.db.Model(&models.NodeSettings{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
+func (r *NodeSettingsRepository) DeleteByNode(nodeID uint) error {
+	return r.db.Where("node_id = ?", nodeID).Delete(&models.NodeSettings{}).Error
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"flux-panel/models"
+
+	"gorm.io/gorm"
+)
+
+type NodeTelemetryRepository struct {
+	db *gorm.DB
+}
+
+func NewNodeTelemetryRepository(db *gorm.DB) *NodeTelemetryRepository {
+	return &NodeTelemetryRepository{db: db}
+}
+
+func (r *NodeTelemetryRepository) Create(telemetry *models.NodeTelemetry) error {
+	return r.db.Create(telemetry).Error
+}
+
+// FindByNode 按采集时间升序获取节点在时间范围内的遥测记录
+func (r *NodeTelemetryRepository) FindByNode(nodeID uint, startTime, endTime int64, limit int) ([]models.NodeTelemetry, error) {
+	var records []models.NodeTelemetry
+	err := r.db.Where("node_id = ? AND collected_time >= ? AND collected_time <= ?", nodeID, startTime, endTime).
+		Order("collected_time ASC").Limit(limit).Find(&records).Error
+	return records, err
+}
+
+func (r *NodeTelemetryRepository) DeleteBefore(collectedTime int64) (int64, error) {
+	result := r.db.Where("collected_time < ?", collectedTime).Delete(&models.NodeTelemetry{})
+	return result.RowsAffected, result.Error
+}
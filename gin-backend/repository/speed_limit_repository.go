@@ -37,3 +37,10 @@ func (r *SpeedLimitRepository) Update(speedLimit *models.SpeedLimit) error {
 func (r *SpeedLimitRepository) Delete(id uint) error {
 	return r.db.Model(&models.SpeedLimit{}).Where("id = ?", id).Update("status", 1).Error
 }
+
+// FindByTunnelID 获取隧道的限速规则
+func (r *SpeedLimitRepository) FindByTunnelID(tunnelID uint) ([]models.SpeedLimit, error) {
+	var speedLimits []models.SpeedLimit
+	err := r.db.Where("tunnel_id = ? AND status = 0", tunnelID).Find(&speedLimits).Error
+	return speedLimits, err
+}
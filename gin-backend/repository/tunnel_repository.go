@@ -45,3 +45,39 @@ func (r *TunnelRepository) FindByUserID(userID uint) ([]models.Tunnel, error) {
 		Find(&tunnels).Error
 	return tunnels, err
 }
+
+// FindByGroupID 获取以节点组作为入口或出口的隧道
+func (r *TunnelRepository) FindByGroupID(groupID uint) ([]models.Tunnel, error) {
+	var tunnels []models.Tunnel
+	err := r.db.Where("in_group_id = ? OR out_group_id = ?", groupID, groupID).Find(&tunnels).Error
+	return tunnels, err
+}
+
+// FindByEntryNode 获取以节点或其所属节点组作为入口的隧道
+func (r *TunnelRepository) FindByEntryNode(nodeID uint, groupIDs []uint) ([]models.Tunnel, error) {
+	var tunnels []models.Tunnel
+	query := r.db.Where("in_node_id = ?", nodeID)
+	if len(groupIDs) > 0 {
+		query = query.Or("in_group_id IN ?", groupIDs)
+	}
+	err := query.Find(&tunnels).Error
+	return tunnels, err
+}
+
+// FindByNode 获取以节点或其所属节点组作为入口或出口的隧道
+func (r *TunnelRepository) FindByNode(nodeID uint, groupIDs []uint) ([]models.Tunnel, error) {
+	var tunnels []models.Tunnel
+	query := r.db.Where("in_node_id = ? OR out_node_id = ?", nodeID, nodeID)
+	if len(groupIDs) > 0 {
+		query = query.Or("in_group_id IN ? OR out_group_id IN ?", groupIDs, groupIDs)
+	}
+	err := query.Find(&tunnels).Error
+	return tunnels, err
+}
+
+// FindByDNSProviderID 获取使用 DNS 服务商的隧道
+func (r *TunnelRepository) FindByDNSProviderID(providerID uint) ([]models.Tunnel, error) {
+	var tunnels []models.Tunnel
+	err := r.db.Where("dns_provider_id = ?", providerID).Find(&tunnels).Error
+	return tunnels, err
+}
@@ -21,6 +21,7 @@ func SetupRouter() *gin.Engine {
 	// 创建handlers
 	userHandler := handler.NewUserHandler(models.DB)
 	nodeHandler := handler.NewNodeHandler(models.DB)
+	nodeGroupHandler := handler.NewNodeGroupHandler(models.DB)
 	tunnelHandler := handler.NewTunnelHandler(models.DB)
 	configHandler := handler.NewConfigHandler(models.DB)
 	forwardHandler := handler.NewForwardHandler(models.DB)
@@ -28,6 +29,11 @@ func SetupRouter() *gin.Engine {
 	captchaHandler := handler.NewCaptchaHandler(models.DB)
 	openApiHandler := handler.NewOpenApiHandler(models.DB)
 	flowHandler := handler.NewFlowHandler(models.DB)
+	accessLogHandler := handler.NewAccessLogHandler(models.DB)
+	agentHandler := handler.NewAgentHandler(models.DB)
+	dnsHandler := handler.NewDNSHandler(models.DB)
+	auditLogHandler := handler.NewAuditLogHandler(models.DB)
+	backupHandler := handler.NewBackupHandler(models.DB)
 
 	// API v1路由组
 	v1 := r.Group("/api/v1")
@@ -55,6 +61,8 @@ func SetupRouter() *gin.Engine {
 					adminUser.POST("/delete", userHandler.DeleteUser)
 					adminUser.POST("/reset", userHandler.ResetFlow)
 					adminUser.POST("/toggle-status", userHandler.ToggleUserStatus)
+					adminUser.POST("/import", userHandler.ImportUsers)
+					adminUser.POST("/export", userHandler.ExportUsers)
 				}
 			}
 		}
@@ -70,6 +78,73 @@ func SetupRouter() *gin.Engine {
 			node.POST("/delete", nodeHandler.DeleteNode)
 			node.POST("/install", nodeHandler.GetInstallCommand)
 			node.POST("/check-status", nodeHandler.CheckNodeStatus)
+			node.POST("/envelope-stats", nodeHandler.GetEnvelopeStats)
+			node.POST("/telemetry", nodeHandler.GetTelemetry)
+			node.POST("/probe", nodeHandler.Probe)
+			node.POST("/logs", nodeHandler.GetLogs)
+			node.GET("/logs/stream", nodeHandler.StreamLogs)
+			node.POST("/logs/level", nodeHandler.SetLogLevel)
+			node.POST("/settings", nodeHandler.GetSettings)
+			node.POST("/settings/update", nodeHandler.UpdateSettings)
+			node.POST("/commands", nodeHandler.GetCommands)
+			node.POST("/secret/rotate", nodeHandler.RotateSecret)
+			node.POST("/secret/list", nodeHandler.GetSecretRotations)
+		}
+
+		// 节点组相关路由
+		nodeGroup := v1.Group("/node-group")
+		nodeGroup.Use(middleware.JWTAuth())
+		nodeGroup.Use(middleware.RequireRole())
+		{
+			nodeGroup.POST("/create", nodeGroupHandler.CreateGroup)
+			nodeGroup.POST("/list", nodeGroupHandler.GetAllGroups)
+			nodeGroup.POST("/update", nodeGroupHandler.UpdateGroup)
+			nodeGroup.POST("/delete", nodeGroupHandler.DeleteGroup)
+		}
+
+		// DNS 服务商与入口域名解析相关路由
+		dns := v1.Group("/dns-provider")
+		dns.Use(middleware.JWTAuth())
+		dns.Use(middleware.RequireRole())
+		{
+			dns.POST("/create", dnsHandler.CreateProvider)
+			dns.POST("/list", dnsHandler.GetAllProviders)
+			dns.POST("/update", dnsHandler.UpdateProvider)
+			dns.POST("/delete", dnsHandler.DeleteProvider)
+			dns.POST("/records", dnsHandler.GetRecords)
+			dns.POST("/sync", dnsHandler.SyncRecords)
+		}
+
+		// 审计日志相关路由
+		auditLog := v1.Group("/audit-log")
+		auditLog.Use(middleware.JWTAuth())
+		auditLog.Use(middleware.RequireRole())
+		{
+			auditLog.POST("/list", auditLogHandler.GetAuditLogs)
+		}
+
+		// 面板备份与恢复相关路由
+		backup := v1.Group("/backup")
+		backup.Use(middleware.JWTAuth())
+		backup.Use(middleware.RequireRole())
+		{
+			backup.POST("/export", backupHandler.ExportBackup)
+			backup.POST("/restore", backupHandler.RestoreBackup)
+		}
+
+		// 节点程序升级相关路由
+		agent := v1.Group("/agent")
+		agent.Use(middleware.JWTAuth())
+		agent.Use(middleware.RequireRole())
+		{
+			agent.POST("/release/create", agentHandler.CreateRelease)
+			agent.POST("/release/list", agentHandler.GetReleases)
+			agent.POST("/release/delete", agentHandler.DeleteRelease)
+			agent.POST("/fleet", agentHandler.GetFleet)
+			agent.POST("/upgrade", agentHandler.UpgradeNode)
+			agent.POST("/rollout/create", agentHandler.StartRollout)
+			agent.POST("/rollout/list", agentHandler.GetRollouts)
+			agent.POST("/rollout/cancel", agentHandler.CancelRollout)
 		}
 
 		// 隧道相关路由
@@ -86,6 +161,8 @@ func SetupRouter() *gin.Engine {
 				adminTunnel.POST("/update", tunnelHandler.UpdateTunnel)
 				adminTunnel.POST("/delete", tunnelHandler.DeleteTunnel)
 				adminTunnel.POST("/diagnose", tunnelHandler.DiagnoseTunnel)
+				adminTunnel.POST("/bandwidth-test", tunnelHandler.BandwidthTest)
+				adminTunnel.POST("/bandwidth-test/list", tunnelHandler.GetBandwidthTests)
 
 				// 用户隧道权限管理
 				adminTunnel.POST("/user/assign", tunnelHandler.AssignUserTunnel)
@@ -132,6 +209,17 @@ func SetupRouter() *gin.Engine {
 			forward.POST("/resume", forwardHandler.ResumeForward)
 			forward.POST("/diagnose", forwardHandler.DiagnoseForward)
 			forward.POST("/update-order", forwardHandler.UpdateForwardOrder)
+			forward.POST("/commands", forwardHandler.GetForwardCommands)
+			forward.POST("/import", forwardHandler.ImportForwards)
+			forward.POST("/export", forwardHandler.ExportForwards)
+		}
+
+		// 连接日志相关路由 (普通用户仅可查看自己的转发)
+		accessLog := v1.Group("/access-log")
+		accessLog.Use(middleware.JWTAuth())
+		{
+			accessLog.POST("/list", accessLogHandler.GetAccessLogs)
+			accessLog.POST("/export", accessLogHandler.ExportAccessLogs)
 		}
 
 		// 限速规则相关路由
@@ -168,6 +256,8 @@ func SetupRouter() *gin.Engine {
 		flow.POST("/config", flowHandler.Config)
 		flow.Any("/test", flowHandler.Test)
 		flow.Any("/upload", flowHandler.Upload)
+		flow.POST("/limit", flowHandler.Limit)
+		flow.POST("/access", flowHandler.Access)
 	}
 
 	// WebSocket 节点连接 (路径匹配 Spring Boot 后端)
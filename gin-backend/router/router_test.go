@@ -0,0 +1,461 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"flux-panel/config"
+	"flux-panel/models"
+	"flux-panel/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 授权集成测试：分别以管理员、转发所有者、其他用户和未登录身份访问 SetupRouter 注册的全部路由
+
+// 路由的访问级别
+const (
+	accessPublic = iota // 无需登录
+	accessNode          // 节点使用 secret 访问，不经过 JWT 认证
+	accessUser          // 登录用户均可访问，结果按用户过滤
+	accessOwner         // 资源所有者或管理员
+	accessAdmin         // 仅管理员
+)
+
+type routeCase struct {
+	method string
+	access int
+	// body 构造请求体，为 nil 时发送空对象，管理员路由只校验授权不执行实际操作
+	body func(f *fixture) interface{}
+}
+
+// routeCases 全部路由的访问级别，新增路由必须在此登记
+var routeCases = map[string]routeCase{
+	"/api/v1/user/login":          {method: http.MethodPost, access: accessPublic},
+	"/api/v1/user/package":        {method: http.MethodPost, access: accessUser},
+	"/api/v1/user/updatePassword": {method: http.MethodPost, access: accessUser},
+	"/api/v1/user/create":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/list":           {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/update":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/delete":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/reset":          {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/toggle-status":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/import":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/user/export":         {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/node/create":          {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/list":            {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/update":          {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/delete":          {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/install":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/check-status":    {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/envelope-stats":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/telemetry":       {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/probe":           {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/logs":            {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/logs/stream":     {method: http.MethodGet, access: accessAdmin},
+	"/api/v1/node/logs/level":      {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/settings":        {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/settings/update": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/commands":        {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/secret/rotate":   {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node/secret/list":     {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/node-group/create": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node-group/list":   {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node-group/update": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/node-group/delete": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/dns-provider/create":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/dns-provider/list":    {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/dns-provider/update":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/dns-provider/delete":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/dns-provider/records": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/dns-provider/sync":    {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/audit-log/list": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/backup/export":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/backup/restore": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/agent/release/create": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/release/list":   {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/release/delete": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/fleet":          {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/upgrade":        {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/rollout/create": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/rollout/list":   {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/agent/rollout/cancel": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/tunnel/create":              {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/list":                {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/get":                 {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/update":              {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/delete":              {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/diagnose":            {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/bandwidth-test":      {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/bandwidth-test/list": {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/user/assign":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/user/list":           {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/user/remove":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/user/update":         {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/tunnel/user/tunnel":         {method: http.MethodPost, access: accessUser},
+
+	"/api/v1/config/list":          {method: http.MethodPost, access: accessPublic},
+	"/api/v1/config/get":           {method: http.MethodPost, access: accessPublic},
+	"/api/v1/config/update":        {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/config/update-single": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/forward/create": {method: http.MethodPost, access: accessOwner, body: func(f *fixture) interface{} {
+		// 私有隧道只分配给了所有者
+		return map[string]interface{}{"name": "authz", "tunnelId": f.privateTunnel.ID, "remoteAddr": "127.0.0.1:80"}
+	}},
+	"/api/v1/forward/list": {method: http.MethodPost, access: accessUser},
+	"/api/v1/forward/update": {method: http.MethodPost, access: accessOwner, body: func(f *fixture) interface{} {
+		forward := f.newForward()
+		return map[string]interface{}{"id": forward.ID, "name": "authz", "tunnelId": forward.TunnelID, "remoteAddr": "127.0.0.1:81"}
+	}},
+	"/api/v1/forward/delete":       {method: http.MethodPost, access: accessOwner, body: forwardIDBody("id")},
+	"/api/v1/forward/force-delete": {method: http.MethodPost, access: accessOwner, body: forwardIDBody("id")},
+	"/api/v1/forward/pause":        {method: http.MethodPost, access: accessOwner, body: forwardIDBody("id")},
+	"/api/v1/forward/resume":       {method: http.MethodPost, access: accessOwner, body: forwardIDBody("id")},
+	"/api/v1/forward/diagnose":     {method: http.MethodPost, access: accessOwner, body: forwardIDBody("forwardId")},
+	"/api/v1/forward/commands":     {method: http.MethodPost, access: accessOwner, body: forwardIDBody("id")},
+	"/api/v1/forward/import":       {method: http.MethodPost, access: accessUser},
+	"/api/v1/forward/export":       {method: http.MethodPost, access: accessUser},
+	"/api/v1/forward/update-order": {method: http.MethodPost, access: accessOwner, body: func(f *fixture) interface{} {
+		forward := f.newForward()
+		return map[string]interface{}{"forwards": []map[string]interface{}{{"id": forward.ID, "inx": 1}}}
+	}},
+
+	"/api/v1/access-log/list":   {method: http.MethodPost, access: accessUser},
+	"/api/v1/access-log/export": {method: http.MethodPost, access: accessUser},
+
+	"/api/v1/speed-limit/create":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/speed-limit/list":    {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/speed-limit/update":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/speed-limit/delete":  {method: http.MethodPost, access: accessAdmin},
+	"/api/v1/speed-limit/tunnels": {method: http.MethodPost, access: accessAdmin},
+
+	"/api/v1/captcha/check":            {method: http.MethodPost, access: accessPublic},
+	"/api/v1/captcha/generate":         {method: http.MethodPost, access: accessPublic},
+	"/api/v1/captcha/verify":           {method: http.MethodPost, access: accessPublic},
+	"/api/v1/captcha/verify-turnstile": {method: http.MethodPost, access: accessPublic},
+
+	"/api/v1/open_api/sub_store": {method: http.MethodGet, access: accessPublic},
+
+	"/flow/config": {method: http.MethodPost, access: accessNode},
+	"/flow/test":   {method: http.MethodPost, access: accessNode},
+	"/flow/upload": {method: http.MethodPost, access: accessNode},
+	"/flow/limit":  {method: http.MethodPost, access: accessNode},
+	"/flow/access": {method: http.MethodPost, access: accessNode},
+	"/system-info": {method: http.MethodGet, access: accessNode},
+	"/health":      {method: http.MethodGet, access: accessPublic},
+}
+
+// forwardIDBody 为每次请求创建一个属于所有者的新转发，避免删除类操作影响后续用例
+func forwardIDBody(key string) func(f *fixture) interface{} {
+	return func(f *fixture) interface{} {
+		return map[string]interface{}{key: f.newForward().ID}
+	}
+}
+
+// fixture 测试数据
+type fixture struct {
+	t             *testing.T
+	admin         *models.User
+	owner         *models.User
+	stranger      *models.User
+	tunnel        *models.Tunnel // 所有者和其他用户都有权限
+	privateTunnel *models.Tunnel // 仅所有者有权限
+	tokens        map[string]string
+}
+
+func (f *fixture) newForward() *models.Forward {
+	forward := &models.Forward{
+		UserID:     int(f.owner.ID),
+		UserName:   f.owner.User,
+		Name:       "owner-forward",
+		TunnelID:   int(f.tunnel.ID),
+		InPort:     20000,
+		RemoteAddr: "127.0.0.1:80",
+	}
+	forward.Status = 1
+	f.create(forward)
+	return forward
+}
+
+func (f *fixture) create(v interface{}) {
+	if err := models.DB.Create(v).Error; err != nil {
+		f.t.Fatalf("写入测试数据失败: %v", err)
+	}
+}
+
+func (f *fixture) newUser(name string, roleID int) *models.User {
+	user := &models.User{User: name, Pwd: "x", RoleID: roleID, Status: 1}
+	f.create(user)
+	// role_id 列默认值为 1，零值需要单独写入
+	if err := models.DB.Model(user).Update("role_id", roleID).Error; err != nil {
+		f.t.Fatalf("写入测试数据失败: %v", err)
+	}
+	user.RoleID = roleID
+	token, err := utils.GenerateToken(user)
+	if err != nil {
+		f.t.Fatalf("生成 token 失败: %v", err)
+	}
+	f.tokens[name] = token
+	return user
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Server.Mode = "test"
+	config.AppConfig.JWT.Secret = "authz-test-secret"
+	config.AppConfig.JWT.ExpireTime = 1
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "flux.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	models.DB = db
+	if err := models.AutoMigrate(); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() { models.CloseDB() })
+
+	f := &fixture{t: t, tokens: make(map[string]string)}
+	f.admin = f.newUser("admin", 0)
+	f.owner = f.newUser("owner", 1)
+	f.stranger = f.newUser("stranger", 1)
+
+	node := &models.Node{Name: "node", Secret: "node-secret", ServerIP: "127.0.0.1", PortSta: 20000, PortEnd: 20100}
+	f.create(node)
+
+	f.tunnel = &models.Tunnel{Name: "shared", InNodeID: node.ID, Type: 1}
+	f.tunnel.Status = 1
+	f.create(f.tunnel)
+	f.privateTunnel = &models.Tunnel{Name: "private", InNodeID: node.ID, Type: 1}
+	f.privateTunnel.Status = 1
+	f.create(f.privateTunnel)
+
+	f.create(&models.UserTunnel{UserID: f.owner.ID, TunnelID: f.tunnel.ID})
+	f.create(&models.UserTunnel{UserID: f.owner.ID, TunnelID: f.privateTunnel.ID})
+	f.create(&models.UserTunnel{UserID: f.stranger.ID, TunnelID: f.tunnel.ID})
+	return f
+}
+
+// result 一次请求的结果，code 为响应体中的业务码，非 JSON 响应时为 0
+type result struct {
+	status int
+	code   int
+	msg    string
+}
+
+func (r result) rejected() bool {
+	return r.status == http.StatusUnauthorized || r.status == http.StatusForbidden || r.code == 401 || r.code == 403
+}
+
+func (r result) String() string {
+	return fmt.Sprintf("status=%d code=%d msg=%q", r.status, r.code, r.msg)
+}
+
+func request(t *testing.T, r http.Handler, method, path, token string, body interface{}) result {
+	t.Helper()
+
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("序列化请求失败: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res := result{status: w.Code}
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(w.Body.Bytes(), &resp) == nil {
+		res.code, res.msg = resp.Code, resp.Msg
+	}
+	return res
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// TestRoutesRegistered 路由表与 SetupRouter 注册的路由一致，新增路由需要登记访问级别
+func TestRoutesRegistered(t *testing.T) {
+	newFixture(t)
+	r := SetupRouter()
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Path] = true
+		if _, ok := routeCases[route.Path]; !ok {
+			t.Errorf("路由 %s %s 未登记访问级别", route.Method, route.Path)
+		}
+	}
+	for path := range routeCases {
+		if !registered[path] {
+			t.Errorf("登记的路由 %s 未注册", path)
+		}
+	}
+}
+
+// TestRouteAuthorization 以各身份访问全部路由，校验认证和授权结果
+func TestRouteAuthorization(t *testing.T) {
+	f := newFixture(t)
+	r := SetupRouter()
+
+	identities := []string{"", "stranger", "owner", "admin"}
+	for path, rc := range routeCases {
+		for _, identity := range identities {
+			name := identity
+			if name == "" {
+				name = "anonymous"
+			}
+			t.Run(path+"/"+name, func(t *testing.T) {
+				var body interface{}
+				if rc.body != nil {
+					body = rc.body(f)
+				}
+				res := request(t, r, rc.method, path, f.tokens[identity], body)
+				if res.status == http.StatusInternalServerError {
+					t.Fatalf("请求异常: %v", res)
+				}
+
+				switch {
+				case rc.access == accessPublic:
+					if res.rejected() {
+						t.Errorf("公开接口被拒绝: %v", res)
+					}
+				case rc.access == accessNode:
+					// 节点接口使用自身的 secret 校验，与用户身份无关
+				case identity == "":
+					if res.status != http.StatusUnauthorized {
+						t.Errorf("未登录访问应返回 401: %v", res)
+					}
+				case rc.access == accessUser:
+					if res.rejected() {
+						t.Errorf("登录用户被拒绝: %v", res)
+					}
+				case rc.access == accessAdmin:
+					if identity == "admin" && res.rejected() {
+						t.Errorf("管理员被拒绝: %v", res)
+					}
+					if identity != "admin" && res.code != 403 {
+						t.Errorf("普通用户访问管理员接口应返回 403: %v", res)
+					}
+				case rc.access == accessOwner:
+					if identity == "stranger" && res.code != 403 {
+						t.Errorf("其他用户操作转发应返回 403: %v", res)
+					}
+					if identity != "stranger" && res.rejected() {
+						t.Errorf("所有者或管理员被拒绝: %v", res)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestForwardListScope 普通用户只能看到自己的转发，管理员可以看到全部
+func TestForwardListScope(t *testing.T) {
+	f := newFixture(t)
+	r := SetupRouter()
+	forward := f.newForward()
+
+	list := func(identity string) []models.Forward {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/forward/list", bytes.NewReader([]byte("{}")))
+		req.Header.Set("Authorization", "Bearer "+f.tokens[identity])
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp struct {
+			Code int              `json:"code"`
+			Data []models.Forward `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != 0 {
+			t.Fatalf("%s 查询转发失败: %s", identity, w.Body.String())
+		}
+		return resp.Data
+	}
+
+	contains := func(forwards []models.Forward) bool {
+		for _, fw := range forwards {
+			if fw.ID == forward.ID {
+				return true
+			}
+		}
+		return false
+	}
+	if !contains(list("owner")) {
+		t.Error("所有者的转发列表缺少自己的转发")
+	}
+	if !contains(list("admin")) {
+		t.Error("管理员的转发列表缺少用户的转发")
+	}
+	if contains(list("stranger")) {
+		t.Error("其他用户可以看到所有者的转发")
+	}
+}
+
+// TestForwardTunnelScope 普通用户不能把转发创建或迁移到未分配的隧道
+func TestForwardTunnelScope(t *testing.T) {
+	f := newFixture(t)
+	r := SetupRouter()
+
+	// 其他用户创建转发前需要取得转发所属隧道的权限，这里将所有者的转发迁移到私有隧道
+	forward := f.newForward()
+	res := request(t, r, http.MethodPost, "/api/v1/forward/update", f.tokens["stranger"], map[string]interface{}{
+		"id": forward.ID, "name": "authz", "tunnelId": f.privateTunnel.ID, "remoteAddr": "127.0.0.1:80",
+	})
+	if res.code != 403 {
+		t.Errorf("其他用户迁移转发应返回 403: %v", res)
+	}
+
+	// 所有者自己的转发也不能迁移到未分配的隧道
+	models.DB.Where("user_id = ? AND tunnel_id = ?", f.owner.ID, f.privateTunnel.ID).Delete(&models.UserTunnel{})
+	res = request(t, r, http.MethodPost, "/api/v1/forward/update", f.tokens["owner"], map[string]interface{}{
+		"id": forward.ID, "name": "authz", "tunnelId": f.privateTunnel.ID, "remoteAddr": "127.0.0.1:80",
+	})
+	if res.code != 403 {
+		t.Errorf("迁移到未分配的隧道应返回 403: %v", res)
+	}
+
+	// 排序请求中混入他人的转发时整体拒绝
+	res = request(t, r, http.MethodPost, "/api/v1/forward/update-order", f.tokens["stranger"], map[string]interface{}{
+		"forwards": []map[string]interface{}{{"id": forward.ID, "inx": 9}},
+	})
+	if res.code != 403 {
+		t.Errorf("其他用户调整转发排序应返回 403: %v", res)
+	}
+	var stored models.Forward
+	models.DB.First(&stored, forward.ID)
+	if stored.Inx == 9 {
+		t.Error("越权的排序请求修改了转发")
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessLogRetentionDays = 7       // 默认保留天数
+	defaultAccessLogMaxRows       = 1000000 // 默认最多保留条数
+	defaultAccessLogPageSize      = 50
+	maxAccessLogPageSize          = 500
+	maxAccessLogExportRows        = 100000 // 单次导出的最大条数
+	maxAccessLogErrLength         = 255
+)
+
+type AccessLogService struct {
+	repo        *repository.AccessLogRepository
+	forwardRepo *repository.ForwardRepository
+	configRepo  *repository.ConfigRepository
+}
+
+func NewAccessLogService(db *gorm.DB) *AccessLogService {
+	return &AccessLogService{
+		repo:        repository.NewAccessLogRepository(db),
+		forwardRepo: repository.NewForwardRepository(db),
+		configRepo:  repository.NewConfigRepository(db),
+	}
+}
+
+// SaveReport 保存节点上报的连接日志，只记录开启了连接日志的转发
+func (s *AccessLogService) SaveReport(nodeID uint, items []dto.AccessLogItemDto) error {
+	forwardIDs := make([]uint, 0, len(items))
+	itemForwardIDs := make([]uint, len(items))
+	seen := make(map[uint]bool)
+	for i, item := range items {
+		parts := strings.Split(item.N, "_")
+		if len(parts) < 3 {
+			continue
+		}
+		forwardID, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		itemForwardIDs[i] = uint(forwardID)
+		if !seen[uint(forwardID)] {
+			seen[uint(forwardID)] = true
+			forwardIDs = append(forwardIDs, uint(forwardID))
+		}
+	}
+	if len(forwardIDs) == 0 {
+		return nil
+	}
+
+	forwards, err := s.forwardRepo.FindByIDs(forwardIDs)
+	if err != nil {
+		return err
+	}
+	enabled := make(map[uint]*models.Forward, len(forwards))
+	for i := range forwards {
+		if forwards[i].AccessLog == 1 {
+			enabled[forwards[i].ID] = &forwards[i]
+		}
+	}
+
+	logs := make([]models.AccessLog, 0, len(items))
+	for i, item := range items {
+		forward, ok := enabled[itemForwardIDs[i]]
+		if !ok {
+			continue
+		}
+		errMsg := item.E
+		if len(errMsg) > maxAccessLogErrLength {
+			errMsg = errMsg[:maxAccessLogErrLength]
+		}
+		logs = append(logs, models.AccessLog{
+			ForwardID: forward.ID,
+			UserID:    forward.UserID,
+			NodeID:    nodeID,
+			Network:   item.W,
+			ClientIP:  item.C,
+			Target:    item.H,
+			InBytes:   item.I,
+			OutBytes:  item.O,
+			Err:       errMsg,
+			StartTime: item.S,
+			EndTime:   item.T,
+		})
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+	return s.repo.CreateBatch(logs)
+}
+
+// GetAccessLogs 分页查询连接日志，非管理员只能查看自己的转发
+func (s *AccessLogService) GetAccessLogs(userID, roleID int, query *dto.AccessLogQueryDto) (*dto.AccessLogPageDto, error) {
+	page := max(query.Page, 1)
+	size := query.Size
+	if size <= 0 {
+		size = defaultAccessLogPageSize
+	}
+	size = min(size, maxAccessLogPageSize)
+
+	logs, total, err := s.repo.Find(accessLogScope(userID, roleID), query, (page-1)*size, size)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.AccessLogPageDto{List: logs, Total: total}, nil
+}
+
+// ExportAccessLogs 导出符合条件的连接日志（最多 maxAccessLogExportRows 条）
+func (s *AccessLogService) ExportAccessLogs(userID, roleID int, query *dto.AccessLogQueryDto) ([]models.AccessLog, error) {
+	logs, _, err := s.repo.Find(accessLogScope(userID, roleID), query, 0, maxAccessLogExportRows)
+	return logs, err
+}
+
+// CleanExpired 按保留天数和最大条数清理连接日志
+func (s *AccessLogService) CleanExpired() {
+	days := configPositiveInt(s.configRepo, "access_log_retention_days", defaultAccessLogRetentionDays)
+	cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+	if n, err := s.repo.DeleteBefore(cutoff); err != nil {
+		log.Printf("Failed to delete expired access logs: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d expired access logs", n)
+	}
+
+	maxRows := configPositiveInt(s.configRepo, "access_log_max_rows", defaultAccessLogMaxRows)
+	if n, err := s.repo.DeleteExceeding(maxRows); err != nil {
+		log.Printf("Failed to trim access logs: %v", err)
+	} else if n > 0 {
+		log.Printf("Trimmed %d access logs exceeding limit", n)
+	}
+}
+
+// accessLogScope 管理员可查看全部日志，普通用户只能查看自己的
+func accessLogScope(userID, roleID int) *int {
+	if roleID == 0 {
+		return nil
+	}
+	return &userID
+}
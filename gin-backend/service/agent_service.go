@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flux-panel/cluster"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUpgradeTimeout   = 120 // 节点升级后重连的默认期限（秒）
+	defaultRolloutBatchSize = 1
+	upgradeWaitGrace        = 30 // 在节点重连期限之外额外等待的时间（秒），覆盖下载耗时
+
+	rolloutCancelPoll = 3 * time.Second
+	rolloutCancelTTL  = 24 * time.Hour
+)
+
+// rolloutCancels 本实例正在运行的升级任务的取消函数
+var rolloutCancels sync.Map
+
+// rolloutCancelKey 集群总线中的取消标记，由运行升级任务的实例轮询
+func rolloutCancelKey(id uint) string {
+	return fmt.Sprintf("rollout_cancel:%d", id)
+}
+
+// rolloutLease 运行升级任务的实例持有的租约
+func rolloutLease(id uint) string {
+	return fmt.Sprintf("rollout:%d", id)
+}
+
+type AgentService struct {
+	releaseRepo *repository.AgentReleaseRepository
+	rolloutRepo *repository.AgentRolloutRepository
+	nodeRepo    *repository.NodeRepository
+}
+
+func NewAgentService(db *gorm.DB) *AgentService {
+	return &AgentService{
+		releaseRepo: repository.NewAgentReleaseRepository(db),
+		rolloutRepo: repository.NewAgentRolloutRepository(db),
+		nodeRepo:    repository.NewNodeRepository(db),
+	}
+}
+
+// CreateRelease 登记发布包
+func (s *AgentService) CreateRelease(releaseDto *dto.AgentReleaseDto) error {
+	digest, err := hex.DecodeString(releaseDto.SHA256)
+	if err != nil || len(digest) != 32 {
+		return errors.New("sha256 校验值格式错误")
+	}
+	signature, err := base64.StdEncoding.DecodeString(releaseDto.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.New("签名格式错误，应为 base64 编码的 ed25519 签名")
+	}
+	if !strings.HasPrefix(releaseDto.URL, "http://") && !strings.HasPrefix(releaseDto.URL, "https://") {
+		return errors.New("下载地址必须是 http 或 https 链接")
+	}
+	if _, err := s.releaseRepo.FindByPlatform(releaseDto.Version, releaseDto.OS, releaseDto.Arch); err == nil {
+		return errors.New("该版本的平台发布包已存在")
+	}
+
+	release := &models.AgentRelease{
+		Version:   releaseDto.Version,
+		OS:        releaseDto.OS,
+		Arch:      releaseDto.Arch,
+		URL:       releaseDto.URL,
+		SHA256:    strings.ToLower(releaseDto.SHA256),
+		Signature: releaseDto.Signature,
+		Remark:    releaseDto.Remark,
+	}
+	return s.releaseRepo.Create(release)
+}
+
+// GetReleases 获取所有发布包
+func (s *AgentService) GetReleases() ([]models.AgentRelease, error) {
+	return s.releaseRepo.FindAll()
+}
+
+// DeleteRelease 删除发布包
+func (s *AgentService) DeleteRelease(id uint) error {
+	return s.releaseRepo.Delete(id)
+}
+
+// GetFleet 获取节点版本总览
+func (s *AgentService) GetFleet() (*dto.AgentFleetDto, error) {
+	nodes, err := s.nodeRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	fleet := &dto.AgentFleetDto{
+		Nodes:    make([]dto.AgentFleetNodeDto, 0, len(nodes)),
+		Versions: make(map[string]int),
+	}
+	for _, node := range nodes {
+		fleet.Nodes = append(fleet.Nodes, dto.AgentFleetNodeDto{
+			ID:      node.ID,
+			Name:    node.Name,
+			Version: node.Version,
+			OS:      node.OS,
+			Arch:    node.Arch,
+			Online:  websocket.IsNodeConnected(node.ID),
+		})
+		fleet.Versions[node.Version]++
+	}
+	return fleet, nil
+}
+
+// UpgradeNode 升级单个节点
+func (s *AgentService) UpgradeNode(upgradeDto *dto.AgentUpgradeDto) error {
+	node, err := s.nodeRepo.FindByID(upgradeDto.NodeID)
+	if err != nil {
+		return errors.New("节点不存在")
+	}
+	timeout := upgradeDto.Timeout
+	if timeout <= 0 {
+		timeout = defaultUpgradeTimeout
+	}
+	return s.sendUpgrade(node, upgradeDto.Version, timeout)
+}
+
+// sendUpgrade 向节点下发升级命令；节点下载耗时较长导致响应超时时视为升级仍在进行
+func (s *AgentService) sendUpgrade(node *models.Node, version string, timeout int) error {
+	if node.Version == version {
+		return fmt.Errorf("节点 %s 已是版本 %s", node.Name, version)
+	}
+	if node.OS == "" || node.Arch == "" {
+		return fmt.Errorf("节点 %s 未上报平台信息，不支持远程升级", node.Name)
+	}
+	release, err := s.releaseRepo.FindByPlatform(version, node.OS, node.Arch)
+	if err != nil {
+		return fmt.Errorf("版本 %s 没有 %s/%s 平台的发布包", version, node.OS, node.Arch)
+	}
+
+	data := map[string]interface{}{
+		"version":   release.Version,
+		"os":        release.OS,
+		"arch":      release.Arch,
+		"url":       release.URL,
+		"sha256":    release.SHA256,
+		"signature": release.Signature,
+		"timeout":   timeout,
+	}
+	resp, err := websocket.GetServer().SendMessage(node.ID, data, websocket.MessageTypeUpgradeAgent)
+	if errors.Is(err, websocket.ErrResponseTimeout) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("节点 %s 升级失败: %v", node.Name, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("节点 %s 升级失败: %s", node.Name, resp.Message)
+	}
+	return nil
+}
+
+// waitForVersion 等待节点以目标版本重新连上面板
+func (s *AgentService) waitForVersion(ctx context.Context, nodeID uint, version string, timeout int) error {
+	deadline := time.Now().Add(time.Duration(timeout+upgradeWaitGrace) * time.Second)
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("升级任务已取消")
+		case <-ticker.C:
+			node, err := s.nodeRepo.FindByID(nodeID)
+			if err != nil {
+				return errors.New("节点不存在")
+			}
+			if node.Version == version && websocket.IsNodeConnected(nodeID) {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("节点 %s 未在期限内以版本 %s 重连，当前版本 %s", node.Name, version, node.Version)
+			}
+		}
+	}
+}
+
+// StartRollout 创建分批升级任务，每批全部成功后才进入下一批，任一节点失败则停止
+func (s *AgentService) StartRollout(rolloutDto *dto.AgentRolloutDto) (*models.AgentRollout, error) {
+	releases, err := s.releaseRepo.FindByVersion(rolloutDto.Version)
+	if err != nil || len(releases) == 0 {
+		return nil, errors.New("目标版本没有发布包")
+	}
+
+	nodeIDs := rolloutDto.NodeIDs
+	if len(nodeIDs) == 0 {
+		nodes, err := s.nodeRepo.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if node.Version != rolloutDto.Version {
+				nodeIDs = append(nodeIDs, node.ID)
+			}
+		}
+	}
+	if len(nodeIDs) == 0 {
+		return nil, errors.New("没有需要升级的节点")
+	}
+
+	batchSize := rolloutDto.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRolloutBatchSize
+	}
+	timeout := rolloutDto.Timeout
+	if timeout <= 0 {
+		timeout = defaultUpgradeTimeout
+	}
+
+	ids := make([]string, len(nodeIDs))
+	for i, id := range nodeIDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	rollout := &models.AgentRollout{
+		Version:   rolloutDto.Version,
+		NodeIDs:   strings.Join(ids, ","),
+		BatchSize: batchSize,
+		Timeout:   timeout,
+	}
+	rollout.Status = models.RolloutRunning
+	if err := s.rolloutRepo.Create(rollout); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rolloutCancels.Store(rollout.ID, cancel)
+	go s.runRollout(ctx, rollout, nodeIDs)
+
+	return rollout, nil
+}
+
+// runRollout 按批次执行升级任务
+func (s *AgentService) runRollout(ctx context.Context, rollout *models.AgentRollout, nodeIDs []uint) {
+	defer rolloutCancels.Delete(rollout.ID)
+	release := holdJobLease(rolloutLease(rollout.ID))
+	defer release()
+	go watchRolloutCancel(ctx, rollout.ID)
+
+	finish := func(status int, message string) {
+		rollout.Status = status
+		rollout.Message = message
+		if err := s.rolloutRepo.Update(rollout); err != nil {
+			log.Printf("更新升级任务 %d 失败: %v", rollout.ID, err)
+		}
+	}
+
+	for start := 0; start < len(nodeIDs); start += rollout.BatchSize {
+		end := start + rollout.BatchSize
+		if end > len(nodeIDs) {
+			end = len(nodeIDs)
+		}
+		batch := nodeIDs[start:end]
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		var failures []string
+		for _, nodeID := range batch {
+			wg.Add(1)
+			go func(nodeID uint) {
+				defer wg.Done()
+				err := s.upgradeAndWait(ctx, nodeID, rollout.Version, rollout.Timeout)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					rollout.Failed++
+					failures = append(failures, err.Error())
+				} else {
+					rollout.Succeeded++
+				}
+			}(nodeID)
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			finish(models.RolloutCancelled, "升级任务已取消")
+			return
+		}
+		if len(failures) > 0 {
+			finish(models.RolloutFailed, strings.Join(failures, "; "))
+			return
+		}
+		if err := s.rolloutRepo.Update(rollout); err != nil {
+			log.Printf("更新升级任务 %d 失败: %v", rollout.ID, err)
+		}
+	}
+
+	finish(models.RolloutDone, "")
+}
+
+// upgradeAndWait 升级节点并等待其以新版本重连，已是目标版本的节点直接视为成功
+func (s *AgentService) upgradeAndWait(ctx context.Context, nodeID uint, version string, timeout int) error {
+	node, err := s.nodeRepo.FindByID(nodeID)
+	if err != nil {
+		return fmt.Errorf("节点 %d 不存在", nodeID)
+	}
+	if node.Version == version {
+		return nil
+	}
+	if err := s.sendUpgrade(node, version, timeout); err != nil {
+		return err
+	}
+	return s.waitForVersion(ctx, nodeID, version, timeout)
+}
+
+// watchRolloutCancel 轮询其他实例写入的取消标记，任务结束时退出
+func watchRolloutCancel(ctx context.Context, id uint) {
+	ticker := time.NewTicker(rolloutCancelPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cluster.Get().Take(rolloutCancelKey(id)); err == nil {
+				if cancel, ok := rolloutCancels.Load(id); ok {
+					cancel.(context.CancelFunc)()
+				}
+				return
+			}
+		}
+	}
+}
+
+// CancelRollout 取消升级任务，已下发升级命令的节点不受影响
+// 任务由其他面板实例运行时通过集群总线写入取消标记，由该实例在下一次轮询时取消
+func (s *AgentService) CancelRollout(id uint) error {
+	rollout, err := s.rolloutRepo.FindByID(id)
+	if err != nil || rollout.Status != models.RolloutRunning {
+		return errors.New("升级任务不存在或已结束")
+	}
+	if cancel, ok := rolloutCancels.Load(id); ok {
+		cancel.(context.CancelFunc)()
+		return nil
+	}
+	// 运行任务的实例已退出时直接结束任务
+	if lease := rolloutLease(id); claimStaleJob(lease) {
+		defer cluster.Unlock(lease)
+		return s.rolloutRepo.Interrupt([]uint{id}, models.RolloutCancelled, "升级任务已取消")
+	}
+	return cluster.Get().Set(rolloutCancelKey(id), []byte(cluster.InstanceID()), rolloutCancelTTL)
+}
+
+// GetRollouts 获取所有升级任务
+func (s *AgentService) GetRollouts() ([]models.AgentRollout, error) {
+	return s.rolloutRepo.FindAll()
+}
+
+// InterruptRollouts 面板启动时将运行实例已退出的升级任务标记为失败，其他实例仍在运行的任务不受影响
+func (s *AgentService) InterruptRollouts() error {
+	rollouts, err := s.rolloutRepo.FindRunning()
+	if err != nil {
+		return err
+	}
+	var ids []uint
+	for _, rollout := range rollouts {
+		if lease := rolloutLease(rollout.ID); claimStaleJob(lease) {
+			defer cluster.Unlock(lease)
+			ids = append(ids, rollout.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.rolloutRepo.Interrupt(ids, models.RolloutFailed, "面板重启，升级任务中断")
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"flux-panel/cluster"
+	"flux-panel/models"
+	"testing"
+	"time"
+)
+
+// TestInterruptRollouts 启动时只中断租约已失效的升级任务，其他实例仍持有租约的任务保持运行
+func TestInterruptRollouts(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAgentService(db)
+
+	newRollout := func() *models.AgentRollout {
+		rollout := &models.AgentRollout{Version: "2.0.0", NodeIDs: "1", BatchSize: 1, Timeout: 60}
+		rollout.Status = models.RolloutRunning
+		if err := db.Create(rollout).Error; err != nil {
+			t.Fatal(err)
+		}
+		return rollout
+	}
+	orphaned := newRollout()
+	remote := newRollout()
+	restarted := newRollout()
+
+	// 其他实例正在运行的任务
+	if err := cluster.Get().Set("lock:"+rolloutLease(remote.ID), []byte("other-instance"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// 本实例重启前运行的任务
+	if !cluster.TryLock(rolloutLease(restarted.ID), time.Minute) {
+		t.Fatal("获取租约失败")
+	}
+
+	if err := s.InterruptRollouts(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]int{
+		orphaned.ID:  models.RolloutFailed,
+		remote.ID:    models.RolloutRunning,
+		restarted.ID: models.RolloutFailed,
+	}
+	for id, status := range want {
+		rollout, err := s.rolloutRepo.FindByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rollout.Status != status {
+			t.Errorf("升级任务 %d 状态为 %d，应为 %d", id, rollout.Status, status)
+		}
+	}
+
+	// 其他实例仍在运行的任务通过总线取消，不直接修改状态
+	if err := s.CancelRollout(remote.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cluster.Get().Take(rolloutCancelKey(remote.ID)); err != nil {
+		t.Errorf("未写入取消标记: %v", err)
+	}
+}
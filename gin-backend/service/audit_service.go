@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/json"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditLogRetentionDays = 180     // 默认保留天数
+	defaultAuditLogMaxRows       = 1000000 // 默认最多保留条数
+	defaultAuditLogPageSize      = 50
+	maxAuditLogPageSize          = 500
+	maxAuditMessageLength        = 500
+)
+
+// auditSensitiveKeys 字段名包含这些内容时差异中只记录是否变更，不记录具体值
+var auditSensitiveKeys = []string{"pwd", "password", "secret", "token"}
+
+// auditIgnoredKeys 不参与差异比较的字段
+var auditIgnoredKeys = map[string]bool{
+	"created_time": true,
+	"updated_time": true,
+	"createdTime":  true,
+	"updatedTime":  true,
+}
+
+// AuditEntry 审计记录的操作对象及变更前后的状态
+type AuditEntry struct {
+	Action     string // 如 tunnel.create、forward.pause
+	TargetType string
+	TargetID   uint
+	TargetName string
+	Before     interface{} // 变更前的对象，创建时为 nil
+	After      interface{} // 变更后的对象，删除时为 nil
+	Message    string      // 附加说明，操作失败时记录错误信息
+}
+
+// AuditService 审计日志，由服务层在每次变更后写入
+type AuditService struct {
+	repo       *repository.AuditLogRepository
+	configRepo *repository.ConfigRepository
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		repo:       repository.NewAuditLogRepository(db),
+		configRepo: repository.NewConfigRepository(db),
+	}
+}
+
+// Record 写入一条审计日志，err 为操作结果；写入失败只打印日志，不影响业务操作
+func (s *AuditService) Record(actor Actor, entry *AuditEntry, err error) {
+	auditLog := &models.AuditLog{
+		ActorID:    actor.UserID,
+		ActorName:  actor.Name,
+		IP:         actor.IP,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		TargetName: entry.TargetName,
+		Success:    err == nil,
+		Message:    entry.Message,
+	}
+	if err == nil {
+		auditLog.Diff = auditDiff(entry.Before, entry.After)
+	} else {
+		auditLog.Message = err.Error()
+	}
+	if message := []rune(auditLog.Message); len(message) > maxAuditMessageLength {
+		auditLog.Message = string(message[:maxAuditMessageLength])
+	}
+
+	if err := s.repo.Create(auditLog); err != nil {
+		log.Printf("写入审计日志失败 %s %s#%d: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+// GetAuditLogs 分页查询审计日志
+func (s *AuditService) GetAuditLogs(query *dto.AuditLogQueryDto) (*dto.AuditLogPageDto, error) {
+	page := max(query.Page, 1)
+	size := query.Size
+	if size <= 0 {
+		size = defaultAuditLogPageSize
+	}
+	size = min(size, maxAuditLogPageSize)
+
+	logs, total, err := s.repo.Find(query, (page-1)*size, size)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.AuditLogPageDto{List: logs, Total: total}, nil
+}
+
+// CleanExpired 按保留天数和最大条数清理审计日志
+func (s *AuditService) CleanExpired() {
+	days := configPositiveInt(s.configRepo, "audit_log_retention_days", defaultAuditLogRetentionDays)
+	cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+	if n, err := s.repo.DeleteBefore(cutoff); err != nil {
+		log.Printf("Failed to delete expired audit logs: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d expired audit logs", n)
+	}
+
+	maxRows := configPositiveInt(s.configRepo, "audit_log_max_rows", defaultAuditLogMaxRows)
+	if n, err := s.repo.DeleteExceeding(maxRows); err != nil {
+		log.Printf("Failed to trim audit logs: %v", err)
+	} else if n > 0 {
+		log.Printf("Trimmed %d audit logs exceeding limit", n)
+	}
+}
+
+// auditDiff 比较变更前后对象的 JSON 字段，返回 {字段: {before, after}}，无变化时返回空串
+func auditDiff(before, after interface{}) string {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+	keys := make(map[string]bool, len(beforeFields)+len(afterFields))
+	for key := range beforeFields {
+		keys[key] = true
+	}
+	for key := range afterFields {
+		keys[key] = true
+	}
+
+	diff := make(map[string]map[string]interface{})
+	for key := range keys {
+		if auditIgnoredKeys[key] {
+			continue
+		}
+		oldValue, hadOld := beforeFields[key]
+		newValue, hasNew := afterFields[key]
+		if hadOld == hasNew && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if auditSensitive(key) {
+			if hadOld {
+				oldValue = secretMask
+			}
+			if hasNew {
+				newValue = secretMask
+			}
+		}
+		diff[key] = map[string]interface{}{"before": oldValue, "after": newValue}
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditFields 将对象按 JSON 序列化后展开为字段表，非对象的值记录在 value 字段下
+func auditFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var value interface{}
+		json.Unmarshal(data, &value)
+		return map[string]interface{}{"value": value}
+	}
+	return fields
+}
+
+func auditSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range auditSensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
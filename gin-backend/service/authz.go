@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/models"
+	"time"
+)
+
+// ErrForbidden 操作对象不属于当前用户
+var ErrForbidden = errors.New("无权操作该资源")
+
+// Actor 发起操作的用户，由 JWT 中的用户ID和角色构造
+type Actor struct {
+	UserID int
+	RoleID int
+	Name   string // 用户名，写入审计日志
+	IP     string // 客户端IP，写入审计日志
+}
+
+// SystemActor 定时任务和流量上报触发的系统操作
+var SystemActor = Actor{Name: "system"}
+
+// IsAdmin 是否为管理员 (role_id == 0)
+func (a Actor) IsAdmin() bool {
+	return a.RoleID == 0
+}
+
+// CanAccess 判断是否可以操作属于 ownerID 的资源，管理员可操作全部用户的资源
+func (a Actor) CanAccess(ownerID int) bool {
+	return a.IsAdmin() || a.UserID == ownerID
+}
+
+// authorizeForward 查询转发并校验当前用户是否有权操作
+func (s *ForwardService) authorizeForward(actor Actor, id uint) (*models.Forward, error) {
+	forward, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("转发不存在")
+	}
+	if !actor.CanAccess(forward.UserID) {
+		return nil, ErrForbidden
+	}
+	return forward, nil
+}
+
+// authorizeTunnel 校验当前用户是否可以使用隧道，普通用户需要已分配且未到期的隧道权限
+func (s *ForwardService) authorizeTunnel(actor Actor, tunnelID uint) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	userTunnel, err := s.userTunnelRepo.FindByUserAndTunnel(uint(actor.UserID), tunnelID)
+	if err != nil {
+		return ErrForbidden
+	}
+	if userTunnel.ExpTime > 0 && userTunnel.ExpTime < time.Now().UnixMilli() {
+		return errors.New("隧道权限已到期")
+	}
+	return nil
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// validateBackup 校验备份版本、ID 唯一性以及各数据之间的引用
+func validateBackup(archive *dto.BackupArchive) error {
+	if archive.Version <= 0 {
+		return errors.New("不是有效的面板备份文件")
+	}
+	if archive.Version > dto.BackupVersion {
+		return fmt.Errorf("备份文件版本 %d 高于当前支持的版本 %d，请升级面板后再恢复", archive.Version, dto.BackupVersion)
+	}
+
+	users := make(map[uint]bool)
+	usernames := make(map[string]bool)
+	for _, u := range archive.Users {
+		if u.ID == 0 || users[u.ID] {
+			return fmt.Errorf("用户 %s 的ID无效或重复", u.User.User)
+		}
+		if u.User.User == "" || usernames[u.User.User] {
+			return fmt.Errorf("用户名 %s 为空或重复", u.User.User)
+		}
+		users[u.ID] = true
+		usernames[u.User.User] = true
+	}
+
+	nodes := make(map[uint]bool)
+	secrets := make(map[string]bool)
+	for _, n := range archive.Nodes {
+		if n.ID == 0 || nodes[n.ID] {
+			return fmt.Errorf("节点 %s 的ID无效或重复", n.Name)
+		}
+		if n.Secret == "" || secrets[n.Secret] {
+			return fmt.Errorf("节点 %s 的密钥为空或与其他节点重复", n.Name)
+		}
+		nodes[n.ID] = true
+		secrets[n.Secret] = true
+	}
+
+	groups := make(map[uint]bool)
+	groupNames := make(map[string]bool)
+	for _, g := range archive.NodeGroups {
+		if g.ID == 0 || groups[g.ID] || groupNames[g.Name] {
+			return fmt.Errorf("节点组 %s 的ID或名称无效或重复", g.Name)
+		}
+		for _, nodeID := range g.NodeIDs {
+			if !nodes[nodeID] {
+				return fmt.Errorf("节点组 %s 的成员节点 %d 不存在", g.Name, nodeID)
+			}
+		}
+		groups[g.ID] = true
+		groupNames[g.Name] = true
+	}
+
+	providers := make(map[uint]bool)
+	providerNames := make(map[string]bool)
+	for _, p := range archive.DNSProviders {
+		if p.ID == 0 || providers[p.ID] || providerNames[p.Name] {
+			return fmt.Errorf("DNS 服务商 %s 的ID或名称无效或重复", p.Name)
+		}
+		providers[p.ID] = true
+		providerNames[p.Name] = true
+	}
+
+	tunnels := make(map[uint]bool)
+	for _, t := range archive.Tunnels {
+		if t.ID == 0 || tunnels[t.ID] {
+			return fmt.Errorf("隧道 %s 的ID无效或重复", t.Name)
+		}
+		switch {
+		case t.InNodeID > 0 && !nodes[t.InNodeID], t.OutNodeID > 0 && !nodes[t.OutNodeID]:
+			return fmt.Errorf("隧道 %s 引用的节点不存在", t.Name)
+		case t.InGroupID > 0 && !groups[t.InGroupID], t.OutGroupID > 0 && !groups[t.OutGroupID]:
+			return fmt.Errorf("隧道 %s 引用的节点组不存在", t.Name)
+		case t.DNSProviderID > 0 && !providers[t.DNSProviderID]:
+			return fmt.Errorf("隧道 %s 引用的 DNS 服务商不存在", t.Name)
+		}
+		tunnels[t.ID] = true
+	}
+
+	speedLimits := make(map[uint]bool)
+	for _, l := range archive.SpeedLimits {
+		if l.ID == 0 || speedLimits[l.ID] {
+			return fmt.Errorf("限速规则 %s 的ID无效或重复", l.Name)
+		}
+		if !tunnels[uint(l.TunnelID)] {
+			return fmt.Errorf("限速规则 %s 引用的隧道 %d 不存在", l.Name, l.TunnelID)
+		}
+		speedLimits[l.ID] = true
+	}
+
+	userTunnels := make(map[uint]bool)
+	for _, ut := range archive.UserTunnels {
+		if ut.ID == 0 || userTunnels[ut.ID] {
+			return fmt.Errorf("用户隧道权限 %d 的ID无效或重复", ut.ID)
+		}
+		if !users[ut.UserID] || !tunnels[ut.TunnelID] {
+			return fmt.Errorf("用户隧道权限 %d 引用的用户或隧道不存在", ut.ID)
+		}
+		if ut.SpeedID > 0 && !speedLimits[uint(ut.SpeedID)] {
+			return fmt.Errorf("用户隧道权限 %d 引用的限速规则 %d 不存在", ut.ID, ut.SpeedID)
+		}
+		userTunnels[ut.ID] = true
+	}
+
+	forwards := make(map[uint]bool)
+	for _, f := range archive.Forwards {
+		if f.ID == 0 || forwards[f.ID] {
+			return fmt.Errorf("转发 %s 的ID无效或重复", f.Name)
+		}
+		if !users[uint(f.UserID)] || !tunnels[uint(f.TunnelID)] {
+			return fmt.Errorf("转发 %s 引用的用户或隧道不存在", f.Name)
+		}
+		if f.InNodeID > 0 && !nodes[f.InNodeID] {
+			return fmt.Errorf("转发 %s 引用的入口节点 %d 不存在", f.Name, f.InNodeID)
+		}
+		forwards[f.ID] = true
+	}
+
+	configs := make(map[string]bool)
+	for _, c := range archive.Configs {
+		if c.Name == "" || configs[c.Name] {
+			return fmt.Errorf("配置 %s 为空或重复", c.Name)
+		}
+		configs[c.Name] = true
+	}
+
+	for _, sf := range archive.StatisticsFlows {
+		if !users[uint(sf.UserID)] {
+			return fmt.Errorf("流量统计 %d 引用的用户 %d 不存在", sf.ID, sf.UserID)
+		}
+	}
+	return nil
+}
+
+// idAllocator 恢复时为新建的数据分配ID：原ID在目标表中未被占用时保留，否则分配未使用的ID
+type idAllocator struct {
+	used map[uint]bool
+	next uint
+}
+
+// newIDAllocator archiveIDs 为备份中该类数据的全部ID，新分配的ID大于备份与目标表中的全部ID，避免占用其后需要保留的原ID
+func newIDAllocator(tx *gorm.DB, model interface{}, archiveIDs []uint) (*idAllocator, error) {
+	var existing []uint
+	if err := tx.Model(model).Pluck("id", &existing).Error; err != nil {
+		return nil, err
+	}
+	a := &idAllocator{used: make(map[uint]bool, len(existing)), next: 1}
+	for _, id := range existing {
+		a.used[id] = true
+		a.next = max(a.next, id+1)
+	}
+	for _, id := range archiveIDs {
+		a.next = max(a.next, id+1)
+	}
+	return a, nil
+}
+
+func (a *idAllocator) assign(id uint) uint {
+	if id == 0 || a.used[id] {
+		for a.used[a.next] {
+			a.next++
+		}
+		id = a.next
+		a.next++
+	}
+	a.used[id] = true
+	return id
+}
+
+// backupRestorer 在事务中按依赖顺序写入备份数据，记录备份ID到新ID的映射
+type backupRestorer struct {
+	tx      *gorm.DB
+	archive *dto.BackupArchive
+	result  *dto.RestoreResultDto
+
+	users       map[uint]uint
+	nodes       map[uint]uint
+	groups      map[uint]uint
+	providers   map[uint]uint
+	tunnels     map[uint]uint
+	speedLimits map[uint]uint
+}
+
+func newBackupRestorer(tx *gorm.DB, archive *dto.BackupArchive, result *dto.RestoreResultDto) *backupRestorer {
+	return &backupRestorer{
+		tx:          tx,
+		archive:     archive,
+		result:      result,
+		users:       make(map[uint]uint),
+		nodes:       make(map[uint]uint),
+		groups:      make(map[uint]uint),
+		providers:   make(map[uint]uint),
+		tunnels:     make(map[uint]uint),
+		speedLimits: make(map[uint]uint),
+	}
+}
+
+func (r *backupRestorer) run() error {
+	steps := []func() error{
+		r.checkTargets,
+		r.restoreUsers,
+		r.restoreNodes,
+		r.restoreNodeGroups,
+		r.restoreDNSProviders,
+		r.restoreTunnels,
+		r.restoreSpeedLimits,
+		r.restoreUserTunnels,
+		r.restoreForwards,
+		r.restoreConfigs,
+		r.restoreStatisticsFlows,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTargets 隧道、限速规则、用户隧道、转发和流量统计没有可用于合并的唯一键，
+// 目标库中已有这些数据时重复恢复会产生重复记录，且备份中的端口可能与沿用节点上已使用的端口冲突，因此拒绝恢复
+func (r *backupRestorer) checkTargets() error {
+	targets := []struct {
+		name    string
+		model   interface{}
+		archive int
+	}{
+		{"隧道", &models.Tunnel{}, len(r.archive.Tunnels)},
+		{"限速规则", &models.SpeedLimit{}, len(r.archive.SpeedLimits)},
+		{"用户隧道", &models.UserTunnel{}, len(r.archive.UserTunnels)},
+		{"转发", &models.Forward{}, len(r.archive.Forwards)},
+		{"流量统计", &models.StatisticsFlow{}, len(r.archive.StatisticsFlows)},
+	}
+	for _, target := range targets {
+		if target.archive == 0 {
+			continue
+		}
+		var count int64
+		if err := r.tx.Model(target.model).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("目标库中已有%d条%s数据，恢复会产生重复记录和端口冲突，请在没有%s数据的面板上恢复", count, target.name, target.name)
+		}
+	}
+	return nil
+}
+
+// create 写入全部字段；gorm 创建时会将零值替换为字段默认值（如禁用状态的用户被恢复为启用），写入后按原值更新一次
+func (r *backupRestorer) create(kind string, value interface{}) error {
+	original := reflect.ValueOf(value).Elem().Interface()
+	if err := r.tx.Create(value).Error; err != nil {
+		return fmt.Errorf("恢复%s失败: %v", kind, err)
+	}
+	if err := r.tx.Model(value).Select("*").UpdateColumns(original).Error; err != nil {
+		return fmt.Errorf("恢复%s失败: %v", kind, err)
+	}
+	r.result.Created[kind]++
+	return nil
+}
+
+func (r *backupRestorer) restoreUsers() error {
+	archiveIDs := make([]uint, 0, len(r.archive.Users))
+	for _, u := range r.archive.Users {
+		archiveIDs = append(archiveIDs, u.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.User{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.Users {
+		var existing models.User
+		if err := r.tx.Where("user = ?", item.User.User).First(&existing).Error; err == nil {
+			r.users[item.ID] = existing.ID
+			r.result.Merged["users"]++
+			continue
+		}
+		user := item.User
+		user.Pwd = item.Pwd
+		user.ID = ids.assign(item.ID)
+		if err := r.create("users", &user); err != nil {
+			return err
+		}
+		r.users[item.ID] = user.ID
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreNodes() error {
+	archiveIDs := make([]uint, 0, len(r.archive.Nodes))
+	for _, n := range r.archive.Nodes {
+		archiveIDs = append(archiveIDs, n.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.Node{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.Nodes {
+		var existing models.Node
+		if err := r.tx.Where("secret = ?", item.Secret).First(&existing).Error; err == nil {
+			r.nodes[item.ID] = existing.ID
+			r.result.Merged["nodes"]++
+			continue
+		}
+		node := item
+		node.ID = ids.assign(item.ID)
+		node.Status = 0 // 节点使用原密钥连接后更新为在线
+		node.PrevSecret = ""
+		node.PrevSecretExpire = 0
+		if err := r.create("nodes", &node); err != nil {
+			return err
+		}
+		r.nodes[item.ID] = node.ID
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreNodeGroups() error {
+	archiveIDs := make([]uint, 0, len(r.archive.NodeGroups))
+	for _, g := range r.archive.NodeGroups {
+		archiveIDs = append(archiveIDs, g.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.NodeGroup{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.NodeGroups {
+		var group models.NodeGroup
+		if err := r.tx.Where("name = ?", item.Name).First(&group).Error; err == nil {
+			r.result.Merged["nodeGroups"]++
+		} else {
+			group = models.NodeGroup{
+				ID:          ids.assign(item.ID),
+				Name:        item.Name,
+				Tags:        joinTags(item.TagList),
+				CreatedTime: item.CreatedTime,
+				UpdatedTime: item.UpdatedTime,
+			}
+			if err := r.create("nodeGroups", &group); err != nil {
+				return err
+			}
+		}
+		r.groups[item.ID] = group.ID
+
+		// 同名节点组沿用时只补充缺少的成员
+		for _, nodeID := range item.NodeIDs {
+			member := models.NodeGroupMember{GroupID: group.ID, NodeID: r.nodes[nodeID]}
+			if err := r.tx.Where(&member).FirstOrCreate(&member).Error; err != nil {
+				return fmt.Errorf("恢复节点组 %s 的成员失败: %v", item.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreDNSProviders() error {
+	archiveIDs := make([]uint, 0, len(r.archive.DNSProviders))
+	for _, p := range r.archive.DNSProviders {
+		archiveIDs = append(archiveIDs, p.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.DNSProvider{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.DNSProviders {
+		var existing models.DNSProvider
+		if err := r.tx.Where("name = ?", item.Name).First(&existing).Error; err == nil {
+			r.providers[item.ID] = existing.ID
+			r.result.Merged["dnsProviders"]++
+			continue
+		}
+		provider := item.DNSProvider
+		provider.ID = ids.assign(item.ID)
+		provider.Config = item.RawConfig
+		if err := r.create("dnsProviders", &provider); err != nil {
+			return err
+		}
+		r.providers[item.ID] = provider.ID
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreTunnels() error {
+	archiveIDs := make([]uint, 0, len(r.archive.Tunnels))
+	for _, t := range r.archive.Tunnels {
+		archiveIDs = append(archiveIDs, t.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.Tunnel{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.Tunnels {
+		tunnel := item
+		tunnel.ID = ids.assign(item.ID)
+		tunnel.InNodeID = r.nodes[item.InNodeID]
+		tunnel.OutNodeID = r.nodes[item.OutNodeID]
+		tunnel.InGroupID = r.groups[item.InGroupID]
+		tunnel.OutGroupID = r.groups[item.OutGroupID]
+		tunnel.DNSProviderID = r.providers[item.DNSProviderID]
+		if err := r.create("tunnels", &tunnel); err != nil {
+			return err
+		}
+		r.tunnels[item.ID] = tunnel.ID
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreSpeedLimits() error {
+	archiveIDs := make([]uint, 0, len(r.archive.SpeedLimits))
+	for _, l := range r.archive.SpeedLimits {
+		archiveIDs = append(archiveIDs, l.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.SpeedLimit{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.SpeedLimits {
+		speedLimit := item
+		speedLimit.ID = ids.assign(item.ID)
+		speedLimit.TunnelID = int64(r.tunnels[uint(item.TunnelID)])
+		if err := r.create("speedLimits", &speedLimit); err != nil {
+			return err
+		}
+		r.speedLimits[item.ID] = speedLimit.ID
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreUserTunnels() error {
+	archiveIDs := make([]uint, 0, len(r.archive.UserTunnels))
+	for _, ut := range r.archive.UserTunnels {
+		archiveIDs = append(archiveIDs, ut.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.UserTunnel{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.UserTunnels {
+		userTunnel := item
+		userTunnel.ID = ids.assign(item.ID)
+		userTunnel.UserID = r.users[item.UserID]
+		userTunnel.TunnelID = r.tunnels[item.TunnelID]
+		userTunnel.SpeedID = int(r.speedLimits[uint(item.SpeedID)])
+		if err := r.create("userTunnels", &userTunnel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreForwards() error {
+	archiveIDs := make([]uint, 0, len(r.archive.Forwards))
+	for _, f := range r.archive.Forwards {
+		archiveIDs = append(archiveIDs, f.ID)
+	}
+	ids, err := newIDAllocator(r.tx, &models.Forward{}, archiveIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range r.archive.Forwards {
+		forward := item
+		forward.ID = ids.assign(item.ID)
+		forward.UserID = int(r.users[uint(item.UserID)])
+		forward.TunnelID = int(r.tunnels[uint(item.TunnelID)])
+		forward.InNodeID = r.nodes[item.InNodeID]
+		if err := r.create("forwards", &forward); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreConfigs 前端配置按名称覆盖
+func (r *backupRestorer) restoreConfigs() error {
+	for _, item := range r.archive.Configs {
+		var config models.ViteConfig
+		if err := r.tx.Where("name = ?", item.Name).First(&config).Error; err == nil {
+			config.Value = item.Value
+			config.Time = time.Now().UnixMilli()
+			if err := r.tx.Save(&config).Error; err != nil {
+				return fmt.Errorf("恢复配置 %s 失败: %v", item.Name, err)
+			}
+			r.result.Merged["configs"]++
+			continue
+		}
+		config = models.ViteConfig{Name: item.Name, Value: item.Value, Time: item.Time}
+		if err := r.create("configs", &config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRestorer) restoreStatisticsFlows() error {
+	if len(r.archive.StatisticsFlows) == 0 {
+		return nil
+	}
+	flows := make([]models.StatisticsFlow, 0, len(r.archive.StatisticsFlows))
+	for _, item := range r.archive.StatisticsFlows {
+		flow := item
+		flow.ID = 0
+		flow.UserID = int(r.users[uint(item.UserID)])
+		flows = append(flows, flow)
+	}
+	if err := r.tx.CreateInBatches(flows, 500).Error; err != nil {
+		return fmt.Errorf("恢复流量统计失败: %v", err)
+	}
+	r.result.Created["statisticsFlows"] = len(flows)
+	return nil
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BackupService 面板备份与恢复
+// 备份包含用户、节点、节点组、DNS 服务商、隧道、用户隧道权限、转发、限速规则和前端配置，可选包含流量统计历史；
+// 恢复时在一个事务中写入，并在完成后将全部隧道的转发与限速规则重新下发到节点
+type BackupService struct {
+	db                *gorm.DB
+	tunnelRepo        *repository.TunnelRepository
+	forwardRepo       *repository.ForwardRepository
+	locator           *nodeLocator
+	forwardService    *ForwardService
+	speedLimitService *SpeedLimitService
+	dnsService        *DNSService
+	audit             *AuditService
+}
+
+func NewBackupService(db *gorm.DB) *BackupService {
+	return &BackupService{
+		db:                db,
+		tunnelRepo:        repository.NewTunnelRepository(db),
+		forwardRepo:       repository.NewForwardRepository(db),
+		locator:           newNodeLocator(db),
+		forwardService:    NewForwardService(db),
+		speedLimitService: NewSpeedLimitService(db),
+		dnsService:        NewDNSService(db),
+		audit:             NewAuditService(db),
+	}
+}
+
+// Export 导出面板数据，includeFlow 为 true 时包含流量统计历史
+func (s *BackupService) Export(actor Actor, includeFlow bool) (_ *dto.BackupArchive, err error) {
+	entry := &AuditEntry{Action: "panel.backup", TargetType: "panel"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	archive := &dto.BackupArchive{
+		Version:     dto.BackupVersion,
+		CreatedTime: time.Now().UnixMilli(),
+	}
+
+	var users []models.User
+	if err := s.db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		archive.Users = append(archive.Users, dto.BackupUser{User: user, Pwd: user.Pwd})
+	}
+
+	var providers []models.DNSProvider
+	if err := s.db.Order("id").Find(&providers).Error; err != nil {
+		return nil, err
+	}
+	for _, provider := range providers {
+		archive.DNSProviders = append(archive.DNSProviders, dto.BackupDNSProvider{DNSProvider: provider, RawConfig: provider.Config})
+	}
+
+	tables := []interface{}{
+		&archive.Nodes,
+		&archive.NodeGroups,
+		&archive.Tunnels,
+		&archive.UserTunnels,
+		&archive.Forwards,
+		&archive.SpeedLimits,
+		&archive.Configs,
+	}
+	if includeFlow {
+		tables = append(tables, &archive.StatisticsFlows)
+	}
+	for _, dest := range tables {
+		if err := s.db.Order("id").Find(dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var members []models.NodeGroupMember
+	if err := s.db.Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	memberIDs := make(map[uint][]uint)
+	for _, m := range members {
+		memberIDs[m.GroupID] = append(memberIDs[m.GroupID], m.NodeID)
+	}
+	for i := range archive.NodeGroups {
+		archive.NodeGroups[i].TagList = splitTags(archive.NodeGroups[i].Tags)
+		archive.NodeGroups[i].NodeIDs = memberIDs[archive.NodeGroups[i].ID]
+	}
+
+	entry.Message = fmt.Sprintf("用户 %d，节点 %d，隧道 %d，转发 %d，包含流量历史: %v",
+		len(archive.Users), len(archive.Nodes), len(archive.Tunnels), len(archive.Forwards), includeFlow)
+	return archive, nil
+}
+
+// Restore 校验并恢复备份，完成后将全部隧道的配置下发到节点
+// 目标库中同名的用户、节点组、DNS 服务商以及密钥相同的节点直接沿用，不覆盖；前端配置按名称覆盖；
+// 其余数据全部新建，原ID在目标库中未被占用时保留，否则重新分配；目标库中已有隧道、转发等无法合并的数据时拒绝恢复
+func (s *BackupService) Restore(actor Actor, archive *dto.BackupArchive) (_ *dto.RestoreResultDto, err error) {
+	entry := &AuditEntry{Action: "panel.restore", TargetType: "panel"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	if err := validateBackup(archive); err != nil {
+		return nil, err
+	}
+
+	result := &dto.RestoreResultDto{
+		Created:    make(map[string]int),
+		Merged:     make(map[string]int),
+		PushErrors: []string{},
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return newBackupRestorer(tx, archive, result).run()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.PushErrors = s.PushAll()
+	entry.Message = fmt.Sprintf("新建 %v，合并 %v，下发失败 %d", result.Created, result.Merged, len(result.PushErrors))
+	return result, nil
+}
+
+// PushAll 将全部隧道的限速规则与转发下发到节点，非启用状态的转发下发后随即暂停，并同步入口域名的解析记录
+// 节点离线时命令进入队列，节点上线后下发
+func (s *BackupService) PushAll() []string {
+	errs := []string{}
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return append(errs, err.Error())
+	}
+
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		inNodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("隧道 %s: %v", tunnel.Name, err))
+			continue
+		}
+		// 限流器需先于引用它的服务下发
+		s.speedLimitService.SyncTunnelLimiters(tunnel.ID, inNodes)
+		if err := s.forwardService.SyncTunnelForwards(tunnel); err != nil {
+			errs = append(errs, fmt.Sprintf("隧道 %s: %v", tunnel.Name, err))
+		}
+
+		forwards, err := s.forwardRepo.FindByTunnelID(tunnel.ID)
+		if err != nil {
+			continue
+		}
+		for j := range forwards {
+			if forwards[j].Status != 1 {
+				s.forwardService.SuspendForward(&forwards[j])
+			}
+		}
+	}
+
+	if err := s.dnsService.SyncAll(true); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, e := range errs {
+		log.Printf("恢复备份后下发配置失败: %s", e)
+	}
+	return errs
+}
+
+// WriteBackup 将备份以 gzip 压缩的 JSON 写入 w
+func WriteBackup(w io.Writer, archive *dto.BackupArchive) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(archive); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadBackup 读取备份文件，支持 gzip 压缩与未压缩的 JSON
+func ReadBackup(r io.Reader) (*dto.BackupArchive, error) {
+	br := bufio.NewReader(r)
+	var reader io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.New("备份文件解压失败: " + err.Error())
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var archive dto.BackupArchive
+	if err := json.NewDecoder(reader).Decode(&archive); err != nil {
+		return nil, errors.New("备份文件格式错误: " + err.Error())
+	}
+	return &archive, nil
+}
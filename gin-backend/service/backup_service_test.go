@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newBackupSource 创建包含用户、节点、隧道、权限和转发的面板并导出备份，经过一次写入与读取
+func newBackupSource(t *testing.T) *dto.BackupArchive {
+	t.Helper()
+	db := newTestDB(t)
+
+	alice := &models.User{User: "alice", Pwd: "alice-hash", RoleID: 1, Num: 10}
+	alice.ID = 10
+	carol := &models.User{User: "carol", Pwd: "carol-hash", RoleID: 1, Num: 10}
+	carol.ID = 11
+	n1 := &models.Node{Name: "n1", Secret: "s1", ServerIP: "10.0.0.1", PortSta: 20000, PortEnd: 20100}
+	n1.ID = 5
+	n2 := &models.Node{Name: "n2", Secret: "s2", ServerIP: "10.0.0.2", PortSta: 30000, PortEnd: 30100}
+	n2.ID = 6
+	tunnel := &models.Tunnel{Name: "t", Type: 2, InNodeID: 5, OutNodeID: 6, Protocol: "tls"}
+	tunnel.ID = 3
+	tunnel.Status = 1
+	userTunnel := &models.UserTunnel{UserID: 10, TunnelID: 3, Num: 10}
+	userTunnel.ID = 4
+	userTunnel.Status = 1
+	forward := &models.Forward{Name: "web", UserID: 10, UserName: "alice", TunnelID: 3, RemoteAddr: "10.1.0.1:80", InPort: 20001, OutPort: 30001, PortCount: 1}
+	forward.ID = 7
+	forward.Status = 1
+	for _, v := range []interface{}{alice, carol, n1, n2, tunnel, userTunnel, forward} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive, err := NewBackupService(db).Export(Actor{Name: "admin"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf, archive); err != nil {
+		t.Fatal(err)
+	}
+	archive, err = ReadBackup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func findByName(t *testing.T, db *gorm.DB, value interface{}, column, name string) {
+	t.Helper()
+	if err := db.Where(column+" = ?", name).First(value).Error; err != nil {
+		t.Fatalf("未找到 %s: %v", name, err)
+	}
+}
+
+// TestRestoreKeepsFreeIDs 恢复到空面板时保留全部原ID
+func TestRestoreKeepsFreeIDs(t *testing.T) {
+	archive := newBackupSource(t)
+	db := newTestDB(t)
+
+	result, err := NewBackupService(db).Restore(Actor{Name: "admin"}, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created["users"] != 2 || result.Created["nodes"] != 2 || result.Created["forwards"] != 1 || len(result.Merged) != 0 {
+		t.Errorf("恢复结果不符合预期: created=%v merged=%v", result.Created, result.Merged)
+	}
+
+	var alice models.User
+	findByName(t, db, &alice, "user", "alice")
+	if alice.ID != 10 || alice.Pwd != "alice-hash" {
+		t.Errorf("用户 alice 恢复为 id=%d pwd=%s", alice.ID, alice.Pwd)
+	}
+	var n1 models.Node
+	findByName(t, db, &n1, "name", "n1")
+	if n1.ID != 5 {
+		t.Errorf("节点 n1 的ID为 %d，应保留 5", n1.ID)
+	}
+	var forward models.Forward
+	if err := db.First(&forward, 7).Error; err != nil {
+		t.Fatalf("转发未保留原ID: %v", err)
+	}
+	if forward.UserID != 10 || forward.TunnelID != 3 || forward.InPort != 20001 || forward.OutPort != 30001 {
+		t.Errorf("转发恢复后的关联或端口不一致: %+v", forward)
+	}
+}
+
+// TestRestoreRemapsClashingIDs 目标库中同名用户与密钥相同的节点直接沿用，原ID被占用的数据重新分配ID并更新关联
+func TestRestoreRemapsClashingIDs(t *testing.T) {
+	archive := newBackupSource(t)
+	db := newTestDB(t)
+
+	bob := &models.User{User: "bob", Pwd: "x", RoleID: 1}
+	bob.ID = 10 // 占用 alice 的原ID
+	carol := &models.User{User: "carol", Pwd: "target-hash", RoleID: 1}
+	carol.ID = 20 // 同名用户，沿用
+	other := &models.Node{Name: "other", Secret: "x", ServerIP: "10.0.0.9", PortSta: 1000, PortEnd: 2000}
+	other.ID = 5 // 占用 n1 的原ID
+	n2 := &models.Node{Name: "n2-existing", Secret: "s2", ServerIP: "10.0.0.2", PortSta: 30000, PortEnd: 30100}
+	n2.ID = 8 // 密钥相同的节点，沿用
+	for _, v := range []interface{}{bob, carol, other, n2} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := NewBackupService(db).Restore(Actor{Name: "admin"}, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Merged["users"] != 1 || result.Merged["nodes"] != 1 {
+		t.Errorf("合并结果不符合预期: %v", result.Merged)
+	}
+
+	var alice models.User
+	findByName(t, db, &alice, "user", "alice")
+	if alice.ID == 10 || alice.ID <= 20 {
+		t.Errorf("alice 的ID %d 应重新分配为大于现有ID的值", alice.ID)
+	}
+	var storedCarol models.User
+	findByName(t, db, &storedCarol, "user", "carol")
+	if storedCarol.ID != 20 || storedCarol.Pwd != "target-hash" {
+		t.Errorf("同名用户被覆盖: %+v", storedCarol)
+	}
+	var n1 models.Node
+	findByName(t, db, &n1, "name", "n1")
+	if n1.ID == 5 {
+		t.Error("n1 的原ID已被占用，应重新分配")
+	}
+
+	var tunnel models.Tunnel
+	if err := db.First(&tunnel, 3).Error; err != nil {
+		t.Fatalf("隧道未保留原ID: %v", err)
+	}
+	if tunnel.InNodeID != n1.ID || tunnel.OutNodeID != 8 {
+		t.Errorf("隧道节点关联为 %d/%d，应为 %d/8", tunnel.InNodeID, tunnel.OutNodeID, n1.ID)
+	}
+	var userTunnel models.UserTunnel
+	if err := db.First(&userTunnel, 4).Error; err != nil {
+		t.Fatal(err)
+	}
+	if userTunnel.UserID != alice.ID || userTunnel.TunnelID != 3 {
+		t.Errorf("用户隧道关联不一致: %+v", userTunnel)
+	}
+	var forward models.Forward
+	if err := db.First(&forward, 7).Error; err != nil {
+		t.Fatal(err)
+	}
+	if forward.UserID != int(alice.ID) || forward.TunnelID != 3 {
+		t.Errorf("转发关联不一致: %+v", forward)
+	}
+}
+
+// TestRestoreRefusesDuplicates 目标库已有隧道等无法合并的数据时拒绝恢复，不写入任何数据
+func TestRestoreRefusesDuplicates(t *testing.T) {
+	archive := newBackupSource(t)
+	db := newTestDB(t)
+
+	existing := &models.Tunnel{Name: "existing", Type: 1}
+	if err := db.Create(existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewBackupService(db).Restore(Actor{Name: "admin"}, archive)
+	if err == nil || !strings.Contains(err.Error(), "隧道") {
+		t.Fatalf("应拒绝恢复，实际错误: %v", err)
+	}
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	if users != 0 {
+		t.Errorf("拒绝恢复后写入了 %d 个用户", users)
+	}
+}
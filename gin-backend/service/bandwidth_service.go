@@ -0,0 +1,304 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"flux-panel/cluster"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// BandwidthTestServicePrefix 带宽测试临时服务的名称前缀，该前缀的流量不计入用户
+const BandwidthTestServicePrefix = "bwtest_"
+
+const (
+	defaultBandwidthTestDuration = 10
+	maxBandwidthTestDuration     = 20
+	bandwidthServerGrace         = 30 // 出口节点测试服务在测试时长之外的存活时间(秒)
+	bandwidthTestHistory         = 10
+)
+
+// runningBandwidthTests 正在测试的隧道，同一隧道同时只允许一个测试
+var runningBandwidthTests sync.Map
+
+type BandwidthService struct {
+	repo           *repository.BandwidthTestRepository
+	tunnelRepo     *repository.TunnelRepository
+	nodeRepo       *repository.NodeRepository
+	forwardService *ForwardService
+}
+
+func NewBandwidthService(db *gorm.DB) *BandwidthService {
+	return &BandwidthService{
+		repo:           repository.NewBandwidthTestRepository(db),
+		tunnelRepo:     repository.NewTunnelRepository(db),
+		nodeRepo:       repository.NewNodeRepository(db),
+		forwardService: NewForwardService(db),
+	}
+}
+
+// bandwidthClientResult 入口节点返回的测试结果
+type bandwidthClientResult struct {
+	Duration     float64 `json:"duration"`
+	Bytes        int64   `json:"bytes"`
+	Bps          float64 `json:"bps"`
+	Latency      float64 `json:"latency"`
+	Sent         int64   `json:"sent"`
+	ErrorMessage string  `json:"errorMessage"`
+}
+
+// bandwidthServerStats 出口节点返回的接收统计
+type bandwidthServerStats struct {
+	TCPBytes   int64   `json:"tcpBytes"`
+	TCPBps     float64 `json:"tcpBps"`
+	UDPPackets int64   `json:"udpPackets"`
+	UDPBytes   int64   `json:"udpBytes"`
+	UDPBps     float64 `json:"udpBps"`
+	Jitter     float64 `json:"jitter"`
+}
+
+// RunTest 在隧道的入口与出口节点之间按隧道协议执行带宽测试并保存结果
+// 出口节点在空闲端口上启动临时测试服务，入口节点经与隧道相同的链路收发数据，测试流量不经过用户的转发服务
+func (s *BandwidthService) RunTest(testDto *dto.BandwidthTestDto) (*models.BandwidthTest, error) {
+	tunnel, err := s.tunnelRepo.FindByID(testDto.TunnelID)
+	if err != nil {
+		return nil, errors.New("隧道不存在")
+	}
+	if tunnel.Type != 2 {
+		return nil, errors.New("仅隧道转发支持带宽测试")
+	}
+	inNodes, outNodes, err := s.forwardService.locator.tunnelNodes(tunnel)
+	if err != nil {
+		return nil, err
+	}
+	inNode, err := pickMember(inNodes, testDto.InNodeID, "入口")
+	if err != nil {
+		return nil, err
+	}
+	outNode, err := pickMember(outNodes, testDto.OutNodeID, "出口")
+	if err != nil {
+		return nil, err
+	}
+
+	mode := testDto.Mode
+	if mode == "" {
+		mode = "tcp"
+	}
+	if mode != "tcp" && mode != "udp" {
+		return nil, fmt.Errorf("不支持的测试模式: %s", mode)
+	}
+	direction := testDto.Direction
+	if direction == "" || mode == "udp" {
+		direction = "upload"
+	}
+	if direction != "upload" && direction != "download" {
+		return nil, fmt.Errorf("不支持的测试方向: %s", direction)
+	}
+	duration := testDto.Duration
+	if duration <= 0 {
+		duration = defaultBandwidthTestDuration
+	}
+	if duration > maxBandwidthTestDuration {
+		return nil, fmt.Errorf("测试时长不能超过 %d 秒", maxBandwidthTestDuration)
+	}
+
+	if _, running := runningBandwidthTests.LoadOrStore(tunnel.ID, true); running {
+		return nil, errors.New("该隧道正在进行带宽测试")
+	}
+	defer runningBandwidthTests.Delete(tunnel.ID)
+
+	port := s.freeOutPort(outNode)
+	if port == 0 {
+		return nil, errors.New("出口节点无可用端口")
+	}
+
+	test := &models.BandwidthTest{
+		TunnelID:  tunnel.ID,
+		InNodeID:  inNode.ID,
+		OutNodeID: outNode.ID,
+		Protocol:  tunnel.Protocol,
+		Mode:      mode,
+		Direction: direction,
+		Duration:  duration,
+		Bandwidth: testDto.Bandwidth,
+		State:     models.BandwidthTestRunning,
+	}
+	if err := s.repo.Create(test); err != nil {
+		return nil, err
+	}
+	release := holdJobLease(bandwidthTestLease(test.ID))
+	defer release()
+
+	if err := s.execute(test, tunnel, outNode, port); err != nil {
+		test.State = models.BandwidthTestFailed
+		test.Message = err.Error()
+	} else {
+		test.State = models.BandwidthTestDone
+	}
+	if err := s.repo.Update(test); err != nil {
+		log.Printf("保存带宽测试 %d 结果失败: %v", test.ID, err)
+	}
+	return test, nil
+}
+
+// execute 依次启动出口测试服务、执行入口测试、停止出口服务并汇总两端的结果
+func (s *BandwidthService) execute(test *models.BandwidthTest, tunnel *models.Tunnel, outNode *models.Node, port int) error {
+	name := BandwidthTestServicePrefix + strconv.FormatUint(uint64(test.ID), 10)
+
+	startResp := sendGostMessage(outNode.ID, map[string]interface{}{
+		"name":     name,
+		"port":     port,
+		"protocol": tunnel.Protocol,
+		"ttl":      test.Duration + bandwidthServerGrace,
+	}, websocket.MessageTypeStartBandwidthServer)
+	if !startResp.Success {
+		return fmt.Errorf("出口节点启动测试服务失败: %s", startResp.Message)
+	}
+	var started struct {
+		Target string `json:"target"`
+	}
+	if err := decodeGostData(startResp.Data, &started); err != nil || started.Target == "" {
+		sendGostMessage(outNode.ID, map[string]interface{}{"name": name}, websocket.MessageTypeStopBandwidthServer)
+		return errors.New("出口节点未返回测试接收端地址")
+	}
+
+	clientReq := map[string]interface{}{
+		"addr":      net.JoinHostPort(outNode.ServerIP, strconv.Itoa(port)),
+		"target":    started.Target,
+		"protocol":  tunnel.Protocol,
+		"mode":      test.Mode,
+		"direction": test.Direction,
+		"duration":  test.Duration,
+		"bandwidth": test.Bandwidth,
+	}
+	if tunnel.InterfaceName != "" {
+		clientReq["interface"] = tunnel.InterfaceName
+	}
+	clientResp := sendGostMessage(test.InNodeID, clientReq, websocket.MessageTypeBandwidthTest)
+
+	// 无论入口测试是否成功都要停止出口服务
+	stopResp := sendGostMessage(outNode.ID, map[string]interface{}{"name": name}, websocket.MessageTypeStopBandwidthServer)
+
+	if !clientResp.Success {
+		return fmt.Errorf("入口节点测试失败: %s", clientResp.Message)
+	}
+	var client bandwidthClientResult
+	if err := decodeGostData(clientResp.Data, &client); err != nil {
+		return errors.New("入口节点返回的测试结果格式错误")
+	}
+	if client.ErrorMessage != "" {
+		return errors.New(client.ErrorMessage)
+	}
+	test.Latency = client.Latency
+	test.ClientBytes = client.Bytes
+	test.ClientBps = client.Bps
+	test.Sent = client.Sent
+
+	var server bandwidthServerStats
+	if stopResp.Success {
+		decodeGostData(stopResp.Data, &server)
+	} else {
+		test.Message = "出口节点统计获取失败: " + stopResp.Message
+	}
+
+	// 吞吐以接收端的测量为准
+	switch {
+	case test.Mode == "udp":
+		test.ServerBytes = server.UDPBytes
+		test.ServerBps = server.UDPBps
+		test.Received = server.UDPPackets
+		test.Jitter = server.Jitter
+		test.Throughput = server.UDPBps
+		if test.Sent > 0 {
+			lost := test.Sent - test.Received
+			if lost < 0 {
+				lost = 0
+			}
+			test.PacketLoss = float64(lost) / float64(test.Sent) * 100
+		}
+	case test.Direction == "download":
+		test.Throughput = client.Bps
+	default:
+		test.ServerBytes = server.TCPBytes
+		test.ServerBps = server.TCPBps
+		test.Throughput = server.TCPBps
+		if test.Throughput == 0 {
+			test.Throughput = client.Bps
+		}
+	}
+	return nil
+}
+
+// pickMember 选取测试的节点，未指定时为第一个成员
+func pickMember(nodes []models.Node, nodeID uint, role string) (*models.Node, error) {
+	if nodeID == 0 {
+		return &nodes[0], nil
+	}
+	matched := filterNodes(nodes, nodeID)
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("指定的%s节点不属于该隧道", role)
+	}
+	return &matched[0], nil
+}
+
+// freeOutPort 在出口节点端口范围内选取未被转发占用的端口
+func (s *BandwidthService) freeOutPort(outNode *models.Node) int {
+	used := s.forwardService.getAllUsedPorts(outNode.ID)
+	var available []int
+	for p := outNode.PortSta; p <= outNode.PortEnd; p++ {
+		if !used[p] {
+			available = append(available, p)
+		}
+	}
+	return s.forwardService.getRandomPort(available)
+}
+
+// GetTests 获取隧道最近的带宽测试记录
+func (s *BandwidthService) GetTests(tunnelID uint) ([]models.BandwidthTest, error) {
+	if _, err := s.tunnelRepo.FindByID(tunnelID); err != nil {
+		return nil, errors.New("隧道不存在")
+	}
+	return s.repo.FindByTunnel(tunnelID, bandwidthTestHistory)
+}
+
+// bandwidthTestLease 执行带宽测试的实例持有的租约
+func bandwidthTestLease(id uint) string {
+	return fmt.Sprintf("bandwidth_test:%d", id)
+}
+
+// InterruptTests 面板启动时将执行实例已退出的测试标记为失败，其他实例仍在执行的测试不受影响
+func (s *BandwidthService) InterruptTests() error {
+	tests, err := s.repo.FindRunning()
+	if err != nil {
+		return err
+	}
+	var ids []uint
+	for _, test := range tests {
+		if lease := bandwidthTestLease(test.ID); claimStaleJob(lease) {
+			defer cluster.Unlock(lease)
+			ids = append(ids, test.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.repo.Interrupt(ids, "面板重启，测试中断")
+}
+
+// decodeGostData 将节点响应的数据解析为具体结构
+func decodeGostData(data interface{}, v interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -4,18 +4,22 @@ import (
 	"errors"
 	"flux-panel/models"
 	"flux-panel/repository"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type ConfigService struct {
-	repo *repository.ConfigRepository
+	repo  *repository.ConfigRepository
+	audit *AuditService
 }
 
 func NewConfigService(db *gorm.DB) *ConfigService {
 	return &ConfigService{
-		repo: repository.NewConfigRepository(db),
+		repo:  repository.NewConfigRepository(db),
+		audit: NewAuditService(db),
 	}
 }
 
@@ -42,11 +46,12 @@ func (s *ConfigService) GetConfigByName(name string) (*models.ViteConfig, error)
 }
 
 // UpdateConfigs 批量更新配置
-func (s *ConfigService) UpdateConfigs(configMap map[string]string) error {
+func (s *ConfigService) UpdateConfigs(actor Actor, configMap map[string]string) (err error) {
+	delete(configMap, "")
+	entry := &AuditEntry{Action: "config.update", TargetType: "config", Before: s.configValues(configMap), After: configMap}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	for name, value := range configMap {
-		if name == "" {
-			continue
-		}
 		if err := s.updateOrCreateConfig(name, value); err != nil {
 			return err
 		}
@@ -55,13 +60,29 @@ func (s *ConfigService) UpdateConfigs(configMap map[string]string) error {
 }
 
 // UpdateConfig 更新单个配置
-func (s *ConfigService) UpdateConfig(name, value string) error {
+func (s *ConfigService) UpdateConfig(actor Actor, name, value string) (err error) {
+	entry := &AuditEntry{Action: "config.update", TargetType: "config", TargetName: name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	if name == "" {
 		return errors.New("配置名称不能为空")
 	}
+	entry.Before = s.configValues(map[string]string{name: value})
+	entry.After = map[string]string{name: value}
 	return s.updateOrCreateConfig(name, value)
 }
 
+// configValues 获取 configMap 中各配置的当前值，用于审计记录变更前的状态
+func (s *ConfigService) configValues(configMap map[string]string) map[string]string {
+	values := make(map[string]string, len(configMap))
+	for name := range configMap {
+		if config, err := s.repo.FindByName(name); err == nil {
+			values[name] = config.Value
+		}
+	}
+	return values
+}
+
 func (s *ConfigService) updateOrCreateConfig(name, value string) error {
 	config, err := s.repo.FindByName(name)
 	if err != nil {
@@ -79,3 +100,16 @@ func (s *ConfigService) updateOrCreateConfig(name, value string) error {
 	config.Time = time.Now().UnixMilli()
 	return s.repo.Update(config)
 }
+
+// configPositiveInt 读取正整数配置，未配置或非法时使用默认值
+func configPositiveInt(repo *repository.ConfigRepository, name string, def int) int {
+	config, err := repo.FindByName(name)
+	if err != nil {
+		return def
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(config.Value))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"flux-panel/config"
+	"flux-panel/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建已迁移的 sqlite 测试数据库，并设为全局数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	config.AppConfig = &config.Config{}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "flux.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	models.DB = db
+	if err := models.AutoMigrate(); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() { models.CloseDB() })
+	return db
+}
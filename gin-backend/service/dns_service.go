@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flux-panel/dnsprovider"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// secretMask 查询服务商时密钥的脱敏值，更新时传回该值表示保留原密钥
+const secretMask = "******"
+
+// dnsOfflineGrace 节点离线超过该时间才将其从解析记录中移除，避免短暂断线导致记录频繁变更
+const dnsOfflineGrace = time.Minute
+
+// dnsSyncTimeout 单次调用 DNS 服务商的超时时间
+const dnsSyncTimeout = 30 * time.Second
+
+var (
+	dnsOfflineMu     sync.Mutex
+	dnsOfflineTimers = make(map[uint]*time.Timer)
+)
+
+// DNSService 维护隧道入口域名的解析记录
+// 隧道配置了域名与 DNS 服务商后，域名解析到全部在线的入口节点，入口节点全部离线时解析到全部入口节点
+type DNSService struct {
+	repo       *repository.DNSRepository
+	tunnelRepo *repository.TunnelRepository
+	groupRepo  *repository.NodeGroupRepository
+	locator    *nodeLocator
+	audit      *AuditService
+}
+
+func NewDNSService(db *gorm.DB) *DNSService {
+	return &DNSService{
+		repo:       repository.NewDNSRepository(db),
+		tunnelRepo: repository.NewTunnelRepository(db),
+		groupRepo:  repository.NewNodeGroupRepository(db),
+		locator:    newNodeLocator(db),
+		audit:      NewAuditService(db),
+	}
+}
+
+// CreateProvider 创建 DNS 服务商
+func (s *DNSService) CreateProvider(actor Actor, providerDto *dto.DNSProviderDto) (err error) {
+	entry := &AuditEntry{Action: "dns_provider.create", TargetType: "dns_provider", TargetName: providerDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	name := strings.TrimSpace(providerDto.Name)
+	if name == "" {
+		return errors.New("服务商名称不能为空")
+	}
+	if _, err := s.repo.FindProviderByName(name); err == nil {
+		return errors.New("服务商名称已存在")
+	}
+	if _, err := dnsprovider.New(providerDto.Type, providerDto.Config); err != nil {
+		return err
+	}
+
+	config, err := json.Marshal(providerDto.Config)
+	if err != nil {
+		return err
+	}
+	provider := &models.DNSProvider{
+		Name:   name,
+		Type:   providerDto.Type,
+		Config: string(config),
+		TTL:    providerDto.TTL,
+	}
+	if provider.TTL <= 0 {
+		provider.TTL = 60
+	}
+	if err := s.repo.CreateProvider(provider); err != nil {
+		return err
+	}
+	entry.TargetID = provider.ID
+	entry.After = provider
+	return nil
+}
+
+// GetAllProviders 获取所有 DNS 服务商，配置中的密钥脱敏
+func (s *DNSService) GetAllProviders() ([]models.DNSProvider, error) {
+	providers, err := s.repo.FindAllProviders()
+	if err != nil {
+		return nil, err
+	}
+	for i := range providers {
+		config := decodeProviderConfig(&providers[i])
+		for _, key := range dnsprovider.SecretKeys {
+			if config[key] != "" {
+				config[key] = secretMask
+			}
+		}
+		providers[i].ConfigMap = config
+	}
+	return providers, nil
+}
+
+// UpdateProvider 更新 DNS 服务商，配置变更后重新同步使用该服务商的隧道
+func (s *DNSService) UpdateProvider(actor Actor, updateDto *dto.DNSProviderUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "dns_provider.update", TargetType: "dns_provider", TargetID: updateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	provider, err := s.repo.FindProviderByID(updateDto.ID)
+	if err != nil {
+		return errors.New("DNS 服务商不存在")
+	}
+	entry.TargetName = provider.Name
+	before := *provider
+	entry.Before = &before
+
+	if updateDto.Name != nil {
+		name := strings.TrimSpace(*updateDto.Name)
+		if name == "" {
+			return errors.New("服务商名称不能为空")
+		}
+		if existing, err := s.repo.FindProviderByName(name); err == nil && existing.ID != provider.ID {
+			return errors.New("服务商名称已存在")
+		}
+		provider.Name = name
+	}
+	if updateDto.TTL != nil {
+		provider.TTL = *updateDto.TTL
+		if provider.TTL <= 0 {
+			provider.TTL = 60
+		}
+	}
+
+	configChanged := updateDto.Config != nil
+	if configChanged {
+		previous := decodeProviderConfig(provider)
+		for _, key := range dnsprovider.SecretKeys {
+			if updateDto.Config[key] == secretMask {
+				updateDto.Config[key] = previous[key]
+			}
+		}
+		if _, err := dnsprovider.New(provider.Type, updateDto.Config); err != nil {
+			return err
+		}
+		config, err := json.Marshal(updateDto.Config)
+		if err != nil {
+			return err
+		}
+		provider.Config = string(config)
+		// 服务商配置含密钥，审计日志只记录配置已变更
+		entry.Message = "更新服务商配置"
+	}
+
+	if err := s.repo.UpdateProvider(provider); err != nil {
+		return err
+	}
+	entry.After = provider
+	if !configChanged && updateDto.TTL == nil {
+		return nil
+	}
+
+	tunnels, err := s.tunnelRepo.FindByDNSProviderID(provider.ID)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := range tunnels {
+		if err := s.syncTunnel(&tunnels[i], true); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// DeleteProvider 删除 DNS 服务商，被隧道使用的服务商不能删除
+func (s *DNSService) DeleteProvider(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "dns_provider.delete", TargetType: "dns_provider", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	provider, err := s.repo.FindProviderByID(id)
+	if err != nil {
+		return errors.New("DNS 服务商不存在")
+	}
+	entry.TargetName = provider.Name
+	entry.Before = provider
+	tunnels, err := s.tunnelRepo.FindByDNSProviderID(id)
+	if err != nil {
+		return err
+	}
+	if len(tunnels) > 0 {
+		return fmt.Errorf("服务商正在被隧道 %s 使用，无法删除", tunnels[0].Name)
+	}
+	return s.repo.DeleteProvider(id)
+}
+
+// GetRecords 获取全部隧道入口域名的解析记录
+func (s *DNSService) GetRecords() ([]models.DNSRecord, error) {
+	return s.repo.FindAllRecords()
+}
+
+// ValidateTunnelHostname 规范化并校验隧道的入口域名配置
+func (s *DNSService) ValidateTunnelHostname(tunnel *models.Tunnel) error {
+	tunnel.Hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(tunnel.Hostname)), ".")
+	if tunnel.Hostname == "" {
+		tunnel.DNSProviderID = 0
+		return nil
+	}
+	if tunnel.DNSProviderID == 0 {
+		return errors.New("配置入口域名时需指定 DNS 服务商")
+	}
+	if _, err := s.repo.FindProviderByID(tunnel.DNSProviderID); err != nil {
+		return errors.New("DNS 服务商不存在")
+	}
+	tunnels, err := s.tunnelRepo.FindByDNSProviderID(tunnel.DNSProviderID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tunnels {
+		if t.ID != tunnel.ID && t.Hostname == tunnel.Hostname {
+			return fmt.Errorf("入口域名已被隧道 %s 使用", t.Name)
+		}
+	}
+	return nil
+}
+
+// SyncTunnel 将隧道入口域名解析到当前的入口节点，解析结果未变化时不调用服务商
+func (s *DNSService) SyncTunnel(tunnel *models.Tunnel) error {
+	return s.syncTunnel(tunnel, false)
+}
+
+// ForceSyncTunnel 重新写入隧道入口域名的解析记录
+func (s *DNSService) ForceSyncTunnel(tunnelID uint) error {
+	tunnel, err := s.tunnelRepo.FindByID(tunnelID)
+	if err != nil {
+		return errors.New("隧道不存在")
+	}
+	return s.syncTunnel(tunnel, true)
+}
+
+// DeleteTunnelRecord 删除隧道入口域名的解析记录，用于删除隧道时
+func (s *DNSService) DeleteTunnelRecord(tunnelID uint) error {
+	record, err := s.repo.FindRecord(tunnelID)
+	if err != nil {
+		return nil
+	}
+	if err := s.setRecords(record.ProviderID, record.Hostname, nil); err != nil {
+		return fmt.Errorf("删除域名 %s 的解析记录失败: %v", record.Hostname, err)
+	}
+	return s.repo.DeleteRecord(tunnelID)
+}
+
+// SyncNode 同步以该节点作为入口的隧道的解析记录，用于节点上线、离线或IP变更后
+func (s *DNSService) SyncNode(nodeID uint) error {
+	groupIDs, err := s.groupRepo.GroupIDsOfNode(nodeID)
+	if err != nil {
+		return err
+	}
+	tunnels, err := s.tunnelRepo.FindByEntryNode(nodeID, groupIDs)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := range tunnels {
+		if err := s.SyncTunnel(&tunnels[i]); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// SyncAll 同步全部隧道的解析记录，force 为 true 时即使解析结果未变化也重新写入
+func (s *DNSService) SyncAll(force bool) error {
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := range tunnels {
+		if err := s.syncTunnel(&tunnels[i], force); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// OnNodeStatus 节点上线后立即同步解析记录，离线超过 dnsOfflineGrace 后再同步
+func (s *DNSService) OnNodeStatus(nodeID uint, online bool) {
+	dnsOfflineMu.Lock()
+	if timer, ok := dnsOfflineTimers[nodeID]; ok {
+		timer.Stop()
+		delete(dnsOfflineTimers, nodeID)
+	}
+	if !online {
+		dnsOfflineTimers[nodeID] = time.AfterFunc(dnsOfflineGrace, func() {
+			dnsOfflineMu.Lock()
+			delete(dnsOfflineTimers, nodeID)
+			dnsOfflineMu.Unlock()
+			if err := s.SyncNode(nodeID); err != nil {
+				log.Printf("节点 %d 离线后同步解析记录失败: %v", nodeID, err)
+			}
+		})
+		dnsOfflineMu.Unlock()
+		return
+	}
+	dnsOfflineMu.Unlock()
+
+	if err := s.SyncNode(nodeID); err != nil {
+		log.Printf("节点 %d 上线后同步解析记录失败: %v", nodeID, err)
+	}
+}
+
+// syncTunnel 同步隧道的解析记录，域名或服务商变更时先删除旧记录
+func (s *DNSService) syncTunnel(tunnel *models.Tunnel, force bool) error {
+	record, err := s.repo.FindRecord(tunnel.ID)
+	exists := err == nil
+	if exists && (record.Hostname != tunnel.Hostname || record.ProviderID != tunnel.DNSProviderID) {
+		if err := s.setRecords(record.ProviderID, record.Hostname, nil); err != nil {
+			return fmt.Errorf("删除域名 %s 的旧解析记录失败: %v", record.Hostname, err)
+		}
+		if err := s.repo.DeleteRecord(tunnel.ID); err != nil {
+			return err
+		}
+		exists = false
+	}
+	if tunnel.Hostname == "" || tunnel.DNSProviderID == 0 {
+		return nil
+	}
+
+	ips, err := s.entryIPs(tunnel)
+	if err == nil && len(ips) == 0 {
+		err = errors.New("入口节点没有可用于解析的IP地址")
+	}
+	if !exists {
+		record = &models.DNSRecord{
+			TunnelID:   tunnel.ID,
+			ProviderID: tunnel.DNSProviderID,
+			Hostname:   tunnel.Hostname,
+		}
+	}
+	if err == nil {
+		joined := strings.Join(ips, ",")
+		if !force && exists && record.Error == "" && record.IPs == joined {
+			return nil
+		}
+		if err = s.setRecords(tunnel.DNSProviderID, tunnel.Hostname, ips); err == nil {
+			record.IPs = joined
+		}
+	}
+
+	record.Error = ""
+	if err != nil {
+		record.Error = err.Error()
+		err = fmt.Errorf("隧道 %s 的域名 %s 解析同步失败: %v", tunnel.Name, tunnel.Hostname, err)
+	}
+	record.SyncedTime = time.Now().UnixMilli()
+	if saveErr := s.repo.SaveRecord(record); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// entryIPs 返回域名应解析到的入口节点IP，优先使用在线节点
+func (s *DNSService) entryIPs(tunnel *models.Tunnel) ([]string, error) {
+	nodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+	if err != nil {
+		return nil, err
+	}
+	var online, all []string
+	for _, node := range nodes {
+		all = append(all, node.ServerIP)
+		if node.Status == 1 {
+			online = append(online, node.ServerIP)
+		}
+	}
+	if ips := dnsprovider.NormalizeIPs(online); len(ips) > 0 {
+		return ips, nil
+	}
+	return dnsprovider.NormalizeIPs(all), nil
+}
+
+// setRecords 通过服务商写入域名的解析记录，ips 为空时删除
+func (s *DNSService) setRecords(providerID uint, hostname string, ips []string) error {
+	model, err := s.repo.FindProviderByID(providerID)
+	if err != nil {
+		return errors.New("DNS 服务商不存在")
+	}
+	provider, err := dnsprovider.New(model.Type, decodeProviderConfig(model))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dnsSyncTimeout)
+	defer cancel()
+	return provider.SetRecords(ctx, hostname, ips, model.TTL)
+}
+
+// decodeProviderConfig 解析服务商保存的配置
+func decodeProviderConfig(provider *models.DNSProvider) map[string]string {
+	config := make(map[string]string)
+	if provider.Config != "" {
+		if err := json.Unmarshal([]byte(provider.Config), &config); err != nil {
+			log.Printf("DNS 服务商 %d 配置解析失败: %v", provider.ID, err)
+		}
+	}
+	return config
+}
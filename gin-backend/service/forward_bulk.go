@@ -0,0 +1,612 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/websocket"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxBulkRows 单次批量导入的最大行数
+const maxBulkRows = 5000
+
+// unlimitedNum 转发数量限制为该值时不限制
+const unlimitedNum = 99999
+
+// bulkImportResource 批量导入时合并下发的服务命令作用的配置
+const bulkImportResource = "service:import"
+
+// portReservations 批量导入时已分配给前面各行、尚未写入数据库的端口，按节点记录
+type portReservations map[uint]map[int]bool
+
+// addTo 将 nodes 上已预留的端口加入 used
+func (r portReservations) addTo(used map[int]bool, nodes []models.Node) {
+	for _, node := range nodes {
+		for port := range r[node.ID] {
+			used[port] = true
+		}
+	}
+}
+
+func (r portReservations) reserve(nodes []models.Node, port, count int) {
+	for _, node := range nodes {
+		if r[node.ID] == nil {
+			r[node.ID] = make(map[int]bool)
+		}
+		for i := 0; i < count; i++ {
+			r[node.ID][port+i] = true
+		}
+	}
+}
+
+// bulkForward 批量导入中校验通过的转发
+type bulkForward struct {
+	row        int
+	forward    *models.Forward
+	user       *models.User
+	tunnel     *models.Tunnel
+	p          *placement
+	userTunnel *models.UserTunnel
+}
+
+// ImportForwards 批量导入转发，dryRun 为 true 或存在校验失败的行时只返回校验报告；
+// 校验通过后在一个事务中创建全部转发，再按节点合并下发服务配置
+func (s *ForwardService) ImportForwards(actor Actor, rows []dto.BulkForwardRow, dryRun bool) (*dto.BulkImportResultDto, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("导入内容为空")
+	}
+	if len(rows) > maxBulkRows {
+		return nil, fmt.Errorf("单次最多导入 %d 行", maxBulkRows)
+	}
+
+	result := &dto.BulkImportResultDto{DryRun: dryRun, Total: len(rows), PushErrors: []string{}}
+	items, err := s.planForwardImport(actor, rows, result)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || result.Invalid > 0 {
+		return result, nil
+	}
+
+	if err := s.createImportedForwards(actor, items, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// planForwardImport 逐行校验并分配端口，端口冲突计入前面各行，数量限制只计入前面通过全部校验的行
+func (s *ForwardService) planForwardImport(actor Actor, rows []dto.BulkForwardRow, result *dto.BulkImportResultDto) ([]*bulkForward, error) {
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tunnelsByName := make(map[string][]models.Tunnel)
+	for _, tunnel := range tunnels {
+		tunnelsByName[tunnel.Name] = append(tunnelsByName[tunnel.Name], tunnel)
+	}
+
+	// 用户及用户在各隧道下的已有转发数量
+	var counts []struct {
+		UserID   int
+		TunnelID int
+		Count    int
+	}
+	if err := s.db.Model(&models.Forward{}).
+		Select("user_id, tunnel_id, COUNT(*) AS count").
+		Group("user_id, tunnel_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	userCounts := make(map[uint]int)
+	tunnelCounts := make(map[[2]uint]int)
+	for _, c := range counts {
+		userCounts[uint(c.UserID)] += c.Count
+		tunnelCounts[[2]uint{uint(c.UserID), uint(c.TunnelID)}] += c.Count
+	}
+
+	users := make(map[string]*models.User)
+	findUser := func(name string) *models.User {
+		if user, ok := users[name]; ok {
+			return user
+		}
+		// 包含禁用的用户，以便在报告中区分用户不存在与已禁用
+		var user models.User
+		if err := s.db.Where("user = ? AND status >= 0", name).First(&user).Error; err != nil {
+			users[name] = nil
+			return nil
+		}
+		users[name] = &user
+		return &user
+	}
+
+	now := time.Now().UnixMilli()
+	planned := make([]*bulkForward, len(rows))
+	for i, row := range rows {
+		res := dto.BulkRowResultDto{Row: i + 1, Name: row.Name, Errors: []string{}}
+		fail := func(format string, args ...interface{}) {
+			res.Errors = append(res.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if strings.TrimSpace(row.Name) == "" {
+			fail("转发名称不能为空")
+		}
+		if strings.TrimSpace(row.RemoteAddr) == "" {
+			fail("目标地址不能为空")
+		}
+
+		ownerName := strings.TrimSpace(row.User)
+		if ownerName == "" {
+			ownerName = actor.Name
+		}
+		user := findUser(ownerName)
+		switch {
+		case !actor.IsAdmin() && ownerName != actor.Name:
+			fail("只能导入自己的转发")
+			user = nil
+		case user == nil:
+			fail("用户 %s 不存在", ownerName)
+		case user.Status != 1:
+			fail("用户 %s 已禁用", ownerName)
+		case user.RoleID != 0 && user.ExpTime > 0 && user.ExpTime < now:
+			fail("用户 %s 已到期", ownerName)
+		}
+
+		var tunnel *models.Tunnel
+		switch matched := tunnelsByName[strings.TrimSpace(row.Tunnel)]; {
+		case len(matched) == 0:
+			fail("隧道 %s 不存在", row.Tunnel)
+		case len(matched) > 1:
+			fail("隧道名称 %s 不唯一", row.Tunnel)
+		case matched[0].Status != 1:
+			fail("隧道 %s 被禁用", row.Tunnel)
+		default:
+			tunnel = &matched[0]
+		}
+
+		var userTunnel *models.UserTunnel
+		if user != nil && tunnel != nil {
+			userTunnel, _ = s.userTunnelRepo.FindByUserAndTunnel(user.ID, tunnel.ID)
+			if user.RoleID != 0 {
+				switch {
+				case userTunnel == nil:
+					fail("用户 %s 没有隧道 %s 的权限", user.User, tunnel.Name)
+				case userTunnel.ExpTime > 0 && userTunnel.ExpTime < now:
+					fail("用户 %s 的隧道 %s 权限已到期", user.User, tunnel.Name)
+				}
+			}
+		}
+		if len(res.Errors) > 0 {
+			result.Rows = append(result.Rows, res)
+			continue
+		}
+
+		item, err := s.prepareForwardRow(row, user, tunnel, userTunnel)
+		if err != nil {
+			fail("%v", err)
+		} else {
+			item.row = i + 1
+			planned[i] = item
+		}
+		result.Rows = append(result.Rows, res)
+	}
+
+	// 先分配指定的入口端口，再为其余各行自动分配，避免自动分配的端口占用后面各行指定的端口
+	reserved := portReservations{}
+	for _, explicit := range []bool{true, false} {
+		for i, item := range planned {
+			if item == nil || (item.forward.InPort > 0) != explicit {
+				continue
+			}
+			if err := s.allocateForwardPorts(item, reserved); err != nil {
+				result.Rows[i].Errors = append(result.Rows[i].Errors, err.Error())
+			}
+			result.Rows[i].InPort = item.forward.InPort
+		}
+	}
+
+	// 数量限制按行序计入前面通过全部校验的行，校验失败的行不占用数量
+	var items []*bulkForward
+	for i := range result.Rows {
+		res := &result.Rows[i]
+		if len(res.Errors) == 0 {
+			res.Errors = checkImportQuota(planned[i], userCounts, tunnelCounts)
+		}
+		if len(res.Errors) > 0 {
+			res.InPort = 0
+			result.Invalid++
+			continue
+		}
+		items = append(items, planned[i])
+	}
+	return items, nil
+}
+
+// checkImportQuota 检查转发数量限制，通过时计入 userCounts 与 tunnelCounts
+func checkImportQuota(item *bulkForward, userCounts map[uint]int, tunnelCounts map[[2]uint]int) []string {
+	errs := []string{}
+	user, tunnel, userTunnel := item.user, item.tunnel, item.userTunnel
+	tunnelKey := [2]uint{user.ID, tunnel.ID}
+	if user.RoleID != 0 {
+		if user.Num != unlimitedNum && userCounts[user.ID]+1 > user.Num {
+			errs = append(errs, fmt.Sprintf("超出用户 %s 的转发数量限制 %d", user.User, user.Num))
+		}
+		if userTunnel != nil && userTunnel.Num != unlimitedNum && tunnelCounts[tunnelKey]+1 > userTunnel.Num {
+			errs = append(errs, fmt.Sprintf("超出用户 %s 在隧道 %s 的转发数量限制 %d", user.User, tunnel.Name, userTunnel.Num))
+		}
+	}
+	if len(errs) == 0 {
+		userCounts[user.ID]++
+		tunnelCounts[tunnelKey]++
+	}
+	return errs
+}
+
+// prepareForwardRow 校验单行的端口段与目标地址，计算部署位置，入口端口为 0 时自动分配
+func (s *ForwardService) prepareForwardRow(row dto.BulkForwardRow, user *models.User, tunnel *models.Tunnel, userTunnel *models.UserTunnel) (*bulkForward, error) {
+	var inPort, inPortEnd *int
+	if row.InPort > 0 {
+		inPort = &row.InPort
+	}
+	if row.InPortEnd > 0 {
+		inPortEnd = &row.InPortEnd
+	}
+	portCount, err := parsePortCount(inPort, inPortEnd)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRemotePortRange(row.RemoteAddr, portCount); err != nil {
+		return nil, err
+	}
+
+	forward := &models.Forward{
+		UserID:     int(user.ID),
+		UserName:   user.User,
+		Name:       strings.TrimSpace(row.Name),
+		TunnelID:   int(tunnel.ID),
+		RemoteAddr: strings.TrimSpace(row.RemoteAddr),
+		Strategy:   row.Strategy,
+		InPort:     row.InPort,
+		PortCount:  portCount,
+	}
+	forward.Status = 1
+
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		return nil, err
+	}
+	return &bulkForward{forward: forward, user: user, tunnel: tunnel, p: p, userTunnel: userTunnel}, nil
+}
+
+// allocateForwardPorts 校验指定的入口端口并分配其余端口，分配的端口加入 reserved
+func (s *ForwardService) allocateForwardPorts(item *bulkForward, reserved portReservations) error {
+	forward, p := item.forward, item.p
+	var inPort *int
+	if forward.InPort > 0 {
+		port := forward.InPort
+		inPort = &port
+	}
+
+	// 指定的单个入口端口需未被占用，端口段由 allocatePorts 校验
+	if inPort != nil && forward.PortCount == 1 {
+		used := s.usedPortsOf(p.in, 0)
+		reserved.addTo(used, p.in)
+		if used[*inPort] {
+			return fmt.Errorf("入口端口 %d 已被占用", *inPort)
+		}
+	}
+
+	allocInPort, allocOutPort, err := s.allocatePorts(p, forward.PortCount, inPort, reserved)
+	if err != nil {
+		return err
+	}
+	if inPort == nil {
+		forward.InPort = allocInPort
+	}
+	forward.OutPort = allocOutPort
+
+	reserved.reserve(p.in, forward.InPort, forward.PortCount)
+	if len(p.out) > 0 {
+		reserved.reserve(p.out, forward.OutPort, forward.PortCount)
+	}
+	return nil
+}
+
+// createImportedForwards 在一个事务中创建全部转发，提交后下发到节点
+func (s *ForwardService) createImportedForwards(actor Actor, items []*bulkForward, result *dto.BulkImportResultDto) (err error) {
+	entry := &AuditEntry{Action: "forward.import", TargetType: "forward"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkImportedPorts(tx, items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := tx.Create(item.forward).Error; err != nil {
+				return fmt.Errorf("第 %d 行创建失败: %v", item.row, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		result.Rows[item.row-1].ID = item.forward.ID
+		s.audit.Record(actor, &AuditEntry{
+			Action:     "forward.create",
+			TargetType: "forward",
+			TargetID:   item.forward.ID,
+			TargetName: item.forward.Name,
+			After:      item.forward,
+			Message:    "批量导入",
+		}, nil)
+	}
+	result.Created = len(items)
+
+	result.PushErrors = s.pushImportedForwards(items)
+	entry.Message = fmt.Sprintf("导入 %d 条转发，下发失败 %d", result.Created, len(result.PushErrors))
+	return nil
+}
+
+// checkImportedPorts 在创建事务中重新检查分配的端口，校验后其他请求可能已占用了这些端口
+func (s *ForwardService) checkImportedPorts(tx *gorm.DB, items []*bulkForward) error {
+	used := make(map[uint]map[int]bool)
+	check := func(item *bulkForward, nodes []models.Node, port int) error {
+		for _, node := range nodes {
+			if used[node.ID] == nil {
+				used[node.ID] = s.usedPortsIn(tx, node.ID, 0)
+			}
+			for i := 0; i < item.forward.Ports(); i++ {
+				if used[node.ID][port+i] {
+					return fmt.Errorf("第 %d 行的端口 %d 在节点 %s 上已被占用，请重新导入", item.row, port+i, node.Name)
+				}
+			}
+			for i := 0; i < item.forward.Ports(); i++ {
+				used[node.ID][port+i] = true
+			}
+		}
+		return nil
+	}
+
+	for _, item := range items {
+		if err := check(item, item.p.in, item.forward.InPort); err != nil {
+			return err
+		}
+		if err := check(item, item.p.out, item.forward.OutPort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeServiceBatch 批量导入时下发到同一节点的服务，节点在线且支持时限流器、解析器和链也合并下发
+type nodeServiceBatch struct {
+	name        string
+	services    []map[string]interface{}
+	resources   []string                   // 各转发服务的配置标识，与 groups 一一对应
+	groups      [][]map[string]interface{} // 各转发的服务配置，节点离线时按转发分别入队
+	forwards    []string
+	batchConfig bool // 限流器、解析器和链是否合并下发
+	climiters   []map[string]interface{}
+	rlimiters   []map[string]interface{}
+	resolvers   []map[string]interface{}
+	chains      []map[string]interface{}
+}
+
+func (b *nodeServiceBatch) add(resource, forward string, services []map[string]interface{}) {
+	b.services = append(b.services, services...)
+	b.resources = append(b.resources, resource)
+	b.groups = append(b.groups, services)
+	b.forwards = append(b.forwards, forward)
+}
+
+// sendDependencies 下发合并的限流器、解析器和链，服务引用这些配置，需先于服务下发
+func (b *nodeServiceBatch) sendDependencies(nodeID uint) *GostResponse {
+	commands := []struct {
+		msgType  string
+		resource string
+		configs  []map[string]interface{}
+	}{
+		{websocket.MessageTypeAddCLimiters, "climiter:import", b.climiters},
+		{websocket.MessageTypeAddRLimiters, "rlimiter:import", b.rlimiters},
+		{websocket.MessageTypeAddResolvers, "resolver:import", b.resolvers},
+		{websocket.MessageTypeAddChains, "chain:import", b.chains},
+	}
+	for _, cmd := range commands {
+		if len(cmd.configs) == 0 {
+			continue
+		}
+		if resp := sendNodeCommand(nodeID, cmd.configs, cmd.msgType, cmd.resource); !resp.Success {
+			return resp
+		}
+	}
+	return &GostResponse{Success: true, Message: "OK"}
+}
+
+// pushImportedForwards 下发导入的转发，连接数限制器、解析器、链以及入口与出口服务按节点合并为一条命令；
+// 节点离线或不支持合并时限流器、解析器和链按转发下发，离线时服务按转发分别入队，与单条创建时的队列记录一致。
+// 下发失败的转发保留在数据库中，节点重新上报配置时补齐
+func (s *ForwardService) pushImportedForwards(items []*bulkForward) []string {
+	errs := []string{}
+	batches := make(map[uint]*nodeServiceBatch)
+	batchOf := func(node models.Node) *nodeServiceBatch {
+		if batches[node.ID] == nil {
+			batches[node.ID] = &nodeServiceBatch{
+				name:        node.Name,
+				batchConfig: websocket.IsNodeConnected(node.ID) && nodeHasFeature(node.ID, websocket.FeatureBatchConfig),
+			}
+		}
+		return batches[node.ID]
+	}
+
+	for _, item := range items {
+		forward, tunnel, p := item.forward, item.tunnel, item.p
+		var limiter *int
+		var userTunnelID uint
+		if item.userTunnel != nil {
+			if item.userTunnel.SpeedID > 0 {
+				limiter = &item.userTunnel.SpeedID
+			}
+			userTunnelID = item.userTunnel.ID
+		}
+		serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+
+		resolver, err := addImportedDependencies(item, serviceName, batchOf)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("转发 %s: %v", forward.Name, err))
+			continue
+		}
+
+		for _, node := range p.out {
+			services := createRemoteServiceConfigs(serviceName, forward.OutPort, forward.Ports(), forward.RemoteAddr,
+				tunnel.Protocol, forward.Strategy, forward.InterfaceName, resolver)
+			batchOf(node).add("remote:"+serviceName, forward.Name, services)
+		}
+		interfaceName, entryResolver := entryServiceOptions(forward, tunnel, resolver)
+		for _, node := range p.in {
+			services := createServiceConfigs(serviceName, forward.InPort, forward.Ports(), limiter, forward.RemoteAddr,
+				tunnel.Type, tunnel, forward.Strategy, interfaceName, entryResolver, adaptAccessLog(node.ID, forward.AccessLog == 1))
+			batchOf(node).add("service:"+serviceName, forward.Name, services)
+		}
+	}
+
+	nodeIDs := make([]uint, 0, len(batches))
+	for nodeID := range batches {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+
+	for _, nodeID := range nodeIDs {
+		batch := batches[nodeID]
+		if batch.batchConfig {
+			if resp := batch.sendDependencies(nodeID); !resp.Success {
+				errs = append(errs, fmt.Sprintf("节点 %s: %s (转发 %s)", batch.name, resp.Message, strings.Join(batch.forwards, ", ")))
+				continue
+			}
+		}
+		if websocket.IsNodeConnected(nodeID) {
+			if resp := sendNodeCommand(nodeID, batch.services, websocket.MessageTypeAddService, bulkImportResource); !resp.Success {
+				errs = append(errs, fmt.Sprintf("节点 %s: %s (转发 %s)", batch.name, resp.Message, strings.Join(batch.forwards, ", ")))
+			}
+			continue
+		}
+		for i, services := range batch.groups {
+			if resp := sendNodeCommand(nodeID, services, websocket.MessageTypeAddService, batch.resources[i]); !resp.Success {
+				errs = append(errs, fmt.Sprintf("节点 %s: %s (转发 %s)", batch.name, resp.Message, batch.forwards[i]))
+			}
+		}
+	}
+	return errs
+}
+
+// addImportedDependencies 添加导入转发的连接数限制器、解析器和链，与 addGostDependencies 一致；
+// 节点支持合并下发时加入节点的批量命令，否则逐条下发
+func addImportedDependencies(item *bulkForward, serviceName string, batchOf func(models.Node) *nodeServiceBatch) (string, error) {
+	forward, tunnel, p := item.forward, item.tunnel, item.p
+
+	// 0. 连接数限制
+	connLimits, rateLimits := BuildConnLimits(item.userTunnel), BuildRateLimits(item.userTunnel)
+	if len(connLimits) > 0 || len(rateLimits) > 0 {
+		for _, node := range p.in {
+			if batch := batchOf(node); batch.batchConfig {
+				if len(connLimits) > 0 {
+					batch.climiters = append(batch.climiters, map[string]interface{}{"name": serviceName, "limits": connLimits})
+				}
+				if len(rateLimits) > 0 {
+					batch.rlimiters = append(batch.rlimiters, map[string]interface{}{"name": serviceName, "limits": rateLimits})
+				}
+				continue
+			}
+			if resp := SyncConnLimiters(node.ID, serviceName, item.userTunnel); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
+		}
+	}
+
+	// 0. 目标地址解析器
+	resolver := forwardResolverName(tunnel, serviceName)
+	if resolver != "" {
+		for _, node := range p.targets(tunnel) {
+			if batch := batchOf(node); batch.batchConfig {
+				batch.resolvers = append(batch.resolvers, createResolverConfig(resolver, tunnel, forward.IPPreference))
+				continue
+			}
+			if resp := AddResolvers(node.ID, resolver, tunnel, forward.IPPreference); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
+		}
+	}
+
+	// 1. 隧道转发的链
+	if tunnel.Type == 2 {
+		remoteAddr := p.chainAddr(tunnel, forward.OutPort)
+		for _, node := range p.in {
+			if batch := batchOf(node); batch.batchConfig {
+				batch.chains = append(batch.chains, createChainConfigs(serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)...)
+				continue
+			}
+			if resp := AddChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
+		}
+	}
+	return resolver, nil
+}
+
+// ExportForwards 导出转发，格式与批量导入相同，普通用户只导出自己的转发
+func (s *ForwardService) ExportForwards(actor Actor) ([]dto.BulkForwardRow, error) {
+	var forwards []models.Forward
+	var err error
+	if actor.IsAdmin() {
+		forwards, err = s.repo.FindAll()
+	} else {
+		forwards, err = s.repo.FindByUserID(actor.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tunnelNames := make(map[uint]string, len(tunnels))
+	for _, tunnel := range tunnels {
+		tunnelNames[tunnel.ID] = tunnel.Name
+	}
+	users, err := s.userRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	userNames := make(map[uint]string, len(users))
+	for _, user := range users {
+		userNames[user.ID] = user.User
+	}
+
+	rows := make([]dto.BulkForwardRow, 0, len(forwards))
+	for _, forward := range forwards {
+		row := dto.BulkForwardRow{
+			User:       userNames[uint(forward.UserID)],
+			Tunnel:     tunnelNames[uint(forward.TunnelID)],
+			Name:       forward.Name,
+			RemoteAddr: forward.RemoteAddr,
+			InPort:     forward.InPort,
+			Strategy:   forward.Strategy,
+		}
+		if row.User == "" {
+			row.User = forward.UserName
+		}
+		if forward.Ports() > 1 {
+			row.InPortEnd = forward.InPort + forward.Ports() - 1
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"flux-panel/dto"
+	"flux-panel/models"
+	"testing"
+)
+
+// TestImportForwardsQuota 数量限制只计入通过全部校验的行，前面校验失败的行不占用数量
+func TestImportForwardsQuota(t *testing.T) {
+	db := newTestDB(t)
+
+	node := &models.Node{Name: "node", Secret: "node-secret", ServerIP: "127.0.0.1", PortSta: 20000, PortEnd: 20100}
+	user := &models.User{User: "owner", Pwd: "x", RoleID: 1, Num: 2}
+	tunnel := &models.Tunnel{Name: "tunnel", Type: 1}
+	tunnel.Status = 1
+	for _, v := range []interface{}{node, user} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tunnel.InNodeID = node.ID
+	if err := db.Create(tunnel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.UserTunnel{UserID: user.ID, TunnelID: tunnel.ID, Num: unlimitedNum}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 其他用户的转发占用的端口，不计入 owner 的数量
+	if err := db.Create(&models.Forward{UserID: 999, Name: "taken", TunnelID: int(tunnel.ID), RemoteAddr: "10.0.0.1:80", InPort: 20050, PortCount: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []dto.BulkForwardRow{
+		{User: "owner", Tunnel: "tunnel", Name: "port-taken", RemoteAddr: "10.0.0.1:80", InPort: 20050},
+		{User: "owner", Tunnel: "tunnel", Name: "a", RemoteAddr: "10.0.0.1:80"},
+		{User: "owner", Tunnel: "tunnel", Name: "b", RemoteAddr: "10.0.0.1:80"},
+		{User: "owner", Tunnel: "tunnel", Name: "c", RemoteAddr: "10.0.0.1:80"},
+	}
+	result, err := NewForwardService(db).ImportForwards(Actor{Name: "admin"}, rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantInvalid := []bool{true, false, false, true}
+	for i, res := range result.Rows {
+		if (len(res.Errors) > 0) != wantInvalid[i] {
+			t.Errorf("第 %d 行校验结果 %v 不符合预期", res.Row, res.Errors)
+		}
+	}
+	if result.Invalid != 2 {
+		t.Errorf("校验失败 %d 行，应为 2 行", result.Invalid)
+	}
+}
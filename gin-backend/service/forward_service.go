@@ -8,6 +8,7 @@ import (
 	"flux-panel/repository"
 	"flux-panel/websocket"
 	"fmt"
+	"log"
 	"math/big"
 	"net"
 	"strconv"
@@ -17,12 +18,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// maxPortRangeSize 单个端口段转发最多包含的端口数量
+const maxPortRangeSize = 1000
+
 type ForwardService struct {
 	db             *gorm.DB
 	repo           *repository.ForwardRepository
 	tunnelRepo     *repository.TunnelRepository
 	userTunnelRepo *repository.UserTunnelRepository
 	nodeRepo       *repository.NodeRepository
+	commandRepo    *repository.NodeCommandRepository
+	groupRepo      *repository.NodeGroupRepository
+	userRepo       *repository.UserRepository
+	locator        *nodeLocator
+	audit          *AuditService
 }
 
 func NewForwardService(db *gorm.DB) *ForwardService {
@@ -32,11 +41,20 @@ func NewForwardService(db *gorm.DB) *ForwardService {
 		tunnelRepo:     repository.NewTunnelRepository(db),
 		userTunnelRepo: repository.NewUserTunnelRepository(db),
 		nodeRepo:       repository.NewNodeRepository(db),
+		commandRepo:    repository.NewNodeCommandRepository(db),
+		groupRepo:      repository.NewNodeGroupRepository(db),
+		userRepo:       repository.NewUserRepository(db),
+		locator:        newNodeLocator(db),
+		audit:          NewAuditService(db),
 	}
 }
 
 // CreateForward 创建转发
-func (s *ForwardService) CreateForward(userID int, userName string, forwardDto *dto.ForwardDto) error {
+func (s *ForwardService) CreateForward(actor Actor, userName string, forwardDto *dto.ForwardDto) (err error) {
+	entry := &AuditEntry{Action: "forward.create", TargetType: "forward", TargetName: forwardDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	userID := actor.UserID
 	tunnel, err := s.tunnelRepo.FindByID(uint(forwardDto.TunnelID))
 	if err != nil {
 		return errors.New("隧道不存在")
@@ -44,155 +62,323 @@ func (s *ForwardService) CreateForward(userID int, userName string, forwardDto *
 	if tunnel.Status != 1 {
 		return errors.New("隧道被禁用")
 	}
+	if err := s.authorizeTunnel(actor, tunnel.ID); err != nil {
+		return err
+	}
 
-	allocInPort, allocOutPort, err := s.allocatePorts(tunnel)
+	portCount, err := parsePortCount(forwardDto.InPort, forwardDto.InPortEnd)
 	if err != nil {
 		return err
 	}
-
-	inPort := allocInPort
-	if forwardDto.InPort != nil {
-		inPort = *forwardDto.InPort
+	if err := validateRemotePortRange(forwardDto.RemoteAddr, portCount); err != nil {
+		return err
 	}
 
-	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(userID), tunnel.ID)
-
 	forward := &models.Forward{
 		UserID:        userID,
 		UserName:      userName,
 		Name:          forwardDto.Name,
 		TunnelID:      forwardDto.TunnelID,
+		InNodeID:      entryNodeID(tunnel, forwardDto.InNodeID),
 		RemoteAddr:    forwardDto.RemoteAddr,
 		Strategy:      forwardDto.Strategy,
-		InPort:        inPort,
-		OutPort:       allocOutPort,
+		IPPreference:  forwardDto.IPPreference,
+		PortCount:     portCount,
 		InterfaceName: forwardDto.InterfaceName,
+		AccessLog:     forwardDto.AccessLog,
 	}
 	forward.Status = 1
 
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		return err
+	}
+
+	allocInPort, allocOutPort, err := s.allocatePorts(p, portCount, forwardDto.InPort, nil)
+	if err != nil {
+		return err
+	}
+
+	forward.InPort = allocInPort
+	if forwardDto.InPort != nil {
+		forward.InPort = *forwardDto.InPort
+	}
+	forward.OutPort = allocOutPort
+
+	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(userID), tunnel.ID)
+
 	if err := s.repo.Create(forward); err != nil {
 		return err
 	}
 
 	var limiter *int
+	if userTunnel != nil && userTunnel.SpeedID > 0 {
+		limiter = &userTunnel.SpeedID
+	}
+
+	if err := s.createGostServices(forward, tunnel, limiter, p, userTunnel); err != nil {
+		s.repo.Delete(forward.ID)
+		return err
+	}
+	entry.TargetID = forward.ID
+	entry.After = forward
+
+	return nil
+}
+
+// entryNodeID 返回转发指定的入口节点，隧道入口不是节点组时不能指定
+func entryNodeID(tunnel *models.Tunnel, inNodeID uint) uint {
+	if tunnel.InGroupID == 0 {
+		return 0
+	}
+	return inNodeID
+}
+
+// createGostServices 在部署位置的全部节点上创建转发服务，任一节点失败时删除已下发的配置
+func (s *ForwardService) createGostServices(forward *models.Forward, tunnel *models.Tunnel, limiter *int, p *placement, userTunnel *models.UserTunnel) error {
 	var userTunnelID uint
 	if userTunnel != nil {
-		if userTunnel.SpeedID > 0 {
-			limiter = &userTunnel.SpeedID
-		}
 		userTunnelID = userTunnel.ID
 	}
+	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
 
-	inNode, err := s.nodeRepo.FindByID(tunnel.InNodeID)
+	if err := s.addGostServices(forward, tunnel, limiter, p, serviceName, userTunnel); err != nil {
+		s.deleteGostServices(forward, tunnel, p, serviceName, userTunnel)
+		return err
+	}
+	return nil
+}
+
+func (s *ForwardService) addGostServices(forward *models.Forward, tunnel *models.Tunnel, limiter *int, p *placement, serviceName string, userTunnel *models.UserTunnel) error {
+	resolver, err := s.addGostDependencies(forward, tunnel, p, serviceName, userTunnel)
 	if err != nil {
-		s.repo.Delete(forward.ID)
-		return errors.New("入口节点不存在")
+		return err
 	}
 
-	var outNode *models.Node
 	if tunnel.Type == 2 {
-		outNode, err = s.nodeRepo.FindByID(tunnel.OutNodeID)
-		if err != nil {
-			s.repo.Delete(forward.ID)
-			return errors.New("出口节点不存在")
+		// 2. Remote Service
+		for _, node := range p.out {
+			if resp := AddRemoteService(node.ID, serviceName, forward.OutPort, forward.Ports(), forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName, resolver); !resp.Success {
+				return errors.New(resp.Message)
+			}
 		}
 	}
 
-	if err := s.createGostServices(forward, tunnel, limiter, inNode, outNode, userTunnelID); err != nil {
-		s.repo.Delete(forward.ID)
-		return err
+	interfaceName, entryResolver := entryServiceOptions(forward, tunnel, resolver)
+	for _, node := range p.in {
+		if resp := AddService(node.ID, serviceName, forward.InPort, forward.Ports(), limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName, entryResolver, forward.AccessLog == 1); !resp.Success {
+			return errors.New(resp.Message)
+		}
 	}
 
 	return nil
 }
 
-func (s *ForwardService) createGostServices(forward *models.Forward, tunnel *models.Tunnel, limiter *int, inNode, outNode *models.Node, userTunnelID uint) error {
-	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
-
-	if tunnel.Type == 2 {
-		// Tunnel Forward
-		// 1. Add Chain
-		remoteAddr := fmt.Sprintf("%s:%d", tunnel.OutIP, forward.OutPort)
-		if strings.Contains(tunnel.OutIP, ":") {
-			remoteAddr = fmt.Sprintf("[%s]:%d", tunnel.OutIP, forward.OutPort)
+// addGostDependencies 下发服务引用的连接数限制器、目标地址解析器和隧道转发的链，返回解析器名称
+func (s *ForwardService) addGostDependencies(forward *models.Forward, tunnel *models.Tunnel, p *placement, serviceName string, userTunnel *models.UserTunnel) (string, error) {
+	// 0. 连接数限制
+	if len(BuildConnLimits(userTunnel)) > 0 || len(BuildRateLimits(userTunnel)) > 0 {
+		for _, node := range p.in {
+			if resp := SyncConnLimiters(node.ID, serviceName, userTunnel); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
 		}
+	}
 
-		chainResp := AddChains(inNode.ID, serviceName, remoteAddr, tunnel.Protocol, tunnel.InterfaceName)
-		if !chainResp.Success {
-			DeleteChains(inNode.ID, serviceName)
-			return errors.New(chainResp.Message)
+	// 0. 目标地址解析器
+	resolver := forwardResolverName(tunnel, serviceName)
+	if resolver != "" {
+		for _, node := range p.targets(tunnel) {
+			if resp := AddResolvers(node.ID, resolver, tunnel, forward.IPPreference); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
 		}
+	}
 
-		// 2. Remote Service
-		remoteResp := AddRemoteService(outNode.ID, serviceName, forward.OutPort, forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName)
-		if !remoteResp.Success {
-			DeleteChains(inNode.ID, serviceName)
-			DeleteRemoteService(outNode.ID, serviceName)
-			return errors.New(remoteResp.Message)
+	if tunnel.Type == 2 {
+		// Tunnel Forward
+		// 1. Add Chain
+		remoteAddr := p.chainAddr(tunnel, forward.OutPort)
+		for _, node := range p.in {
+			if resp := AddChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName); !resp.Success {
+				return "", errors.New(resp.Message)
+			}
 		}
 	}
+	return resolver, nil
+}
 
-	interfaceName := ""
-	if tunnel.Type != 2 {
-		interfaceName = forward.InterfaceName
+// entryServiceOptions 返回入口服务使用的网卡与解析器，隧道转发由出口服务连接目标，入口服务不使用
+func entryServiceOptions(forward *models.Forward, tunnel *models.Tunnel, resolver string) (string, string) {
+	if tunnel.Type == 2 {
+		return "", ""
 	}
+	return forward.InterfaceName, resolver
+}
 
-	resp := AddService(inNode.ID, serviceName, forward.InPort, limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName)
-	if !resp.Success {
-		DeleteChains(inNode.ID, serviceName)
-		if outNode != nil {
-			DeleteRemoteService(outNode.ID, serviceName)
+// deleteGostServices 删除部署位置上全部节点的转发配置 (忽略失败)
+func (s *ForwardService) deleteGostServices(forward *models.Forward, tunnel *models.Tunnel, p *placement, serviceName string, userTunnel *models.UserTunnel) {
+	for _, node := range p.in {
+		DeleteService(node.ID, serviceName, forward.Ports())
+		DeleteChains(node.ID, serviceName, forward.Ports())
+		s.deleteConnLimiters(node.ID, serviceName, userTunnel)
+	}
+	for _, node := range p.out {
+		DeleteRemoteService(node.ID, serviceName, forward.Ports())
+	}
+	if tunnel.UsesResolver() {
+		for _, node := range p.targets(tunnel) {
+			DeleteResolvers(node.ID, serviceName)
 		}
-		return errors.New(resp.Message)
 	}
-
-	return nil
 }
 
-// allocatePorts 分配端口
-func (s *ForwardService) allocatePorts(tunnel *models.Tunnel) (int, int, error) {
-	// 1. 分配入口端口
-	inNode, err := s.nodeRepo.FindByID(tunnel.InNodeID)
-	if err != nil {
-		return 0, 0, errors.New("入口节点不存在")
+// deleteConnLimiters 回滚时删除已下发的连接数限制器
+func (s *ForwardService) deleteConnLimiters(nodeID uint, serviceName string, userTunnel *models.UserTunnel) {
+	if len(BuildConnLimits(userTunnel)) > 0 {
+		DeleteConnLimiters(nodeID, serviceName)
 	}
+	if len(BuildRateLimits(userTunnel)) > 0 {
+		DeleteRateLimiters(nodeID, serviceName)
+	}
+}
 
-	usedInPorts := s.getAllUsedPorts(tunnel.InNodeID)
-	var availableInPorts []int
-	for p := inNode.PortSta; p <= inNode.PortEnd; p++ {
-		if !usedInPorts[p] {
-			availableInPorts = append(availableInPorts, p)
+// allocatePorts 分配端口，端口段转发在入口节点使用指定的起始端口，在出口节点分配连续端口；
+// 部署到多个节点时端口需在每个节点的端口范围内且均未被占用，reserved 为尚未写入数据库的其他转发已分配的端口
+func (s *ForwardService) allocatePorts(p *placement, portCount int, inPort *int, reserved portReservations) (int, int, error) {
+	// 1. 分配入口端口
+	inSta, inEnd := commonPortRange(p.in)
+	usedInPorts := s.usedPortsOf(p.in, 0)
+	reserved.addTo(usedInPorts, p.in)
+	allocInPort := 0
+	if portCount > 1 {
+		if *inPort < inSta || *inPort+portCount-1 > inEnd {
+			return 0, 0, fmt.Errorf("端口段必须在入口节点端口范围 %d-%d 内", inSta, inEnd)
+		}
+		for port := *inPort; port < *inPort+portCount; port++ {
+			if usedInPorts[port] {
+				return 0, 0, fmt.Errorf("入口端口 %d 已被占用", port)
+			}
+		}
+		allocInPort = *inPort
+	} else {
+		var availableInPorts []int
+		for port := inSta; port <= inEnd; port++ {
+			if !usedInPorts[port] {
+				availableInPorts = append(availableInPorts, port)
+			}
 		}
-	}
 
-	if len(availableInPorts) == 0 {
-		return 0, 0, errors.New("入口节点无可用端口")
+		if len(availableInPorts) == 0 {
+			return 0, 0, errors.New("入口节点无可用端口")
+		}
+		allocInPort = s.getRandomPort(availableInPorts)
 	}
-	inPort := s.getRandomPort(availableInPorts)
 
 	// 2. 分配出口端口 (仅隧道转发)
 	outPort := 0
-	if tunnel.Type == 2 {
-		outNode, err := s.nodeRepo.FindByID(tunnel.OutNodeID)
-		if err != nil {
-			return 0, 0, errors.New("出口节点不存在")
-		}
+	if len(p.out) > 0 {
+		outSta, outEnd := commonPortRange(p.out)
+		usedOutPorts := s.usedPortsOf(p.out, 0)
+		reserved.addTo(usedOutPorts, p.out)
+		if portCount > 1 {
+			outPort = findFreePortBlock(outSta, outEnd, portCount, usedOutPorts)
+			if outPort == 0 {
+				return 0, 0, errors.New("出口节点无足够的连续可用端口")
+			}
+		} else {
+			var availableOutPorts []int
+			for port := outSta; port <= outEnd; port++ {
+				if !usedOutPorts[port] {
+					availableOutPorts = append(availableOutPorts, port)
+				}
+			}
 
-		usedOutPorts := s.getAllUsedPorts(tunnel.OutNodeID)
-		var availableOutPorts []int
-		for p := outNode.PortSta; p <= outNode.PortEnd; p++ {
-			if !usedOutPorts[p] {
-				availableOutPorts = append(availableOutPorts, p)
+			if len(availableOutPorts) == 0 {
+				return 0, 0, errors.New("出口节点无可用端口")
 			}
+			outPort = s.getRandomPort(availableOutPorts)
+		}
+	}
+
+	return allocInPort, outPort, nil
+}
+
+// commonPortRange 返回多个节点端口范围的交集
+func commonPortRange(nodes []models.Node) (int, int) {
+	portSta, portEnd := 0, 65535
+	for _, node := range nodes {
+		portSta = max(portSta, node.PortSta)
+		portEnd = min(portEnd, node.PortEnd)
+	}
+	return portSta, portEnd
+}
+
+// checkPortsAvailable 校验转发占用的端口在节点上可用，用于转发部署到新的节点前
+func (s *ForwardService) checkPortsAvailable(forward *models.Forward, node *models.Node, port int) error {
+	if port < node.PortSta || port+forward.Ports()-1 > node.PortEnd {
+		return fmt.Errorf("转发 %s 的端口 %d 不在节点 %s 的端口范围 %d-%d 内", forward.Name, port, node.Name, node.PortSta, node.PortEnd)
+	}
+	used := s.usedPorts(node.ID, forward.ID)
+	for i := 0; i < forward.Ports(); i++ {
+		if used[port+i] {
+			return fmt.Errorf("转发 %s 的端口 %d 在节点 %s 上已被占用", forward.Name, port+i, node.Name)
 		}
+	}
+	return nil
+}
 
-		if len(availableOutPorts) == 0 {
-			return 0, 0, errors.New("出口节点无可用端口")
+// findFreePortBlock 查找首个长度为 count 的连续空闲端口段，返回起始端口，无可用时返回0
+func findFreePortBlock(portSta, portEnd, count int, used map[int]bool) int {
+	run := 0
+	for p := portSta; p <= portEnd; p++ {
+		if used[p] {
+			run = 0
+			continue
+		}
+		run++
+		if run == count {
+			return p - count + 1
 		}
-		outPort = s.getRandomPort(availableOutPorts)
 	}
+	return 0
+}
 
-	return inPort, outPort, nil
+// parsePortCount 根据起止端口计算端口段大小
+func parsePortCount(inPort, inPortEnd *int) (int, error) {
+	if inPortEnd == nil || *inPortEnd == 0 {
+		return 1, nil
+	}
+	if inPort == nil {
+		return 0, errors.New("端口段转发需指定起始端口")
+	}
+
+	portCount := *inPortEnd - *inPort + 1
+	if portCount < 1 {
+		return 0, errors.New("端口段结束端口不能小于起始端口")
+	}
+	if portCount > maxPortRangeSize {
+		return 0, fmt.Errorf("端口段最多包含 %d 个端口", maxPortRangeSize)
+	}
+	return portCount, nil
+}
+
+// validateRemotePortRange 校验目标地址按端口段平移后端口仍然有效
+func validateRemotePortRange(remoteAddr string, portCount int) error {
+	if portCount <= 1 {
+		return nil
+	}
+	for _, addr := range strings.Split(remoteAddr, ",") {
+		_, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+		if err != nil {
+			return fmt.Errorf("目标地址格式错误: %s", addr)
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil || p+portCount-1 > 65535 {
+			return fmt.Errorf("目标地址 %s 的端口段超出范围", addr)
+		}
+	}
+	return nil
 }
 
 func (s *ForwardService) getRandomPort(ports []int) int {
@@ -205,35 +391,81 @@ func (s *ForwardService) getRandomPort(ports []int) int {
 
 // getAllUsedPorts 获取节点已用端口
 func (s *ForwardService) getAllUsedPorts(nodeID uint) map[int]bool {
+	return s.usedPorts(nodeID, 0)
+}
+
+// usedPortsOf 获取多个节点已用端口的并集
+func (s *ForwardService) usedPortsOf(nodes []models.Node, excludeForwardID uint) map[int]bool {
 	used := make(map[int]bool)
-	var ports []int
+	for _, node := range nodes {
+		for port := range s.usedPorts(node.ID, excludeForwardID) {
+			used[port] = true
+		}
+	}
+	return used
+}
 
-	// 1. 作为入口节点被占用的端口
-	// SELECT forward.in_port FROM forward JOIN tunnel ON forward.tunnel_id = tunnel.id WHERE tunnel.in_node_id = ?
-	s.db.Table("forward").
+// usedPorts 获取节点已用端口，包括通过节点组部署到该节点的转发，excludeForwardID 不为 0 时不计入该转发
+func (s *ForwardService) usedPorts(nodeID, excludeForwardID uint) map[int]bool {
+	return s.usedPortsIn(s.db, nodeID, excludeForwardID)
+}
+
+// usedPortsIn 通过 db 查询节点已用端口，在事务中调用时包括事务内已创建的转发
+func (s *ForwardService) usedPortsIn(db *gorm.DB, nodeID, excludeForwardID uint) map[int]bool {
+	used := make(map[int]bool)
+	var ranges []usedPortRange
+	groupIDs, _ := s.groupRepo.GroupIDsOfNode(nodeID)
+
+	// 1. 作为入口节点被占用的端口，入口为节点组时只计入部署到该节点的转发
+	// SELECT forward.in_port, forward.port_count FROM forward JOIN tunnel ON forward.tunnel_id = tunnel.id WHERE tunnel.in_node_id = ?
+	query := db.Table("forward").
+		Select("forward.in_port AS port, forward.port_count").
 		Joins("JOIN tunnel ON forward.tunnel_id = tunnel.id").
-		Where("tunnel.in_node_id = ?", nodeID).
-		Pluck("forward.in_port", &ports)
+		Where("forward.id <> ?", excludeForwardID)
+	if len(groupIDs) > 0 {
+		query = query.Where("tunnel.in_node_id = ? OR (tunnel.in_group_id IN ? AND forward.in_node_id IN ?)", nodeID, groupIDs, []uint{0, nodeID})
+	} else {
+		query = query.Where("tunnel.in_node_id = ?", nodeID)
+	}
+	query.Scan(&ranges)
 
-	for _, p := range ports {
-		used[p] = true
+	for _, r := range ranges {
+		r.markUsed(used)
 	}
 
 	// 2. 作为出口节点被占用的端口
-	// SELECT forward.out_port FROM forward JOIN tunnel ON forward.tunnel_id = tunnel.id WHERE tunnel.out_node_id = ?
-	ports = []int{}
-	s.db.Table("forward").
+	// SELECT forward.out_port, forward.port_count FROM forward JOIN tunnel ON forward.tunnel_id = tunnel.id WHERE tunnel.out_node_id = ?
+	ranges = []usedPortRange{}
+	query = db.Table("forward").
+		Select("forward.out_port AS port, forward.port_count").
 		Joins("JOIN tunnel ON forward.tunnel_id = tunnel.id").
-		Where("tunnel.out_node_id = ?", nodeID).
-		Pluck("forward.out_port", &ports)
+		Where("forward.id <> ?", excludeForwardID)
+	if len(groupIDs) > 0 {
+		query = query.Where("tunnel.out_node_id = ? OR tunnel.out_group_id IN ?", nodeID, groupIDs)
+	} else {
+		query = query.Where("tunnel.out_node_id = ?", nodeID)
+	}
+	query.Scan(&ranges)
 
-	for _, p := range ports {
-		used[p] = true
+	for _, r := range ranges {
+		r.markUsed(used)
 	}
 
 	return used
 }
 
+// usedPortRange 转发占用的端口段
+type usedPortRange struct {
+	Port      int
+	PortCount int
+}
+
+func (r usedPortRange) markUsed(used map[int]bool) {
+	for i := 0; i < max(r.PortCount, 1); i++ {
+		used[r.Port+i] = true
+	}
+}
+
 // GetAllForwards 获取所有转发
 func (s *ForwardService) GetAllForwards() ([]models.Forward, error) {
 	forwards, err := s.repo.FindAll()
@@ -252,7 +484,7 @@ func (s *ForwardService) GetForwardsByUserID(userID int) ([]models.Forward, erro
 	return s.populateForwardDetails(forwards)
 }
 
-// populateForwardDetails 填充转发详情(TunnelName, InIP)
+// populateForwardDetails 填充转发详情(TunnelName, InIP, 排队命令数)
 func (s *ForwardService) populateForwardDetails(forwards []models.Forward) ([]models.Forward, error) {
 	tunnels, err := s.tunnelRepo.FindAll()
 	if err != nil {
@@ -274,31 +506,163 @@ func (s *ForwardService) populateForwardDetails(forwards []models.Forward) ([]mo
 		nodeMap[n.ID] = n
 	}
 
+	// 节点组ID -> 成员节点
+	groupNodes := make(map[uint][]models.Node)
+	if members, err := s.groupRepo.FindMembers(); err == nil {
+		for _, m := range members {
+			if node, ok := nodeMap[m.NodeID]; ok {
+				groupNodes[m.GroupID] = append(groupNodes[m.GroupID], node)
+			}
+		}
+	}
+
+	pending, err := s.commandRepo.CountPendingByForward()
+	if err != nil {
+		pending = map[uint]int{}
+	}
+
 	for i := range forwards {
-		if tunnel, ok := tunnelMap[uint(forwards[i].TunnelID)]; ok {
-			forwards[i].TunnelName = tunnel.Name
+		forwards[i].Pending = pending[forwards[i].ID]
+		tunnel, ok := tunnelMap[uint(forwards[i].TunnelID)]
+		if !ok {
+			continue
+		}
+		forwards[i].TunnelName = tunnel.Name
+		// 配置了入口域名时展示域名，指定了入口节点的转发仍展示该节点的IP
+		if tunnel.Hostname != "" && forwards[i].InNodeID == 0 {
+			forwards[i].InIP = tunnel.Hostname
+			continue
+		}
+		if tunnel.InGroupID == 0 {
 			if node, ok := nodeMap[tunnel.InNodeID]; ok {
 				forwards[i].InIP = node.ServerIP
 			}
+			continue
+		}
+		in := groupNodes[tunnel.InGroupID]
+		if forwards[i].InNodeID > 0 {
+			in = filterNodes(in, forwards[i].InNodeID)
 		}
+		forwards[i].InIP = joinServerIPs(in)
 	}
 
 	return forwards, nil
 }
 
 // UpdateForward 更新转发
-func (s *ForwardService) UpdateForward(updateDto *dto.ForwardUpdateDto) error {
-	forward, err := s.repo.FindByID(updateDto.ID)
+func (s *ForwardService) UpdateForward(actor Actor, updateDto *dto.ForwardUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "forward.update", TargetType: "forward", TargetID: updateDto.ID, TargetName: updateDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	forward, err := s.authorizeForward(actor, updateDto.ID)
 	if err != nil {
-		return errors.New("转发不存在")
+		return err
 	}
+	snapshot := *forward
+	entry.Before = &snapshot
 
 	// 获取隧道信息
 	tunnel, err := s.tunnelRepo.FindByID(uint(updateDto.TunnelID))
 	if err != nil {
 		return errors.New("隧道不存在")
 	}
+	if tunnel.ID != uint(forward.TunnelID) {
+		if err := s.authorizeTunnel(actor, tunnel.ID); err != nil {
+			return err
+		}
+	}
+
+	// 同一隧道内更换入口节点时，需从不再部署的节点上删除服务
+	var before *placement
+	if tunnel.ID == uint(forward.TunnelID) {
+		before, _ = s.locator.forwardPlacement(tunnel, forward)
+	}
+
+	// 更新转发信息
+	forward.Name = updateDto.Name
+	forward.TunnelID = updateDto.TunnelID
+	forward.RemoteAddr = updateDto.RemoteAddr
+	forward.Strategy = updateDto.Strategy
+	forward.IPPreference = updateDto.IPPreference
+	forward.InterfaceName = updateDto.InterfaceName
+	forward.AccessLog = updateDto.AccessLog
+	if updateDto.InPort != nil {
+		forward.InPort = *updateDto.InPort
+	}
+	if updateDto.InNodeID != nil {
+		forward.InNodeID = *updateDto.InNodeID
+	}
+	forward.InNodeID = entryNodeID(tunnel, forward.InNodeID)
+	if updateDto.InPortEnd != nil && *updateDto.InPortEnd != 0 {
+		portCount, err := parsePortCount(&forward.InPort, updateDto.InPortEnd)
+		if err != nil {
+			return err
+		}
+		if portCount != forward.Ports() {
+			return errors.New("端口段大小不可修改，请删除后重新创建")
+		}
+	}
+	if err := validateRemotePortRange(forward.RemoteAddr, forward.Ports()); err != nil {
+		return err
+	}
+
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		return err
+	}
+	// 更换隧道或入口端口时整个端口段在全部入口节点上重新校验，否则只校验新增的入口节点
+	check := p.in
+	if before != nil && forward.InPort == snapshot.InPort {
+		check = subtractNodes(p.in, before.in)
+	}
+	for _, node := range check {
+		if err := s.checkPortsAvailable(forward, &node, forward.InPort); err != nil {
+			return err
+		}
+	}
+	var removed []models.Node
+	if before != nil {
+		removed = subtractNodes(before.in, p.in)
+	}
+
+	if err := s.syncGostServices(forward, tunnel, p); err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		s.removeGostServices(forward, tunnel, &placement{in: removed})
+	}
+
+	entry.After = forward
+	return s.repo.Update(forward)
+}
+
+// SyncTunnelForwards 隧道配置变更后重新下发该隧道下全部转发的 Gost 配置
+func (s *ForwardService) SyncTunnelForwards(tunnel *models.Tunnel) error {
+	forwards, err := s.repo.FindByTunnelID(tunnel.ID)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range forwards {
+		if err := s.updateGostServices(&forwards[i], tunnel); err != nil {
+			lastErr = fmt.Errorf("转发 %s 同步失败: %v", forwards[i].Name, err)
+		}
+	}
+	return lastErr
+}
+
+// updateGostServices 按当前转发与隧道配置更新节点上的 Gost 服务
+func (s *ForwardService) updateGostServices(forward *models.Forward, tunnel *models.Tunnel) error {
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		return err
+	}
+	return s.syncGostServices(forward, tunnel, p)
+}
 
+// syncGostServices 更新部署位置上全部节点的 Gost 服务，节点上缺少的配置重新创建
+func (s *ForwardService) syncGostServices(forward *models.Forward, tunnel *models.Tunnel, p *placement) error {
 	// 获取用户隧道信息
 	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
 
@@ -311,72 +675,192 @@ func (s *ForwardService) UpdateForward(updateDto *dto.ForwardUpdateDto) error {
 		userTunnelID = userTunnel.ID
 	}
 
-	// 获取入口节点
-	inNode, err := s.nodeRepo.FindByID(tunnel.InNodeID)
-	if err != nil {
-		return errors.New("入口节点不存在")
+	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+
+	// 同步目标地址解析器 (需先于引用它的服务下发)
+	resolver := forwardResolverName(tunnel, serviceName)
+	if resolver != "" {
+		for _, node := range p.targets(tunnel) {
+			resolverResp := UpdateResolvers(node.ID, resolver, tunnel, forward.IPPreference)
+			if !resolverResp.Success {
+				resolverResp = AddResolvers(node.ID, resolver, tunnel, forward.IPPreference)
+			}
+			if !resolverResp.Success {
+				return errors.New(resolverResp.Message)
+			}
+		}
 	}
 
-	// 获取出口节点（仅隧道转发）
-	var outNode *models.Node
+	// 更新 Gost 服务配置
 	if tunnel.Type == 2 {
-		outNode, err = s.nodeRepo.FindByID(tunnel.OutNodeID)
-		if err != nil {
-			return errors.New("出口节点不存在")
+		// 隧道转发：更新 Chain 和远程服务
+		remoteAddr := p.chainAddr(tunnel, forward.OutPort)
+		for _, node := range p.in {
+			chainResp := UpdateChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)
+			if !chainResp.Success {
+				// 节点上缺少链时重新创建
+				DeleteChains(node.ID, serviceName, forward.Ports())
+				chainResp = AddChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)
+			}
+			if !chainResp.Success {
+				return errors.New(chainResp.Message)
+			}
+		}
+
+		for _, node := range p.out {
+			remoteResp := UpdateRemoteService(node.ID, serviceName, forward.OutPort, forward.Ports(), forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName, resolver)
+			if !remoteResp.Success {
+				DeleteRemoteService(node.ID, serviceName, forward.Ports())
+				remoteResp = AddRemoteService(node.ID, serviceName, forward.OutPort, forward.Ports(), forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName, resolver)
+			}
+			if !remoteResp.Success {
+				return errors.New(remoteResp.Message)
+			}
+		}
+	}
+
+	interfaceName, entryResolver := entryServiceOptions(forward, tunnel, resolver)
+	for _, node := range p.in {
+		// 同步连接数限制
+		limitResp := SyncConnLimiters(node.ID, serviceName, userTunnel)
+		if !limitResp.Success {
+			return errors.New(limitResp.Message)
+		}
+
+		// 更新入口服务
+		resp := UpdateService(node.ID, serviceName, forward.InPort, forward.Ports(), limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName, entryResolver, forward.AccessLog == 1)
+		if !resp.Success {
+			// 节点上缺少服务时重新创建
+			DeleteService(node.ID, serviceName, forward.Ports())
+			resp = AddService(node.ID, serviceName, forward.InPort, forward.Ports(), limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName, entryResolver, forward.AccessLog == 1)
+		}
+		if !resp.Success {
+			return errors.New(resp.Message)
+		}
+	}
+
+	// 隧道不再使用独立解析器时清理 (服务已不再引用)
+	if resolver == "" {
+		for _, node := range p.targets(tunnel) {
+			DeleteResolvers(node.ID, serviceName)
 		}
 	}
 
+	return nil
+}
+
+// extendGostServices 节点组新增成员后只在新成员上部署转发，已暂停的转发直接以暂停状态创建；
+// 出口成员变化时链的地址随之变化，同时更新其余入口节点上的链
+func (s *ForwardService) extendGostServices(forward *models.Forward, tunnel *models.Tunnel, added *placement, outChanged bool) error {
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		return err
+	}
+	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
+
+	var limiter *int
+	var userTunnelID uint
+	if userTunnel != nil {
+		if userTunnel.SpeedID > 0 {
+			limiter = &userTunnel.SpeedID
+		}
+		userTunnelID = userTunnel.ID
+	}
 	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+	paused := forward.Status != 1
 
-	// 更新转发信息
-	forward.Name = updateDto.Name
-	forward.TunnelID = updateDto.TunnelID
-	forward.RemoteAddr = updateDto.RemoteAddr
-	forward.Strategy = updateDto.Strategy
-	forward.InterfaceName = updateDto.InterfaceName
-	if updateDto.InPort != nil {
-		forward.InPort = *updateDto.InPort
+	if len(BuildConnLimits(userTunnel)) > 0 || len(BuildRateLimits(userTunnel)) > 0 {
+		for _, node := range added.in {
+			if resp := SyncConnLimiters(node.ID, serviceName, userTunnel); !resp.Success {
+				return errors.New(resp.Message)
+			}
+		}
+	}
+	resolver := forwardResolverName(tunnel, serviceName)
+	if resolver != "" {
+		for _, node := range added.targets(tunnel) {
+			if resp := AddResolvers(node.ID, resolver, tunnel, forward.IPPreference); !resp.Success {
+				return errors.New(resp.Message)
+			}
+		}
 	}
 
-	// 更新 Gost 服务配置
 	if tunnel.Type == 2 {
-		// 隧道转发：更新 Chain 和远程服务
-		remoteAddr := fmt.Sprintf("%s:%d", tunnel.OutIP, forward.OutPort)
-		if strings.Contains(tunnel.OutIP, ":") {
-			remoteAddr = fmt.Sprintf("[%s]:%d", tunnel.OutIP, forward.OutPort)
+		remoteAddr := p.chainAddr(tunnel, forward.OutPort)
+		for _, node := range p.in {
+			var resp *GostResponse
+			switch {
+			case added.isIn(node.ID):
+				resp = AddChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)
+			case outChanged:
+				resp = UpdateChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)
+				if !resp.Success {
+					DeleteChains(node.ID, serviceName, forward.Ports())
+					resp = AddChains(node.ID, serviceName, remoteAddr, forward.Ports(), tunnel.Protocol, tunnel.InterfaceName)
+				}
+			default:
+				continue
+			}
+			if !resp.Success {
+				return errors.New(resp.Message)
+			}
 		}
 
-		chainResp := UpdateChains(inNode.ID, serviceName, remoteAddr, tunnel.Protocol, tunnel.InterfaceName)
-		if !chainResp.Success {
-			return errors.New(chainResp.Message)
+		for _, node := range added.out {
+			add := AddRemoteService
+			if paused {
+				add = AddPausedRemoteService
+			}
+			if resp := add(node.ID, serviceName, forward.OutPort, forward.Ports(), forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName, resolver); !resp.Success {
+				return errors.New(resp.Message)
+			}
 		}
+	}
 
-		remoteResp := UpdateRemoteService(outNode.ID, serviceName, forward.OutPort, forward.RemoteAddr, tunnel.Protocol, forward.Strategy, forward.InterfaceName)
-		if !remoteResp.Success {
-			return errors.New(remoteResp.Message)
+	interfaceName, entryResolver := entryServiceOptions(forward, tunnel, resolver)
+	for _, node := range added.in {
+		add := AddService
+		if paused {
+			add = AddPausedService
+		}
+		if resp := add(node.ID, serviceName, forward.InPort, forward.Ports(), limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName, entryResolver, forward.AccessLog == 1); !resp.Success {
+			return errors.New(resp.Message)
 		}
 	}
+	return nil
+}
 
-	interfaceName := ""
-	if tunnel.Type != 2 {
-		interfaceName = forward.InterfaceName
+// forwardResolverName 返回转发目标地址使用的解析器名称，隧道未配置解析器时为空
+func forwardResolverName(tunnel *models.Tunnel, serviceName string) string {
+	if tunnel.UsesResolver() {
+		return serviceName
 	}
+	return ""
+}
 
-	// 更新入口服务
-	resp := UpdateService(inNode.ID, serviceName, forward.InPort, limiter, forward.RemoteAddr, tunnel.Type, tunnel, forward.Strategy, interfaceName)
-	if !resp.Success {
-		return errors.New(resp.Message)
+// removeGostServices 删除转发在指定节点上的全部配置
+func (s *ForwardService) removeGostServices(forward *models.Forward, tunnel *models.Tunnel, p *placement) {
+	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
+	var userTunnelID uint
+	if userTunnel != nil {
+		userTunnelID = userTunnel.ID
 	}
 
-	return s.repo.Update(forward)
+	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+	s.deleteGostServices(forward, tunnel, p, serviceName, userTunnel)
 }
 
 // DeleteForward 删除转发
-func (s *ForwardService) DeleteForward(id uint) error {
-	forward, err := s.repo.FindByID(id)
+func (s *ForwardService) DeleteForward(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "forward.delete", TargetType: "forward", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	forward, err := s.authorizeForward(actor, id)
 	if err != nil {
-		return errors.New("转发不存在")
+		return err
 	}
+	entry.TargetName = forward.Name
+	entry.Before = forward
 
 	// 获取隧道信息
 	tunnel, err := s.tunnelRepo.FindByID(uint(forward.TunnelID))
@@ -385,69 +869,154 @@ func (s *ForwardService) DeleteForward(id uint) error {
 		return s.repo.Delete(id)
 	}
 
-	// 获取用户隧道信息
-	userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
-	var userTunnelID uint
-	if userTunnel != nil {
-		userTunnelID = userTunnel.ID
-	}
-
-	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
-
 	// 删除 Gost 服务
-	DeleteService(tunnel.InNodeID, serviceName)
-	DeleteChains(tunnel.InNodeID, serviceName)
-
-	if tunnel.Type == 2 {
-		DeleteRemoteService(tunnel.OutNodeID, serviceName)
+	if p, err := s.locator.forwardPlacement(tunnel, forward); err == nil {
+		s.removeGostServices(forward, tunnel, p)
 	}
 
 	return s.repo.Delete(id)
 }
 
 // ForceDeleteForward 强制删除转发
-func (s *ForwardService) ForceDeleteForward(id uint) error {
+func (s *ForwardService) ForceDeleteForward(actor Actor, id uint) error {
 	forward, err := s.repo.FindByID(id)
 	if err != nil {
 		// 转发不存在，直接返回成功
 		return nil
 	}
+	entry := &AuditEntry{Action: "forward.force_delete", TargetType: "forward", TargetID: id, TargetName: forward.Name, Before: forward}
+	if !actor.CanAccess(forward.UserID) {
+		s.audit.Record(actor, entry, ErrForbidden)
+		return ErrForbidden
+	}
 
 	// 尝试删除 agent 上的服务（忽略错误）
 	tunnel, err := s.tunnelRepo.FindByID(uint(forward.TunnelID))
 	if err == nil {
-		userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
-		var userTunnelID uint
-		if userTunnel != nil {
-			userTunnelID = userTunnel.ID
+		if p, err := s.locator.forwardPlacement(tunnel, forward); err == nil {
+			s.removeGostServices(forward, tunnel, p)
+		}
+	}
+
+	err = s.repo.Delete(id)
+	s.audit.Record(actor, entry, err)
+	return err
+}
+
+// ReconcileNode 按节点上报的配置对账：重新下发节点上缺失的转发服务，并纠正与面板不一致的暂停状态
+func (s *ForwardService) ReconcileNode(nodeID uint, gostConfig *dto.GostConfigDto) {
+	// 服务名 -> 是否处于暂停状态
+	reported := make(map[string]bool, len(gostConfig.Services))
+	for _, svc := range gostConfig.Services {
+		reported[svc.Name] = svc.Paused()
+	}
+
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		log.Printf("节点 %d 对账失败: %v", nodeID, err)
+		return
+	}
+
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		in, out, err := s.locator.tunnelNodes(tunnel)
+		if err != nil {
+			continue
+		}
+		if len(filterNodes(in, nodeID)) == 0 && (tunnel.Type != 2 || len(filterNodes(out, nodeID)) == 0) {
+			continue
+		}
+
+		forwards, err := s.repo.FindByTunnelID(tunnel.ID)
+		if err != nil {
+			log.Printf("节点 %d 对账时查询隧道 %d 的转发失败: %v", nodeID, tunnel.ID, err)
+			continue
+		}
+		for j := range forwards {
+			p := newPlacement(tunnel, in, out, &forwards[j])
+			isIn, isOut := p.isIn(nodeID), p.isOut(nodeID)
+			if isIn || isOut {
+				s.reconcileForward(nodeID, &forwards[j], tunnel, p, isIn, isOut, reported)
+			}
 		}
+	}
+}
+
+// reconcileForward 对账单个转发在节点上的服务
+func (s *ForwardService) reconcileForward(nodeID uint, forward *models.Forward, tunnel *models.Tunnel, p *placement, isIn, isOut bool, reported map[string]bool) {
+	var userTunnelID uint
+	if userTunnel, err := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID); err == nil && userTunnel != nil {
+		userTunnelID = userTunnel.ID
+	}
+	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
 
-		serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+	var names []string
+	if isIn {
+		names = append(names, portServiceNames(serviceName, forward.Ports(), "_tcp", "_udp")...)
+	}
+	if isOut {
+		names = append(names, portServiceNames(serviceName, forward.Ports(), "_tls")...)
+	}
 
-		// 删除 Gost 服务（忽略失败）
-		DeleteService(tunnel.InNodeID, serviceName)
-		DeleteChains(tunnel.InNodeID, serviceName)
+	missing, anyPaused, allPaused := false, false, true
+	for _, name := range names {
+		paused, ok := reported[name]
+		if !ok {
+			missing = true
+			continue
+		}
+		anyPaused = anyPaused || paused
+		allPaused = allPaused && paused
+	}
 
-		if tunnel.Type == 2 {
-			DeleteRemoteService(tunnel.OutNodeID, serviceName)
+	if missing {
+		log.Printf("节点 %d 缺少转发 %d 的服务，重新下发", nodeID, forward.ID)
+		if err := s.syncGostServices(forward, tunnel, p); err != nil {
+			log.Printf("节点 %d 重新下发转发 %d 失败: %v", nodeID, forward.ID, err)
+			return
 		}
+		// 重新下发的服务处于运行状态
+		anyPaused, allPaused = false, false
 	}
 
-	return s.repo.Delete(id)
+	// 转发状态为 1 时运行，其余（手动暂停、流量超限等）均应处于暂停状态
+	if forward.Status != 1 && !allPaused {
+		log.Printf("节点 %d 上的转发 %d 应处于暂停状态，重新暂停", nodeID, forward.ID)
+		if isIn {
+			PauseService(nodeID, serviceName, forward.Ports())
+		}
+		if isOut {
+			PauseRemoteService(nodeID, serviceName, forward.Ports())
+		}
+	} else if forward.Status == 1 && anyPaused {
+		log.Printf("节点 %d 上的转发 %d 应处于运行状态，重新恢复", nodeID, forward.ID)
+		if isIn {
+			ResumeService(nodeID, serviceName, forward.Ports())
+		}
+		if isOut {
+			ResumeRemoteService(nodeID, serviceName, forward.Ports())
+		}
+	}
 }
 
 // PauseForward 暂停转发
-func (s *ForwardService) PauseForward(id uint) error {
-	forward, err := s.repo.FindByID(id)
+func (s *ForwardService) PauseForward(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "forward.pause", TargetType: "forward", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	forward, err := s.authorizeForward(actor, id)
 	if err != nil {
-		return errors.New("转发不存在")
+		return err
 	}
+	entry.TargetName = forward.Name
+	entry.Before = map[string]int{"status": forward.Status}
 
 	// 更新数据库状态
 	forward.Status = 0
 	if err := s.repo.Update(forward); err != nil {
 		return err
 	}
+	entry.After = map[string]int{"status": forward.Status}
 
 	// 调用 Gost API 暂停服务
 	userTunnel, err := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), uint(forward.TunnelID))
@@ -461,26 +1030,29 @@ func (s *ForwardService) PauseForward(id uint) error {
 	}
 
 	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnel.ID)
-	PauseService(tunnel.InNodeID, serviceName)
-	if tunnel.Type == 2 {
-		PauseRemoteService(tunnel.OutNodeID, serviceName)
-	}
+	s.setPaused(forward, tunnel, serviceName, true)
 
 	return nil
 }
 
 // ResumeForward 恢复转发
-func (s *ForwardService) ResumeForward(id uint) error {
-	forward, err := s.repo.FindByID(id)
+func (s *ForwardService) ResumeForward(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "forward.resume", TargetType: "forward", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	forward, err := s.authorizeForward(actor, id)
 	if err != nil {
-		return errors.New("转发不存在")
+		return err
 	}
+	entry.TargetName = forward.Name
+	entry.Before = map[string]int{"status": forward.Status}
 
 	// 更新数据库状态
 	forward.Status = 1
 	if err := s.repo.Update(forward); err != nil {
 		return err
 	}
+	entry.After = map[string]int{"status": forward.Status}
 
 	// 调用 Gost API 恢复服务
 	userTunnel, err := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), uint(forward.TunnelID))
@@ -494,19 +1066,91 @@ func (s *ForwardService) ResumeForward(id uint) error {
 	}
 
 	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnel.ID)
-	ResumeService(tunnel.InNodeID, serviceName)
-	if tunnel.Type == 2 {
-		ResumeRemoteService(tunnel.OutNodeID, serviceName)
-	}
+	s.setPaused(forward, tunnel, serviceName, false)
 
 	return nil
 }
 
-// DiagnoseForward 诊断转发
-func (s *ForwardService) DiagnoseForward(id uint) (map[string]interface{}, error) {
-	forward, err := s.repo.FindByID(id)
+// SuspendForward 暂停转发在全部节点上的服务，用于流量超限、到期等自动暂停，不修改转发状态
+func (s *ForwardService) SuspendForward(forward *models.Forward) {
+	tunnel, err := s.tunnelRepo.FindByID(uint(forward.TunnelID))
 	if err != nil {
-		return nil, errors.New("转发不存在")
+		return
+	}
+
+	var userTunnelID uint
+	if userTunnel, err := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID); err == nil && userTunnel != nil {
+		userTunnelID = userTunnel.ID
+	}
+
+	serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnelID)
+	s.setPaused(forward, tunnel, serviceName, true)
+}
+
+// AutoPauseForward 流量超限、到期等情况下由系统暂停转发，转发由启用变为暂停时记录审计日志
+func (s *ForwardService) AutoPauseForward(forward *models.Forward, reason string) {
+	s.SuspendForward(forward)
+
+	err := s.db.Model(&models.Forward{}).Where("id = ?", forward.ID).Update("status", 0).Error
+	if forward.Status == 1 {
+		s.audit.Record(SystemActor, &AuditEntry{
+			Action:     "forward.auto_pause",
+			TargetType: "forward",
+			TargetID:   forward.ID,
+			TargetName: forward.Name,
+			Before:     map[string]int{"status": forward.Status},
+			After:      map[string]int{"status": 0},
+			Message:    reason,
+		}, err)
+	}
+	forward.Status = 0
+}
+
+// setPaused 暂停或恢复转发在全部节点上的服务，节点离线时命令进入队列
+func (s *ForwardService) setPaused(forward *models.Forward, tunnel *models.Tunnel, serviceName string, paused bool) {
+	p, err := s.locator.forwardPlacement(tunnel, forward)
+	if err != nil {
+		log.Printf("转发 %d 部署位置解析失败: %v", forward.ID, err)
+		return
+	}
+
+	for _, node := range p.in {
+		if paused {
+			PauseService(node.ID, serviceName, forward.Ports())
+		} else {
+			ResumeService(node.ID, serviceName, forward.Ports())
+		}
+	}
+	for _, node := range p.out {
+		if paused {
+			PauseRemoteService(node.ID, serviceName, forward.Ports())
+		} else {
+			ResumeRemoteService(node.ID, serviceName, forward.Ports())
+		}
+	}
+}
+
+// GetForwardCommands 查询转发在节点上的命令，包括节点离线时排队等待下发的命令
+func (s *ForwardService) GetForwardCommands(actor Actor, id uint) ([]models.NodeCommand, error) {
+	if _, err := s.authorizeForward(actor, id); err != nil {
+		return nil, err
+	}
+	return s.commandRepo.Find(0, id, nil, 100)
+}
+
+// DiagnoseForward 诊断转发，protocol 指定对目标使用的探测方式 (tcp, udp, http, https)，
+// trace 为 true 时额外对每段路径做路由追踪
+func (s *ForwardService) DiagnoseForward(actor Actor, id uint, protocol string, trace bool) (map[string]interface{}, error) {
+	forward, err := s.authorizeForward(actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == "" {
+		protocol = ProbeTCP
+	}
+	if protocol != ProbeTCP && protocol != ProbeUDP && protocol != ProbeHTTP && protocol != ProbeHTTPS {
+		return nil, fmt.Errorf("不支持的诊断协议: %s", protocol)
 	}
 
 	tunnel, err := s.tunnelRepo.FindByID(uint(forward.TunnelID))
@@ -514,38 +1158,58 @@ func (s *ForwardService) DiagnoseForward(id uint) (map[string]interface{}, error
 		return nil, errors.New("隧道不存在")
 	}
 
-	inNode, err := s.nodeRepo.FindByID(tunnel.InNodeID)
+	p, err := s.locator.forwardPlacement(tunnel, forward)
 	if err != nil {
-		return nil, errors.New("入口节点不存在")
+		return nil, err
 	}
 
 	var results []DiagnosisResult
 	addrs := strings.Split(forward.RemoteAddr, ",")
 
-	if tunnel.Type == 1 { // 端口转发
+	// 目标地址使用与转发相同的解析器与优先级
+	resolver := ""
+	if tunnel.UsesResolver() {
+		userTunnel, _ := s.userTunnelRepo.FindByUserAndTunnel(uint(forward.UserID), tunnel.ID)
+		resolver = forwardResolverName(tunnel, BuildServiceName(forward.ID, forward.UserID, userTunnel.ID))
+	}
+
+	// diagnoseTarget 按协议探测目标，需要时追加路由追踪
+	diagnoseTarget := func(node *models.Node, description string) {
 		for _, addr := range addrs {
 			host, port := parseTarget(addr)
 			if host == "" || port == 0 {
 				continue
 			}
-			results = append(results, s.performTcpPingDiagnosis(inNode, host, port, "转发->目标"))
+			if protocol == ProbeTCP {
+				results = append(results, s.performTcpPingDiagnosis(node, host, port, description, resolver, forward.IPPreference))
+			} else {
+				results = append(results, performProbeDiagnosis(node, protocol, host, port, description, resolver, forward.IPPreference))
+			}
+			if trace {
+				results = append(results, performProbeDiagnosis(node, ProbeTrace, host, port, description, resolver, forward.IPPreference))
+			}
+		}
+	}
+
+	// 入口或出口为节点组时对每个成员分别诊断
+	if tunnel.Type == 1 { // 端口转发
+		for i := range p.in {
+			diagnoseTarget(&p.in[i], "转发->目标")
 		}
 	} else { // 隧道转发
-		outNode, err := s.nodeRepo.FindByID(tunnel.OutNodeID)
-		if err != nil {
-			return nil, errors.New("出口节点不存在")
+		for i := range p.in {
+			for _, outNode := range p.out {
+				// 入口->出口
+				results = append(results, s.performTcpPingDiagnosis(&p.in[i], outNode.ServerIP, 22, "入口->出口", "", ""))
+				if trace {
+					results = append(results, performProbeDiagnosis(&p.in[i], ProbeTrace, outNode.ServerIP, 22, "入口->出口", "", ""))
+				}
+			}
 		}
 
-		// 入口->出口
-		results = append(results, s.performTcpPingDiagnosis(inNode, outNode.ServerIP, 22, "入口->出口"))
-
 		// 出口->目标
-		for _, addr := range addrs {
-			host, port := parseTarget(addr)
-			if host == "" || port == 0 {
-				continue
-			}
-			results = append(results, s.performTcpPingDiagnosis(outNode, host, port, "出口->目标"))
+		for i := range p.out {
+			diagnoseTarget(&p.out[i], "出口->目标")
 		}
 	}
 
@@ -558,6 +1222,7 @@ func (s *ForwardService) DiagnoseForward(id uint) (map[string]interface{}, error
 		"forwardId":   forward.ID,
 		"forwardName": forward.Name,
 		"tunnelType":  tunnelTypeStr,
+		"protocol":    protocol,
 		"results":     results,
 		"timestamp":   time.Now().UnixMilli(),
 	}
@@ -565,7 +1230,7 @@ func (s *ForwardService) DiagnoseForward(id uint) (map[string]interface{}, error
 	return result, nil
 }
 
-func (s *ForwardService) performTcpPingDiagnosis(node *models.Node, targetIp string, port int, description string) DiagnosisResult {
+func (s *ForwardService) performTcpPingDiagnosis(node *models.Node, targetIp string, port int, description, resolver, prefer string) DiagnosisResult {
 	result := DiagnosisResult{
 		NodeId:      node.ID,
 		NodeName:    node.Name,
@@ -581,8 +1246,14 @@ func (s *ForwardService) performTcpPingDiagnosis(node *models.Node, targetIp str
 		"count":   4,
 		"timeout": 5000,
 	}
+	if resolver != "" {
+		tcpPingReq["resolver"] = resolver
+	}
+	if prefer != "" {
+		tcpPingReq["prefer"] = prefer
+	}
 
-	resp, err := websocket.GetServer().SendMessage(node.ID, tcpPingReq, "TcpPing")
+	resp, err := websocket.GetServer().SendMessage(node.ID, tcpPingReq, websocket.MessageTypeTcpPing)
 	if err != nil {
 		result.Success = false
 		result.Message = err.Error()
@@ -621,6 +1292,13 @@ func (s *ForwardService) performTcpPingDiagnosis(node *models.Node, targetIp str
 		if val, ok := dataMap["packetLoss"].(float64); ok {
 			result.PacketLoss = val
 		}
+		if val, ok := dataMap["resolvedIps"].([]interface{}); ok {
+			for _, ip := range val {
+				if ipStr, ok := ip.(string); ok {
+					result.ResolvedIPs = append(result.ResolvedIPs, ipStr)
+				}
+			}
+		}
 	} else {
 		result.Success = true
 		result.Message = "TCP连接成功 (数据解析失败)"
@@ -639,7 +1317,23 @@ func parseTarget(addr string) (string, int) {
 }
 
 // UpdateForwardOrder 更新转发排序
-func (s *ForwardService) UpdateForwardOrder(orderDto *dto.ForwardOrderDto) error {
+func (s *ForwardService) UpdateForwardOrder(actor Actor, orderDto *dto.ForwardOrderDto) error {
+	if !actor.IsAdmin() {
+		ids := make([]uint, 0, len(orderDto.Forwards))
+		for _, item := range orderDto.Forwards {
+			ids = append(ids, item.ID)
+		}
+		forwards, err := s.repo.FindByIDs(ids)
+		if err != nil {
+			return err
+		}
+		for _, forward := range forwards {
+			if !actor.CanAccess(forward.UserID) {
+				return ErrForbidden
+			}
+		}
+	}
+
 	for _, item := range orderDto.Forwards {
 		if err := s.repo.UpdateOrder(item.ID, item.Inx); err != nil {
 			return err
@@ -4,9 +4,16 @@ import (
 	"flux-panel/models"
 	"flux-panel/websocket"
 	"fmt"
+	"log"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// AccessLogRecorderName 节点内置的连接日志记录器名
+const AccessLogRecorderName = "access_log"
+
 // GostResponse Gost 操作响应
 type GostResponse struct {
 	Success bool        `json:"success"`
@@ -15,24 +22,58 @@ type GostResponse struct {
 }
 
 // AddLimiters 添加限流器
-func AddLimiters(nodeID uint, name uint, speed string) *GostResponse {
+func AddLimiters(nodeID uint, name uint, limits []string) *GostResponse {
 	data := map[string]interface{}{
 		"name":   fmt.Sprintf("%d", name),
-		"limits": []string{fmt.Sprintf("$ %sMB %sMB", speed, speed)},
+		"limits": limits,
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeAddLimiters)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeAddLimiters, fmt.Sprintf("limiter:%d", name))
 }
 
 // UpdateLimiters 更新限流器
-func UpdateLimiters(nodeID uint, name uint, speed string) *GostResponse {
+func UpdateLimiters(nodeID uint, name uint, limits []string) *GostResponse {
 	data := map[string]interface{}{
 		"limiter": fmt.Sprintf("%d", name),
 		"data": map[string]interface{}{
 			"name":   fmt.Sprintf("%d", name),
-			"limits": []string{fmt.Sprintf("$ %sMB %sMB", speed, speed)},
+			"limits": limits,
 		},
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeUpdateLimiters)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateLimiters, fmt.Sprintf("limiter:%d", name))
+}
+
+// BuildTrafficLimits 根据限速规则生成流量限制规则
+// 格式: "$ 上行[/突发] 下行[/突发]" (服务级), "$$ 上行 下行" (单连接)
+func BuildTrafficLimits(speedLimit *models.SpeedLimit) []string {
+	inSpeed, outSpeed := speedLimit.InSpeed, speedLimit.OutSpeed
+	if inSpeed <= 0 {
+		inSpeed = speedLimit.Speed
+	}
+	if outSpeed <= 0 {
+		outSpeed = speedLimit.Speed
+	}
+
+	limits := make([]string, 0, 2)
+	if inSpeed > 0 || outSpeed > 0 {
+		limits = append(limits, fmt.Sprintf("$ %s %s",
+			formatTrafficRate(inSpeed, speedLimit.InBurst), formatTrafficRate(outSpeed, speedLimit.OutBurst)))
+	}
+	if speedLimit.ConnInSpeed > 0 || speedLimit.ConnOutSpeed > 0 {
+		limits = append(limits, fmt.Sprintf("$$ %s %s",
+			formatTrafficRate(speedLimit.ConnInSpeed, 0), formatTrafficRate(speedLimit.ConnOutSpeed, 0)))
+	}
+	return limits
+}
+
+// formatTrafficRate 格式化速率，0 表示不限制
+func formatTrafficRate(speed, burst int) string {
+	if speed <= 0 {
+		return "0"
+	}
+	if burst > speed {
+		return fmt.Sprintf("%dMB/%dMB", speed, burst)
+	}
+	return fmt.Sprintf("%dMB", speed)
 }
 
 // DeleteLimiters 删除限流器
@@ -40,140 +81,422 @@ func DeleteLimiters(nodeID uint, name uint) *GostResponse {
 	data := map[string]interface{}{
 		"limiter": fmt.Sprintf("%d", name),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeDeleteLimiters)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteLimiters, fmt.Sprintf("limiter:%d", name))
 }
 
-// AddService 添加服务 (TCP/UDP)
-func AddService(nodeID uint, name string, inPort int, limiter *int, remoteAddr string,
-	forwardType int, tunnel *models.Tunnel, strategy, interfaceName string) *GostResponse {
+// AddConnLimiters 添加连接数限制器
+func AddConnLimiters(nodeID uint, name string, limits []string) *GostResponse {
+	data := map[string]interface{}{
+		"name":   name,
+		"limits": limits,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeAddCLimiters, "climiter:"+name)
+}
 
-	services := make([]map[string]interface{}, 0, 2)
-	protocols := []string{"tcp", "udp"}
+// UpdateConnLimiters 更新连接数限制器
+func UpdateConnLimiters(nodeID uint, name string, limits []string) *GostResponse {
+	data := map[string]interface{}{
+		"limiter": name,
+		"data": map[string]interface{}{
+			"name":   name,
+			"limits": limits,
+		},
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateCLimiters, "climiter:"+name)
+}
 
-	for _, protocol := range protocols {
-		service := createServiceConfig(name, inPort, limiter, remoteAddr, protocol, forwardType, tunnel, strategy, interfaceName)
-		services = append(services, service)
+// DeleteConnLimiters 删除连接数限制器
+func DeleteConnLimiters(nodeID uint, name string) *GostResponse {
+	data := map[string]interface{}{
+		"limiter": name,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteCLimiters, "climiter:"+name)
+}
+
+// AddRateLimiters 添加新建连接速率限制器
+func AddRateLimiters(nodeID uint, name string, limits []string) *GostResponse {
+	data := map[string]interface{}{
+		"name":   name,
+		"limits": limits,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeAddRLimiters, "rlimiter:"+name)
+}
+
+// UpdateRateLimiters 更新新建连接速率限制器
+func UpdateRateLimiters(nodeID uint, name string, limits []string) *GostResponse {
+	data := map[string]interface{}{
+		"limiter": name,
+		"data": map[string]interface{}{
+			"name":   name,
+			"limits": limits,
+		},
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateRLimiters, "rlimiter:"+name)
+}
+
+// DeleteRateLimiters 删除新建连接速率限制器
+func DeleteRateLimiters(nodeID uint, name string) *GostResponse {
+	data := map[string]interface{}{
+		"limiter": name,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteRLimiters, "rlimiter:"+name)
+}
+
+// BuildConnLimits 根据用户隧道权限生成连接数限制规则
+func BuildConnLimits(userTunnel *models.UserTunnel) []string {
+	limits := make([]string, 0, 2)
+	if userTunnel == nil {
+		return limits
+	}
+	if userTunnel.ConnLimit > 0 {
+		limits = append(limits, fmt.Sprintf("$ %d", userTunnel.ConnLimit))
+	}
+	if userTunnel.IPConnLimit > 0 {
+		limits = append(limits, fmt.Sprintf("$$ %d", userTunnel.IPConnLimit))
+	}
+	return limits
+}
+
+// BuildRateLimits 根据用户隧道权限生成新建连接速率限制规则
+func BuildRateLimits(userTunnel *models.UserTunnel) []string {
+	limits := make([]string, 0, 2)
+	if userTunnel == nil {
+		return limits
+	}
+	if userTunnel.ConnRate > 0 {
+		limits = append(limits, fmt.Sprintf("$ %d", userTunnel.ConnRate))
+	}
+	if userTunnel.IPConnRate > 0 {
+		limits = append(limits, fmt.Sprintf("$$ %d", userTunnel.IPConnRate))
+	}
+	return limits
+}
+
+// SyncConnLimiters 同步转发的连接数与新建连接速率限制器 (无限制时删除)
+func SyncConnLimiters(nodeID uint, name string, userTunnel *models.UserTunnel) *GostResponse {
+	if limits := BuildConnLimits(userTunnel); len(limits) > 0 {
+		result := UpdateConnLimiters(nodeID, name, limits)
+		if !result.Success {
+			result = AddConnLimiters(nodeID, name, limits)
+		}
+		if !result.Success {
+			return result
+		}
+	} else {
+		DeleteConnLimiters(nodeID, name)
+	}
+
+	if limits := BuildRateLimits(userTunnel); len(limits) > 0 {
+		result := UpdateRateLimiters(nodeID, name, limits)
+		if !result.Success {
+			result = AddRateLimiters(nodeID, name, limits)
+		}
+		if !result.Success {
+			return result
+		}
+	} else {
+		DeleteRateLimiters(nodeID, name)
+	}
+
+	return &GostResponse{Success: true, Message: "OK"}
+}
+
+// AddResolvers 添加解析器
+func AddResolvers(nodeID uint, name string, tunnel *models.Tunnel, prefer string) *GostResponse {
+	data := createResolverConfig(name, tunnel, prefer)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeAddResolvers, "resolver:"+name)
+}
+
+// UpdateResolvers 更新解析器
+func UpdateResolvers(nodeID uint, name string, tunnel *models.Tunnel, prefer string) *GostResponse {
+	data := map[string]interface{}{
+		"resolver": name,
+		"data":     createResolverConfig(name, tunnel, prefer),
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateResolvers, "resolver:"+name)
+}
+
+// DeleteResolvers 删除解析器
+func DeleteResolvers(nodeID uint, name string) *GostResponse {
+	data := map[string]interface{}{
+		"resolver": name,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteResolvers, "resolver:"+name)
+}
+
+// 创建解析器配置
+func createResolverConfig(name string, tunnel *models.Tunnel, prefer string) map[string]interface{} {
+	nameserver := map[string]interface{}{
+		"addr":    BuildNameserverAddr(tunnel.ResolverType, tunnel.ResolverAddr),
+		"timeout": int64(5 * time.Second),
+	}
+	if tunnel.ResolverTTL > 0 {
+		nameserver["ttl"] = int64(time.Duration(tunnel.ResolverTTL) * time.Second)
+	}
+	if prefer != "" {
+		nameserver["prefer"] = prefer
 	}
 
-	return sendGostMessage(nodeID, services, websocket.MessageTypeAddService)
+	return map[string]interface{}{
+		"name":        name,
+		"nameservers": []map[string]interface{}{nameserver},
+	}
+}
+
+// BuildNameserverAddr 根据解析方式生成 gost 解析服务器地址
+func BuildNameserverAddr(resolverType, addr string) string {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "://") {
+		return addr
+	}
+
+	switch resolverType {
+	case "doh":
+		return "https://" + addr
+	case "dot":
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "853")
+		}
+		return "tls://" + addr
+	default:
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		return "udp://" + addr
+	}
+}
+
+// AddService 添加服务 (TCP/UDP)，端口段转发为段内每个端口各生成一组服务
+func AddService(nodeID uint, name string, inPort, portCount int, limiter *int, remoteAddr string,
+	forwardType int, tunnel *models.Tunnel, strategy, interfaceName, resolver string, accessLog bool) *GostResponse {
+
+	accessLog = adaptAccessLog(nodeID, accessLog)
+	services := createServiceConfigs(name, inPort, portCount, limiter, remoteAddr, forwardType, tunnel, strategy, interfaceName, resolver, accessLog)
+	return sendNodeCommand(nodeID, services, websocket.MessageTypeAddService, "service:"+name)
+}
+
+// AddPausedService 以暂停状态添加服务，恢复前不打开监听端口；节点不支持时添加后立即暂停
+func AddPausedService(nodeID uint, name string, inPort, portCount int, limiter *int, remoteAddr string,
+	forwardType int, tunnel *models.Tunnel, strategy, interfaceName, resolver string, accessLog bool) *GostResponse {
+
+	if !nodeHasFeature(nodeID, websocket.FeatureCreatePaused) {
+		if resp := AddService(nodeID, name, inPort, portCount, limiter, remoteAddr, forwardType, tunnel, strategy, interfaceName, resolver, accessLog); !resp.Success {
+			return resp
+		}
+		return PauseService(nodeID, name, portCount)
+	}
+	accessLog = adaptAccessLog(nodeID, accessLog)
+	services := createServiceConfigs(name, inPort, portCount, limiter, remoteAddr, forwardType, tunnel, strategy, interfaceName, resolver, accessLog)
+	return sendNodeCommand(nodeID, markPaused(services), websocket.MessageTypeAddService, "service:"+name)
 }
 
 // UpdateService 更新服务
-func UpdateService(nodeID uint, name string, inPort int, limiter *int, remoteAddr string,
-	forwardType int, tunnel *models.Tunnel, strategy, interfaceName string) *GostResponse {
+func UpdateService(nodeID uint, name string, inPort, portCount int, limiter *int, remoteAddr string,
+	forwardType int, tunnel *models.Tunnel, strategy, interfaceName, resolver string, accessLog bool) *GostResponse {
 
-	services := make([]map[string]interface{}, 0, 2)
-	protocols := []string{"tcp", "udp"}
+	accessLog = adaptAccessLog(nodeID, accessLog)
+	services := createServiceConfigs(name, inPort, portCount, limiter, remoteAddr, forwardType, tunnel, strategy, interfaceName, resolver, accessLog)
+	return sendNodeCommand(nodeID, services, websocket.MessageTypeUpdateService, "service:"+name)
+}
 
-	for _, protocol := range protocols {
-		service := createServiceConfig(name, inPort, limiter, remoteAddr, protocol, forwardType, tunnel, strategy, interfaceName)
-		services = append(services, service)
+// adaptAccessLog 节点不支持连接日志时不下发记录器，避免引用旧版本节点上不存在的记录器
+func adaptAccessLog(nodeID uint, accessLog bool) bool {
+	if accessLog && !websocket.NodeCapabilities(nodeID).HasFeature(websocket.FeatureAccessLog) {
+		log.Printf("节点 %d 不支持连接日志，跳过记录器配置", nodeID)
+		return false
 	}
+	return accessLog
+}
 
-	return sendGostMessage(nodeID, services, websocket.MessageTypeUpdateService)
+// SetProtocol 下发节点的协议屏蔽开关
+func SetProtocol(nodeID uint, http, tls, socks int) *GostResponse {
+	data := map[string]interface{}{
+		"http":  http,
+		"tls":   tls,
+		"socks": socks,
+	}
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeSetProtocol, "protocol")
 }
 
 // DeleteService 删除服务
-func DeleteService(nodeID uint, name string) *GostResponse {
+func DeleteService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
+		"services": portServiceNames(name, portCount, "_tcp", "_udp"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeDeleteService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteService, "service:"+name)
 }
 
 // PauseService 暂停服务
-func PauseService(nodeID uint, name string) *GostResponse {
+func PauseService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
+		"services": portServiceNames(name, portCount, "_tcp", "_udp"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypePauseService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypePauseService, "service:"+name)
 }
 
 // ResumeService 恢复服务
-func ResumeService(nodeID uint, name string) *GostResponse {
+func ResumeService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tcp", name + "_udp"},
+		"services": portServiceNames(name, portCount, "_tcp", "_udp"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeResumeService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeResumeService, "service:"+name)
 }
 
 // AddRemoteService 添加远程服务 (TLS)
-func AddRemoteService(nodeID uint, name string, outPort int, remoteAddr, protocol, strategy, interfaceName string) *GostResponse {
-	service := createRemoteServiceConfig(name, outPort, remoteAddr, protocol, strategy, interfaceName)
-	return sendGostMessage(nodeID, []map[string]interface{}{service}, websocket.MessageTypeAddService)
+func AddRemoteService(nodeID uint, name string, outPort, portCount int, remoteAddr, protocol, strategy, interfaceName, resolver string) *GostResponse {
+	services := createRemoteServiceConfigs(name, outPort, portCount, remoteAddr, protocol, strategy, interfaceName, resolver)
+	return sendNodeCommand(nodeID, services, websocket.MessageTypeAddService, "remote:"+name)
+}
+
+// AddPausedRemoteService 以暂停状态添加远程服务；节点不支持时添加后立即暂停
+func AddPausedRemoteService(nodeID uint, name string, outPort, portCount int, remoteAddr, protocol, strategy, interfaceName, resolver string) *GostResponse {
+	if !nodeHasFeature(nodeID, websocket.FeatureCreatePaused) {
+		if resp := AddRemoteService(nodeID, name, outPort, portCount, remoteAddr, protocol, strategy, interfaceName, resolver); !resp.Success {
+			return resp
+		}
+		return PauseRemoteService(nodeID, name, portCount)
+	}
+	services := createRemoteServiceConfigs(name, outPort, portCount, remoteAddr, protocol, strategy, interfaceName, resolver)
+	return sendNodeCommand(nodeID, markPaused(services), websocket.MessageTypeAddService, "remote:"+name)
+}
+
+// markPaused 在服务配置中标记暂停状态
+func markPaused(services []map[string]interface{}) []map[string]interface{} {
+	for _, service := range services {
+		metadata, ok := service["metadata"].(map[string]interface{})
+		if !ok {
+			metadata = make(map[string]interface{})
+			service["metadata"] = metadata
+		}
+		metadata["paused"] = true
+	}
+	return services
 }
 
 // UpdateRemoteService 更新远程服务
-func UpdateRemoteService(nodeID uint, name string, outPort int, remoteAddr, protocol, strategy, interfaceName string) *GostResponse {
-	service := createRemoteServiceConfig(name, outPort, remoteAddr, protocol, strategy, interfaceName)
-	return sendGostMessage(nodeID, []map[string]interface{}{service}, websocket.MessageTypeUpdateService)
+func UpdateRemoteService(nodeID uint, name string, outPort, portCount int, remoteAddr, protocol, strategy, interfaceName, resolver string) *GostResponse {
+	services := createRemoteServiceConfigs(name, outPort, portCount, remoteAddr, protocol, strategy, interfaceName, resolver)
+	return sendNodeCommand(nodeID, services, websocket.MessageTypeUpdateService, "remote:"+name)
 }
 
 // DeleteRemoteService 删除远程服务
-func DeleteRemoteService(nodeID uint, name string) *GostResponse {
+func DeleteRemoteService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tls"},
+		"services": portServiceNames(name, portCount, "_tls"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeDeleteService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteService, "remote:"+name)
 }
 
 // PauseRemoteService 暂停远程服务
-func PauseRemoteService(nodeID uint, name string) *GostResponse {
+func PauseRemoteService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tls"},
+		"services": portServiceNames(name, portCount, "_tls"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypePauseService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypePauseService, "remote:"+name)
 }
 
 // ResumeRemoteService 恢复远程服务
-func ResumeRemoteService(nodeID uint, name string) *GostResponse {
+func ResumeRemoteService(nodeID uint, name string, portCount int) *GostResponse {
 	data := map[string]interface{}{
-		"services": []string{name + "_tls"},
+		"services": portServiceNames(name, portCount, "_tls"),
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeResumeService)
+	return sendNodeCommand(nodeID, data, websocket.MessageTypeResumeService, "remote:"+name)
 }
 
-// AddChains 添加链
-func AddChains(nodeID uint, name, remoteAddr, protocol, interfaceName string) *GostResponse {
-	dialer := map[string]interface{}{
-		"type": protocol,
+// AddChains 添加链，端口段转发为段内每个端口各添加一条链，节点支持时以一条命令批量下发
+func AddChains(nodeID uint, name, remoteAddr string, portCount int, protocol, interfaceName string) *GostResponse {
+	if batchChains(nodeID, portCount) {
+		data := createChainConfigs(name, remoteAddr, portCount, protocol, interfaceName)
+		return sendNodeCommand(nodeID, data, websocket.MessageTypeAddChains, "chain:"+name)
 	}
 
-	if protocol == "quic" {
-		dialer["metadata"] = map[string]interface{}{
-			"keepAlive": true,
-			"ttl":       "10s",
+	resp := &GostResponse{Success: true, Message: "OK"}
+	for i := 0; i < max(portCount, 1); i++ {
+		portName := PortServiceName(name, i)
+		data := createChainConfig(portName, OffsetRemoteAddr(remoteAddr, i), protocol, interfaceName)
+		if resp = sendNodeCommand(nodeID, data, websocket.MessageTypeAddChains, "chain:"+portName); !resp.Success {
+			return resp
 		}
 	}
+	return resp
+}
 
-	node := map[string]interface{}{
-		"name": "node-" + name,
-		"addr": remoteAddr,
-		"connector": map[string]interface{}{
-			"type": "relay",
-		},
-		"dialer": dialer,
+// UpdateChains 更新链
+func UpdateChains(nodeID uint, name, remoteAddr string, portCount int, protocol, interfaceName string) *GostResponse {
+	if batchChains(nodeID, portCount) {
+		data := map[string]interface{}{
+			"data": createChainConfigs(name, remoteAddr, portCount, protocol, interfaceName),
+		}
+		return sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateChains, "chain:"+name)
 	}
 
-	if interfaceName != "" {
-		node["interface"] = interfaceName
+	resp := &GostResponse{Success: true, Message: "OK"}
+	for i := 0; i < max(portCount, 1); i++ {
+		portName := PortServiceName(name, i)
+		data := map[string]interface{}{
+			"chain": portName + "_chains",
+			"data":  createChainConfig(portName, OffsetRemoteAddr(remoteAddr, i), protocol, interfaceName),
+		}
+		if resp = sendNodeCommand(nodeID, data, websocket.MessageTypeUpdateChains, "chain:"+portName); !resp.Success {
+			return resp
+		}
 	}
+	return resp
+}
 
-	data := map[string]interface{}{
-		"name": name + "_chains",
-		"hops": []map[string]interface{}{
-			{
-				"name":  "hop-" + name,
-				"nodes": []map[string]interface{}{node},
-			},
-		},
+// DeleteChains 删除链
+func DeleteChains(nodeID uint, name string, portCount int) *GostResponse {
+	if batchChains(nodeID, portCount) {
+		data := map[string]interface{}{
+			"chains": portServiceNames(name, portCount, "_chains"),
+		}
+		return sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteChains, "chain:"+name)
 	}
 
-	return sendGostMessage(nodeID, data, websocket.MessageTypeAddChains)
+	resp := &GostResponse{Success: true, Message: "OK"}
+	for i := 0; i < max(portCount, 1); i++ {
+		portName := PortServiceName(name, i)
+		data := map[string]interface{}{
+			"chain": portName + "_chains",
+		}
+		if r := sendNodeCommand(nodeID, data, websocket.MessageTypeDeleteChains, "chain:"+portName); !r.Success {
+			resp = r
+		}
+	}
+	return resp
 }
 
-// UpdateChains 更新链
-func UpdateChains(nodeID uint, name, remoteAddr, protocol, interfaceName string) *GostResponse {
+// batchChains 端口段转发的链是否以一条命令批量下发
+func batchChains(nodeID uint, portCount int) bool {
+	if portCount <= 1 {
+		return false
+	}
+	return nodeHasFeature(nodeID, websocket.FeatureBatchChains)
+}
+
+// nodeHasFeature 节点是否支持指定能力，离线节点按最近一次握手声明的能力判断
+func nodeHasFeature(nodeID uint, feature string) bool {
+	if caps := websocket.NodeCapabilities(nodeID); caps != nil {
+		return caps.HasFeature(feature)
+	}
+	var node models.Node
+	if err := models.DB.Select("protocol_version", "commands", "features").First(&node, nodeID).Error; err != nil {
+		return false
+	}
+	return websocket.ParseCapabilities(node.ProtocolVersion, node.Commands, node.Features).HasFeature(feature)
+}
+
+// createChainConfigs 创建端口段内全部端口的链配置
+func createChainConfigs(name, remoteAddr string, portCount int, protocol, interfaceName string) []map[string]interface{} {
+	chains := make([]map[string]interface{}, 0, max(portCount, 1))
+	for i := 0; i < max(portCount, 1); i++ {
+		chains = append(chains, createChainConfig(PortServiceName(name, i), OffsetRemoteAddr(remoteAddr, i), protocol, interfaceName))
+	}
+	return chains
+}
+
+// 创建链配置
+func createChainConfig(name, remoteAddr, protocol, interfaceName string) map[string]interface{} {
 	dialer := map[string]interface{}{
 		"type": protocol,
 	}
@@ -185,43 +508,90 @@ func UpdateChains(nodeID uint, name, remoteAddr, protocol, interfaceName string)
 		}
 	}
 
-	node := map[string]interface{}{
-		"name": "node-" + name,
-		"addr": remoteAddr,
-		"connector": map[string]interface{}{
-			"type": "relay",
-		},
-		"dialer": dialer,
+	// 出口为节点组时每个成员作为跳点中的一个节点，按轮询负载均衡，失败的节点暂时剔除
+	addrs := strings.Split(remoteAddr, ",")
+	nodes := make([]map[string]interface{}, 0, len(addrs))
+	for i, addr := range addrs {
+		nodeName := "node-" + name
+		if i > 0 {
+			nodeName = fmt.Sprintf("node-%s-%d", name, i)
+		}
+		node := map[string]interface{}{
+			"name": nodeName,
+			"addr": strings.TrimSpace(addr),
+			"connector": map[string]interface{}{
+				"type": "relay",
+			},
+			"dialer": dialer,
+		}
+
+		if interfaceName != "" {
+			node["interface"] = interfaceName
+		}
+		nodes = append(nodes, node)
 	}
 
-	if interfaceName != "" {
-		node["interface"] = interfaceName
+	hop := map[string]interface{}{
+		"name":  "hop-" + name,
+		"nodes": nodes,
+	}
+	if len(nodes) > 1 {
+		hop["selector"] = map[string]interface{}{
+			"strategy":    "round",
+			"maxFails":    1,
+			"failTimeout": "30s",
+		}
 	}
 
-	chainData := map[string]interface{}{
+	return map[string]interface{}{
 		"name": name + "_chains",
-		"hops": []map[string]interface{}{
-			{
-				"name":  "hop-" + name,
-				"nodes": []map[string]interface{}{node},
-			},
-		},
+		"hops": []map[string]interface{}{hop},
 	}
+}
 
-	data := map[string]interface{}{
-		"chain": name + "_chains",
-		"data":  chainData,
-	}
+// 创建端口段内全部服务配置
+func createServiceConfigs(name string, inPort, portCount int, limiter *int, remoteAddr string,
+	forwardType int, tunnel *models.Tunnel, strategy, interfaceName, resolver string, accessLog bool) []map[string]interface{} {
+
+	portCount = max(portCount, 1)
+	services := make([]map[string]interface{}, 0, 2*portCount)
+	protocols := []string{"tcp", "udp"}
 
-	return sendGostMessage(nodeID, data, websocket.MessageTypeUpdateChains)
+	for i := 0; i < portCount; i++ {
+		for _, protocol := range protocols {
+			service := createServiceConfig(PortServiceName(name, i), inPort+i, limiter, OffsetRemoteAddr(remoteAddr, i),
+				protocol, forwardType, tunnel, strategy, interfaceName)
+			// 连接数/新建连接速率限制器按转发共享 (节点按名称延迟解析, 未下发时不做限制)
+			service["climiter"] = name
+			service["rlimiter"] = name
+			if resolver != "" {
+				service["resolver"] = resolver
+			}
+			// 连接日志由节点内置记录器收集后批量上报
+			if accessLog {
+				service["recorders"] = []map[string]interface{}{
+					{"name": AccessLogRecorderName, "record": "recorder.service.handler"},
+				}
+			}
+			services = append(services, service)
+		}
+	}
+	return services
 }
 
-// DeleteChains 删除链
-func DeleteChains(nodeID uint, name string) *GostResponse {
-	data := map[string]interface{}{
-		"chain": name + "_chains",
+// 创建端口段内全部远程服务配置
+func createRemoteServiceConfigs(name string, outPort, portCount int, remoteAddr, protocol, strategy, interfaceName, resolver string) []map[string]interface{} {
+	portCount = max(portCount, 1)
+	services := make([]map[string]interface{}, 0, portCount)
+	for i := 0; i < portCount; i++ {
+		service := createRemoteServiceConfig(PortServiceName(name, i), outPort+i,
+			OffsetRemoteAddr(remoteAddr, i), protocol, strategy, interfaceName)
+		if resolver != "" {
+			service["resolver"] = resolver
+		}
+		services = append(services, service)
 	}
-	return sendGostMessage(nodeID, data, websocket.MessageTypeDeleteChains)
+	return services
 }
 
 // 创建服务配置
@@ -355,3 +725,44 @@ func sendGostMessage(nodeID uint, data interface{}, msgType string) *GostRespons
 func BuildServiceName(forwardID, userID, userTunnelID interface{}) string {
 	return fmt.Sprintf("%v_%v_%v", forwardID, userID, userTunnelID)
 }
+
+// PortServiceName 构建端口段中第 offset 个端口的服务名称，首个端口与单端口转发同名
+func PortServiceName(name string, offset int) string {
+	if offset == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s_%d", name, offset)
+}
+
+// portServiceNames 生成端口段内全部服务名称
+func portServiceNames(name string, portCount int, suffixes ...string) []string {
+	portCount = max(portCount, 1)
+	names := make([]string, 0, portCount*len(suffixes))
+	for i := 0; i < portCount; i++ {
+		for _, suffix := range suffixes {
+			names = append(names, PortServiceName(name, i)+suffix)
+		}
+	}
+	return names
+}
+
+// OffsetRemoteAddr 将目标地址 (可为逗号分隔的多个地址) 的端口平移 offset
+func OffsetRemoteAddr(remoteAddr string, offset int) string {
+	if offset == 0 {
+		return remoteAddr
+	}
+
+	addrs := strings.Split(remoteAddr, ",")
+	for i, addr := range addrs {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+		if err != nil {
+			continue
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		addrs[i] = net.JoinHostPort(host, strconv.Itoa(p+offset))
+	}
+	return strings.Join(addrs, ",")
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"flux-panel/cluster"
+	"log"
+	"time"
+)
+
+// 后台任务的集群租约：执行任务的实例持有锁并定期续期，实例退出后锁到期释放，
+// 面板启动时只中断租约已失效的任务，不影响其他实例仍在执行的任务
+const (
+	jobLeaseTTL     = 30 * time.Second
+	jobLeaseRefresh = 10 * time.Second
+)
+
+// holdJobLease 取得任务租约并在后台续期，返回的函数停止续期并释放租约
+func holdJobLease(name string) func() {
+	if !cluster.TryLock(name, jobLeaseTTL) {
+		log.Printf("获取任务租约 %s 失败", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(jobLeaseRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// 总线短暂不可用导致租约过期时重新获取
+				if !cluster.ExtendLock(name, jobLeaseTTL) && !cluster.TryLock(name, jobLeaseTTL) {
+					log.Printf("续期任务租约 %s 失败", name)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		cluster.Unlock(name)
+	}
+}
+
+// claimStaleJob 租约没有实例持有，或由本实例重启前持有时取得租约并返回 true，调用方处理完后需释放
+func claimStaleJob(name string) bool {
+	return cluster.TryLock(name, jobLeaseTTL) || cluster.ExtendLock(name, jobLeaseTTL)
+}
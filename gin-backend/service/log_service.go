@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLogTailDuration = 300
+	maxLogTailDuration     = 1800
+	maxLogLevelDuration    = 7200
+)
+
+type LogService struct {
+	nodeRepo *repository.NodeRepository
+}
+
+func NewLogService(db *gorm.DB) *LogService {
+	return &LogService{
+		nodeRepo: repository.NewNodeRepository(db),
+	}
+}
+
+// GetLogs 获取节点内存中最近的日志
+func (s *LogService) GetLogs(query *dto.NodeLogQueryDto) (interface{}, error) {
+	if _, err := s.nodeRepo.FindByID(query.NodeID); err != nil {
+		return nil, errors.New("节点不存在")
+	}
+
+	resp, err := websocket.GetServer().SendMessage(query.NodeID, logQueryRequest(query), websocket.MessageTypeTailLogs)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errors.New(resp.Message)
+	}
+	return resp.Data, nil
+}
+
+// StartTail 开始实时查看节点日志，返回会话和节点内存中的历史日志
+// 调用方结束查看时需调用 StopTail
+func (s *LogService) StartTail(query *dto.NodeLogQueryDto) (*websocket.LogTail, interface{}, error) {
+	if _, err := s.nodeRepo.FindByID(query.NodeID); err != nil {
+		return nil, nil, errors.New("节点不存在")
+	}
+
+	duration := query.Duration
+	if duration <= 0 {
+		duration = defaultLogTailDuration
+	}
+	if duration > maxLogTailDuration {
+		duration = maxLogTailDuration
+	}
+
+	tail := websocket.NewLogTail(query.NodeID)
+	req := logQueryRequest(query)
+	req["tailId"] = tail.ID
+	req["follow"] = true
+	req["duration"] = duration
+
+	resp, err := websocket.GetServer().SendMessage(query.NodeID, req, websocket.MessageTypeTailLogs)
+	if err != nil {
+		tail.Close()
+		return nil, nil, err
+	}
+	if !resp.Success {
+		tail.Close()
+		return nil, nil, errors.New(resp.Message)
+	}
+	return tail, resp.Data, nil
+}
+
+// StopTail 结束实时查看并通知节点停止推送
+func (s *LogService) StopTail(tail *websocket.LogTail) {
+	select {
+	case <-tail.Done():
+		// 节点已结束推送或已断开
+	default:
+		tail.Close()
+		sendGostMessage(tail.NodeID, map[string]interface{}{"tailId": tail.ID}, websocket.MessageTypeStopTailLogs)
+	}
+}
+
+// SetLogLevel 临时调整节点上单个服务的日志级别，到期后节点自动恢复
+func (s *LogService) SetLogLevel(levelDto *dto.NodeLogLevelDto) (interface{}, error) {
+	if _, err := s.nodeRepo.FindByID(levelDto.NodeID); err != nil {
+		return nil, errors.New("节点不存在")
+	}
+
+	service := strings.TrimSpace(levelDto.Service)
+	if service == "" {
+		return nil, errors.New("服务名不能为空")
+	}
+	level := levelDto.Level
+	if level == "" {
+		level = "debug"
+	}
+	duration := levelDto.Duration
+	if duration < 0 {
+		return nil, errors.New("调整时长无效")
+	}
+	if duration > maxLogLevelDuration {
+		return nil, errors.New("日志级别调整时长不能超过 2 小时")
+	}
+
+	resp, err := websocket.GetServer().SendMessage(levelDto.NodeID, map[string]interface{}{
+		"service":  service,
+		"level":    level,
+		"duration": duration,
+	}, websocket.MessageTypeSetServiceLogLevel)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errors.New(resp.Message)
+	}
+	return resp.Data, nil
+}
+
+// logQueryRequest 构造节点日志查询参数
+func logQueryRequest(query *dto.NodeLogQueryDto) map[string]interface{} {
+	req := map[string]interface{}{
+		"level":   query.Level,
+		"service": strings.TrimSpace(query.Service),
+	}
+	if query.Lines > 0 {
+		req["lines"] = query.Lines
+	}
+	return req
+}
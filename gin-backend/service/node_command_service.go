@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"flux-panel/cluster"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// nodeCommandRetention 已结束的命令保留时长
+	nodeCommandRetention = 7 * 24 * time.Hour
+	// replayLockTTL 重放锁有效期，每下发一条命令续期一次，需大于单条命令的下发超时
+	replayLockTTL = 2 * time.Minute
+)
+
+// 命令对配置的操作，由消息类型的前缀决定
+const (
+	commandOpAdd    = "Add"
+	commandOpUpdate = "Update"
+	commandOpDelete = "Delete"
+	commandOpPause  = "Pause"
+	commandOpResume = "Resume"
+	commandOpSet    = "Set"
+)
+
+// NodeCommandService 节点配置命令队列
+// 节点在线且没有排队的命令时直接下发；节点离线或已有排队的命令时写入队列，
+// 节点重新上报配置后按入队顺序重放，保证同一节点上的操作顺序不变
+type NodeCommandService struct {
+	repo *repository.NodeCommandRepository
+}
+
+func NewNodeCommandService(db *gorm.DB) *NodeCommandService {
+	return &NodeCommandService{
+		repo: repository.NewNodeCommandRepository(db),
+	}
+}
+
+// Send 下发配置命令，resource 标识命令作用的配置，用于合并排队中被取代的命令
+func (s *NodeCommandService) Send(nodeID uint, data interface{}, msgType, resource string) *GostResponse {
+	if websocket.IsNodeConnected(nodeID) && !s.repo.HasPending(nodeID) {
+		resp := sendGostMessage(nodeID, data, msgType)
+		if resp.Success || websocket.IsNodeConnected(nodeID) {
+			return resp
+		}
+		// 下发过程中节点断开，转入队列等待重放
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return &GostResponse{Success: false, Message: err.Error()}
+	}
+	command, err := s.enqueue(nodeID, msgType, resource, string(payload))
+	if err != nil {
+		return &GostResponse{Success: false, Message: fmt.Sprintf("命令入队失败: %v", err)}
+	}
+
+	// 节点可能在入队期间上线，此时由本次调用触发重放
+	if websocket.IsNodeConnected(nodeID) {
+		go s.Replay(nodeID)
+	}
+
+	message := "节点离线，命令已加入队列，将在节点上线后下发"
+	if command.State == models.NodeCommandSuperseded {
+		message = command.Message
+	}
+	return &GostResponse{
+		Success: true,
+		Message: message,
+		Data:    map[string]interface{}{"commandId": command.ID, "state": command.State},
+	}
+}
+
+// enqueue 写入队列，并将同一配置上被本命令取代的排队命令标记为已被取代
+func (s *NodeCommandService) enqueue(nodeID uint, msgType, resource, payload string) (*models.NodeCommand, error) {
+	command := &models.NodeCommand{
+		NodeID:    nodeID,
+		ForwardID: resourceForwardID(resource),
+		Type:      msgType,
+		Resource:  resource,
+		Payload:   payload,
+		State:     models.NodeCommandPending,
+	}
+
+	pending, err := s.repo.FindPendingByResource(nodeID, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	op := commandOp(msgType)
+	var superseded []uint
+	for _, prev := range pending {
+		prevOp := commandOp(prev.Type)
+		switch op {
+		case commandOpPause, commandOpResume:
+			if prevOp == commandOpPause || prevOp == commandOpResume {
+				superseded = append(superseded, prev.ID)
+			}
+		case commandOpUpdate, commandOpSet:
+			if prevOp == op {
+				superseded = append(superseded, prev.ID)
+			}
+		case commandOpDelete:
+			if prevOp == commandOpDelete {
+				continue
+			}
+			superseded = append(superseded, prev.ID)
+			// 配置尚未创建到节点上，删除命令也无需下发
+			if prevOp == commandOpAdd {
+				command.State = models.NodeCommandSuperseded
+				command.Message = "排队中的创建命令已取消，无需删除"
+			}
+		}
+	}
+
+	if err := s.repo.Supersede(superseded, fmt.Sprintf("已被后续的 %s 命令取代", msgType)); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(command); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// Replay 按入队顺序向节点下发排队的命令，多实例部署时同一节点同时只由一个实例重放
+func (s *NodeCommandService) Replay(nodeID uint) {
+	lock := fmt.Sprintf("node_command:%d", nodeID)
+	for websocket.IsNodeConnected(nodeID) && s.repo.HasPending(nodeID) {
+		// 其他实例正在重放，会在释放锁前处理完新入队的命令
+		if !cluster.TryLock(lock, replayLockTTL) {
+			return
+		}
+		done := s.drain(nodeID, lock)
+		cluster.Unlock(lock)
+		if !done {
+			return
+		}
+	}
+}
+
+// drain 逐条下发排队的命令直到队列为空，节点再次离线或失去重放锁时返回 false
+func (s *NodeCommandService) drain(nodeID uint, lock string) bool {
+	for {
+		command, err := s.repo.NextPending(nodeID)
+		if err != nil {
+			return true
+		}
+
+		resp := s.apply(command)
+		if !resp.Success && !websocket.IsNodeConnected(nodeID) {
+			return false
+		}
+
+		state := models.NodeCommandApplied
+		if !resp.Success {
+			state = models.NodeCommandFailed
+			log.Printf("节点 %d 重放命令 %d (%s %s) 失败: %s", nodeID, command.ID, command.Type, command.Resource, resp.Message)
+		}
+		if _, err := s.repo.UpdateState(command.ID, models.NodeCommandPending, state, resp.Message); err != nil {
+			log.Printf("更新节点命令 %d 状态失败: %v", command.ID, err)
+			return false
+		}
+		if !cluster.ExtendLock(lock, replayLockTTL) {
+			return false
+		}
+	}
+}
+
+// apply 下发单条排队的命令，更新命令失败时按新增重试，与在线下发时的处理一致；
+// 删除的配置在节点上已不存在时视为已生效
+func (s *NodeCommandService) apply(command *models.NodeCommand) *GostResponse {
+	payload := json.RawMessage(command.Payload)
+	resp := sendGostMessage(command.NodeID, payload, command.Type)
+	if resp.Success || !websocket.IsNodeConnected(command.NodeID) {
+		return resp
+	}
+	op := commandOp(command.Type)
+	if op == commandOpDelete && strings.HasSuffix(resp.Message, "not found") {
+		return &GostResponse{Success: true, Message: "节点上已不存在该配置"}
+	}
+	if op != commandOpUpdate {
+		return resp
+	}
+
+	// 更新命令的内容为 {"<名称>": ..., "data": 新配置}，服务的更新命令直接为新配置列表
+	var wrapped struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(payload, &wrapped) == nil && len(wrapped.Data) > 0 {
+		payload = wrapped.Data
+	}
+	addType := commandOpAdd + strings.TrimPrefix(command.Type, commandOpUpdate)
+	if addResp := sendGostMessage(command.NodeID, payload, addType); addResp.Success {
+		return addResp
+	}
+	return resp
+}
+
+// GetCommands 查询节点的命令队列
+func (s *NodeCommandService) GetCommands(query *dto.NodeCommandQueryDto) ([]models.NodeCommand, error) {
+	limit := query.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.Find(query.NodeID, 0, query.State, limit)
+}
+
+// CleanExpired 清理超过保留时长的已结束命令
+func (s *NodeCommandService) CleanExpired() {
+	cutoff := time.Now().Add(-nodeCommandRetention).UnixMilli()
+	if n, err := s.repo.DeleteFinishedBefore(cutoff); err != nil {
+		log.Printf("Failed to delete finished node commands: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d finished node commands", n)
+	}
+}
+
+// commandOp 返回消息类型对应的配置操作
+func commandOp(msgType string) string {
+	for _, op := range []string{commandOpAdd, commandOpUpdate, commandOpDelete, commandOpPause, commandOpResume, commandOpSet} {
+		if strings.HasPrefix(msgType, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// resourceForwardID 从以转发服务名命名的配置中解析转发 ID，与转发无关的配置返回 0
+func resourceForwardID(resource string) uint {
+	name := resource[strings.Index(resource, ":")+1:]
+	parts := strings.Split(name, "_")
+	if len(parts) < 3 {
+		return 0
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// sendNodeCommand 下发修改节点配置的命令，节点离线时加入队列
+func sendNodeCommand(nodeID uint, data interface{}, msgType, resource string) *GostResponse {
+	return NewNodeCommandService(models.DB).Send(nodeID, data, msgType, resource)
+}
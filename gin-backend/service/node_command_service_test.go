@@ -0,0 +1,115 @@
+package service
+
+import (
+	"flux-panel/models"
+	"flux-panel/websocket"
+	"testing"
+)
+
+// TestEnqueueSupersede 节点离线时同一配置上排队的命令按操作类型合并
+func TestEnqueueSupersede(t *testing.T) {
+	type step struct {
+		msgType  string
+		resource string
+	}
+	const (
+		pending    = models.NodeCommandPending
+		superseded = models.NodeCommandSuperseded
+	)
+
+	cases := []struct {
+		name  string
+		steps []step
+		want  []int // 各命令最终的状态，与 steps 一一对应
+	}{
+		{
+			name:  "删除取消排队中的创建，删除也无需下发",
+			steps: []step{{websocket.MessageTypeAddService, "service:a"}, {websocket.MessageTypeDeleteService, "service:a"}},
+			want:  []int{superseded, superseded},
+		},
+		{
+			name:  "删除取代排队中的更新",
+			steps: []step{{websocket.MessageTypeUpdateService, "service:a"}, {websocket.MessageTypeDeleteService, "service:a"}},
+			want:  []int{superseded, pending},
+		},
+		{
+			name:  "重复的删除保留",
+			steps: []step{{websocket.MessageTypeDeleteService, "service:a"}, {websocket.MessageTypeDeleteService, "service:a"}},
+			want:  []int{pending, pending},
+		},
+		{
+			name: "暂停与恢复只保留最后一次",
+			steps: []step{
+				{websocket.MessageTypePauseService, "service:a"},
+				{websocket.MessageTypeResumeService, "service:a"},
+				{websocket.MessageTypePauseService, "service:a"},
+			},
+			want: []int{superseded, superseded, pending},
+		},
+		{
+			name:  "更新取代前一次更新",
+			steps: []step{{websocket.MessageTypeUpdateService, "service:a"}, {websocket.MessageTypeUpdateService, "service:a"}},
+			want:  []int{superseded, pending},
+		},
+		{
+			name:  "设置取代前一次设置",
+			steps: []step{{websocket.MessageTypeSetProtocol, "protocol"}, {websocket.MessageTypeSetProtocol, "protocol"}},
+			want:  []int{superseded, pending},
+		},
+		{
+			name:  "更新不取代创建",
+			steps: []step{{websocket.MessageTypeAddService, "service:a"}, {websocket.MessageTypeUpdateService, "service:a"}},
+			want:  []int{pending, pending},
+		},
+		{
+			name:  "暂停不取代更新",
+			steps: []step{{websocket.MessageTypeUpdateService, "service:a"}, {websocket.MessageTypePauseService, "service:a"}},
+			want:  []int{pending, pending},
+		},
+		{
+			name:  "不同配置互不影响",
+			steps: []step{{websocket.MessageTypeAddService, "service:a"}, {websocket.MessageTypeDeleteService, "service:b"}},
+			want:  []int{pending, pending},
+		},
+		{
+			name: "删除后重新创建",
+			steps: []step{
+				{websocket.MessageTypeUpdateService, "service:a"},
+				{websocket.MessageTypeDeleteService, "service:a"},
+				{websocket.MessageTypeAddService, "service:a"},
+			},
+			want: []int{superseded, pending, pending},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := NewNodeCommandService(db)
+
+			node := &models.Node{Name: "node", Secret: "node-secret", ServerIP: "127.0.0.1", PortSta: 20000, PortEnd: 20100}
+			if err := db.Create(node).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]uint, len(tc.steps))
+			for i, st := range tc.steps {
+				resp := s.Send(node.ID, map[string]interface{}{"step": i}, st.msgType, st.resource)
+				if !resp.Success {
+					t.Fatalf("第 %d 条命令入队失败: %s", i+1, resp.Message)
+				}
+				ids[i] = resp.Data.(map[string]interface{})["commandId"].(uint)
+			}
+
+			for i, id := range ids {
+				var command models.NodeCommand
+				if err := db.First(&command, id).Error; err != nil {
+					t.Fatal(err)
+				}
+				if command.State != tc.want[i] {
+					t.Errorf("第 %d 条命令 %s 状态为 %d，应为 %d", i+1, command.Type, command.State, tc.want[i])
+				}
+			}
+		})
+	}
+}
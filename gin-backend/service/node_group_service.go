@@ -0,0 +1,391 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// NodeGroupService 节点组管理
+// 成员变更后重新部署使用该节点组的隧道下的全部转发：从移出的节点上删除配置，在新加入的节点上创建配置，
+// 出口组变更时同时更新入口节点上链的出口地址
+type NodeGroupService struct {
+	repo              *repository.NodeGroupRepository
+	nodeRepo          *repository.NodeRepository
+	tunnelRepo        *repository.TunnelRepository
+	forwardRepo       *repository.ForwardRepository
+	forwardService    *ForwardService
+	speedLimitService *SpeedLimitService
+	dnsService        *DNSService
+	audit             *AuditService
+}
+
+func NewNodeGroupService(db *gorm.DB) *NodeGroupService {
+	return &NodeGroupService{
+		repo:              repository.NewNodeGroupRepository(db),
+		nodeRepo:          repository.NewNodeRepository(db),
+		tunnelRepo:        repository.NewTunnelRepository(db),
+		forwardRepo:       repository.NewForwardRepository(db),
+		forwardService:    NewForwardService(db),
+		speedLimitService: NewSpeedLimitService(db),
+		dnsService:        NewDNSService(db),
+		audit:             NewAuditService(db),
+	}
+}
+
+// CreateGroup 创建节点组
+func (s *NodeGroupService) CreateGroup(actor Actor, groupDto *dto.NodeGroupDto) (err error) {
+	entry := &AuditEntry{Action: "node_group.create", TargetType: "node_group", TargetName: groupDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	name := strings.TrimSpace(groupDto.Name)
+	if name == "" {
+		return errors.New("节点组名称不能为空")
+	}
+	if _, err := s.repo.FindByName(name); err == nil {
+		return errors.New("节点组名称已存在")
+	}
+
+	nodeIDs := uniqueIDs(groupDto.NodeIDs)
+	if _, err := s.findNodes(nodeIDs); err != nil {
+		return err
+	}
+
+	group := &models.NodeGroup{
+		Name: name,
+		Tags: joinTags(groupDto.Tags),
+	}
+	if err := s.repo.Create(group); err != nil {
+		return err
+	}
+	group.NodeIDs = nodeIDs
+	entry.TargetID = group.ID
+	entry.After = group
+	return s.repo.AddMembers(group.ID, nodeIDs)
+}
+
+// GetAllGroups 获取所有节点组及其成员
+func (s *NodeGroupService) GetAllGroups() ([]models.NodeGroup, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repo.FindMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeIDs := make(map[uint][]uint)
+	for _, m := range members {
+		nodeIDs[m.GroupID] = append(nodeIDs[m.GroupID], m.NodeID)
+	}
+	for i := range groups {
+		groups[i].TagList = splitTags(groups[i].Tags)
+		groups[i].NodeIDs = nodeIDs[groups[i].ID]
+		if groups[i].NodeIDs == nil {
+			groups[i].NodeIDs = []uint{}
+		}
+	}
+	return groups, nil
+}
+
+// UpdateGroup 更新节点组，成员变更时重新部署受影响的转发
+func (s *NodeGroupService) UpdateGroup(actor Actor, updateDto *dto.NodeGroupUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "node_group.update", TargetType: "node_group", TargetID: updateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	group, err := s.repo.FindByID(updateDto.ID)
+	if err != nil {
+		return errors.New("节点组不存在")
+	}
+	entry.TargetName = group.Name
+	before := *group
+	entry.Before = &before
+
+	if updateDto.Name != nil {
+		name := strings.TrimSpace(*updateDto.Name)
+		if name == "" {
+			return errors.New("节点组名称不能为空")
+		}
+		if existing, err := s.repo.FindByName(name); err == nil && existing.ID != group.ID {
+			return errors.New("节点组名称已存在")
+		}
+		group.Name = name
+	}
+	if updateDto.Tags != nil {
+		group.Tags = joinTags(updateDto.Tags)
+	}
+	if err := s.repo.Update(group); err != nil {
+		return err
+	}
+	entry.After = group
+
+	if updateDto.NodeIDs == nil {
+		return nil
+	}
+
+	current, err := s.repo.MemberIDs(group.ID)
+	if err != nil {
+		return err
+	}
+	next := uniqueIDs(*updateDto.NodeIDs)
+	before.NodeIDs = current
+	group.NodeIDs = next
+	return s.changeMembers(group, subtractIDs(next, current), subtractIDs(current, next))
+}
+
+// DeleteGroup 删除节点组，被隧道使用的节点组不能删除
+func (s *NodeGroupService) DeleteGroup(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "node_group.delete", TargetType: "node_group", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("节点组不存在")
+	}
+	entry.TargetName = group.Name
+	entry.Before = group
+	tunnels, err := s.tunnelRepo.FindByGroupID(id)
+	if err != nil {
+		return err
+	}
+	if len(tunnels) > 0 {
+		return fmt.Errorf("节点组正在被隧道 %s 使用，无法删除", tunnels[0].Name)
+	}
+	return s.repo.Delete(id)
+}
+
+// RemoveNode 将节点移出其所属的全部节点组，用于删除节点前
+func (s *NodeGroupService) RemoveNode(nodeID uint) error {
+	groupIDs, err := s.repo.GroupIDsOfNode(nodeID)
+	if err != nil {
+		return err
+	}
+	for _, groupID := range groupIDs {
+		group, err := s.repo.FindByID(groupID)
+		if err != nil {
+			continue
+		}
+		if err := s.changeMembers(group, nil, []uint{nodeID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeMembers 变更节点组成员并重新部署使用该组的隧道下的转发
+func (s *NodeGroupService) changeMembers(group *models.NodeGroup, added, removed []uint) error {
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	addedNodes, err := s.findNodes(added)
+	if err != nil {
+		return err
+	}
+	// 待移出的节点可能已被删除，只清理仍存在的节点上的配置
+	var removedNodes []models.Node
+	for _, id := range removed {
+		if node, err := s.nodeRepo.FindByID(id); err == nil {
+			removedNodes = append(removedNodes, *node)
+		}
+	}
+
+	tunnels, err := s.tunnelRepo.FindByGroupID(group.ID)
+	if err != nil {
+		return err
+	}
+	current, err := s.repo.MemberIDs(group.ID)
+	if err != nil {
+		return err
+	}
+	if len(tunnels) > 0 && len(subtractIDs(current, removed))+len(added) == 0 {
+		return fmt.Errorf("节点组 %s 正在被隧道使用，至少需要保留一个节点", group.Name)
+	}
+
+	// 校验新成员的端口及被移出的节点是否被转发指定为入口
+	forwards := make(map[uint][]models.Forward, len(tunnels))
+	for _, tunnel := range tunnels {
+		list, err := s.forwardRepo.FindByTunnelID(tunnel.ID)
+		if err != nil {
+			return err
+		}
+		forwards[tunnel.ID] = list
+		for i := range list {
+			forward := &list[i]
+			if tunnel.InGroupID == group.ID {
+				if forward.InNodeID > 0 {
+					if containsID(removed, forward.InNodeID) {
+						return fmt.Errorf("节点 %d 是转发 %s 指定的入口节点，无法移出节点组", forward.InNodeID, forward.Name)
+					}
+				} else {
+					for j := range addedNodes {
+						if err := s.forwardService.checkPortsAvailable(forward, &addedNodes[j], forward.InPort); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if tunnel.OutGroupID == group.ID && tunnel.Type == 2 {
+				for j := range addedNodes {
+					if err := s.forwardService.checkPortsAvailable(forward, &addedNodes[j], forward.OutPort); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if err := s.repo.RemoveMembers(group.ID, removed); err != nil {
+		return err
+	}
+	if err := s.repo.AddMembers(group.ID, added); err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range tunnels {
+		if err := s.redeployTunnel(&tunnels[i], group.ID, forwards[tunnels[i].ID], addedNodes, removedNodes); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// redeployTunnel 节点组成员变更后重新部署隧道下的转发
+func (s *NodeGroupService) redeployTunnel(tunnel *models.Tunnel, groupID uint, forwards []models.Forward, addedNodes, removedNodes []models.Node) error {
+	var removedIn, removedOut []models.Node
+	if tunnel.InGroupID == groupID {
+		removedIn = removedNodes
+	}
+	if tunnel.OutGroupID == groupID && tunnel.Type == 2 {
+		removedOut = removedNodes
+	}
+
+	// 刷新隧道记录中的入口、出口IP
+	inNodes, outNodes, err := s.forwardService.locator.tunnelNodes(tunnel)
+	if err != nil {
+		return err
+	}
+	tunnel.InIP = joinServerIPs(inNodes)
+	if tunnel.Type == 2 {
+		tunnel.OutIP = joinServerIPs(outNodes)
+	}
+	if err := s.tunnelRepo.Update(tunnel); err != nil {
+		return err
+	}
+
+	if tunnel.InGroupID == groupID {
+		s.speedLimitService.DeleteTunnelLimiters(tunnel.ID, removedIn)
+		s.speedLimitService.SyncTunnelLimiters(tunnel.ID, addedNodes)
+		if err := s.dnsService.SyncTunnel(tunnel); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	// 只在新成员上部署，其余成员上的服务保持原状；出口成员变化时入口链的地址需更新
+	outChanged := tunnel.OutGroupID == groupID && tunnel.Type == 2
+	var addedIn, addedOut []models.Node
+	if tunnel.InGroupID == groupID {
+		addedIn = addedNodes
+	}
+	if outChanged {
+		addedOut = addedNodes
+	}
+
+	var lastErr error
+	for i := range forwards {
+		forward := &forwards[i]
+		stale := &placement{out: removedOut}
+		added := &placement{out: addedOut}
+		if forward.InNodeID == 0 {
+			stale.in = removedIn
+			added.in = addedIn
+		}
+		if len(stale.in) > 0 || len(stale.out) > 0 {
+			s.forwardService.removeGostServices(forward, tunnel, stale)
+		}
+
+		if err := s.forwardService.extendGostServices(forward, tunnel, added, outChanged); err != nil {
+			log.Printf("节点组变更后重新部署转发 %d 失败: %v", forward.ID, err)
+			lastErr = fmt.Errorf("转发 %s 重新部署失败: %v", forward.Name, err)
+		}
+	}
+	return lastErr
+}
+
+// findNodes 按ID查询节点，任一节点不存在时返回错误
+func (s *NodeGroupService) findNodes(ids []uint) ([]models.Node, error) {
+	nodes := make([]models.Node, 0, len(ids))
+	for _, id := range ids {
+		node, err := s.nodeRepo.FindByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("节点 %d 不存在", id)
+		}
+		nodes = append(nodes, *node)
+	}
+	return nodes, nil
+}
+
+// joinTags 去除空白与重复的标签后以逗号连接
+func joinTags(tags []string) string {
+	seen := make(map[string]bool, len(tags))
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return strings.Join(result, ",")
+}
+
+// splitTags 将逗号分隔的标签拆分为列表
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+// uniqueIDs 去除重复与为 0 的ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// subtractIDs 返回在 a 中但不在 b 中的ID
+func subtractIDs(a, b []uint) []uint {
+	var result []uint
+	for _, id := range a {
+		if !containsID(b, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
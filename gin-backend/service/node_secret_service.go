@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/utils"
+	"flux-panel/websocket"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 密钥轮换宽限期(秒)
+const (
+	defaultSecretGrace = 3600
+	minSecretGrace     = 60
+	maxSecretGrace     = 7 * 86400
+)
+
+// NodeSecretService 节点密钥轮换
+// 新密钥通过已认证的连接下发，节点写入 config.json 后使用新密钥重新连接；
+// 宽限期内新旧密钥均可用于认证，宽限期结束后旧密钥失效，节点始终未确认的新密钥被撤销并恢复旧密钥
+type NodeSecretService struct {
+	repo     *repository.NodeSecretRotationRepository
+	nodeRepo *repository.NodeRepository
+	audit    *AuditService
+}
+
+func NewNodeSecretService(db *gorm.DB) *NodeSecretService {
+	return &NodeSecretService{
+		repo:     repository.NewNodeSecretRotationRepository(db),
+		nodeRepo: repository.NewNodeRepository(db),
+		audit:    NewAuditService(db),
+	}
+}
+
+// newNodeSecret 生成节点密钥
+func newNodeSecret() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// RotateSecret 为在线节点生成并下发新密钥
+func (s *NodeSecretService) RotateSecret(actor Actor, rotateDto *dto.NodeSecretRotateDto) (_ *models.NodeSecretRotation, err error) {
+	entry := &AuditEntry{Action: "node.secret_rotate", TargetType: "node", TargetID: rotateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	node, err := s.nodeRepo.FindByID(rotateDto.ID)
+	if err != nil {
+		return nil, errors.New("节点不存在")
+	}
+	entry.TargetName = node.Name
+	if node.Secret == "" {
+		return nil, errors.New("节点尚未生成密钥")
+	}
+	if !websocket.IsNodeConnected(node.ID) {
+		return nil, errors.New("节点不在线，无法轮换密钥")
+	}
+	if !websocket.ParseCapabilities(node.ProtocolVersion, node.Commands, node.Features).Supports(websocket.MessageTypeRotateSecret) {
+		return nil, errors.New("节点版本过低，不支持密钥轮换，请升级节点")
+	}
+	if latest, err := s.repo.FindLatest(node.ID); err == nil && latest.State == models.NodeSecretRotationPending {
+		return nil, errors.New("上一次密钥轮换尚未确认，请稍后再试")
+	}
+
+	grace := rotateDto.Grace
+	if grace == 0 {
+		grace = defaultSecretGrace
+	}
+	if grace < minSecretGrace || grace > maxSecretGrace {
+		return nil, fmt.Errorf("宽限期应为%d-%d秒", minSecretGrace, maxSecretGrace)
+	}
+
+	oldSecret := node.Secret
+	newSecret := newNodeSecret()
+	rotation := &models.NodeSecretRotation{
+		NodeID:     node.ID,
+		OperatorID: actor.UserID,
+		State:      models.NodeSecretRotationPending,
+		GraceUntil: time.Now().Add(time.Duration(grace) * time.Second).UnixMilli(),
+	}
+	if err := s.repo.Create(rotation); err != nil {
+		return nil, err
+	}
+
+	// 先保存新密钥再下发，节点写入新密钥后立即重连时即可通过认证
+	if err := s.nodeRepo.UpdateSecret(node.ID, newSecret, oldSecret, rotation.GraceUntil); err != nil {
+		s.repo.UpdateState(rotation.ID, models.NodeSecretRotationFailed, err.Error())
+		return nil, err
+	}
+
+	resp, sendErr := websocket.GetServer().SendMessage(node.ID, map[string]interface{}{"secret": newSecret}, websocket.MessageTypeRotateSecret)
+	switch {
+	case sendErr == nil && resp.Success:
+		// 节点已写入新密钥，使用新密钥重连后旧密钥失效
+		rotation.State = models.NodeSecretRotationApplied
+	case sendErr == nil:
+		// 节点明确拒绝时仍持有旧密钥，直接恢复
+		if err := s.nodeRepo.UpdateSecret(node.ID, oldSecret, node.PrevSecret, node.PrevSecretExpire); err != nil {
+			return nil, err
+		}
+		utils.ClearCryptoCache(newSecret)
+		rotation.State = models.NodeSecretRotationFailed
+		rotation.Message = "节点拒绝新密钥: " + resp.Message
+	default:
+		// 未收到响应时无法判断节点是否已写入新密钥，宽限期内新旧密钥均可用，节点使用新密钥连接后确认
+		rotation.Message = "未收到节点响应，等待节点使用新密钥连接: " + sendErr.Error()
+	}
+	if err := s.repo.UpdateState(rotation.ID, rotation.State, rotation.Message); err != nil {
+		return nil, err
+	}
+	log.Printf("管理员 %d 轮换节点 %d 的密钥，状态: %d %s", actor.UserID, node.ID, rotation.State, rotation.Message)
+	entry.Message = rotation.Message
+
+	if rotation.State == models.NodeSecretRotationFailed {
+		return rotation, errors.New(rotation.Message)
+	}
+	return rotation, nil
+}
+
+// GetRotations 获取密钥轮换记录，nodeID 为 0 时返回全部节点的记录
+func (s *NodeSecretService) GetRotations(nodeID uint) ([]models.NodeSecretRotation, error) {
+	return s.repo.FindByNode(nodeID, 100)
+}
+
+// OnAuthenticated 节点使用当前密钥连接后确认轮换，旧密钥立即失效；
+// 节点已应答写入新密钥的轮换在下发时即为已应用，重连后同样使旧密钥失效
+func (s *NodeSecretService) OnAuthenticated(nodeID uint, secret string) {
+	node, err := s.nodeRepo.FindByID(nodeID)
+	if err != nil || node.PrevSecret == "" || node.Secret != secret {
+		return
+	}
+	latest, err := s.repo.FindLatest(nodeID)
+	if err != nil {
+		return
+	}
+	switch latest.State {
+	case models.NodeSecretRotationPending:
+		if err := s.repo.UpdateState(latest.ID, models.NodeSecretRotationApplied, "节点已使用新密钥连接"); err != nil {
+			log.Printf("确认节点 %d 的密钥轮换失败: %v", nodeID, err)
+			return
+		}
+	case models.NodeSecretRotationApplied:
+	default:
+		return
+	}
+
+	if err := s.nodeRepo.UpdateSecret(node.ID, node.Secret, "", 0); err != nil {
+		log.Printf("清除节点 %d 的旧密钥失败: %v", node.ID, err)
+		return
+	}
+	utils.ClearCryptoCache(node.PrevSecret)
+	s.audit.Record(SystemActor, &AuditEntry{
+		Action:     "node.secret_expire",
+		TargetType: "node",
+		TargetID:   node.ID,
+		TargetName: node.Name,
+		Message:    "节点已使用新密钥连接，旧密钥已失效",
+	}, nil)
+}
+
+// ExpireSecrets 宽限期结束后使旧密钥失效，节点始终未确认的新密钥被撤销并恢复旧密钥
+func (s *NodeSecretService) ExpireSecrets() {
+	nodes, err := s.nodeRepo.FindExpiredPrevSecrets(time.Now().UnixMilli())
+	if err != nil {
+		log.Printf("查询密钥轮换宽限期已结束的节点失败: %v", err)
+		return
+	}
+
+	for _, node := range nodes {
+		latest, err := s.repo.FindLatest(node.ID)
+		if err == nil && latest.State == models.NodeSecretRotationPending {
+			if err := s.nodeRepo.UpdateSecret(node.ID, node.PrevSecret, "", 0); err != nil {
+				log.Printf("恢复节点 %d 的密钥失败: %v", node.ID, err)
+				continue
+			}
+			utils.ClearCryptoCache(node.Secret)
+			s.repo.UpdateState(latest.ID, models.NodeSecretRotationFailed, "宽限期内节点未使用新密钥连接，已恢复旧密钥")
+			s.audit.Record(SystemActor, &AuditEntry{
+				Action:     "node.secret_revert",
+				TargetType: "node",
+				TargetID:   node.ID,
+				TargetName: node.Name,
+				Message:    "宽限期内节点未使用新密钥连接，已恢复旧密钥",
+			}, nil)
+			log.Printf("节点 %d 未确认新密钥，已恢复旧密钥", node.ID)
+			continue
+		}
+
+		if err := s.nodeRepo.UpdateSecret(node.ID, node.Secret, "", 0); err != nil {
+			log.Printf("清除节点 %d 的旧密钥失败: %v", node.ID, err)
+			continue
+		}
+		utils.ClearCryptoCache(node.PrevSecret)
+		s.audit.Record(SystemActor, &AuditEntry{
+			Action:     "node.secret_expire",
+			TargetType: "node",
+			TargetID:   node.ID,
+			TargetName: node.Name,
+			Message:    "密钥轮换宽限期结束，旧密钥已失效",
+		}, nil)
+		log.Printf("节点 %d 密钥轮换宽限期结束，旧密钥已失效", node.ID)
+	}
+}
+
+// DeleteRotations 删除节点的密钥轮换记录，用于删除节点时
+func (s *NodeSecretService) DeleteRotations(nodeID uint) error {
+	return s.repo.DeleteByNode(nodeID)
+}
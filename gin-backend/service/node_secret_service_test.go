@@ -0,0 +1,57 @@
+package service
+
+import (
+	"flux-panel/models"
+	"testing"
+	"time"
+)
+
+// TestOnAuthenticatedExpiresPrevSecret 节点使用新密钥重连后旧密钥立即失效，
+// 包括节点已应答、轮换在下发时即为已应用的情况
+func TestOnAuthenticatedExpiresPrevSecret(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		state int
+	}{
+		{"节点应答后重连", models.NodeSecretRotationApplied},
+		{"未收到应答，节点使用新密钥连接", models.NodeSecretRotationPending},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := NewNodeSecretService(db)
+
+			grace := time.Now().Add(time.Hour).UnixMilli()
+			node := &models.Node{Name: "node", Secret: "new-secret", PrevSecret: "old-secret", PrevSecretExpire: grace, ServerIP: "127.0.0.1", PortSta: 20000, PortEnd: 20100}
+			if err := db.Create(node).Error; err != nil {
+				t.Fatal(err)
+			}
+			rotation := &models.NodeSecretRotation{NodeID: node.ID, State: tc.state, GraceUntil: grace}
+			if err := db.Create(rotation).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// 仍使用旧密钥的连接不确认轮换
+			s.OnAuthenticated(node.ID, "old-secret")
+			stored, _ := s.nodeRepo.FindByID(node.ID)
+			if stored.PrevSecret != "old-secret" {
+				t.Fatalf("使用旧密钥连接后旧密钥被清除")
+			}
+
+			s.OnAuthenticated(node.ID, "new-secret")
+			stored, _ = s.nodeRepo.FindByID(node.ID)
+			if stored.Secret != "new-secret" || stored.PrevSecret != "" || stored.PrevSecretExpire != 0 {
+				t.Errorf("重连后旧密钥未失效: secret=%s prev=%s expire=%d", stored.Secret, stored.PrevSecret, stored.PrevSecretExpire)
+			}
+			latest, _ := s.repo.FindLatest(node.ID)
+			if latest.State != models.NodeSecretRotationApplied {
+				t.Errorf("轮换状态为 %d，应为已生效", latest.State)
+			}
+
+			var expired int64
+			db.Model(&models.AuditLog{}).Where("action = ? AND target_id = ?", "node.secret_expire", node.ID).Count(&expired)
+			if expired != 1 {
+				t.Errorf("旧密钥失效的审计记录 %d 条，应为 1 条", expired)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"flux-panel/dto"
 	"flux-panel/models"
 	"flux-panel/repository"
+	"flux-panel/utils"
+	"flux-panel/websocket"
 	"fmt"
 	"strings"
 
@@ -14,18 +16,33 @@ import (
 
 type NodeService struct {
 	repo          *repository.NodeRepository
+	settingsRepo  *repository.NodeSettingsRepository
+	commandRepo   *repository.NodeCommandRepository
 	configService *ConfigService
+	groupService  *NodeGroupService
+	tunnelService *TunnelService
+	secretService *NodeSecretService
+	audit         *AuditService
 }
 
 func NewNodeService(db *gorm.DB) *NodeService {
 	return &NodeService{
 		repo:          repository.NewNodeRepository(db),
+		settingsRepo:  repository.NewNodeSettingsRepository(db),
+		commandRepo:   repository.NewNodeCommandRepository(db),
 		configService: NewConfigService(db),
+		groupService:  NewNodeGroupService(db),
+		tunnelService: NewTunnelService(db),
+		secretService: NewNodeSecretService(db),
+		audit:         NewAuditService(db),
 	}
 }
 
 // CreateNode 创建节点
-func (s *NodeService) CreateNode(nodeDto *dto.NodeDto) error {
+func (s *NodeService) CreateNode(actor Actor, nodeDto *dto.NodeDto) (err error) {
+	entry := &AuditEntry{Action: "node.create", TargetType: "node", TargetName: nodeDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	// 自动生成 Secret（UUID 去掉横线）
 	secret := strings.ReplaceAll(uuid.New().String(), "-", "")
 
@@ -42,31 +59,58 @@ func (s *NodeService) CreateNode(nodeDto *dto.NodeDto) error {
 		Socks:    nodeDto.Socks,
 	}
 
-	return s.repo.Create(node)
+	if err := s.repo.Create(node); err != nil {
+		return err
+	}
+	entry.TargetID = node.ID
+	entry.After = node
+	return nil
 }
 
-// GetAllNodes 获取所有节点
+// GetAllNodes 获取所有节点，附带节点与面板命令协议的兼容状态
 func (s *NodeService) GetAllNodes() ([]models.Node, error) {
-	return s.repo.FindAll()
+	nodes, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		caps := websocket.ParseCapabilities(nodes[i].ProtocolVersion, nodes[i].Commands, nodes[i].Features)
+		nodes[i].Compatibility = caps.Compatibility()
+		nodes[i].MissingCommands = caps.MissingCommands()
+	}
+	return nodes, nil
 }
 
 // UpdateNode 更新节点
-func (s *NodeService) UpdateNode(updateDto *dto.NodeUpdateDto) error {
+func (s *NodeService) UpdateNode(actor Actor, updateDto *dto.NodeUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "node.update", TargetType: "node", TargetID: updateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	node, err := s.repo.FindByID(updateDto.ID)
 	if err != nil {
 		return errors.New("节点不存在")
 	}
+	entry.TargetName = node.Name
+	before := *node
+	entry.Before = &before
 
 	// 更新字段
 	if updateDto.Name != nil {
 		node.Name = *updateDto.Name
 	}
-	if updateDto.Secret != nil {
+	if updateDto.Secret != nil && *updateDto.Secret != node.Secret {
+		// 手动修改密钥时旧密钥立即失效
+		utils.ClearCryptoCache(node.Secret)
+		utils.ClearCryptoCache(node.PrevSecret)
 		node.Secret = *updateDto.Secret
+		node.PrevSecret = ""
+		node.PrevSecretExpire = 0
 	}
 	if updateDto.IP != nil {
 		node.IP = *updateDto.IP
 	}
+	serverIPChanged := updateDto.ServerIP != nil && *updateDto.ServerIP != node.ServerIP
 	if updateDto.ServerIP != nil {
 		node.ServerIP = *updateDto.ServerIP
 	}
@@ -79,6 +123,9 @@ func (s *NodeService) UpdateNode(updateDto *dto.NodeUpdateDto) error {
 	if updateDto.PortEnd != nil {
 		node.PortEnd = *updateDto.PortEnd
 	}
+	protocolChanged := (updateDto.HTTP != nil && *updateDto.HTTP != node.HTTP) ||
+		(updateDto.TLS != nil && *updateDto.TLS != node.TLS) ||
+		(updateDto.Socks != nil && *updateDto.Socks != node.Socks)
 	if updateDto.HTTP != nil {
 		node.HTTP = *updateDto.HTTP
 	}
@@ -89,11 +136,47 @@ func (s *NodeService) UpdateNode(updateDto *dto.NodeUpdateDto) error {
 		node.Socks = *updateDto.Socks
 	}
 
-	return s.repo.Update(node)
+	// 协议屏蔽开关以节点上报为准，需下发到节点；节点离线时命令进入队列，重连后重放
+	if protocolChanged {
+		if result := SetProtocol(node.ID, node.HTTP, node.TLS, node.Socks); !result.Success {
+			return errors.New("下发协议屏蔽设置失败: " + result.Message)
+		}
+	}
+
+	if err := s.repo.Update(node); err != nil {
+		return err
+	}
+	entry.After = node
+
+	// 隧道记录的IP与入口域名的解析需随节点IP更新
+	if serverIPChanged {
+		return s.tunnelService.RefreshNodeAddresses(node.ID)
+	}
+	return nil
 }
 
 // DeleteNode 删除节点
-func (s *NodeService) DeleteNode(id uint) error {
+func (s *NodeService) DeleteNode(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "node.delete", TargetType: "node", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+	if node, err := s.repo.FindByID(id); err == nil {
+		entry.TargetName = node.Name
+		entry.Before = node
+	}
+
+	// 先移出节点组，使用节点组的转发在其余成员上重新部署
+	if err := s.groupService.RemoveNode(id); err != nil {
+		return err
+	}
+	if err := s.settingsRepo.DeleteByNode(id); err != nil {
+		return err
+	}
+	if err := s.commandRepo.DeleteByNode(id); err != nil {
+		return err
+	}
+	if err := s.secretService.DeleteRotations(id); err != nil {
+		return err
+	}
 	return s.repo.Delete(id)
 }
 
@@ -120,7 +203,7 @@ func (s *NodeService) GetInstallCommand(id uint) (string, error) {
 
 	// 检查 Secret 是否为空，如果为空则生成并保存
 	if node.Secret == "" {
-		node.Secret = strings.ReplaceAll(uuid.New().String(), "-", "")
+		node.Secret = newNodeSecret()
 		if err := s.repo.Update(node); err != nil {
 			return "", errors.New("更新节点密钥失败")
 		}
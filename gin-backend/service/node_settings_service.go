@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NodeSettingsService struct {
+	repo     *repository.NodeSettingsRepository
+	nodeRepo *repository.NodeRepository
+}
+
+func NewNodeSettingsService(db *gorm.DB) *NodeSettingsService {
+	return &NodeSettingsService{
+		repo:     repository.NewNodeSettingsRepository(db),
+		nodeRepo: repository.NewNodeRepository(db),
+	}
+}
+
+// GetSettings 获取面板保存的节点设置，节点在线时同时返回节点当前生效的设置
+func (s *NodeSettingsService) GetSettings(nodeID uint) (map[string]interface{}, error) {
+	node, err := s.nodeRepo.FindByID(nodeID)
+	if err != nil {
+		return nil, errors.New("节点不存在")
+	}
+
+	settings, err := s.repo.FindByNode(nodeID)
+	if err != nil {
+		settings = &models.NodeSettings{NodeID: nodeID}
+	}
+	settings.AddrList = splitAddrs(settings.Addrs)
+
+	result := map[string]interface{}{
+		"settings": settings,
+		"http":     node.HTTP,
+		"tls":      node.TLS,
+		"socks":    node.Socks,
+	}
+	if websocket.IsNodeConnected(nodeID) {
+		resp := sendGostMessage(nodeID, nil, websocket.MessageTypeGetAgentSettings)
+		if resp.Success {
+			result["current"] = resp.Data
+		} else {
+			result["currentError"] = resp.Message
+		}
+	}
+	return result, nil
+}
+
+// UpdateSettings 保存节点设置并下发，节点离线时在其重新上报配置后自动下发
+func (s *NodeSettingsService) UpdateSettings(settingsDto *dto.NodeSettingsDto) (*models.NodeSettings, error) {
+	node, err := s.nodeRepo.FindByID(settingsDto.NodeID)
+	if err != nil {
+		return nil, errors.New("节点不存在")
+	}
+	if err := validateNodeSettings(settingsDto); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.repo.FindByNode(node.ID)
+	if err != nil {
+		settings = &models.NodeSettings{NodeID: node.ID}
+	}
+	settings.Addrs = strings.Join(settingsDto.Addrs, ",")
+	settings.PingInterval = settingsDto.PingInterval
+	settings.ConfigInterval = settingsDto.ConfigInterval
+	settings.ObserverPeriod = settingsDto.ObserverPeriod
+	settings.LogLevel = settingsDto.LogLevel
+	settings.MetricsAddr = strings.TrimSpace(settingsDto.MetricsAddr)
+	settings.MetricsPath = settingsDto.MetricsPath
+	settings.APIAddr = strings.TrimSpace(settingsDto.APIAddr)
+	settings.APIPath = settingsDto.APIPath
+	settings.APIUsername = settingsDto.APIUsername
+	settings.APIPassword = settingsDto.APIPassword
+	settings.State = models.NodeSettingsPending
+	settings.Message = ""
+	if err := s.repo.Save(settings); err != nil {
+		return nil, err
+	}
+
+	node.HTTP = settingsDto.HTTP
+	node.TLS = settingsDto.TLS
+	node.Socks = settingsDto.Socks
+	if err := s.nodeRepo.Update(node); err != nil {
+		return nil, err
+	}
+
+	if websocket.IsNodeConnected(node.ID) {
+		s.push(settings, node)
+	} else {
+		settings.Message = "节点离线，上线后自动下发"
+		s.repo.UpdateState(settings.ID, settings.State, settings.Message, 0)
+	}
+	settings.AddrList = splitAddrs(settings.Addrs)
+	return settings, nil
+}
+
+// SyncPending 节点重新连接并上报配置后，下发尚未生效的设置
+func (s *NodeSettingsService) SyncPending(nodeID uint) {
+	settings, err := s.repo.FindByNode(nodeID)
+	if err != nil || settings.State == models.NodeSettingsApplied {
+		return
+	}
+	node, err := s.nodeRepo.FindByID(nodeID)
+	if err != nil {
+		return
+	}
+	if err := s.push(settings, node); err != nil {
+		log.Printf("节点 %d 设置下发失败: %v", nodeID, err)
+	}
+}
+
+// push 下发设置并记录结果
+func (s *NodeSettingsService) push(settings *models.NodeSettings, node *models.Node) error {
+	resp := sendGostMessage(node.ID, agentSettingsPayload(settings, node), websocket.MessageTypeApplyAgentSettings)
+	if !resp.Success {
+		settings.State = models.NodeSettingsFailed
+		settings.Message = resp.Message
+		s.repo.UpdateState(settings.ID, settings.State, settings.Message, 0)
+		return errors.New(resp.Message)
+	}
+
+	settings.State = models.NodeSettingsApplied
+	settings.Message = ""
+	settings.AppliedTime = time.Now().UnixMilli()
+	return s.repo.UpdateState(settings.ID, settings.State, settings.Message, settings.AppliedTime)
+}
+
+// agentSettingsPayload 构造下发给节点的设置，监听设置始终下发以便关闭面板开启的服务
+func agentSettingsPayload(settings *models.NodeSettings, node *models.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"addrs":          splitAddrs(settings.Addrs),
+		"pingInterval":   settings.PingInterval,
+		"configInterval": settings.ConfigInterval,
+		"observerPeriod": settings.ObserverPeriod,
+		"logLevel":       settings.LogLevel,
+		"metrics": map[string]interface{}{
+			"addr": settings.MetricsAddr,
+			"path": settings.MetricsPath,
+		},
+		"api": map[string]interface{}{
+			"addr":     settings.APIAddr,
+			"path":     settings.APIPath,
+			"username": settings.APIUsername,
+			"password": settings.APIPassword,
+		},
+		"http":  node.HTTP,
+		"tls":   node.TLS,
+		"socks": node.Socks,
+	}
+}
+
+// validateNodeSettings 校验节点设置，规则与节点程序一致
+func validateNodeSettings(settingsDto *dto.NodeSettingsDto) error {
+	addrs := make([]string, 0, len(settingsDto.Addrs))
+	for _, addr := range settingsDto.Addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if strings.Contains(addr, ",") || !validHostPort(addr) {
+			return fmt.Errorf("面板地址 %s 格式错误，应为 host:port", addr)
+		}
+		addrs = append(addrs, addr)
+	}
+	settingsDto.Addrs = addrs
+
+	if settingsDto.PingInterval < 0 || settingsDto.PingInterval > 60 {
+		return errors.New("系统信息上报间隔应为1-60秒")
+	}
+	if settingsDto.ConfigInterval != 0 && (settingsDto.ConfigInterval < 60 || settingsDto.ConfigInterval > 86400) {
+		return errors.New("配置上报间隔应为60-86400秒")
+	}
+	if settingsDto.ObserverPeriod < 0 || settingsDto.ObserverPeriod > 300 {
+		return errors.New("流量统计上报周期应为1-300秒")
+	}
+	switch settingsDto.LogLevel {
+	case "", "trace", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("无效的日志级别: %s", settingsDto.LogLevel)
+	}
+	if addr := strings.TrimSpace(settingsDto.MetricsAddr); addr != "" && !validHostPort(addr) {
+		return errors.New("指标监听地址格式错误，应为 host:port")
+	}
+	if addr := strings.TrimSpace(settingsDto.APIAddr); addr != "" {
+		if !validHostPort(addr) {
+			return errors.New("Web API 监听地址格式错误，应为 host:port")
+		}
+		if settingsDto.APIUsername == "" || settingsDto.APIPassword == "" {
+			return errors.New("启用 Web API 时必须设置用户名和密码")
+		}
+	}
+	if !validSwitch(settingsDto.HTTP) || !validSwitch(settingsDto.TLS) || !validSwitch(settingsDto.Socks) {
+		return errors.New("协议屏蔽开关取值必须为0或1")
+	}
+	return nil
+}
+
+func validHostPort(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
+
+func validSwitch(v int) bool {
+	return v == 0 || v == 1
+}
+
+func splitAddrs(addrs string) []string {
+	if addrs == "" {
+		return []string{}
+	}
+	return strings.Split(addrs, ",")
+}
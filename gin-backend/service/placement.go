@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// placement 转发在节点上的部署位置
+type placement struct {
+	in  []models.Node // 部署入口服务、链和连接数限制的节点
+	out []models.Node // 部署出口服务的节点，仅隧道转发
+}
+
+// newPlacement 按隧道的入口、出口节点计算转发的部署位置，转发指定了入口节点时只部署到该节点
+func newPlacement(tunnel *models.Tunnel, in, out []models.Node, forward *models.Forward) *placement {
+	p := &placement{in: in}
+	if forward.InNodeID > 0 {
+		p.in = filterNodes(in, forward.InNodeID)
+	}
+	if tunnel.Type == 2 {
+		p.out = out
+	}
+	return p
+}
+
+// targets 返回连接转发目标的节点 (端口转发为入口节点，隧道转发为出口节点)，目标地址解析器部署在这些节点上
+func (p *placement) targets(tunnel *models.Tunnel) []models.Node {
+	if tunnel.Type == 2 {
+		return p.out
+	}
+	return p.in
+}
+
+// chainAddr 返回入口链连接出口的地址，出口为节点组时为全部成员的地址，由链在成员间轮询
+func (p *placement) chainAddr(tunnel *models.Tunnel, port int) string {
+	if tunnel.OutGroupID == 0 {
+		if strings.Contains(tunnel.OutIP, ":") {
+			return fmt.Sprintf("[%s]:%d", tunnel.OutIP, port)
+		}
+		return fmt.Sprintf("%s:%d", tunnel.OutIP, port)
+	}
+	addrs := make([]string, 0, len(p.out))
+	for _, node := range p.out {
+		addrs = append(addrs, net.JoinHostPort(node.ServerIP, strconv.Itoa(port)))
+	}
+	return strings.Join(addrs, ",")
+}
+
+func (p *placement) isIn(nodeID uint) bool {
+	return len(filterNodes(p.in, nodeID)) > 0
+}
+
+func (p *placement) isOut(nodeID uint) bool {
+	return len(filterNodes(p.out, nodeID)) > 0
+}
+
+// filterNodes 返回列表中ID为 nodeID 的节点
+func filterNodes(nodes []models.Node, nodeID uint) []models.Node {
+	for _, node := range nodes {
+		if node.ID == nodeID {
+			return []models.Node{node}
+		}
+	}
+	return nil
+}
+
+// subtractNodes 返回在 a 中但不在 b 中的节点
+func subtractNodes(a, b []models.Node) []models.Node {
+	var result []models.Node
+	for _, node := range a {
+		if len(filterNodes(b, node.ID)) == 0 {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// joinServerIPs 以逗号连接节点的服务器IP
+func joinServerIPs(nodes []models.Node) string {
+	ips := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ips = append(ips, node.ServerIP)
+	}
+	return strings.Join(ips, ",")
+}
+
+// nodeLocator 解析隧道入口、出口对应的节点，指定节点组时为组内全部成员
+type nodeLocator struct {
+	nodeRepo  *repository.NodeRepository
+	groupRepo *repository.NodeGroupRepository
+}
+
+func newNodeLocator(db *gorm.DB) *nodeLocator {
+	return &nodeLocator{
+		nodeRepo:  repository.NewNodeRepository(db),
+		groupRepo: repository.NewNodeGroupRepository(db),
+	}
+}
+
+// resolve 返回单个节点或节点组的全部成员，role 为错误信息中的角色 (入口、出口)
+func (l *nodeLocator) resolve(nodeID, groupID uint, role string) ([]models.Node, error) {
+	if groupID == 0 {
+		node, err := l.nodeRepo.FindByID(nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("%s节点不存在", role)
+		}
+		return []models.Node{*node}, nil
+	}
+
+	ids, err := l.groupRepo.MemberIDs(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("%s节点组不存在", role)
+	}
+	nodes := make([]models.Node, 0, len(ids))
+	for _, id := range ids {
+		if node, err := l.nodeRepo.FindByID(id); err == nil {
+			nodes = append(nodes, *node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%s节点组没有节点", role)
+	}
+	return nodes, nil
+}
+
+// tunnelNodes 返回隧道的入口节点与出口节点，端口转发没有出口节点
+func (l *nodeLocator) tunnelNodes(tunnel *models.Tunnel) ([]models.Node, []models.Node, error) {
+	in, err := l.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+	if err != nil {
+		return nil, nil, err
+	}
+	if tunnel.Type != 2 {
+		return in, nil, nil
+	}
+	out, err := l.resolve(tunnel.OutNodeID, tunnel.OutGroupID, "出口")
+	if err != nil {
+		return nil, nil, err
+	}
+	return in, out, nil
+}
+
+// forwardPlacement 返回转发的部署位置
+func (l *nodeLocator) forwardPlacement(tunnel *models.Tunnel, forward *models.Forward) (*placement, error) {
+	in, out, err := l.tunnelNodes(tunnel)
+	if err != nil {
+		return nil, err
+	}
+	p := newPlacement(tunnel, in, out, forward)
+	if len(p.in) == 0 {
+		return nil, errors.New("转发指定的入口节点不在隧道的入口节点组中")
+	}
+	return p, nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 诊断探测方式
+const (
+	ProbeTCP   = "tcp"
+	ProbeUDP   = "udp"
+	ProbeHTTP  = "http"
+	ProbeHTTPS = "https"
+	ProbeTrace = "trace"
+	ProbeMTR   = "mtr"
+	ProbeDNS   = "dns"
+)
+
+type ProbeService struct {
+	nodeRepo *repository.NodeRepository
+}
+
+func NewProbeService(db *gorm.DB) *ProbeService {
+	return &ProbeService{
+		nodeRepo: repository.NewNodeRepository(db),
+	}
+}
+
+// Probe 从节点向任意目标发起诊断，返回节点的原始探测结果
+func (s *ProbeService) Probe(probeDto *dto.NodeProbeDto) (interface{}, error) {
+	if _, err := s.nodeRepo.FindByID(probeDto.NodeID); err != nil {
+		return nil, errors.New("节点不存在")
+	}
+
+	target := strings.TrimSpace(probeDto.Target)
+	req := map[string]interface{}{}
+	if probeDto.Count > 0 {
+		req["count"] = probeDto.Count
+	}
+	if probeDto.Timeout > 0 {
+		req["timeout"] = probeDto.Timeout
+	}
+
+	var msgType string
+	switch probeDto.Type {
+	case ProbeTCP, ProbeUDP:
+		if probeDto.Port <= 0 || probeDto.Port > 65535 {
+			return nil, errors.New("端口号无效，范围应为1-65535")
+		}
+		req["ip"] = target
+		req["port"] = probeDto.Port
+		msgType = websocket.MessageTypeTcpPing
+		if probeDto.Type == ProbeUDP {
+			msgType = websocket.MessageTypeUdpProbe
+		}
+	case ProbeHTTP:
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, errors.New("HTTP 探测目标必须是 http 或 https 链接")
+		}
+		req["url"] = target
+		req["insecure"] = probeDto.Insecure
+		msgType = websocket.MessageTypeHttpProbe
+	case ProbeTrace, ProbeMTR:
+		req["ip"] = target
+		if probeDto.Type == ProbeMTR && probeDto.Count <= 0 {
+			req["count"] = 10
+			req["timeout"] = 1000
+		}
+		msgType = websocket.MessageTypeTraceroute
+	case ProbeDNS:
+		req["host"] = target
+		req["type"] = probeDto.RecordType
+		req["server"] = probeDto.Server
+		msgType = websocket.MessageTypeDnsLookup
+	default:
+		return nil, fmt.Errorf("不支持的探测方式: %s", probeDto.Type)
+	}
+
+	resp, err := websocket.GetServer().SendMessage(probeDto.NodeID, req, msgType)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errors.New(resp.Message)
+	}
+	return resp.Data, nil
+}
+
+// performProbeDiagnosis 执行 UDP、HTTP(S) 或路由追踪诊断
+func performProbeDiagnosis(node *models.Node, probe, host string, port int, description, resolver, prefer string) DiagnosisResult {
+	result := DiagnosisResult{
+		NodeId:      node.ID,
+		NodeName:    node.Name,
+		TargetIp:    host,
+		TargetPort:  port,
+		Description: description,
+		Timestamp:   time.Now().UnixMilli(),
+		Probe:       probe,
+		PacketLoss:  100.0,
+		AverageTime: -1.0,
+	}
+
+	req := map[string]interface{}{}
+	if resolver != "" {
+		req["resolver"] = resolver
+	}
+	if prefer != "" {
+		req["prefer"] = prefer
+	}
+
+	var msgType, okMessage string
+	switch probe {
+	case ProbeUDP:
+		req["ip"] = host
+		req["port"] = port
+		req["count"] = 4
+		req["timeout"] = 2000
+		msgType, okMessage = websocket.MessageTypeUdpProbe, "UDP探测收到响应"
+	case ProbeHTTP, ProbeHTTPS:
+		req["url"] = fmt.Sprintf("%s://%s/", probe, net.JoinHostPort(host, strconv.Itoa(port)))
+		req["timeout"] = 10000
+		req["insecure"] = true
+		result.Probe = ProbeHTTP
+		msgType, okMessage = websocket.MessageTypeHttpProbe, "HTTP请求成功"
+	case ProbeTrace:
+		req["ip"] = host
+		req["count"] = 3
+		req["timeout"] = 2000
+		msgType, okMessage = websocket.MessageTypeTraceroute, "路由追踪完成"
+	default:
+		result.Message = fmt.Sprintf("不支持的探测方式: %s", probe)
+		return result
+	}
+
+	resp, err := websocket.GetServer().SendMessage(node.ID, req, msgType)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	if !resp.Success {
+		result.Message = resp.Message
+		return result
+	}
+
+	dataMap, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		result.Success = true
+		result.Message = okMessage + " (数据解析失败)"
+		return result
+	}
+
+	result.Success, _ = dataMap["success"].(bool)
+	if result.Success {
+		result.Message = okMessage
+	} else if errMsg, ok := dataMap["errorMessage"].(string); ok && errMsg != "" {
+		result.Message = errMsg
+	} else {
+		result.Message = "探测失败"
+	}
+	if val, ok := dataMap["resolvedIps"].([]interface{}); ok {
+		for _, ip := range val {
+			if ipStr, ok := ip.(string); ok {
+				result.ResolvedIPs = append(result.ResolvedIPs, ipStr)
+			}
+		}
+	}
+
+	switch msgType {
+	case websocket.MessageTypeUdpProbe:
+		if val, ok := dataMap["packetLoss"].(float64); ok {
+			result.PacketLoss = val
+		}
+		if val, ok := dataMap["averageTime"].(float64); ok && result.Success {
+			result.AverageTime = val
+		}
+		result.Detail = map[string]interface{}{"status": dataMap["status"]}
+	case websocket.MessageTypeHttpProbe:
+		if result.Success {
+			result.PacketLoss = 0
+			if val, ok := dataMap["totalTime"].(float64); ok {
+				result.AverageTime = val
+			}
+			if code, ok := dataMap["statusCode"].(float64); ok {
+				result.Message = fmt.Sprintf("HTTP %d", int(code))
+			}
+		}
+		result.Detail = dataMap
+	case websocket.MessageTypeTraceroute:
+		// 以最后一跳的统计作为整条路径的结果
+		if hops, ok := dataMap["hops"].([]interface{}); ok && len(hops) > 0 {
+			if last, ok := hops[len(hops)-1].(map[string]interface{}); ok {
+				if val, ok := last["packetLoss"].(float64); ok {
+					result.PacketLoss = val
+				}
+				if val, ok := last["average"].(float64); ok && val > 0 {
+					result.AverageTime = val
+				}
+			}
+		}
+		if reached, _ := dataMap["reached"].(bool); result.Success && !reached {
+			result.Message = "路由追踪未到达目标"
+		}
+		result.Detail = dataMap["hops"]
+	}
+
+	return result
+}
@@ -10,25 +10,70 @@ import (
 )
 
 type SpeedLimitService struct {
-	repo *repository.SpeedLimitRepository
+	repo       *repository.SpeedLimitRepository
+	tunnelRepo *repository.TunnelRepository
+	locator    *nodeLocator
+	audit      *AuditService
 }
 
 func NewSpeedLimitService(db *gorm.DB) *SpeedLimitService {
 	return &SpeedLimitService{
-		repo: repository.NewSpeedLimitRepository(db),
+		repo:       repository.NewSpeedLimitRepository(db),
+		tunnelRepo: repository.NewTunnelRepository(db),
+		locator:    newNodeLocator(db),
+		audit:      NewAuditService(db),
 	}
 }
 
 // CreateSpeedLimit 创建限速规则
-func (s *SpeedLimitService) CreateSpeedLimit(limitDto *dto.SpeedLimitDto) error {
+func (s *SpeedLimitService) CreateSpeedLimit(actor Actor, limitDto *dto.SpeedLimitDto) (err error) {
+	entry := &AuditEntry{Action: "speed_limit.create", TargetType: "speed_limit", TargetName: limitDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	speedLimit := &models.SpeedLimit{
-		Name:       limitDto.Name,
-		Speed:      limitDto.Speed,
-		TunnelID:   limitDto.TunnelID,
-		TunnelName: limitDto.TunnelName,
-		Status:     0,
+		Name:         limitDto.Name,
+		Speed:        limitDto.Speed,
+		InSpeed:      limitDto.InSpeed,
+		OutSpeed:     limitDto.OutSpeed,
+		InBurst:      limitDto.InBurst,
+		OutBurst:     limitDto.OutBurst,
+		ConnInSpeed:  limitDto.ConnInSpeed,
+		ConnOutSpeed: limitDto.ConnOutSpeed,
+		TunnelID:     limitDto.TunnelID,
+		TunnelName:   limitDto.TunnelName,
+		Status:       0,
+	}
+	if len(BuildTrafficLimits(speedLimit)) == 0 {
+		return errors.New("限速值不能为空")
+	}
+
+	tunnel, err := s.tunnelRepo.FindByID(uint(limitDto.TunnelID))
+	if err != nil {
+		return errors.New("隧道不存在")
+	}
+	inNodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(speedLimit); err != nil {
+		return err
+	}
+
+	// 入口为节点组时限流器下发到全部成员
+	for _, node := range inNodes {
+		resp := AddLimiters(node.ID, speedLimit.ID, BuildTrafficLimits(speedLimit))
+		if !resp.Success {
+			for _, n := range inNodes {
+				DeleteLimiters(n.ID, speedLimit.ID)
+			}
+			s.repo.Delete(speedLimit.ID)
+			return errors.New(resp.Message)
+		}
 	}
-	return s.repo.Create(speedLimit)
+	entry.TargetID = speedLimit.ID
+	entry.After = speedLimit
+	return nil
 }
 
 // GetAllSpeedLimits 获取所有限速规则
@@ -37,25 +82,120 @@ func (s *SpeedLimitService) GetAllSpeedLimits() ([]models.SpeedLimit, error) {
 }
 
 // UpdateSpeedLimit 更新限速规则
-func (s *SpeedLimitService) UpdateSpeedLimit(updateDto *dto.SpeedLimitUpdateDto) error {
+func (s *SpeedLimitService) UpdateSpeedLimit(actor Actor, updateDto *dto.SpeedLimitUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "speed_limit.update", TargetType: "speed_limit", TargetID: updateDto.ID, TargetName: updateDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	speedLimit, err := s.repo.FindByID(updateDto.ID)
 	if err != nil {
 		return errors.New("限速规则不存在")
 	}
+	before := *speedLimit
+	entry.Before = &before
+
+	oldTunnelID := speedLimit.TunnelID
 
 	speedLimit.Name = updateDto.Name
 	speedLimit.Speed = updateDto.Speed
+	speedLimit.InSpeed = updateDto.InSpeed
+	speedLimit.OutSpeed = updateDto.OutSpeed
+	speedLimit.InBurst = updateDto.InBurst
+	speedLimit.OutBurst = updateDto.OutBurst
+	speedLimit.ConnInSpeed = updateDto.ConnInSpeed
+	speedLimit.ConnOutSpeed = updateDto.ConnOutSpeed
 	speedLimit.TunnelID = updateDto.TunnelID
 	speedLimit.TunnelName = updateDto.TunnelName
 
+	limits := BuildTrafficLimits(speedLimit)
+	if len(limits) == 0 {
+		return errors.New("限速值不能为空")
+	}
+
+	tunnel, err := s.tunnelRepo.FindByID(uint(speedLimit.TunnelID))
+	if err != nil {
+		return errors.New("隧道不存在")
+	}
+	inNodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+	if err != nil {
+		return err
+	}
+
+	// 隧道变更时从原入口节点移除限流器
+	if oldTunnelID != speedLimit.TunnelID {
+		if oldTunnel, err := s.tunnelRepo.FindByID(uint(oldTunnelID)); err == nil {
+			if oldNodes, err := s.locator.resolve(oldTunnel.InNodeID, oldTunnel.InGroupID, "入口"); err == nil {
+				for _, node := range subtractNodes(oldNodes, inNodes) {
+					DeleteLimiters(node.ID, speedLimit.ID)
+				}
+			}
+		}
+	}
+
+	for _, node := range inNodes {
+		if resp := syncLimiters(node.ID, speedLimit.ID, limits); !resp.Success {
+			return errors.New(resp.Message)
+		}
+	}
+
+	entry.After = speedLimit
 	return s.repo.Update(speedLimit)
 }
 
+// SyncTunnelLimiters 将隧道的限速规则下发到指定的入口节点，用于节点加入入口节点组后
+func (s *SpeedLimitService) SyncTunnelLimiters(tunnelID uint, nodes []models.Node) {
+	speedLimits, err := s.repo.FindByTunnelID(tunnelID)
+	if err != nil {
+		return
+	}
+	for i := range speedLimits {
+		limits := BuildTrafficLimits(&speedLimits[i])
+		for _, node := range nodes {
+			syncLimiters(node.ID, speedLimits[i].ID, limits)
+		}
+	}
+}
+
+// DeleteTunnelLimiters 从节点上删除隧道的限速规则，用于节点移出入口节点组后
+func (s *SpeedLimitService) DeleteTunnelLimiters(tunnelID uint, nodes []models.Node) {
+	speedLimits, err := s.repo.FindByTunnelID(tunnelID)
+	if err != nil {
+		return
+	}
+	for i := range speedLimits {
+		for _, node := range nodes {
+			DeleteLimiters(node.ID, speedLimits[i].ID)
+		}
+	}
+}
+
+// syncLimiters 更新节点上的限流器，节点上不存在时创建
+func syncLimiters(nodeID, id uint, limits []string) *GostResponse {
+	resp := UpdateLimiters(nodeID, id, limits)
+	if !resp.Success {
+		resp = AddLimiters(nodeID, id, limits)
+	}
+	return resp
+}
+
 // DeleteSpeedLimit 删除限速规则
-func (s *SpeedLimitService) DeleteSpeedLimit(id uint) error {
-	_, err := s.repo.FindByID(id)
+func (s *SpeedLimitService) DeleteSpeedLimit(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "speed_limit.delete", TargetType: "speed_limit", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	speedLimit, err := s.repo.FindByID(id)
 	if err != nil {
 		return errors.New("限速规则不存在")
 	}
+	entry.TargetName = speedLimit.Name
+	entry.Before = speedLimit
+
+	if tunnel, err := s.tunnelRepo.FindByID(uint(speedLimit.TunnelID)); err == nil {
+		if inNodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口"); err == nil {
+			for _, node := range inNodes {
+				DeleteLimiters(node.ID, speedLimit.ID)
+			}
+		}
+	}
+
 	return s.repo.Delete(id)
 }
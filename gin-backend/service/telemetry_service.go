@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/repository"
+	"flux-panel/websocket"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// telemetryVersion 面板能解析的遥测消息格式版本，更高版本只保存已知字段
+	telemetryVersion = 1
+
+	defaultTelemetryRetentionDays = 7
+	maxTelemetryQueryRows         = 5000
+)
+
+type TelemetryService struct {
+	repo       *repository.NodeTelemetryRepository
+	nodeRepo   *repository.NodeRepository
+	configRepo *repository.ConfigRepository
+}
+
+func NewTelemetryService(db *gorm.DB) *TelemetryService {
+	return &TelemetryService{
+		repo:       repository.NewNodeTelemetryRepository(db),
+		nodeRepo:   repository.NewNodeRepository(db),
+		configRepo: repository.NewConfigRepository(db),
+	}
+}
+
+// SaveReport 保存节点上报的扩展遥测
+func (s *TelemetryService) SaveReport(nodeID uint, data []byte) {
+	var report dto.TelemetryReportDto
+	if err := json.Unmarshal(data, &report); err != nil {
+		log.Printf("解析节点 %d 遥测数据失败: %v", nodeID, err)
+		return
+	}
+	if report.V <= 0 {
+		log.Printf("节点 %d 遥测数据缺少版本号，已忽略", nodeID)
+		return
+	}
+	if report.V > telemetryVersion {
+		log.Printf("节点 %d 遥测数据版本 %d 高于面板支持的版本 %d，仅保存已知字段", nodeID, report.V, telemetryVersion)
+	}
+
+	collectedTime := time.Now().UnixMilli()
+	if report.Time > 0 {
+		collectedTime = report.Time * 1000
+	}
+
+	record := &models.NodeTelemetry{
+		NodeID:        nodeID,
+		Version:       report.V,
+		Load1:         report.Load1,
+		Load5:         report.Load5,
+		Load15:        report.Load15,
+		Disks:         string(report.Disks),
+		Interfaces:    string(report.Interfaces),
+		CollectedTime: collectedTime,
+	}
+	if report.TCPStates != nil {
+		if b, err := json.Marshal(report.TCPStates); err == nil {
+			record.TCPStates = string(b)
+		}
+		for _, n := range report.TCPStates {
+			record.TCPTotal += n
+		}
+	}
+	if report.Conntrack != nil {
+		record.ConntrackCount = report.Conntrack.Count
+		record.ConntrackMax = report.Conntrack.Max
+	}
+	if report.FDs != nil {
+		record.FDOpen = report.FDs.Open
+		record.FDLimit = report.FDs.Limit
+	}
+
+	if err := s.repo.Create(record); err != nil {
+		log.Printf("保存节点 %d 遥测数据失败: %v", nodeID, err)
+	}
+}
+
+// GetHistory 查询节点遥测历史
+func (s *TelemetryService) GetHistory(query *dto.TelemetryQueryDto) ([]dto.NodeTelemetryDto, error) {
+	if _, err := s.nodeRepo.FindByID(query.NodeID); err != nil {
+		return nil, errors.New("节点不存在")
+	}
+
+	endTime := query.EndTime
+	if endTime <= 0 {
+		endTime = time.Now().UnixMilli()
+	}
+	startTime := query.StartTime
+	if startTime <= 0 {
+		startTime = endTime - 24*time.Hour.Milliseconds()
+	}
+	if startTime > endTime {
+		return nil, errors.New("开始时间不能晚于结束时间")
+	}
+
+	records, err := s.repo.FindByNode(query.NodeID, startTime, endTime, maxTelemetryQueryRows)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		if caps := websocket.NodeCapabilities(query.NodeID); caps != nil && !caps.HasFeature(websocket.FeatureTelemetry) {
+			return nil, errors.New("节点版本过低，不支持扩展遥测，请升级节点")
+		}
+	}
+
+	result := make([]dto.NodeTelemetryDto, 0, len(records))
+	for _, r := range records {
+		result = append(result, dto.NodeTelemetryDto{
+			CollectedTime:  r.CollectedTime,
+			Version:        r.Version,
+			Load1:          r.Load1,
+			Load5:          r.Load5,
+			Load15:         r.Load15,
+			Disks:          rawJSON(r.Disks),
+			TCPStates:      rawJSON(r.TCPStates),
+			TCPTotal:       r.TCPTotal,
+			Interfaces:     rawJSON(r.Interfaces),
+			ConntrackCount: r.ConntrackCount,
+			ConntrackMax:   r.ConntrackMax,
+			FDOpen:         r.FDOpen,
+			FDLimit:        r.FDLimit,
+		})
+	}
+	return result, nil
+}
+
+// CleanExpired 清理超过保留天数的遥测记录
+func (s *TelemetryService) CleanExpired() {
+	days := configPositiveInt(s.configRepo, "telemetry_retention_days", defaultTelemetryRetentionDays)
+	cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+	if n, err := s.repo.DeleteBefore(cutoff); err != nil {
+		log.Printf("Failed to delete expired node telemetry: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d expired node telemetry records", n)
+	}
+}
+
+// rawJSON 将存储的 JSON 文本原样输出，空值输出为 null
+func rawJSON(s string) json.RawMessage {
+	if s == "" || !json.Valid([]byte(s)) {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s)
+}
@@ -6,22 +6,28 @@ import (
 	"flux-panel/models"
 	"flux-panel/repository"
 	"flux-panel/websocket"
+	"log"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type DiagnosisResult struct {
-	NodeId      uint    `json:"nodeId"`
-	NodeName    string  `json:"nodeName"`
-	TargetIp    string  `json:"targetIp"`
-	TargetPort  int     `json:"targetPort"`
-	Description string  `json:"description"`
-	Success     bool    `json:"success"`
-	Message     string  `json:"message"`
-	AverageTime float64 `json:"averageTime"`
-	PacketLoss  float64 `json:"packetLoss"`
-	Timestamp   int64   `json:"timestamp"`
+	NodeId      uint     `json:"nodeId"`
+	NodeName    string   `json:"nodeName"`
+	TargetIp    string   `json:"targetIp"`
+	TargetPort  int      `json:"targetPort"`
+	Description string   `json:"description"`
+	Success     bool     `json:"success"`
+	Message     string   `json:"message"`
+	AverageTime float64  `json:"averageTime"`
+	PacketLoss  float64  `json:"packetLoss"`
+	ResolvedIPs []string `json:"resolvedIps,omitempty"` // 目标为域名时解析到的地址
+	Timestamp   int64    `json:"timestamp"`
+
+	Probe  string      `json:"probe,omitempty"`  // 探测方式: tcp, udp, http, trace
+	Detail interface{} `json:"detail,omitempty"` // 探测的详细结果，如 HTTP 各阶段耗时、路由追踪的每一跳
 }
 
 type TunnelService struct {
@@ -30,6 +36,11 @@ type TunnelService struct {
 	nodeRepo       *repository.NodeRepository
 	forwardRepo    *repository.ForwardRepository
 	userRepo       *repository.UserRepository
+	forwardService *ForwardService
+	bandwidthRepo  *repository.BandwidthTestRepository
+	locator        *nodeLocator
+	dnsService     *DNSService
+	audit          *AuditService
 }
 
 func NewTunnelService(db *gorm.DB) *TunnelService {
@@ -38,49 +49,63 @@ func NewTunnelService(db *gorm.DB) *TunnelService {
 		userTunnelRepo: repository.NewUserTunnelRepository(db),
 		nodeRepo:       repository.NewNodeRepository(db),
 		forwardRepo:    repository.NewForwardRepository(db),
+		forwardService: NewForwardService(db),
 		userRepo:       repository.NewUserRepository(db),
+		bandwidthRepo:  repository.NewBandwidthTestRepository(db),
+		locator:        newNodeLocator(db),
+		dnsService:     NewDNSService(db),
+		audit:          NewAuditService(db),
 	}
 }
 
 // ...
 
-// DiagnoseTunnel 诊断隧道
-func (s *TunnelService) DiagnoseTunnel(id uint) (map[string]interface{}, error) {
+// DiagnoseTunnel 诊断隧道，trace 为 true 时额外对每段路径做路由追踪
+func (s *TunnelService) DiagnoseTunnel(id uint, trace bool) (map[string]interface{}, error) {
 	tunnel, err := s.repo.FindByID(id)
 	if err != nil {
 		return nil, errors.New("隧道不存在")
 	}
 
-	inNode, err := s.nodeRepo.FindByID(tunnel.InNodeID)
+	// 入口或出口为节点组时对每个成员分别诊断
+	inNodes, outNodes, err := s.locator.tunnelNodes(tunnel)
 	if err != nil {
-		return nil, errors.New("入口节点不存在")
-	}
-
-	var outNode *models.Node
-	if tunnel.Type == 2 { // 隧道转发
-		outNode, err = s.nodeRepo.FindByID(tunnel.OutNodeID)
-		if err != nil {
-			return nil, errors.New("出口节点不存在")
-		}
+		return nil, err
 	}
 
 	var results []DiagnosisResult
 
 	if tunnel.Type == 1 {
 		// 端口转发
-		inResult := s.performTcpPingDiagnosis(inNode, "www.google.com", 443, "入口->外网")
-		results = append(results, inResult)
+		for i := range inNodes {
+			inNode := &inNodes[i]
+			inResult := s.performTcpPingDiagnosis(inNode, "www.google.com", 443, "入口->外网")
+			results = append(results, inResult)
+			if trace {
+				results = append(results, performProbeDiagnosis(inNode, ProbeTrace, "www.google.com", 443, "入口->外网", "", ""))
+			}
+		}
 	} else {
 		// 隧道转发
 		outNodePort := s.getOutNodeTcpPort(tunnel.ID)
-		if outNode != nil {
-			inToOutResult := s.performTcpPingDiagnosis(inNode, outNode.ServerIP, outNodePort, "入口->出口")
-			results = append(results, inToOutResult)
+		for i := range inNodes {
+			inNode := &inNodes[i]
+			for _, outNode := range outNodes {
+				inToOutResult := s.performTcpPingDiagnosis(inNode, outNode.ServerIP, outNodePort, "入口->出口")
+				results = append(results, inToOutResult)
+				if trace {
+					results = append(results, performProbeDiagnosis(inNode, ProbeTrace, outNode.ServerIP, outNodePort, "入口->出口", "", ""))
+				}
+			}
 		}
 
-		if outNode != nil {
+		for i := range outNodes {
+			outNode := &outNodes[i]
 			outToExternalResult := s.performTcpPingDiagnosis(outNode, "www.google.com", 443, "出口->外网")
 			results = append(results, outToExternalResult)
+			if trace {
+				results = append(results, performProbeDiagnosis(outNode, ProbeTrace, "www.google.com", 443, "出口->外网", "", ""))
+			}
 		}
 	}
 
@@ -97,6 +122,13 @@ func (s *TunnelService) DiagnoseTunnel(id uint) (map[string]interface{}, error)
 		"timestamp":  time.Now().UnixMilli(),
 	}
 
+	// 附带最近的带宽测试结果
+	if tunnel.Type == 2 {
+		if tests, err := s.bandwidthRepo.FindByTunnel(tunnel.ID, bandwidthTestHistory); err == nil {
+			result["bandwidthTests"] = tests
+		}
+	}
+
 	return result, nil
 }
 
@@ -131,7 +163,7 @@ func (s *TunnelService) performTcpPingDiagnosis(node *models.Node, targetIp stri
 		"timeout": 5000,
 	}
 
-	resp, err := websocket.GetServer().SendMessage(node.ID, tcpPingReq, "TcpPing")
+	resp, err := websocket.GetServer().SendMessage(node.ID, tcpPingReq, websocket.MessageTypeTcpPing)
 	if err != nil {
 		result.Success = false
 		result.Message = err.Error()
@@ -185,32 +217,31 @@ func (s *TunnelService) performTcpPingDiagnosis(node *models.Node, targetIp stri
 }
 
 // CreateTunnel 创建隧道
-func (s *TunnelService) CreateTunnel(tunnelDto *dto.TunnelDto) error {
-	inNode, err := s.nodeRepo.FindByID(tunnelDto.InNodeID)
-	if err != nil {
-		return errors.New("入口节点不存在")
-	}
+func (s *TunnelService) CreateTunnel(actor Actor, tunnelDto *dto.TunnelDto) (err error) {
+	entry := &AuditEntry{Action: "tunnel.create", TargetType: "tunnel", TargetName: tunnelDto.Name}
+	defer func() { s.audit.Record(actor, entry, err) }()
 
-	outIP := ""
-	if tunnelDto.OutNodeID > 0 {
-		outNode, err := s.nodeRepo.FindByID(tunnelDto.OutNodeID)
-		if err == nil {
-			outIP = outNode.ServerIP
-		}
+	if err := validateResolver(tunnelDto.ResolverType, tunnelDto.ResolverAddr); err != nil {
+		return err
 	}
 
 	tunnel := &models.Tunnel{
 		Name:          tunnelDto.Name,
 		InNodeID:      tunnelDto.InNodeID,
-		InIP:          inNode.ServerIP,
 		OutNodeID:     tunnelDto.OutNodeID,
-		OutIP:         outIP,
+		InGroupID:     tunnelDto.InGroupID,
+		OutGroupID:    tunnelDto.OutGroupID,
 		Type:          tunnelDto.Type,
 		Flow:          tunnelDto.Flow,
 		Protocol:      tunnelDto.Protocol,
 		TCPListenAddr: tunnelDto.TCPListenAddr,
 		UDPListenAddr: tunnelDto.UDPListenAddr,
 		InterfaceName: tunnelDto.InterfaceName,
+		ResolverType:  tunnelDto.ResolverType,
+		ResolverAddr:  tunnelDto.ResolverAddr,
+		ResolverTTL:   tunnelDto.ResolverTTL,
+		Hostname:      tunnelDto.Hostname,
+		DNSProviderID: tunnelDto.DNSProviderID,
 	}
 
 	if tunnelDto.TrafficRatio != nil {
@@ -220,7 +251,91 @@ func (s *TunnelService) CreateTunnel(tunnelDto *dto.TunnelDto) error {
 	}
 	tunnel.Status = 1 // 默认启用
 
-	return s.repo.Create(tunnel)
+	if err := s.applyTunnelNodes(tunnel); err != nil {
+		return err
+	}
+	if err := s.dnsService.ValidateTunnelHostname(tunnel); err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(tunnel); err != nil {
+		return err
+	}
+	entry.TargetID = tunnel.ID
+	entry.After = tunnel
+	// 解析同步失败不影响隧道创建，失败原因记录在解析记录中
+	if err := s.dnsService.SyncTunnel(tunnel); err != nil {
+		log.Printf("%v", err)
+	}
+	return nil
+}
+
+// applyTunnelNodes 校验隧道的入口与出口并填充服务器IP，指定节点组时清空对应的节点，IP 为全部成员的IP
+func (s *TunnelService) applyTunnelNodes(tunnel *models.Tunnel) error {
+	if tunnel.InGroupID > 0 {
+		tunnel.InNodeID = 0
+	}
+	if tunnel.OutGroupID > 0 {
+		tunnel.OutNodeID = 0
+	}
+
+	inNodes, err := s.locator.resolve(tunnel.InNodeID, tunnel.InGroupID, "入口")
+	if err != nil {
+		return err
+	}
+	tunnel.InIP = joinServerIPs(inNodes)
+
+	tunnel.OutIP = ""
+	if tunnel.OutNodeID > 0 || tunnel.OutGroupID > 0 {
+		outNodes, err := s.locator.resolve(tunnel.OutNodeID, tunnel.OutGroupID, "出口")
+		if err == nil {
+			tunnel.OutIP = joinServerIPs(outNodes)
+		} else if tunnel.OutGroupID > 0 {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshNodeAddresses 节点IP变更后刷新使用该节点的隧道记录的入口、出口IP，
+// 出口IP变化时重新下发隧道下的转发，并同步入口域名的解析记录
+func (s *TunnelService) RefreshNodeAddresses(nodeID uint) error {
+	groupIDs, err := s.locator.groupRepo.GroupIDsOfNode(nodeID)
+	if err != nil {
+		return err
+	}
+	tunnels, err := s.repo.FindByNode(nodeID, groupIDs)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		inNodes, outNodes, err := s.locator.tunnelNodes(tunnel)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		outIP := tunnel.OutIP
+		tunnel.InIP = joinServerIPs(inNodes)
+		if len(outNodes) > 0 {
+			tunnel.OutIP = joinServerIPs(outNodes)
+		}
+		if err := s.repo.Update(tunnel); err != nil {
+			lastErr = err
+			continue
+		}
+		if tunnel.Type == 2 && tunnel.OutIP != outIP {
+			if err := s.forwardService.SyncTunnelForwards(tunnel); err != nil {
+				lastErr = err
+			}
+		}
+		if err := s.dnsService.SyncTunnel(tunnel); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
 }
 
 // GetAllTunnels 获取所有隧道
@@ -229,28 +344,58 @@ func (s *TunnelService) GetAllTunnels() ([]models.Tunnel, error) {
 }
 
 // UpdateTunnel 更新隧道
-func (s *TunnelService) UpdateTunnel(updateDto *dto.TunnelUpdateDto) error {
+func (s *TunnelService) UpdateTunnel(actor Actor, updateDto *dto.TunnelUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "tunnel.update", TargetType: "tunnel", TargetID: updateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	tunnel, err := s.repo.FindByID(updateDto.ID)
 	if err != nil {
 		return errors.New("隧道不存在")
 	}
+	entry.TargetName = tunnel.Name
+	before := *tunnel
+	entry.Before = &before
 
 	// 更新字段
 	if updateDto.Name != nil {
 		tunnel.Name = *updateDto.Name
 	}
+	groupChanged := (updateDto.InGroupID != nil && *updateDto.InGroupID != tunnel.InGroupID) ||
+		(updateDto.OutGroupID != nil && *updateDto.OutGroupID != tunnel.OutGroupID)
+	if groupChanged {
+		if forwards, err := s.forwardRepo.FindByTunnelID(tunnel.ID); err == nil && len(forwards) > 0 {
+			return errors.New("隧道下存在转发，无法修改入口或出口节点组")
+		}
+	}
 	if updateDto.InNodeID != nil {
 		tunnel.InNodeID = *updateDto.InNodeID
-		if node, err := s.nodeRepo.FindByID(*updateDto.InNodeID); err == nil {
-			tunnel.InIP = node.ServerIP
-		}
 	}
 	if updateDto.OutNodeID != nil {
 		tunnel.OutNodeID = *updateDto.OutNodeID
-		if node, err := s.nodeRepo.FindByID(*updateDto.OutNodeID); err == nil {
-			tunnel.OutIP = node.ServerIP
-		} else {
-			tunnel.OutIP = ""
+	}
+	if updateDto.InGroupID != nil {
+		tunnel.InGroupID = *updateDto.InGroupID
+	}
+	if updateDto.OutGroupID != nil {
+		tunnel.OutGroupID = *updateDto.OutGroupID
+	}
+	nodesChanged := updateDto.InNodeID != nil || updateDto.OutNodeID != nil || groupChanged
+	if nodesChanged {
+		if err := s.applyTunnelNodes(tunnel); err != nil {
+			return err
+		}
+	}
+	hostnameChanged := (updateDto.Hostname != nil && *updateDto.Hostname != tunnel.Hostname) ||
+		(updateDto.DNSProviderID != nil && *updateDto.DNSProviderID != tunnel.DNSProviderID)
+	if updateDto.Hostname != nil {
+		tunnel.Hostname = *updateDto.Hostname
+	}
+	if updateDto.DNSProviderID != nil {
+		tunnel.DNSProviderID = *updateDto.DNSProviderID
+	}
+	if hostnameChanged {
+		if err := s.dnsService.ValidateTunnelHostname(tunnel); err != nil {
+			return err
 		}
 	}
 	if updateDto.Type != nil {
@@ -275,12 +420,73 @@ func (s *TunnelService) UpdateTunnel(updateDto *dto.TunnelUpdateDto) error {
 		tunnel.InterfaceName = *updateDto.InterfaceName
 	}
 
-	return s.repo.Update(tunnel)
+	resolverChanged := false
+	if updateDto.ResolverType != nil && *updateDto.ResolverType != tunnel.ResolverType {
+		tunnel.ResolverType = *updateDto.ResolverType
+		resolverChanged = true
+	}
+	if updateDto.ResolverAddr != nil && *updateDto.ResolverAddr != tunnel.ResolverAddr {
+		tunnel.ResolverAddr = *updateDto.ResolverAddr
+		resolverChanged = true
+	}
+	if updateDto.ResolverTTL != nil && *updateDto.ResolverTTL != tunnel.ResolverTTL {
+		tunnel.ResolverTTL = *updateDto.ResolverTTL
+		resolverChanged = true
+	}
+	if err := validateResolver(tunnel.ResolverType, tunnel.ResolverAddr); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(tunnel); err != nil {
+		return err
+	}
+	entry.After = tunnel
+
+	// 入口节点或域名变更后同步解析记录
+	if nodesChanged || hostnameChanged {
+		if err := s.dnsService.SyncTunnel(tunnel); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	// 解析器配置变更需重新下发隧道下的转发
+	if resolverChanged {
+		return s.forwardService.SyncTunnelForwards(tunnel)
+	}
+	return nil
+}
+
+// validateResolver 校验隧道解析器配置
+func validateResolver(resolverType, resolverAddr string) error {
+	switch resolverType {
+	case "", "system":
+		return nil
+	case "dns", "doh", "dot":
+		if strings.TrimSpace(resolverAddr) == "" {
+			return errors.New("解析服务器地址不能为空")
+		}
+		return nil
+	default:
+		return errors.New("不支持的解析方式: " + resolverType)
+	}
 }
 
 // DeleteTunnel 删除隧道
-func (s *TunnelService) DeleteTunnel(id uint) error {
-	return s.repo.Delete(id)
+func (s *TunnelService) DeleteTunnel(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "tunnel.delete", TargetType: "tunnel", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+	if tunnel, err := s.repo.FindByID(id); err == nil {
+		entry.TargetName = tunnel.Name
+		entry.Before = tunnel
+	}
+
+	if err := s.dnsService.DeleteTunnelRecord(id); err != nil {
+		log.Printf("%v", err)
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	return s.bandwidthRepo.DeleteByTunnel(id)
 }
 
 // GetUserTunnels 获取用户可用的隧道
@@ -289,10 +495,12 @@ func (s *TunnelService) GetUserTunnels(userID uint) ([]models.Tunnel, error) {
 }
 
 // AssignUserTunnel 分配用户隧道权限
-func (s *TunnelService) AssignUserTunnel(assignDto *dto.UserTunnelDto) error {
+func (s *TunnelService) AssignUserTunnel(actor Actor, assignDto *dto.UserTunnelDto) (err error) {
+	entry := &AuditEntry{Action: "user_tunnel.assign", TargetType: "user_tunnel"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	// 检查是否已经分配
-	_, err := s.userTunnelRepo.FindByUserAndTunnel(assignDto.UserID, assignDto.TunnelID)
-	if err == nil {
+	if _, err := s.userTunnelRepo.FindByUserAndTunnel(assignDto.UserID, assignDto.TunnelID); err == nil {
 		return errors.New("用户已拥有该隧道权限")
 	}
 
@@ -301,6 +509,7 @@ func (s *TunnelService) AssignUserTunnel(assignDto *dto.UserTunnelDto) error {
 	if err != nil {
 		return errors.New("用户不存在")
 	}
+	entry.TargetName = user.User
 
 	expTime := assignDto.ExpTime
 	if expTime == 0 {
@@ -326,10 +535,19 @@ func (s *TunnelService) AssignUserTunnel(assignDto *dto.UserTunnelDto) error {
 		Flow:          flow,
 		FlowResetTime: flowResetTime,
 		Num:           num,
+		ConnLimit:     assignDto.ConnLimit,
+		IPConnLimit:   assignDto.IPConnLimit,
+		ConnRate:      assignDto.ConnRate,
+		IPConnRate:    assignDto.IPConnRate,
 	}
 	userTunnel.Status = 1 // 默认启用
 
-	return s.userTunnelRepo.Create(userTunnel)
+	if err := s.userTunnelRepo.Create(userTunnel); err != nil {
+		return err
+	}
+	entry.TargetID = userTunnel.ID
+	entry.After = userTunnel
+	return nil
 }
 
 // GetUserTunnelList 获取用户隧道权限列表
@@ -377,6 +595,10 @@ func (s *TunnelService) GetUserTunnelList(queryDto *dto.UserTunnelQueryDto) ([]d
 			FlowResetTime: ut.FlowResetTime,
 			Num:           ut.Num,
 			SpeedID:       ut.SpeedID,
+			ConnLimit:     ut.ConnLimit,
+			IPConnLimit:   ut.IPConnLimit,
+			ConnRate:      ut.ConnRate,
+			IPConnRate:    ut.IPConnRate,
 		})
 	}
 
@@ -384,16 +606,27 @@ func (s *TunnelService) GetUserTunnelList(queryDto *dto.UserTunnelQueryDto) ([]d
 }
 
 // RemoveUserTunnel 移除用户隧道权限
-func (s *TunnelService) RemoveUserTunnel(id uint) error {
+func (s *TunnelService) RemoveUserTunnel(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "user_tunnel.remove", TargetType: "user_tunnel", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+	if userTunnel, err := s.userTunnelRepo.FindByID(id); err == nil {
+		entry.Before = userTunnel
+	}
+
 	return s.userTunnelRepo.Delete(id)
 }
 
 // UpdateUserTunnel 更新用户隧道权限
-func (s *TunnelService) UpdateUserTunnel(updateDto *dto.UserTunnelUpdateDto) error {
+func (s *TunnelService) UpdateUserTunnel(actor Actor, updateDto *dto.UserTunnelUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "user_tunnel.update", TargetType: "user_tunnel", TargetID: updateDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	userTunnel, err := s.userTunnelRepo.FindByID(updateDto.ID)
 	if err != nil {
 		return errors.New("用户隧道权限不存在")
 	}
+	before := *userTunnel
+	entry.Before = &before
 
 	if updateDto.ExpTime != nil {
 		userTunnel.ExpTime = *updateDto.ExpTime
@@ -408,7 +641,61 @@ func (s *TunnelService) UpdateUserTunnel(updateDto *dto.UserTunnelUpdateDto) err
 		userTunnel.Num = *updateDto.Num
 	}
 
-	return s.userTunnelRepo.Update(userTunnel)
+	limitChanged := false
+	if updateDto.ConnLimit != nil && *updateDto.ConnLimit != userTunnel.ConnLimit {
+		userTunnel.ConnLimit = *updateDto.ConnLimit
+		limitChanged = true
+	}
+	if updateDto.IPConnLimit != nil && *updateDto.IPConnLimit != userTunnel.IPConnLimit {
+		userTunnel.IPConnLimit = *updateDto.IPConnLimit
+		limitChanged = true
+	}
+	if updateDto.ConnRate != nil && *updateDto.ConnRate != userTunnel.ConnRate {
+		userTunnel.ConnRate = *updateDto.ConnRate
+		limitChanged = true
+	}
+	if updateDto.IPConnRate != nil && *updateDto.IPConnRate != userTunnel.IPConnRate {
+		userTunnel.IPConnRate = *updateDto.IPConnRate
+		limitChanged = true
+	}
+
+	if err := s.userTunnelRepo.Update(userTunnel); err != nil {
+		return err
+	}
+	entry.After = userTunnel
+
+	if limitChanged {
+		s.syncUserTunnelConnLimiters(userTunnel)
+	}
+	return nil
+}
+
+// syncUserTunnelConnLimiters 将连接数限制同步到该用户在隧道上的所有转发
+func (s *TunnelService) syncUserTunnelConnLimiters(userTunnel *models.UserTunnel) {
+	tunnel, err := s.repo.FindByID(userTunnel.TunnelID)
+	if err != nil {
+		return
+	}
+
+	forwards, err := s.forwardRepo.FindByTunnelID(userTunnel.TunnelID)
+	if err != nil {
+		return
+	}
+
+	for i := range forwards {
+		forward := &forwards[i]
+		if uint(forward.UserID) != userTunnel.UserID {
+			continue
+		}
+		p, err := s.locator.forwardPlacement(tunnel, forward)
+		if err != nil {
+			continue
+		}
+		serviceName := BuildServiceName(forward.ID, forward.UserID, userTunnel.ID)
+		for _, node := range p.in {
+			SyncConnLimiters(node.ID, serviceName, userTunnel)
+		}
+	}
 }
 
 // GetTunnelByID 根据ID获取隧道
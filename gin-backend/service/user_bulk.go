@@ -0,0 +1,238 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flux-panel/dto"
+	"flux-panel/models"
+	"flux-panel/utils"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// importedUser 批量导入中校验通过的用户及其授权的隧道
+type importedUser struct {
+	row     int
+	user    *models.User
+	tunnels []uint
+}
+
+// ImportUsers 批量导入用户及隧道权限，dryRun 为 true 或存在校验失败的行时只返回校验报告；
+// 校验通过后在一个事务中创建全部用户，隧道权限的流量、到期时间和转发数量沿用用户的配置。
+// 未提供密码的行（如导出的文件）生成初始密码，在导入结果中返回
+func (s *UserService) ImportUsers(actor Actor, rows []dto.BulkUserRow, dryRun bool) (*dto.BulkImportResultDto, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("导入内容为空")
+	}
+	if len(rows) > maxBulkRows {
+		return nil, fmt.Errorf("单次最多导入 %d 行", maxBulkRows)
+	}
+
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tunnelsByName := make(map[string][]uint)
+	for _, tunnel := range tunnels {
+		tunnelsByName[tunnel.Name] = append(tunnelsByName[tunnel.Name], tunnel.ID)
+	}
+
+	result := &dto.BulkImportResultDto{DryRun: dryRun, Total: len(rows), PushErrors: []string{}}
+	names := make(map[string]bool)
+	var items []*importedUser
+	for i, row := range rows {
+		name := strings.TrimSpace(row.User)
+		res := dto.BulkRowResultDto{Row: i + 1, Name: name, Errors: []string{}}
+		fail := func(format string, args ...interface{}) {
+			res.Errors = append(res.Errors, fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case name == "":
+			fail("用户名不能为空")
+		case names[name]:
+			fail("用户名 %s 与前面的行重复", name)
+		default:
+			// 用户名唯一，包含已禁用和已删除的用户
+			var count int64
+			s.db.Model(&models.User{}).Where("user = ?", name).Count(&count)
+			if count > 0 {
+				fail("用户 %s 已存在", name)
+			}
+		}
+		names[name] = true
+
+		status := 1
+		if row.Status != nil {
+			status = *row.Status
+		}
+		if status != 0 && status != 1 {
+			fail("状态只能为 0 (禁用) 或 1 (启用)")
+		}
+		if row.FlowResetTime < 0 || row.FlowResetTime > 31 {
+			fail("流量重置日需在 1-31 之间，0 表示不重置")
+		}
+		if row.Flow < 0 || row.Num < 0 {
+			fail("流量和转发数量不能为负数")
+		}
+
+		var tunnelIDs []uint
+		granted := make(map[uint]bool)
+		for _, tunnelName := range row.Tunnels {
+			tunnelName = strings.TrimSpace(tunnelName)
+			if tunnelName == "" {
+				continue
+			}
+			switch ids := tunnelsByName[tunnelName]; {
+			case len(ids) == 0:
+				fail("隧道 %s 不存在", tunnelName)
+			case len(ids) > 1:
+				fail("隧道名称 %s 不唯一", tunnelName)
+			case !granted[ids[0]]:
+				granted[ids[0]] = true
+				tunnelIDs = append(tunnelIDs, ids[0])
+			}
+		}
+
+		result.Rows = append(result.Rows, res)
+		if len(res.Errors) > 0 {
+			result.Invalid++
+			continue
+		}
+		items = append(items, &importedUser{
+			row: i + 1,
+			user: &models.User{
+				User:          name,
+				Pwd:           row.Pwd,
+				RoleID:        1, // 普通用户
+				ExpTime:       row.ExpTime,
+				Flow:          row.Flow,
+				Num:           row.Num,
+				FlowResetTime: row.FlowResetTime,
+				Status:        status,
+			},
+			tunnels: tunnelIDs,
+		})
+	}
+
+	if dryRun || result.Invalid > 0 {
+		return result, nil
+	}
+	if err := s.createImportedUsers(actor, items, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newImportPassword 为未提供密码的导入行生成初始密码
+func newImportPassword() string {
+	b := make([]byte, 9)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// createImportedUsers 在一个事务中创建全部用户与隧道权限
+func (s *UserService) createImportedUsers(actor Actor, items []*importedUser, result *dto.BulkImportResultDto) (err error) {
+	entry := &AuditEntry{Action: "user.import", TargetType: "user"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			user := item.user
+			if user.Pwd == "" {
+				user.Pwd = newImportPassword()
+				result.Rows[item.row-1].Pwd = user.Pwd
+			}
+			user.Pwd = utils.HashPassword(user.Pwd)
+			status := user.Status
+			if err := tx.Create(user).Error; err != nil {
+				return fmt.Errorf("第 %d 行创建失败: %v", item.row, err)
+			}
+			// 状态字段有默认值，创建时零值会被替换为启用
+			if status == 0 {
+				if err := tx.Model(user).Update("status", 0).Error; err != nil {
+					return fmt.Errorf("第 %d 行创建失败: %v", item.row, err)
+				}
+				user.Status = 0
+			}
+
+			for _, tunnelID := range item.tunnels {
+				userTunnel := &models.UserTunnel{
+					UserID:        user.ID,
+					TunnelID:      tunnelID,
+					ExpTime:       user.ExpTime,
+					Flow:          user.Flow,
+					FlowResetTime: user.FlowResetTime,
+					Num:           user.Num,
+				}
+				userTunnel.Status = 1 // 默认启用
+				if err := tx.Create(userTunnel).Error; err != nil {
+					return fmt.Errorf("第 %d 行分配隧道权限失败: %v", item.row, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		result.Rows[item.row-1].ID = item.user.ID
+		s.audit.Record(actor, &AuditEntry{
+			Action:     "user.create",
+			TargetType: "user",
+			TargetID:   item.user.ID,
+			TargetName: item.user.User,
+			After:      item.user,
+			Message:    "批量导入",
+		}, nil)
+	}
+	result.Created = len(items)
+	entry.Message = fmt.Sprintf("导入 %d 个用户", result.Created)
+	return nil
+}
+
+// ExportUsers 导出普通用户及其授权的隧道，格式与批量导入相同；不包含密码，重新导入时为各用户生成初始密码
+func (s *UserService) ExportUsers() ([]dto.BulkUserRow, error) {
+	users, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tunnels, err := s.tunnelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tunnelNames := make(map[uint]string, len(tunnels))
+	for _, tunnel := range tunnels {
+		tunnelNames[tunnel.ID] = tunnel.Name
+	}
+	userTunnels, err := s.userTunnelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	grants := make(map[uint][]string)
+	for _, ut := range userTunnels {
+		if name, ok := tunnelNames[ut.TunnelID]; ok {
+			grants[ut.UserID] = append(grants[ut.UserID], name)
+		}
+	}
+
+	rows := make([]dto.BulkUserRow, 0, len(users))
+	for _, user := range users {
+		status := user.Status
+		rows = append(rows, dto.BulkUserRow{
+			User:          user.User,
+			Flow:          user.Flow,
+			Num:           user.Num,
+			ExpTime:       user.ExpTime,
+			FlowResetTime: user.FlowResetTime,
+			Status:        &status,
+			Tunnels:       grants[user.ID],
+		})
+	}
+	return rows, nil
+}
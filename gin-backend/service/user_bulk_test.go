@@ -0,0 +1,65 @@
+package service
+
+import (
+	"flux-panel/models"
+	"flux-panel/utils"
+	"testing"
+)
+
+// TestExportedUsersReimport 导出的用户不含密码，重新导入时生成初始密码并在结果中返回
+func TestExportedUsersReimport(t *testing.T) {
+	db := newTestDB(t)
+	s := NewUserService(db)
+
+	tunnel := &models.Tunnel{Name: "tunnel", Type: 1}
+	tunnel.Status = 1
+	user := &models.User{User: "alice", Pwd: utils.HashPassword("secret"), RoleID: 1, Num: 5, Flow: 100}
+	for _, v := range []interface{}{tunnel, user} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.UserTunnel{UserID: user.ID, TunnelID: tunnel.ID, Num: 5}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.ExportUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Pwd != "" {
+		t.Fatalf("导出结果不符合预期: %+v", rows)
+	}
+
+	// 导入到没有该用户的面板
+	db.Unscoped().Where("1 = 1").Delete(&models.UserTunnel{})
+	db.Unscoped().Delete(user)
+
+	result, err := s.ImportUsers(Actor{Name: "admin"}, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Invalid != 0 || result.Created != 1 {
+		t.Fatalf("导入失败: %+v", result.Rows)
+	}
+	generated := result.Rows[0].Pwd
+	if generated == "" {
+		t.Fatal("未返回生成的初始密码")
+	}
+
+	imported, err := s.repo.FindByID(result.Rows[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.ComparePassword(imported.Pwd, generated) {
+		t.Error("生成的初始密码无法登录")
+	}
+	if imported.Num != 5 || imported.Flow != 100 {
+		t.Errorf("导入的用户配置不一致: %+v", imported)
+	}
+	var grants int64
+	db.Model(&models.UserTunnel{}).Where("user_id = ? AND tunnel_id = ?", imported.ID, tunnel.ID).Count(&grants)
+	if grants != 1 {
+		t.Errorf("隧道权限 %d 条，应为 1 条", grants)
+	}
+}
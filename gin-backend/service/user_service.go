@@ -11,6 +11,7 @@ import (
 )
 
 type UserService struct {
+	db             *gorm.DB
 	repo           *repository.UserRepository
 	configService  *ConfigService
 	userTunnelRepo *repository.UserTunnelRepository
@@ -18,10 +19,12 @@ type UserService struct {
 	statsRepo      *repository.StatisticsFlowRepository
 	tunnelRepo     *repository.TunnelRepository
 	nodeRepo       *repository.NodeRepository
+	audit          *AuditService
 }
 
 func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{
+		db:             db,
 		repo:           repository.NewUserRepository(db),
 		configService:  NewConfigService(db),
 		userTunnelRepo: repository.NewUserTunnelRepository(db),
@@ -29,6 +32,7 @@ func NewUserService(db *gorm.DB) *UserService {
 		statsRepo:      repository.NewStatisticsFlowRepository(db),
 		tunnelRepo:     repository.NewTunnelRepository(db),
 		nodeRepo:       repository.NewNodeRepository(db),
+		audit:          NewAuditService(db),
 	}
 }
 
@@ -100,10 +104,12 @@ func (s *UserService) Login(loginDto *dto.LoginDto, validateCaptcha func(string)
 }
 
 // CreateUser 创建用户
-func (s *UserService) CreateUser(userDto *dto.UserDto) error {
+func (s *UserService) CreateUser(actor Actor, userDto *dto.UserDto) (err error) {
+	entry := &AuditEntry{Action: "user.create", TargetType: "user", TargetName: userDto.User}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	// 检查用户是否存在
-	_, err := s.repo.FindByUsername(userDto.User)
-	if err == nil {
+	if _, err := s.repo.FindByUsername(userDto.User); err == nil {
 		return errors.New("用户已存在")
 	}
 
@@ -126,6 +132,8 @@ func (s *UserService) CreateUser(userDto *dto.UserDto) error {
 	if err := s.repo.Create(user); err != nil {
 		return err
 	}
+	entry.TargetID = user.ID
+	entry.After = user
 
 	// 分配隧道权限
 	if len(userDto.TunnelAssigns) > 0 {
@@ -184,11 +192,16 @@ func (s *UserService) GetAllUsers() ([]models.User, error) {
 }
 
 // UpdateUser 更新用户
-func (s *UserService) UpdateUser(updateDto *dto.UserUpdateDto) error {
+func (s *UserService) UpdateUser(actor Actor, updateDto *dto.UserUpdateDto) (err error) {
+	entry := &AuditEntry{Action: "user.update", TargetType: "user", TargetID: updateDto.ID, TargetName: updateDto.User}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	user, err := s.repo.FindByID(updateDto.ID)
 	if err != nil {
 		return errors.New("用户不存在")
 	}
+	before := *user
+	entry.Before = &before
 
 	// 不能修改管理员
 	if user.RoleID == 0 {
@@ -212,6 +225,7 @@ func (s *UserService) UpdateUser(updateDto *dto.UserUpdateDto) error {
 
 	if updateDto.Pwd != "" {
 		user.Pwd = utils.HashPassword(updateDto.Pwd)
+		entry.Message = "重置密码"
 	}
 	if updateDto.Status != nil {
 		user.Status = *updateDto.Status
@@ -220,6 +234,7 @@ func (s *UserService) UpdateUser(updateDto *dto.UserUpdateDto) error {
 	if err := s.repo.Update(user); err != nil {
 		return err
 	}
+	entry.After = user
 
 	// 更新隧道权限
 	if len(updateDto.TunnelAssigns) > 0 {
@@ -316,11 +331,16 @@ func (s *UserService) UpdateUser(updateDto *dto.UserUpdateDto) error {
 }
 
 // DeleteUser 删除用户
-func (s *UserService) DeleteUser(id uint) error {
+func (s *UserService) DeleteUser(actor Actor, id uint) (err error) {
+	entry := &AuditEntry{Action: "user.delete", TargetType: "user", TargetID: id}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	user, err := s.repo.FindByID(id)
 	if err != nil {
 		return errors.New("用户不存在")
 	}
+	entry.TargetName = user.User
+	entry.Before = user
 
 	// 不能删除管理员
 	if user.RoleID == 0 {
@@ -400,6 +420,9 @@ func (s *UserService) GetUserPackageInfo(userID uint) (*dto.UserDashboardRespons
 		if t, ok := tunnelMap[uint(f.TunnelID)]; ok {
 			tunnelName = t.Name
 			inIP = t.InIP
+			if t.Hostname != "" {
+				inIP = t.Hostname
+			}
 		}
 		dashboardForwards = append(dashboardForwards, dto.DashboardForwardDto{
 			ID:         f.ID,
@@ -435,11 +458,17 @@ func (s *UserService) GetUserPackageInfo(userID uint) (*dto.UserDashboardRespons
 }
 
 // UpdatePassword 修改密码
-func (s *UserService) UpdatePassword(userID uint, changeDto *dto.ChangePasswordDto) error {
+func (s *UserService) UpdatePassword(actor Actor, userID uint, changeDto *dto.ChangePasswordDto) (err error) {
+	entry := &AuditEntry{Action: "user.password", TargetType: "user", TargetID: userID, Message: "修改密码"}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	user, err := s.repo.FindByID(userID)
 	if err != nil {
 		return errors.New("用户不存在")
 	}
+	entry.TargetName = user.User
+	before := *user
+	entry.Before = &before
 
 	// 验证新密码和确认密码是否匹配
 	if changeDto.NewPassword != changeDto.ConfirmPassword {
@@ -462,25 +491,40 @@ func (s *UserService) UpdatePassword(userID uint, changeDto *dto.ChangePasswordD
 
 	// 更新密码
 	user.Pwd = utils.HashPassword(changeDto.NewPassword)
+	entry.After = user
 	return s.repo.Update(user)
 }
 
 // ResetFlow 重置流量
-func (s *UserService) ResetFlow(resetDto *dto.ResetFlowDto) error {
-	if resetDto.Type == 1 {
-		// 清零账号流量
-		return s.repo.ResetFlow(resetDto.ID)
+func (s *UserService) ResetFlow(actor Actor, resetDto *dto.ResetFlowDto) (err error) {
+	if resetDto.Type != 1 {
+		// Type == 2: 清零隧道流量 - 暂不实现
+		return nil
 	}
-	// Type == 2: 清零隧道流量 - 暂不实现
-	return nil
+
+	entry := &AuditEntry{Action: "user.flow_reset", TargetType: "user", TargetID: resetDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+	if user, err := s.repo.FindByID(resetDto.ID); err == nil {
+		entry.TargetName = user.User
+		entry.Before = map[string]int64{"inFlow": user.InFlow, "outFlow": user.OutFlow}
+		entry.After = map[string]int64{"inFlow": 0, "outFlow": 0}
+	}
+
+	// 清零账号流量
+	return s.repo.ResetFlow(resetDto.ID)
 }
 
 // ToggleUserStatus 切换用户状态
-func (s *UserService) ToggleUserStatus(toggleDto *dto.ToggleUserStatusDto) (*models.User, error) {
+func (s *UserService) ToggleUserStatus(actor Actor, toggleDto *dto.ToggleUserStatusDto) (_ *models.User, err error) {
+	entry := &AuditEntry{Action: "user.toggle_status", TargetType: "user", TargetID: toggleDto.ID}
+	defer func() { s.audit.Record(actor, entry, err) }()
+
 	user, err := s.repo.FindByID(toggleDto.ID)
 	if err != nil {
 		return nil, errors.New("用户不存在")
 	}
+	entry.TargetName = user.User
+	entry.Before = map[string]int{"status": user.Status}
 
 	// 不能修改管理员状态
 	if user.RoleID == 0 {
@@ -497,6 +541,7 @@ func (s *UserService) ToggleUserStatus(toggleDto *dto.ToggleUserStatusDto) (*mod
 	if err := s.repo.Update(user); err != nil {
 		return nil, err
 	}
+	entry.After = map[string]int{"status": user.Status}
 
 	// 清空密码后返回
 	user.Pwd = ""
@@ -1,8 +1,10 @@
 package task
 
 import (
+	"flux-panel/cluster"
 	"flux-panel/models"
 	"flux-panel/service"
+	"fmt"
 	"log"
 	"time"
 
@@ -15,28 +17,77 @@ var db *gorm.DB
 
 const bytesToGB = 1024 * 1024 * 1024
 
+// singletonLockTTL 定时任务锁的有效期，需小于任务的最短执行间隔并大于各实例间的时钟偏差
+const singletonLockTTL = 5 * time.Minute
+
 // InitScheduler 初始化定时任务
 func InitScheduler(database *gorm.DB) {
 	db = database
 	scheduler = cron.New(cron.WithSeconds())
 
 	// 每小时统计一次流量 (0 0 * * * *)
-	_, err := scheduler.AddFunc("0 0 * * * *", StatisticsFlow)
+	_, err := scheduler.AddFunc("0 0 * * * *", singleton("statistics_flow", StatisticsFlow))
 	if err != nil {
 		log.Printf("Failed to add statistics flow task: %v", err)
 	}
 
 	// 每天凌晨00:00:05检查并重置流量 (5 0 0 * * *)
-	_, err = scheduler.AddFunc("5 0 0 * * *", ResetFlowTask)
+	_, err = scheduler.AddFunc("5 0 0 * * *", singleton("reset_flow", ResetFlowTask))
 	if err != nil {
 		log.Printf("Failed to add reset flow task: %v", err)
 	}
 
+	// 每小时第30分钟清理过期的连接日志 (0 30 * * * *)
+	_, err = scheduler.AddFunc("0 30 * * * *", singleton("clean_access_logs", CleanAccessLogs))
+	if err != nil {
+		log.Printf("Failed to add clean access logs task: %v", err)
+	}
+
+	// 每小时第40分钟清理过期的节点遥测 (0 40 * * * *)
+	_, err = scheduler.AddFunc("0 40 * * * *", singleton("clean_node_telemetry", CleanNodeTelemetry))
+	if err != nil {
+		log.Printf("Failed to add clean node telemetry task: %v", err)
+	}
+
+	// 每小时第50分钟清理已结束的节点命令 (0 50 * * * *)
+	_, err = scheduler.AddFunc("0 50 * * * *", singleton("clean_node_commands", CleanNodeCommands))
+	if err != nil {
+		log.Printf("Failed to add clean node commands task: %v", err)
+	}
+
+	// 每10分钟同步一次入口域名的解析记录，补偿同步失败或面板离线期间的节点状态变化 (0 */10 * * * *)
+	_, err = scheduler.AddFunc("0 */10 * * * *", singleton("sync_dns_records", SyncDNSRecords))
+	if err != nil {
+		log.Printf("Failed to add sync dns records task: %v", err)
+	}
+
+	// 每10分钟处理一次密钥轮换宽限期已结束的节点 (0 5-59/10 * * * *)
+	_, err = scheduler.AddFunc("0 5-59/10 * * * *", singleton("expire_node_secrets", ExpireNodeSecrets))
+	if err != nil {
+		log.Printf("Failed to add expire node secrets task: %v", err)
+	}
+
+	// 每小时清理一次过期的审计日志 (0 20 * * * *)
+	_, err = scheduler.AddFunc("0 20 * * * *", singleton("clean_audit_logs", CleanAuditLogs))
+	if err != nil {
+		log.Printf("Failed to add clean audit logs task: %v", err)
+	}
+
 	// 启动调度器
 	scheduler.Start()
 	log.Println("Scheduler started")
 }
 
+// singleton 多实例部署时每次触发只由抢到锁的实例执行，锁在 singletonLockTTL 后自动释放
+func singleton(name string, fn func()) func() {
+	return func() {
+		if !cluster.TryLock("task:"+name, singletonLockTTL) {
+			return
+		}
+		fn()
+	}
+}
+
 // StopScheduler 停止定时任务
 func StopScheduler() {
 	if scheduler != nil {
@@ -160,19 +211,30 @@ func resetUserFlow(currentDay, lastDayOfMonth int) {
 
 	log.Printf("找到 %d 个需要重置流量的用户", len(users))
 
+	auditService := service.NewAuditService(db)
 	// 使用原子SQL更新避免并发冲突
 	for _, user := range users {
-		if err := db.Model(&models.User{}).
+		err := db.Model(&models.User{}).
 			Where("id = ?", user.ID).
 			Updates(map[string]interface{}{
 				"in_flow":  0,
 				"out_flow": 0,
-			}).Error; err != nil {
+			}).Error
+		if err != nil {
 			log.Printf("Failed to reset user %d flow: %v", user.ID, err)
 		} else {
 			log.Printf("用户[ID: %d, 用户名: %s]流量重置成功，重置日期: 每月%d号",
 				user.ID, user.User, user.FlowResetTime)
 		}
+		auditService.Record(service.SystemActor, &service.AuditEntry{
+			Action:     "user.flow_reset",
+			TargetType: "user",
+			TargetID:   user.ID,
+			TargetName: user.User,
+			Before:     map[string]int64{"inFlow": user.InFlow, "outFlow": user.OutFlow},
+			After:      map[string]int64{"inFlow": 0, "outFlow": 0},
+			Message:    fmt.Sprintf("每月%d号自动重置", user.FlowResetTime),
+		}, err)
 	}
 }
 
@@ -199,15 +261,25 @@ func resetUserTunnelFlow(currentDay, lastDayOfMonth int) {
 
 	log.Printf("找到 %d 个需要重置流量的用户隧道", len(userTunnels))
 
+	auditService := service.NewAuditService(db)
 	for _, ut := range userTunnels {
-		if err := db.Model(&models.UserTunnel{}).
+		err := db.Model(&models.UserTunnel{}).
 			Where("id = ?", ut.ID).
 			Updates(map[string]interface{}{
 				"in_flow":  0,
 				"out_flow": 0,
-			}).Error; err != nil {
+			}).Error
+		if err != nil {
 			log.Printf("Failed to reset user tunnel %d flow: %v", ut.ID, err)
 		}
+		auditService.Record(service.SystemActor, &service.AuditEntry{
+			Action:     "user_tunnel.flow_reset",
+			TargetType: "user_tunnel",
+			TargetID:   ut.ID,
+			Before:     map[string]int64{"inFlow": ut.InFlow, "outFlow": ut.OutFlow},
+			After:      map[string]int64{"inFlow": 0, "outFlow": 0},
+			Message:    fmt.Sprintf("每月%d号自动重置", ut.FlowResetTime),
+		}, err)
 	}
 }
 
@@ -222,28 +294,36 @@ func handleExpiredUsers() {
 		return
 	}
 
+	forwardService := service.NewForwardService(db)
+	auditService := service.NewAuditService(db)
 	for _, user := range users {
 		// 查找用户的活跃转发
 		var forwards []models.Forward
 		db.Where("user_id = ? AND status = 1", user.ID).Find(&forwards)
 
-		for _, forward := range forwards {
+		for i := range forwards {
 			// 获取用户隧道
 			var userTunnel models.UserTunnel
-			if err := db.Where("user_id = ? AND tunnel_id = ?", forward.UserID, forward.TunnelID).
+			if err := db.Where("user_id = ? AND tunnel_id = ?", forwards[i].UserID, forwards[i].TunnelID).
 				First(&userTunnel).Error; err != nil {
 				continue
 			}
 
-			// 暂停转发服务
-			pauseForwardService(&forward, userTunnel.ID)
-
-			// 更新转发状态
-			db.Model(&models.Forward{}).Where("id = ?", forward.ID).Update("status", 0)
+			// 暂停转发服务并更新转发状态
+			forwardService.AutoPauseForward(&forwards[i], "用户已到期")
 		}
 
 		// 更新用户状态
-		db.Model(&models.User{}).Where("id = ?", user.ID).Update("status", 0)
+		err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("status", 0).Error
+		auditService.Record(service.SystemActor, &service.AuditEntry{
+			Action:     "user.expire",
+			TargetType: "user",
+			TargetID:   user.ID,
+			TargetName: user.User,
+			Before:     map[string]int{"status": user.Status},
+			After:      map[string]int{"status": 0},
+			Message:    "用户已到期",
+		}, err)
 		log.Printf("用户 %s 已过期并被禁用", user.User)
 	}
 }
@@ -259,38 +339,60 @@ func handleExpiredUserTunnels() {
 		return
 	}
 
+	forwardService := service.NewForwardService(db)
+	auditService := service.NewAuditService(db)
 	for _, ut := range userTunnels {
 		// 查找相关的活跃转发
 		var forwards []models.Forward
 		db.Where("tunnel_id = ? AND user_id = ? AND status = 1", ut.TunnelID, ut.UserID).Find(&forwards)
 
-		for _, forward := range forwards {
-			// 暂停转发服务
-			pauseForwardService(&forward, ut.ID)
-
-			// 更新转发状态
-			db.Model(&models.Forward{}).Where("id = ?", forward.ID).Update("status", 0)
+		// 暂停转发服务并更新转发状态，节点离线时暂停命令进入队列，节点上线后下发
+		for i := range forwards {
+			forwardService.AutoPauseForward(&forwards[i], "隧道权限已到期")
 		}
 
 		// 更新隧道状态
-		db.Model(&models.UserTunnel{}).Where("id = ?", ut.ID).Update("status", 0)
+		err := db.Model(&models.UserTunnel{}).Where("id = ?", ut.ID).Update("status", 0).Error
+		auditService.Record(service.SystemActor, &service.AuditEntry{
+			Action:     "user_tunnel.expire",
+			TargetType: "user_tunnel",
+			TargetID:   ut.ID,
+			Before:     map[string]int{"status": ut.Status},
+			After:      map[string]int{"status": 0},
+			Message:    "隧道权限已到期",
+		}, err)
 		log.Printf("用户隧道 %d 已过期并被禁用", ut.ID)
 	}
 }
 
-// pauseForwardService 暂停转发服务
-func pauseForwardService(forward *models.Forward, userTunnelID uint) {
-	var tunnel models.Tunnel
-	if err := db.First(&tunnel, forward.TunnelID).Error; err != nil {
-		return
-	}
+// CleanAccessLogs 清理过期的连接日志 (每小时执行)
+func CleanAccessLogs() {
+	service.NewAccessLogService(db).CleanExpired()
+}
+
+// CleanAuditLogs 清理过期的审计日志 (每小时执行)
+func CleanAuditLogs() {
+	service.NewAuditService(db).CleanExpired()
+}
+
+// CleanNodeTelemetry 清理过期的节点遥测 (每小时执行)
+func CleanNodeTelemetry() {
+	service.NewTelemetryService(db).CleanExpired()
+}
 
-	serviceName := service.BuildServiceName(forward.ID, forward.UserID, userTunnelID)
-	log.Printf("Setting %s to disabled due to traffic limit exceeded", serviceName)
+// CleanNodeCommands 清理已结束的节点命令 (每小时执行)
+func CleanNodeCommands() {
+	service.NewNodeCommandService(db).CleanExpired()
+}
 
-	if tunnel.Type == 1 {
-		service.PauseService(tunnel.OutNodeID, serviceName)
-	} else if tunnel.Type == 2 {
-		service.PauseRemoteService(tunnel.OutNodeID, serviceName)
+// SyncDNSRecords 同步隧道入口域名的解析记录 (每10分钟执行)
+func SyncDNSRecords() {
+	if err := service.NewDNSService(db).SyncAll(false); err != nil {
+		log.Printf("同步入口域名解析记录失败: %v", err)
 	}
 }
+
+// ExpireNodeSecrets 使密钥轮换宽限期已结束的旧密钥失效 (每10分钟执行)
+func ExpireNodeSecrets() {
+	service.NewNodeSecretService(db).ExpireSecrets()
+}
@@ -41,6 +41,16 @@ func NewAESCrypto(secret string) (*AESCrypto, error) {
 	}, nil
 }
 
+// NewAESCryptoFromKey 使用已派生的 32 字节密钥创建加密器（如会话密钥）
+func NewAESCryptoFromKey(key []byte) (*AESCrypto, error) {
+	if len(key) != 32 {
+		return nil, errors.New("密钥长度必须为32字节")
+	}
+	return &AESCrypto{
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
 // GetOrCreateCrypto 获取或创建加密器实例（带缓存）
 func GetOrCreateCrypto(secret string) (*AESCrypto, error) {
 	if secret == "" {
@@ -76,6 +86,11 @@ func GetOrCreateCrypto(secret string) (*AESCrypto, error) {
 // Encrypt 加密数据
 // 返回: Base64(IV + ciphertext)
 func (c *AESCrypto) Encrypt(plaintext []byte) (string, error) {
+	return c.EncryptWithAAD(plaintext, nil)
+}
+
+// EncryptWithAAD 加密数据，aad 作为附加认证数据参与完整性校验但不加密
+func (c *AESCrypto) EncryptWithAAD(plaintext, aad []byte) (string, error) {
 	if len(plaintext) == 0 {
 		return "", errors.New("待加密数据不能为空")
 	}
@@ -97,7 +112,7 @@ func (c *AESCrypto) Encrypt(plaintext []byte) (string, error) {
 	}
 
 	// 加密数据
-	ciphertext := gcm.Seal(nil, iv, plaintext, nil)
+	ciphertext := gcm.Seal(nil, iv, plaintext, aad)
 
 	// 组合 IV + 密文
 	result := make([]byte, len(iv)+len(ciphertext))
@@ -116,6 +131,11 @@ func (c *AESCrypto) EncryptString(plaintext string) (string, error) {
 // Decrypt 解密数据
 // encryptedData: Base64(IV + ciphertext)
 func (c *AESCrypto) Decrypt(encryptedData string) ([]byte, error) {
+	return c.DecryptWithAAD(encryptedData, nil)
+}
+
+// DecryptWithAAD 解密数据并校验附加认证数据
+func (c *AESCrypto) DecryptWithAAD(encryptedData string, aad []byte) ([]byte, error) {
 	if encryptedData == "" {
 		return nil, errors.New("加密数据不能为空")
 	}
@@ -145,7 +165,7 @@ func (c *AESCrypto) Decrypt(encryptedData string) ([]byte, error) {
 	ciphertext := encrypted[gcmIVLength:]
 
 	// 解密
-	plaintext, err := gcm.Open(nil, iv, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, iv, ciphertext, aad)
 	if err != nil {
 		return nil, errors.New("解密失败: " + err.Error())
 	}
@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flux-panel/cluster"
+	"flux-panel/config"
+	"flux-panel/models"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EnvelopeVersion 带 nonce 和时间戳签名的加密信封版本
+const EnvelopeVersion = 2
+
+// SessionNonceHeader 节点握手时双方交换 nonce 的请求/响应头，用于派生会话密钥
+const SessionNonceHeader = "X-Session-Nonce"
+
+const defaultReplayWindow = 5 * time.Minute
+
+var (
+	ErrReplay         = errors.New("重复的消息nonce，疑似重放")
+	ErrExpired        = errors.New("消息时间戳超出允许范围")
+	ErrLegacyEnvelope = errors.New("消息未携带签名的nonce和时间戳")
+)
+
+// Envelope 加密消息信封
+// v2 信封的 nonce 和 timestamp 作为 AES-GCM 的附加认证数据，篡改任一字段都会导致解密失败
+type Envelope struct {
+	Encrypted bool   `json:"encrypted"`
+	Version   int    `json:"v,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	Data      string `json:"data"`
+}
+
+// NewNonce 生成随机 nonce（16字节十六进制）
+func NewNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func envelopeAAD(timestamp int64, nonce string) []byte {
+	return []byte(strconv.FormatInt(timestamp, 10) + "|" + nonce)
+}
+
+// Seal 加密数据并封装为 v2 信封
+func (c *AESCrypto) Seal(data []byte) ([]byte, error) {
+	env := Envelope{
+		Encrypted: true,
+		Version:   EnvelopeVersion,
+		Nonce:     NewNonce(),
+		Timestamp: time.Now().Unix(),
+	}
+	encrypted, err := c.EncryptWithAAD(data, envelopeAAD(env.Timestamp, env.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	env.Data = encrypted
+	return json.Marshal(env)
+}
+
+// Open 校验并解密 v2 信封，拒绝超出时间窗口或重复的 nonce
+func (c *AESCrypto) Open(env *Envelope, guard *ReplayGuard) ([]byte, error) {
+	if env.Version < EnvelopeVersion || env.Nonce == "" {
+		return nil, ErrLegacyEnvelope
+	}
+	if !guard.inWindow(env.Timestamp) {
+		return nil, ErrExpired
+	}
+
+	data, err := c.DecryptWithAAD(env.Data, envelopeAAD(env.Timestamp, env.Nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	// 解密成功后再登记 nonce，避免伪造消息污染缓存
+	if err := guard.Check(env.Nonce, env.Timestamp); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DeriveSessionKey 由节点密钥和握手双方的 nonce 派生单次连接的会话密钥
+func DeriveSessionKey(secret, clientNonce, serverNonce string) []byte {
+	base := sha256.Sum256([]byte(secret))
+	mac := hmac.New(sha256.New, base[:])
+	mac.Write([]byte("flux-session|" + clientNonce + "|" + serverNonce))
+	return mac.Sum(nil)
+}
+
+// ReplayGuard 记录时间窗口内已使用的 nonce
+// scope 不为空时 nonce 登记在集群总线中，多实例部署时消息无法重放到其他实例
+type ReplayGuard struct {
+	window    time.Duration
+	scope     string
+	seen      map[string]int64
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+// NewReplayGuard 创建重放检测器，时间窗口取自配置
+func NewReplayGuard() *ReplayGuard {
+	window := defaultReplayWindow
+	if config.AppConfig != nil && config.AppConfig.Security.ReplayWindow > 0 {
+		window = time.Duration(config.AppConfig.Security.ReplayWindow) * time.Second
+	}
+	return &ReplayGuard{
+		window: window,
+		seen:   make(map[string]int64),
+	}
+}
+
+// NewSharedReplayGuard 创建在集群内共享 nonce 记录的重放检测器，scope 区分不同的密钥
+func NewSharedReplayGuard(scope string) *ReplayGuard {
+	guard := NewReplayGuard()
+	guard.scope = scope
+	return guard
+}
+
+func (g *ReplayGuard) inWindow(timestamp int64) bool {
+	diff := time.Since(time.Unix(timestamp, 0))
+	return diff <= g.window && diff >= -g.window
+}
+
+// Check 检查时间戳是否在窗口内且 nonce 未被使用过，通过后登记该 nonce
+func (g *ReplayGuard) Check(nonce string, timestamp int64) error {
+	if !g.inWindow(timestamp) {
+		return ErrExpired
+	}
+
+	if g.scope != "" {
+		// nonce 只需保留到时间戳离开窗口为止
+		ttl := time.Until(time.Unix(timestamp, 0).Add(g.window)) + time.Second
+		ok, err := cluster.Get().SetNX("replay:"+g.scope+":"+nonce, nil, ttl)
+		if err == nil {
+			if !ok {
+				return ErrReplay
+			}
+			return nil
+		}
+		log.Printf("登记消息nonce失败，使用本实例记录: %v", err)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(g.lastPrune) > g.window {
+		cutoff := now.Add(-g.window).Unix()
+		for n, ts := range g.seen {
+			if ts < cutoff {
+				delete(g.seen, n)
+			}
+		}
+		g.lastPrune = now
+	}
+
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplay
+	}
+	g.seen[nonce] = timestamp
+	return nil
+}
+
+// EnvelopeStats 节点消息校验统计
+type EnvelopeStats struct {
+	Accepted int64 `json:"accepted"` // 通过校验的签名信封
+	Legacy   int64 `json:"legacy"`   // 接受的旧格式消息
+	Replayed int64 `json:"replayed"` // 拒绝的重放消息
+	Expired  int64 `json:"expired"`  // 拒绝的过期消息
+	Unsigned int64 `json:"unsigned"` // 拒绝的未签名消息
+	Invalid  int64 `json:"invalid"`  // 解密失败的消息
+}
+
+var (
+	envelopeStats = make(map[uint]*EnvelopeStats)
+	signedNodes   = make(map[uint]bool)
+	nodeGuards    = make(map[uint]*ReplayGuard)
+	envelopeMutex sync.Mutex
+)
+
+// NodeReplayGuard 获取使用节点密钥加密的消息共用的重放检测器，nonce 记录在集群内共享
+func NodeReplayGuard(nodeID uint) *ReplayGuard {
+	envelopeMutex.Lock()
+	defer envelopeMutex.Unlock()
+
+	guard, ok := nodeGuards[nodeID]
+	if !ok {
+		guard = NewSharedReplayGuard(fmt.Sprintf("node:%d", nodeID))
+		nodeGuards[nodeID] = guard
+	}
+	return guard
+}
+
+// LegacyEnvelopeAllowed 节点是否仍可使用旧格式消息
+// 配置强制签名，或节点已发送过签名信封（说明已升级）时不再接受旧格式
+// 签名标记保存在节点表中，面板重启或由其他实例处理请求时同样生效
+func LegacyEnvelopeAllowed(nodeID uint) bool {
+	if config.AppConfig != nil && config.AppConfig.Security.RequireSignedEnvelope {
+		return false
+	}
+	envelopeMutex.Lock()
+	signed := signedNodes[nodeID]
+	envelopeMutex.Unlock()
+	if signed || models.DB == nil {
+		return !signed
+	}
+
+	var node models.Node
+	if err := models.DB.Select("signed_envelope").First(&node, nodeID).Error; err != nil {
+		return true
+	}
+	if node.SignedEnvelope {
+		envelopeMutex.Lock()
+		signedNodes[nodeID] = true
+		envelopeMutex.Unlock()
+	}
+	return !node.SignedEnvelope
+}
+
+// markNodeSigned 持久化节点的签名标记，标记只会从 false 变为 true
+func markNodeSigned(nodeID uint) {
+	if models.DB == nil {
+		return
+	}
+	if err := models.DB.Model(&models.Node{}).Where("id = ? AND signed_envelope = ?", nodeID, false).
+		Update("signed_envelope", true).Error; err != nil {
+		log.Printf("保存节点 %d 签名标记失败: %v", nodeID, err)
+	}
+}
+
+// OpenNodeEnvelope 解析节点发送的数据：签名信封校验签名和重放，旧格式仅在 allowLegacy 时接受
+func OpenNodeEnvelope(nodeID uint, crypto *AESCrypto, guard *ReplayGuard, rawData []byte, allowLegacy bool) ([]byte, error) {
+	var env Envelope
+	isEnvelope := json.Unmarshal(rawData, &env) == nil && env.Encrypted && env.Data != ""
+
+	if isEnvelope && env.Version >= EnvelopeVersion {
+		data, err := crypto.Open(&env, guard)
+		recordEnvelope(nodeID, err, false)
+		return data, err
+	}
+
+	if !allowLegacy {
+		recordEnvelope(nodeID, ErrLegacyEnvelope, false)
+		return nil, ErrLegacyEnvelope
+	}
+
+	if !isEnvelope {
+		recordEnvelope(nodeID, nil, true)
+		return rawData, nil
+	}
+	data, err := crypto.Decrypt(env.Data)
+	recordEnvelope(nodeID, err, true)
+	return data, err
+}
+
+// recordEnvelope 记录校验结果，拒绝重放类消息时输出日志
+func recordEnvelope(nodeID uint, err error, legacy bool) {
+	envelopeMutex.Lock()
+	defer envelopeMutex.Unlock()
+
+	stats, ok := envelopeStats[nodeID]
+	if !ok {
+		stats = &EnvelopeStats{}
+		envelopeStats[nodeID] = stats
+	}
+
+	switch {
+	case err == nil && legacy:
+		stats.Legacy++
+	case err == nil:
+		stats.Accepted++
+		if !signedNodes[nodeID] {
+			signedNodes[nodeID] = true
+			go markNodeSigned(nodeID)
+		}
+	case errors.Is(err, ErrReplay):
+		stats.Replayed++
+	case errors.Is(err, ErrExpired):
+		stats.Expired++
+	case errors.Is(err, ErrLegacyEnvelope):
+		stats.Unsigned++
+	default:
+		stats.Invalid++
+	}
+
+	if err != nil {
+		log.Printf("节点 %d 消息被拒绝: %v", nodeID, err)
+	}
+}
+
+// GetEnvelopeStats 获取各节点的消息校验统计
+func GetEnvelopeStats() map[uint]EnvelopeStats {
+	envelopeMutex.Lock()
+	defer envelopeMutex.Unlock()
+
+	result := make(map[uint]EnvelopeStats, len(envelopeStats))
+	for nodeID, stats := range envelopeStats {
+		result[nodeID] = *stats
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"flux-panel/config"
+	"flux-panel/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestCrypto(t *testing.T) *AESCrypto {
+	t.Helper()
+	crypto, err := NewAESCrypto("envelope-test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto
+}
+
+// sealAt 以指定的时间戳和 nonce 封装签名信封
+func sealAt(t *testing.T, crypto *AESCrypto, data string, timestamp int64, nonce string) *Envelope {
+	t.Helper()
+	encrypted, err := crypto.EncryptWithAAD([]byte(data), envelopeAAD(timestamp, nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Envelope{Encrypted: true, Version: EnvelopeVersion, Nonce: nonce, Timestamp: timestamp, Data: encrypted}
+}
+
+func TestOpenRoundTrip(t *testing.T) {
+	crypto := newTestCrypto(t)
+	sealed, err := crypto.Seal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env Envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatal(err)
+	}
+	data, err := crypto.Open(&env, NewReplayGuard())
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("解密结果 %q, %v", data, err)
+	}
+}
+
+// TestOpenRejectsTamperedHeader nonce 和时间戳是附加认证数据，修改任一字段都无法解密，且不会登记 nonce
+func TestOpenRejectsTamperedHeader(t *testing.T) {
+	crypto := newTestCrypto(t)
+	now := time.Now().Unix()
+
+	for _, tc := range []struct {
+		name   string
+		tamper func(env *Envelope)
+	}{
+		{"修改时间戳", func(env *Envelope) { env.Timestamp-- }},
+		{"修改nonce", func(env *Envelope) { env.Nonce = NewNonce() }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			guard := NewReplayGuard()
+			env := sealAt(t, crypto, "payload", now, NewNonce())
+			forged := *env
+			tc.tamper(&forged)
+
+			_, err := crypto.Open(&forged, guard)
+			if err == nil || errors.Is(err, ErrReplay) || errors.Is(err, ErrExpired) {
+				t.Fatalf("篡改后的信封应解密失败，实际: %v", err)
+			}
+			if _, err := crypto.Open(env, guard); err != nil {
+				t.Fatalf("伪造消息不应影响原消息: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsExpired(t *testing.T) {
+	crypto := newTestCrypto(t)
+	guard := NewReplayGuard()
+
+	for _, offset := range []time.Duration{-defaultReplayWindow - time.Minute, defaultReplayWindow + time.Minute} {
+		env := sealAt(t, crypto, "payload", time.Now().Add(offset).Unix(), NewNonce())
+		if _, err := crypto.Open(env, guard); !errors.Is(err, ErrExpired) {
+			t.Errorf("时间偏移 %v 时应返回 ErrExpired，实际: %v", offset, err)
+		}
+	}
+}
+
+func TestOpenRejectsReplay(t *testing.T) {
+	crypto := newTestCrypto(t)
+	env := sealAt(t, crypto, "payload", time.Now().Unix(), NewNonce())
+
+	guard := NewReplayGuard()
+	if _, err := crypto.Open(env, guard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crypto.Open(env, guard); !errors.Is(err, ErrReplay) {
+		t.Errorf("重复的 nonce 应返回 ErrReplay，实际: %v", err)
+	}
+
+	// 共享检测器的 nonce 记录在集群总线中，重放到同一 scope 的其他检测器同样被拒绝
+	shared := sealAt(t, crypto, "payload", time.Now().Unix(), NewNonce())
+	if _, err := crypto.Open(shared, NewSharedReplayGuard("test")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crypto.Open(shared, NewSharedReplayGuard("test")); !errors.Is(err, ErrReplay) {
+		t.Errorf("重放到其他实例应返回 ErrReplay，实际: %v", err)
+	}
+}
+
+// TestLegacyEnvelopeRefused 节点发送过签名信封或配置强制签名后不再接受旧格式消息
+func TestLegacyEnvelopeRefused(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "flux.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.DB = db
+	t.Cleanup(func() { models.CloseDB() })
+	if err := models.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig = &config.Config{}
+	// 节点ID在每个测试库中从 1 开始，清除之前记录的签名标记
+	envelopeMutex.Lock()
+	signedNodes = make(map[uint]bool)
+	envelopeMutex.Unlock()
+
+	crypto := newTestCrypto(t)
+	node := &models.Node{Name: "node", Secret: "envelope-test-secret", ServerIP: "127.0.0.1", PortSta: 20000, PortEnd: 20100}
+	if err := db.Create(node).Error; err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := crypto.Encrypt([]byte("legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := json.Marshal(Envelope{Encrypted: true, Timestamp: time.Now().Unix(), Data: encrypted})
+
+	if !LegacyEnvelopeAllowed(node.ID) {
+		t.Fatal("未发送过签名信封的节点应允许旧格式")
+	}
+	if _, err := OpenNodeEnvelope(node.ID, crypto, NewReplayGuard(), legacy, true); err != nil {
+		t.Fatalf("允许时旧格式应解密成功: %v", err)
+	}
+
+	sealed, _ := crypto.Seal([]byte("signed"))
+	if _, err := OpenNodeEnvelope(node.ID, crypto, NewReplayGuard(), sealed, LegacyEnvelopeAllowed(node.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if LegacyEnvelopeAllowed(node.ID) {
+		t.Error("节点发送签名信封后仍允许旧格式")
+	}
+	if _, err := OpenNodeEnvelope(node.ID, crypto, NewReplayGuard(), legacy, LegacyEnvelopeAllowed(node.ID)); !errors.Is(err, ErrLegacyEnvelope) {
+		t.Errorf("旧格式消息应返回 ErrLegacyEnvelope，实际: %v", err)
+	}
+
+	// 签名标记写入节点表，面板重启后同样生效
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var stored models.Node
+		db.First(&stored, node.ID)
+		if stored.SignedEnvelope {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("签名标记未写入节点表")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	restarted := &models.Node{Name: "restarted", Secret: "other", ServerIP: "127.0.0.2", PortSta: 20000, PortEnd: 20100, SignedEnvelope: true}
+	if err := db.Create(restarted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if LegacyEnvelopeAllowed(restarted.ID) {
+		t.Error("节点表中已标记签名的节点仍允许旧格式")
+	}
+
+	config.AppConfig.Security.RequireSignedEnvelope = true
+	other := &models.Node{Name: "other", Secret: "another", ServerIP: "127.0.0.3", PortSta: 20000, PortEnd: 20100}
+	if err := db.Create(other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if LegacyEnvelopeAllowed(other.ID) {
+		t.Error("配置强制签名时仍允许旧格式")
+	}
+}
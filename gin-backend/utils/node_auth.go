@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NodeSecretHeader 节点通过该请求头携带密钥，避免密钥出现在URL中
+const NodeSecretHeader = "X-Node-Secret"
+
+// NodeSecret 获取节点请求携带的密钥，优先读取请求头，兼容旧版本节点的 secret 查询参数
+func NodeSecret(c *gin.Context) string {
+	if secret := c.GetHeader(NodeSecretHeader); secret != "" {
+		return secret
+	}
+	return c.Query("secret")
+}
+
+// NodeBySecret 按节点携带的密钥查询节点，密钥轮换的宽限期内轮换前的密钥仍然有效
+func NodeBySecret(secret string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("secret = ? OR (prev_secret = ? AND prev_secret_expire > ?)", secret, secret, time.Now().UnixMilli())
+	}
+}
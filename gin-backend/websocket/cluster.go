@@ -0,0 +1,316 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"flux-panel/cluster"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 多实例部署时节点的 WebSocket 只连接到其中一个面板实例，
+// 节点归属登记在总线中，其他实例发往该节点的命令经总线转交给持有连接的实例执行
+
+const (
+	nodeOwnerTTL         = 90 * time.Second
+	nodeOwnerRefresh     = 30 * time.Second
+	remoteRequestTimeout = 40 * time.Second // 大于节点连接上发送和等待响应的总超时
+
+	eventsChannel = "events" // 所有实例共同订阅的频道
+)
+
+// 总线消息类型
+const (
+	clusterRequest     = "request"      // 请求持有连接的实例向节点发送命令
+	clusterResponse    = "response"     // 命令执行结果
+	clusterLogs        = "logs"         // 节点推送的实时日志，转交给日志会话所在实例
+	clusterUsers       = "users"        // 推送给浏览器的消息
+	clusterNodeOffline = "node-offline" // 节点断开
+)
+
+// clusterMessage 实例间传递的消息
+type clusterMessage struct {
+	Kind    string          `json:"kind"`
+	ID      string          `json:"id,omitempty"`
+	From    string          `json:"from,omitempty"`
+	NodeID  uint            `json:"nodeId,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Success bool            `json:"success,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Timeout bool            `json:"timeout,omitempty"` // 节点未在期限内响应
+}
+
+// nodeOwner 节点归属登记，同时保存节点能力供其他实例检查命令支持情况
+type nodeOwner struct {
+	Instance string `json:"instance"`
+	Protocol int    `json:"protocol"`
+	Commands string `json:"commands,omitempty"`
+	Features string `json:"features,omitempty"`
+}
+
+var (
+	clusterStarted atomic.Bool
+	remotePending  sync.Map // 请求 ID -> chan *clusterMessage
+)
+
+// StartCluster 订阅总线消息并定期续期本实例持有的节点连接，需在 cluster.Init 之后调用
+func StartCluster() error {
+	bus := cluster.Get()
+	if err := bus.Subscribe(instanceChannel(cluster.InstanceID()), handleInstanceMessage); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(eventsChannel, handleClusterEvent); err != nil {
+		return err
+	}
+	clusterStarted.Store(true)
+	go refreshNodeOwners()
+	return nil
+}
+
+func instanceChannel(instance string) string {
+	return "instance:" + instance
+}
+
+func nodeOwnerKey(nodeID uint) string {
+	return fmt.Sprintf("node:%d", nodeID)
+}
+
+// registerNode 登记本实例持有节点连接，返回写入的登记内容
+func registerNode(nodeID uint, caps *Capabilities) []byte {
+	owner := nodeOwner{Instance: cluster.InstanceID()}
+	if caps != nil {
+		owner.Protocol = caps.Protocol
+		owner.Commands = joinKeys(caps.Commands)
+		owner.Features = joinKeys(caps.Features)
+	}
+	data, _ := json.Marshal(owner)
+	if err := cluster.Get().Set(nodeOwnerKey(nodeID), data, nodeOwnerTTL); err != nil {
+		log.Printf("登记节点 %d 归属失败: %v", nodeID, err)
+	}
+	return data
+}
+
+// unregisterNode 节点断开时注销登记，节点已重连到其他实例时保留对方的登记
+func unregisterNode(nodeID uint, registration []byte) {
+	if err := cluster.Get().DeleteIf(nodeOwnerKey(nodeID), registration); err != nil {
+		log.Printf("注销节点 %d 归属失败: %v", nodeID, err)
+	}
+}
+
+// lookupNodeOwner 查询持有节点连接的实例
+func lookupNodeOwner(nodeID uint) (*nodeOwner, bool) {
+	data, err := cluster.Get().Get(nodeOwnerKey(nodeID))
+	if err != nil {
+		if !errors.Is(err, cluster.ErrNotFound) {
+			log.Printf("查询节点 %d 归属失败: %v", nodeID, err)
+		}
+		return nil, false
+	}
+	var owner nodeOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, false
+	}
+	return &owner, true
+}
+
+// remoteNodeOwner 返回持有节点连接的其他实例，节点未连接或由本实例持有时返回 false
+func remoteNodeOwner(nodeID uint) (*nodeOwner, bool) {
+	owner, ok := lookupNodeOwner(nodeID)
+	if !ok || owner.Instance == cluster.InstanceID() {
+		return nil, false
+	}
+	return owner, true
+}
+
+func (o *nodeOwner) capabilities() *Capabilities {
+	return ParseCapabilities(o.Protocol, o.Commands, o.Features)
+}
+
+// refreshNodeOwners 定期续期本实例持有的节点登记，登记已被其他实例取代时断开本地的旧连接
+func refreshNodeOwners() {
+	ticker := time.NewTicker(nodeOwnerRefresh)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s := GetServer()
+		s.mutex.RLock()
+		conns := make([]*NodeConnection, 0, len(s.connections))
+		for _, nc := range s.connections {
+			conns = append(conns, nc)
+		}
+		s.mutex.RUnlock()
+
+		bus := cluster.Get()
+		for _, nc := range conns {
+			ok, err := bus.Refresh(nodeOwnerKey(nc.NodeID), nc.registration, nodeOwnerTTL)
+			if err != nil {
+				log.Printf("续期节点 %d 归属失败: %v", nc.NodeID, err)
+				continue
+			}
+			if ok {
+				continue
+			}
+			if _, taken := remoteNodeOwner(nc.NodeID); taken {
+				log.Printf("节点 %d 已连接到其他实例，断开本实例的旧连接", nc.NodeID)
+				s.RemoveConnection(nc.NodeID)
+				continue
+			}
+			// 登记已过期（如实例长时间停顿），重新登记，内容与原登记相同
+			registerNode(nc.NodeID, nc.Capabilities)
+		}
+	}
+}
+
+// sendRemote 将命令转交给持有节点连接的实例执行并等待结果
+func (s *Server) sendRemote(nodeID uint, data interface{}, msgType string) (*Response, error) {
+	owner, ok := remoteNodeOwner(nodeID)
+	if !ok || !clusterStarted.Load() {
+		return nil, errors.New("节点未连接")
+	}
+	if !owner.capabilities().Supports(msgType) {
+		return nil, fmt.Errorf("节点版本过低，不支持 %s 命令，请升级节点", msgType)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req := clusterMessage{
+		Kind:   clusterRequest,
+		ID:     uuid.NewString(),
+		From:   cluster.InstanceID(),
+		NodeID: nodeID,
+		Type:   msgType,
+		Data:   raw,
+	}
+
+	respChan := make(chan *clusterMessage, 1)
+	remotePending.Store(req.ID, respChan)
+	defer remotePending.Delete(req.ID)
+
+	if err := publishCluster(instanceChannel(owner.Instance), req); err != nil {
+		return nil, fmt.Errorf("转发命令失败: %v", err)
+	}
+
+	select {
+	case resp := <-respChan:
+		if resp.Timeout {
+			return nil, ErrResponseTimeout
+		}
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		var respData interface{}
+		if len(resp.Data) > 0 {
+			json.Unmarshal(resp.Data, &respData)
+		}
+		return &Response{Success: resp.Success, Message: resp.Message, Data: respData}, nil
+	case <-time.After(remoteRequestTimeout):
+		return nil, ErrResponseTimeout
+	}
+}
+
+// handleInstanceMessage 处理发给本实例的消息
+func handleInstanceMessage(payload []byte) {
+	var msg clusterMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	switch msg.Kind {
+	case clusterRequest:
+		go handleRemoteRequest(&msg)
+	case clusterResponse:
+		if ch, ok := remotePending.Load(msg.ID); ok {
+			select {
+			case ch.(chan *clusterMessage) <- &msg:
+			default:
+			}
+		}
+	case clusterLogs:
+		deliverLocalLogs(msg.NodeID, msg.Data)
+	}
+}
+
+// handleRemoteRequest 代其他实例向本实例持有的节点发送命令，并回传结果
+func handleRemoteRequest(req *clusterMessage) {
+	resp := clusterMessage{Kind: clusterResponse, ID: req.ID}
+
+	nc := GetServer().GetConnection(req.NodeID)
+	if nc == nil {
+		resp.Error = "节点未连接"
+	} else {
+		var data interface{}
+		if len(req.Data) > 0 && string(req.Data) != "null" {
+			data = req.Data
+		}
+		result, err := nc.SendMessage(data, req.Type)
+		if err != nil {
+			resp.Error = err.Error()
+			resp.Timeout = errors.Is(err, ErrResponseTimeout)
+		} else {
+			resp.Success = result.Success
+			resp.Message = result.Message
+			resp.Data, _ = json.Marshal(result.Data)
+		}
+	}
+
+	if err := publishCluster(instanceChannel(req.From), resp); err != nil {
+		log.Printf("回传节点 %d 命令结果失败: %v", req.NodeID, err)
+	}
+}
+
+// handleClusterEvent 处理广播给所有实例的事件
+func handleClusterEvent(payload []byte) {
+	var msg clusterMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	switch msg.Kind {
+	case clusterUsers:
+		GetServer().broadcastLocal(msg.Data)
+	case clusterNodeOffline:
+		closeNodeLogTails(msg.NodeID)
+	}
+}
+
+// publishEvent 向所有实例广播事件，总线未启动时返回 false 由调用方在本地处理
+func publishEvent(msg clusterMessage) bool {
+	if !clusterStarted.Load() {
+		return false
+	}
+	if err := publishCluster(eventsChannel, msg); err != nil {
+		log.Printf("广播集群事件失败: %v", err)
+		return false
+	}
+	return true
+}
+
+func publishCluster(channel string, msg clusterMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return cluster.Get().Publish(channel, data)
+}
+
+func joinKeys(m map[string]bool) string {
+	keys := make([]string, 0, len(m))
+	for k, ok := range m {
+		if ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"flux-panel/config"
+	"flux-panel/utils"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -36,9 +37,15 @@ type Node struct {
 	Secret  string  `gorm:"column:secret"`
 	Status  int     `gorm:"column:status"`
 	Version *string `gorm:"column:version"`
+	OS      *string `gorm:"column:os"`
+	Arch    *string `gorm:"column:arch"`
 	HTTP    *int    `gorm:"column:http"`
 	TLS     *int    `gorm:"column:tls"`
 	Socks   *int    `gorm:"column:socks"`
+
+	ProtocolVersion int    `gorm:"column:protocol_version"`
+	Commands        string `gorm:"column:commands"`
+	Features        string `gorm:"column:features"`
 }
 
 // TableName 指定表名
@@ -82,7 +89,7 @@ func parseToken(tokenString string) (*Claims, error) {
 
 // HandleConnection 处理 WebSocket 连接
 func (h *Handler) HandleConnection(c *gin.Context) {
-	secret := c.Query("secret")
+	secret := utils.NodeSecret(c)
 	if secret == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少认证信息"})
 		return
@@ -109,18 +116,43 @@ func (h *Handler) HandleConnection(c *gin.Context) {
 	httpStr := c.Query("http")
 	tlsStr := c.Query("tls")
 	socksStr := c.Query("socks")
+	goos := c.Query("os")
+	goarch := c.Query("arch")
 
 	// 验证节点
 	var node Node
-	if err := h.db.Where("secret = ?", secret).First(&node).Error; err != nil {
+	if err := h.db.Scopes(utils.NodeBySecret(secret)).First(&node).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "节点认证失败"})
 		return
 	}
+	if node.Secret != secret {
+		log.Printf("节点 %d 使用轮换前的旧密钥连接", node.ID)
+	}
 
 	log.Printf("节点 %d 尝试连接，版本: %s", node.ID, version)
 
+	// 协商会话密钥：节点提供 nonce 时由面板回复另一半，双方据此派生本次连接的会话密钥
+	responseHeader := http.Header{PanelProtocolHeader: []string{strconv.Itoa(ProtocolVersion)}}
+	var session *utils.AESCrypto
+	if clientNonce := c.GetHeader(utils.SessionNonceHeader); clientNonce != "" {
+		serverNonce := utils.NewNonce()
+		if crypto, err := utils.NewAESCryptoFromKey(utils.DeriveSessionKey(secret, clientNonce, serverNonce)); err == nil {
+			session = crypto
+			responseHeader.Set(utils.SessionNonceHeader, serverNonce)
+		}
+	}
+
+	// 能力协商：旧版本节点不发送能力信息，按协议版本 0 处理
+	protocolVersion := ParseProtocolVersion(c.GetHeader(AgentProtocolHeader))
+	commands := c.GetHeader(AgentCommandsHeader)
+	features := c.GetHeader(AgentFeaturesHeader)
+	caps := ParseCapabilities(protocolVersion, commands, features)
+	if missing := caps.MissingCommands(); len(missing) > 0 {
+		log.Printf("节点 %d 协议版本 %d，不支持命令: %v", node.ID, protocolVersion, missing)
+	}
+
 	// 升级为 WebSocket 连接
-	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		log.Printf("WebSocket 升级失败: %v", err)
 		return
@@ -128,23 +160,35 @@ func (h *Handler) HandleConnection(c *gin.Context) {
 
 	// 更新节点状态和参数
 	updates := map[string]interface{}{
-		"status": 1, // 在线
+		"status":           1, // 在线
+		"protocol_version": protocolVersion,
+		"commands":         commands,
+		"features":         features,
 	}
 
 	if version != "" {
 		updates["version"] = version
 	}
-	if httpStr != "" {
+	if goos != "" {
+		updates["os"] = goos
+	}
+	if goarch != "" {
+		updates["arch"] = goarch
+	}
+	// 面板修改的协议屏蔽设置尚在队列中等待重放时，保留面板的设置，不使用节点上报的旧值
+	var pendingProtocol int64
+	h.db.Table("node_command").Where("node_id = ? AND resource = ? AND state = ?", node.ID, "protocol", 0).Count(&pendingProtocol)
+	if httpStr != "" && pendingProtocol == 0 {
 		if httpVal, err := strconv.Atoi(httpStr); err == nil {
 			updates["http"] = httpVal
 		}
 	}
-	if tlsStr != "" {
+	if tlsStr != "" && pendingProtocol == 0 {
 		if tlsVal, err := strconv.Atoi(tlsStr); err == nil {
 			updates["tls"] = tlsVal
 		}
 	}
-	if socksStr != "" {
+	if socksStr != "" && pendingProtocol == 0 {
 		if socksVal, err := strconv.Atoi(socksStr); err == nil {
 			updates["socks"] = socksVal
 		}
@@ -164,10 +208,17 @@ func (h *Handler) HandleConnection(c *gin.Context) {
 		if msgBytes, err := json.Marshal(statusMsg); err == nil {
 			GetServer().BroadcastToUsers(msgBytes)
 		}
+		if nodeStatusHandler != nil {
+			go nodeStatusHandler(node.ID, true)
+		}
 	}
 
 	// 添加到连接管理器，并设置断开回调
-	nc := GetServer().AddConnection(node.ID, secret, conn)
+	nc := GetServer().AddConnection(node.ID, secret, conn, session, caps)
+
+	if authHandler != nil {
+		go authHandler(node.ID, secret)
+	}
 
 	// 设置连接断开时的回调
 	go h.handleDisconnect(nc, node.ID)
@@ -178,8 +229,8 @@ func (h *Handler) handleDisconnect(nc *NodeConnection, nodeID uint) {
 	// 等待连接关闭
 	<-nc.Done
 
-	// 检查是否还有其他连接
-	if GetServer().GetConnection(nodeID) == nil {
+	// 检查是否还有其他连接（包括重连到其他面板实例的连接）
+	if !GetServer().IsOnline(nodeID) {
 		// 更新状态为离线
 		h.db.Model(&Node{}).Where("id = ?", nodeID).Update("status", 0)
 		log.Printf("节点 %d 已断开所有连接", nodeID)
@@ -193,10 +244,13 @@ func (h *Handler) handleDisconnect(nc *NodeConnection, nodeID uint) {
 		if msgBytes, err := json.Marshal(statusMsg); err == nil {
 			GetServer().BroadcastToUsers(msgBytes)
 		}
+		if nodeStatusHandler != nil {
+			nodeStatusHandler(nodeID, false)
+		}
 	}
 }
 
-// IsNodeConnected 检查节点是否已连接
+// IsNodeConnected 检查节点是否已连接到任一面板实例
 func IsNodeConnected(nodeID uint) bool {
-	return GetServer().GetConnection(nodeID) != nil
+	return GetServer().IsOnline(nodeID)
 }
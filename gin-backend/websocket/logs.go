@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"encoding/json"
+	"flux-panel/cluster"
+	"strings"
+	"sync"
+)
+
+// LogTail 管理员查看节点实时日志的会话，节点推送的日志按 tailId 投递到对应会话
+type LogTail struct {
+	ID     string
+	NodeID uint
+	Lines  chan json.RawMessage // 每次推送的一批日志 (JSON 数组)
+	done   chan struct{}
+	once   sync.Once
+}
+
+// logTails 进行中的实时日志会话
+var logTails sync.Map // tailId -> *LogTail
+
+// NewLogTail 创建节点实时日志会话，会话 ID 以实例标识开头，以便其他实例收到日志后转交
+func NewLogTail(nodeID uint) *LogTail {
+	t := &LogTail{
+		ID:     cluster.InstanceID() + "." + generateMsgID(),
+		NodeID: nodeID,
+		Lines:  make(chan json.RawMessage, 64),
+		done:   make(chan struct{}),
+	}
+	logTails.Store(t.ID, t)
+	return t
+}
+
+// Done 节点结束推送或连接断开时关闭
+func (t *LogTail) Done() <-chan struct{} {
+	return t.done
+}
+
+// Close 结束会话，之后收到的日志将被丢弃
+func (t *LogTail) Close() {
+	t.once.Do(func() {
+		logTails.Delete(t.ID)
+		close(t.done)
+	})
+}
+
+// logBatch 节点推送的一批实时日志
+type logBatch struct {
+	TailID string          `json:"tailId"`
+	Lines  json.RawMessage `json:"lines"`
+	Done   bool            `json:"done"`
+}
+
+// deliverLogs 将节点推送的一批日志投递给对应会话，会话在其他实例上时经总线转交
+func deliverLogs(nodeID uint, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if deliverLocalLogs(nodeID, raw) {
+		return
+	}
+
+	var batch logBatch
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return
+	}
+	i := strings.LastIndex(batch.TailID, ".")
+	if i <= 0 || batch.TailID[:i] == cluster.InstanceID() || !clusterStarted.Load() {
+		return
+	}
+	publishCluster(instanceChannel(batch.TailID[:i]), clusterMessage{Kind: clusterLogs, NodeID: nodeID, Data: raw})
+}
+
+// deliverLocalLogs 将一批日志投递给本实例上的会话，会话处理不过来时丢弃，会话不在本实例时返回 false
+func deliverLocalLogs(nodeID uint, raw []byte) bool {
+	var batch logBatch
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return false
+	}
+
+	v, ok := logTails.Load(batch.TailID)
+	if !ok {
+		return false
+	}
+	t := v.(*LogTail)
+	if t.NodeID != nodeID {
+		return true
+	}
+
+	if len(batch.Lines) > 0 && string(batch.Lines) != "[]" && string(batch.Lines) != "null" {
+		select {
+		case t.Lines <- batch.Lines:
+		default:
+		}
+	}
+	if batch.Done {
+		t.Close()
+	}
+	return true
+}
+
+// closeNodeLogTails 节点断开时结束其全部日志会话
+func closeNodeLogTails(nodeID uint) {
+	logTails.Range(func(_, v any) bool {
+		if t := v.(*LogTail); t.NodeID == nodeID {
+			t.Close()
+		}
+		return true
+	})
+}
@@ -0,0 +1,165 @@
+package websocket
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion 面板命令协议版本
+const ProtocolVersion = 10
+
+// 握手时交换能力信息的请求/响应头
+const (
+	AgentProtocolHeader = "X-Agent-Protocol"
+	AgentCommandsHeader = "X-Agent-Commands"
+	AgentFeaturesHeader = "X-Agent-Features"
+	PanelProtocolHeader = "X-Panel-Protocol"
+)
+
+// 节点能力（非命令类）
+const (
+	FeatureSignedEnvelope = "signed_envelope" // 会话密钥和带签名的加密信封
+	FeatureAccessLog      = "access_log"      // 连接日志记录与上报
+	FeatureConfigRestore  = "config_restore"  // 重启后从本地配置恢复服务
+	FeatureTelemetry      = "telemetry"       // 扩展遥测上报
+	FeatureBatchChains    = "batch_chains"    // 链的增删改命令支持以列表批量下发
+	FeatureCreatePaused   = "create_paused"   // 添加服务时支持以暂停状态创建
+	FeatureBatchConfig    = "batch_config"    // 限流器和解析器的添加命令支持以列表批量下发
+)
+
+// 节点兼容状态
+const (
+	CompatibilityFull         = "compatible"   // 支持面板的全部命令
+	CompatibilityLimited      = "limited"      // 缺少部分可选命令，相关功能不可用
+	CompatibilityIncompatible = "incompatible" // 缺少管理转发所必需的命令
+)
+
+// legacyCommands 未进行能力协商的旧版本节点（协议版本 0）支持的命令
+var legacyCommands = []string{
+	MessageTypeAddService, MessageTypeUpdateService, MessageTypeDeleteService,
+	MessageTypePauseService, MessageTypeResumeService,
+	MessageTypeAddChains, MessageTypeUpdateChains, MessageTypeDeleteChains,
+	MessageTypeAddLimiters, MessageTypeUpdateLimiters, MessageTypeDeleteLimiters,
+	MessageTypeTcpPing, MessageTypeSetProtocol,
+}
+
+// requiredCommands 面板管理转发所必需的命令
+var requiredCommands = []string{
+	MessageTypeAddService, MessageTypeUpdateService, MessageTypeDeleteService,
+	MessageTypePauseService, MessageTypeResumeService,
+	MessageTypeAddChains, MessageTypeUpdateChains, MessageTypeDeleteChains,
+	MessageTypeAddLimiters, MessageTypeUpdateLimiters, MessageTypeDeleteLimiters,
+}
+
+// panelCommands 面板会下发的全部命令
+var panelCommands = []string{
+	MessageTypeAddLimiters, MessageTypeUpdateLimiters, MessageTypeDeleteLimiters,
+	MessageTypeAddCLimiters, MessageTypeUpdateCLimiters, MessageTypeDeleteCLimiters,
+	MessageTypeAddRLimiters, MessageTypeUpdateRLimiters, MessageTypeDeleteRLimiters,
+	MessageTypeAddResolvers, MessageTypeUpdateResolvers, MessageTypeDeleteResolvers,
+	MessageTypeAddService, MessageTypeUpdateService, MessageTypeDeleteService,
+	MessageTypePauseService, MessageTypeResumeService,
+	MessageTypeAddChains, MessageTypeUpdateChains, MessageTypeDeleteChains,
+	MessageTypeTcpPing, MessageTypeUpgradeAgent, MessageTypeSetProtocol,
+	MessageTypeUdpProbe, MessageTypeHttpProbe, MessageTypeTraceroute, MessageTypeDnsLookup,
+	MessageTypeStartBandwidthServer, MessageTypeStopBandwidthServer, MessageTypeBandwidthTest,
+	MessageTypeTailLogs, MessageTypeStopTailLogs, MessageTypeSetServiceLogLevel,
+	MessageTypeGetAgentSettings, MessageTypeApplyAgentSettings,
+	MessageTypeRotateSecret,
+}
+
+// Capabilities 节点在握手时声明的协议版本、命令和能力
+type Capabilities struct {
+	Protocol int
+	Commands map[string]bool
+	Features map[string]bool
+}
+
+// ParseCapabilities 解析节点声明的能力，未声明协议版本的旧节点按 legacyCommands 处理
+func ParseCapabilities(protocol int, commands, features string) *Capabilities {
+	c := &Capabilities{
+		Protocol: protocol,
+		Commands: make(map[string]bool),
+		Features: make(map[string]bool),
+	}
+	if protocol <= 0 {
+		c.Protocol = 0
+		for _, cmd := range legacyCommands {
+			c.Commands[cmd] = true
+		}
+		return c
+	}
+	for _, cmd := range splitList(commands) {
+		c.Commands[cmd] = true
+	}
+	for _, feature := range splitList(features) {
+		c.Features[feature] = true
+	}
+	return c
+}
+
+// ParseProtocolVersion 解析协议版本头，缺失或非法时返回 0
+func ParseProtocolVersion(s string) int {
+	version, err := strconv.Atoi(s)
+	if err != nil || version < 0 {
+		return 0
+	}
+	return version
+}
+
+// Supports 节点是否支持指定命令
+func (c *Capabilities) Supports(cmd string) bool {
+	return c == nil || c.Commands[cmd]
+}
+
+// HasFeature 节点是否支持指定能力
+func (c *Capabilities) HasFeature(feature string) bool {
+	return c != nil && c.Features[feature]
+}
+
+// MissingCommands 返回面板会下发但节点不支持的命令
+func (c *Capabilities) MissingCommands() []string {
+	missing := make([]string, 0)
+	for _, cmd := range panelCommands {
+		if !c.Supports(cmd) {
+			missing = append(missing, cmd)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Compatibility 返回节点的兼容状态
+func (c *Capabilities) Compatibility() string {
+	for _, cmd := range requiredCommands {
+		if !c.Supports(cmd) {
+			return CompatibilityIncompatible
+		}
+	}
+	if len(c.MissingCommands()) > 0 {
+		return CompatibilityLimited
+	}
+	return CompatibilityFull
+}
+
+// NodeCapabilities 返回在线节点的能力，节点连接在其他面板实例上时读取其登记的能力，节点不在线时为 nil
+func NodeCapabilities(nodeID uint) *Capabilities {
+	if nc := GetServer().GetConnection(nodeID); nc != nil {
+		return nc.Capabilities
+	}
+	if owner, ok := remoteNodeOwner(nodeID); ok {
+		return owner.capabilities()
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"flux-panel/utils"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -15,17 +16,46 @@ import (
 
 // 消息类型
 const (
-	MessageTypeAddLimiters    = "AddLimiters"
-	MessageTypeUpdateLimiters = "UpdateLimiters"
-	MessageTypeDeleteLimiters = "DeleteLimiters"
-	MessageTypeAddService     = "AddService"
-	MessageTypeUpdateService  = "UpdateService"
-	MessageTypeDeleteService  = "DeleteService"
-	MessageTypePauseService   = "PauseService"
-	MessageTypeResumeService  = "ResumeService"
-	MessageTypeAddChains      = "AddChains"
-	MessageTypeUpdateChains   = "UpdateChains"
-	MessageTypeDeleteChains   = "DeleteChains"
+	MessageTypeAddLimiters     = "AddLimiters"
+	MessageTypeUpdateLimiters  = "UpdateLimiters"
+	MessageTypeDeleteLimiters  = "DeleteLimiters"
+	MessageTypeAddCLimiters    = "AddCLimiters"
+	MessageTypeUpdateCLimiters = "UpdateCLimiters"
+	MessageTypeDeleteCLimiters = "DeleteCLimiters"
+	MessageTypeAddRLimiters    = "AddRLimiters"
+	MessageTypeUpdateRLimiters = "UpdateRLimiters"
+	MessageTypeDeleteRLimiters = "DeleteRLimiters"
+	MessageTypeAddResolvers    = "AddResolvers"
+	MessageTypeUpdateResolvers = "UpdateResolvers"
+	MessageTypeDeleteResolvers = "DeleteResolvers"
+	MessageTypeAddService      = "AddService"
+	MessageTypeUpdateService   = "UpdateService"
+	MessageTypeDeleteService   = "DeleteService"
+	MessageTypePauseService    = "PauseService"
+	MessageTypeResumeService   = "ResumeService"
+	MessageTypeAddChains       = "AddChains"
+	MessageTypeUpdateChains    = "UpdateChains"
+	MessageTypeDeleteChains    = "DeleteChains"
+	MessageTypeUpgradeAgent    = "UpgradeAgent"
+	MessageTypeTcpPing         = "TcpPing"
+	MessageTypeUdpProbe        = "UdpProbe"
+	MessageTypeHttpProbe       = "HttpProbe"
+	MessageTypeTraceroute      = "Traceroute"
+	MessageTypeDnsLookup       = "DnsLookup"
+	MessageTypeSetProtocol     = "SetProtocol"
+
+	MessageTypeStartBandwidthServer = "StartBandwidthServer"
+	MessageTypeStopBandwidthServer  = "StopBandwidthServer"
+	MessageTypeBandwidthTest        = "BandwidthTest"
+
+	MessageTypeTailLogs           = "TailLogs"
+	MessageTypeStopTailLogs       = "StopTailLogs"
+	MessageTypeSetServiceLogLevel = "SetServiceLogLevel"
+
+	MessageTypeGetAgentSettings   = "GetAgentSettings"
+	MessageTypeApplyAgentSettings = "ApplyAgentSettings"
+
+	MessageTypeRotateSecret = "RotateSecret"
 )
 
 // Message WebSocket 消息结构
@@ -44,14 +74,18 @@ type Response struct {
 
 // NodeConnection 节点连接
 type NodeConnection struct {
-	NodeID     uint
-	Secret     string
-	Conn       *websocket.Conn
-	Send       chan []byte
-	Done       chan struct{}
-	mutex      sync.Mutex
-	pendingReq map[string]chan *Response
-	reqMutex   sync.Mutex
+	NodeID       uint
+	Secret       string
+	Session      *utils.AESCrypto // 握手时协商的会话密钥，旧版本节点为 nil
+	Capabilities *Capabilities    // 握手时声明的协议版本和支持的命令
+	Conn         *websocket.Conn
+	Send         chan []byte
+	Done         chan struct{}
+	mutex        sync.Mutex
+	pendingReq   map[string]chan *Response
+	reqMutex     sync.Mutex
+	guard        *utils.ReplayGuard
+	registration []byte // 写入总线的节点归属登记，断开时据此注销
 }
 
 // UserConnection 用户连接
@@ -74,6 +108,33 @@ var (
 	once     sync.Once
 )
 
+// ErrResponseTimeout 命令已发出但节点未在期限内响应，节点可能仍在执行
+var ErrResponseTimeout = errors.New("响应超时")
+
+// telemetryHandler 节点扩展遥测的处理函数，由上层注册以避免循环依赖
+var telemetryHandler func(nodeID uint, data []byte)
+
+// SetTelemetryHandler 注册节点扩展遥测的处理函数
+func SetTelemetryHandler(handler func(nodeID uint, data []byte)) {
+	telemetryHandler = handler
+}
+
+// nodeStatusHandler 节点上线、离线的处理函数，由上层注册以避免循环依赖
+var nodeStatusHandler func(nodeID uint, online bool)
+
+// SetNodeStatusHandler 注册节点上线、离线的处理函数
+func SetNodeStatusHandler(handler func(nodeID uint, online bool)) {
+	nodeStatusHandler = handler
+}
+
+// authHandler 节点连接认证成功后的处理函数，参数为节点使用的密钥，由上层注册以避免循环依赖
+var authHandler func(nodeID uint, secret string)
+
+// SetAuthHandler 注册节点连接认证成功后的处理函数
+func SetAuthHandler(handler func(nodeID uint, secret string)) {
+	authHandler = handler
+}
+
 // GetServer 获取 WebSocket 服务端单例
 func GetServer() *Server {
 	once.Do(func() {
@@ -91,7 +152,7 @@ func GetServer() *Server {
 }
 
 // AddConnection 添加节点连接
-func (s *Server) AddConnection(nodeID uint, secret string, conn *websocket.Conn) *NodeConnection {
+func (s *Server) AddConnection(nodeID uint, secret string, conn *websocket.Conn, session *utils.AESCrypto, caps *Capabilities) *NodeConnection {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -102,13 +163,17 @@ func (s *Server) AddConnection(nodeID uint, secret string, conn *websocket.Conn)
 	}
 
 	nc := &NodeConnection{
-		NodeID:     nodeID,
-		Secret:     secret,
-		Conn:       conn,
-		Send:       make(chan []byte, 256),
-		Done:       make(chan struct{}),
-		pendingReq: make(map[string]chan *Response),
+		NodeID:       nodeID,
+		Secret:       secret,
+		Session:      session,
+		Capabilities: caps,
+		Conn:         conn,
+		Send:         make(chan []byte, 256),
+		Done:         make(chan struct{}),
+		pendingReq:   make(map[string]chan *Response),
+		guard:        utils.NewReplayGuard(),
 	}
+	nc.registration = registerNode(nodeID, caps)
 
 	s.connections[nodeID] = nc
 
@@ -123,14 +188,23 @@ func (s *Server) AddConnection(nodeID uint, secret string, conn *websocket.Conn)
 // RemoveConnection 移除节点连接
 func (s *Server) RemoveConnection(nodeID uint) {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if nc, exists := s.connections[nodeID]; exists {
+	nc, exists := s.connections[nodeID]
+	if exists {
 		close(nc.Done)
 		nc.Conn.Close()
 		delete(s.connections, nodeID)
-		log.Printf("节点 %d 已断开", nodeID)
 	}
+	s.mutex.Unlock()
+
+	if !exists {
+		return
+	}
+	unregisterNode(nodeID, nc.registration)
+	// 日志会话可能在其他实例上，通知所有实例结束该节点的会话
+	if !publishEvent(clusterMessage{Kind: clusterNodeOffline, NodeID: nodeID}) {
+		closeNodeLogTails(nodeID)
+	}
+	log.Printf("节点 %d 已断开", nodeID)
 }
 
 // GetConnection 获取节点连接
@@ -140,11 +214,20 @@ func (s *Server) GetConnection(nodeID uint) *NodeConnection {
 	return s.connections[nodeID]
 }
 
-// SendMessage 发送消息到节点
+// IsOnline 节点是否连接到本实例或其他面板实例
+func (s *Server) IsOnline(nodeID uint) bool {
+	if s.GetConnection(nodeID) != nil {
+		return true
+	}
+	_, ok := remoteNodeOwner(nodeID)
+	return ok
+}
+
+// SendMessage 发送消息到节点，节点连接在其他面板实例上时经总线转发
 func (s *Server) SendMessage(nodeID uint, data interface{}, msgType string) (*Response, error) {
 	nc := s.GetConnection(nodeID)
 	if nc == nil {
-		return nil, errors.New("节点未连接")
+		return s.sendRemote(nodeID, data, msgType)
 	}
 
 	return nc.SendMessage(data, msgType)
@@ -179,8 +262,16 @@ func (s *Server) RemoveUserConnection(uc *UserConnection) {
 	}
 }
 
-// BroadcastToUsers 广播消息给所有用户
+// BroadcastToUsers 广播消息给所有实例上的用户
 func (s *Server) BroadcastToUsers(message []byte) {
+	if publishEvent(clusterMessage{Kind: clusterUsers, Data: message}) {
+		return
+	}
+	s.broadcastLocal(message)
+}
+
+// broadcastLocal 广播消息给连接到本实例的用户
+func (s *Server) broadcastLocal(message []byte) {
 	s.userMutex.RLock()
 	defer s.userMutex.RUnlock()
 
@@ -196,6 +287,10 @@ func (s *Server) BroadcastToUsers(message []byte) {
 
 // SendMessage 发送消息并等待响应
 func (nc *NodeConnection) SendMessage(data interface{}, msgType string) (*Response, error) {
+	if !nc.Capabilities.Supports(msgType) {
+		return nil, fmt.Errorf("节点版本过低，不支持 %s 命令，请升级节点", msgType)
+	}
+
 	// 生成消息 ID
 	msgID := generateMsgID()
 
@@ -218,7 +313,7 @@ func (nc *NodeConnection) SendMessage(data interface{}, msgType string) (*Respon
 
 	// 发送消息
 	select {
-	case nc.Send <- msgBytes:
+	case nc.Send <- nc.sealMessage(msgBytes):
 	case <-nc.Done:
 		return nil, errors.New("连接已关闭")
 	case <-time.After(5 * time.Second):
@@ -235,7 +330,7 @@ func (nc *NodeConnection) SendMessage(data interface{}, msgType string) (*Respon
 		nc.reqMutex.Lock()
 		delete(nc.pendingReq, msgID)
 		nc.reqMutex.Unlock()
-		return nil, errors.New("响应超时")
+		return nil, ErrResponseTimeout
 	}
 }
 
@@ -261,29 +356,19 @@ func (nc *NodeConnection) readPump() {
 			return
 		}
 
-		// 尝试解密
-		var encMsg struct {
-			Encrypted bool   `json:"encrypted"`
-			Data      string `json:"data"`
-		}
-
-		if err := json.Unmarshal(message, &encMsg); err == nil && encMsg.Encrypted && encMsg.Data != "" {
-			crypto, err := utils.GetOrCreateCrypto(nc.Secret)
-			if err == nil {
-				decrypted, err := crypto.DecryptString(encMsg.Data)
-				if err == nil {
-					message = []byte(decrypted)
-				} else {
-					log.Printf("节点 %d 解密失败: %v", nc.NodeID, err)
-				}
-			}
+		// 解密并校验消息，拒绝重放、过期或未签名的消息
+		message, err = nc.openMessage(message)
+		if err != nil {
+			continue
 		}
 
 		// 检查系统信息 ACK
 		if strings.Contains(string(message), "memory_usage") {
 			ackMsg := map[string]string{"type": "call"}
 			ackBytes, _ := json.Marshal(ackMsg)
-			if nc.Secret != "" {
+			if nc.Session != nil {
+				ackBytes = nc.sealMessage(ackBytes)
+			} else if nc.Secret != "" {
 				// 发送加密的 ACK
 				crypto, _ := utils.GetOrCreateCrypto(nc.Secret)
 				if crypto != nil {
@@ -335,6 +420,19 @@ func (nc *NodeConnection) readPump() {
 			nc.reqMutex.Unlock()
 		}
 
+		// 实时日志只投递给发起查看的管理员会话，不广播
+		if resp.Type == "logs" {
+			deliverLogs(nc.NodeID, resp.Data)
+			continue
+		}
+
+		// 扩展遥测需要持久化，保存后仍广播给用户用于实时展示
+		if resp.Type == "telemetry" && telemetryHandler != nil {
+			if data, err := json.Marshal(resp.Data); err == nil {
+				go telemetryHandler(nc.NodeID, data)
+			}
+		}
+
 		// 如果不是响应，或者没有 ID，则视为推送消息，广播给用户
 		// 对于 Agent 上报的系统信息，通常 Type='info'
 		// 我们需要补充 NodeID，以便前端知道是哪个节点的消息
@@ -365,6 +463,33 @@ func (nc *NodeConnection) readPump() {
 	}
 }
 
+// openMessage 解密节点消息；协商了会话密钥的连接只接受签名信封，会话密钥仅在本连接有效，nonce 只需在连接内记录
+func (nc *NodeConnection) openMessage(message []byte) ([]byte, error) {
+	if nc.Session != nil {
+		return utils.OpenNodeEnvelope(nc.NodeID, nc.Session, nc.guard, message, false)
+	}
+
+	crypto, err := utils.GetOrCreateCrypto(nc.Secret)
+	if err != nil {
+		return message, nil
+	}
+	// 节点密钥加密的消息可被重放到其他连接或其他实例，使用集群共享的 nonce 记录
+	return utils.OpenNodeEnvelope(nc.NodeID, crypto, utils.NodeReplayGuard(nc.NodeID), message, utils.LegacyEnvelopeAllowed(nc.NodeID))
+}
+
+// sealMessage 使用会话密钥封装发往节点的消息，旧版本节点保持原有格式
+func (nc *NodeConnection) sealMessage(message []byte) []byte {
+	if nc.Session == nil {
+		return message
+	}
+	sealed, err := nc.Session.Seal(message)
+	if err != nil {
+		log.Printf("节点 %d 消息加密失败: %v", nc.NodeID, err)
+		return message
+	}
+	return sealed
+}
+
 // writePump 写入消息
 func (nc *NodeConnection) writePump() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -13,6 +13,11 @@ type Config struct {
 	Http   int    `json:"http"`
 	Tls    int    `json:"tls"`
 	Socks  int    `json:"socks"`
+	Scheme string `json:"scheme"` // 面板连接方式: http（默认）或 https（使用 wss/https）
+	CA     string `json:"ca"`     // 自定义 CA 证书文件路径
+	Pin    string `json:"pin"`    // 面板证书公钥指纹 base64(sha256(SPKI))
+
+	UpgradeKey string `json:"upgrade_key"` // 校验升级包签名的 ed25519 公钥（base64），为空时拒绝远程升级
 }
 
 // LoadConfig 加载配置文件
@@ -38,6 +43,9 @@ func LoadConfig(configPath string) (*Config, error) {
 	if config.Addr == "" {
 		return nil, fmt.Errorf("服务器地址不能为空")
 	}
+	if config.Scheme != "" && config.Scheme != "http" && config.Scheme != "https" {
+		return nil, fmt.Errorf("不支持的连接方式: %s", config.Scheme)
+	}
 
 	return &config, nil
 }
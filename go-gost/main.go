@@ -106,6 +106,11 @@ func init() {
 }
 
 func main() {
+	// 接管标准输出，使节点直接打印的日志也能被面板实时查看
+	if err := xlogger.CaptureStdout(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ 接管标准输出失败: %v\n", err)
+	}
+
 	// 加载配置文件
 	config, err := LoadConfig("config.json")
 	if err != nil {
@@ -119,10 +124,37 @@ func main() {
 	log := xlogger.NewLogger()
 	logger.SetDefault(log)
 
-	wsReporter := socket.StartWebSocketReporterWithConfig(config.Addr, config.Secret, config.Http, config.Tls, config.Socks, "1.2.4")
+	// 面板下发过的节点设置（地址列表、上报间隔等）
+	if _, err := socket.LoadAgentSettings(socket.AgentConfigFile); err != nil {
+		fmt.Printf("⚠️ 节点设置无效，使用默认设置: %v\n", err)
+	}
+
+	if err := service.SetPanelTransport(service.PanelTLSOptions{
+		Enabled: config.Scheme == "https",
+		CAFile:  config.CA,
+		Pin:     config.Pin,
+	}); err != nil {
+		fmt.Printf("❌ 传输加密配置错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := socket.SetUpgradePublicKey(config.UpgradeKey); err != nil {
+		fmt.Printf("❌ 升级公钥配置错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	wsReporter := socket.StartWebSocketReporterWithConfig(config.Addr, config.Secret, config.Http, config.Tls, config.Socks, agentVersion)
 	defer wsReporter.Stop()
 	service.SetHTTPReportURL(config.Addr, config.Secret)
 
+	// 未指定配置时从上次保存的面板配置恢复服务，面板不可用时也能立即提供转发
+	if cfgFile == "" && len(services) == 0 {
+		if _, err := os.Stat(socket.ConfigFile); err == nil {
+			cfgFile = socket.ConfigFile
+			fmt.Printf("♻️ 从 %s 恢复服务配置\n", socket.ConfigFile)
+		}
+	}
+
 	p := &program{}
 	if err := svc.Run(p); err != nil {
 		logger.Default().Fatal(err)
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/go-gost/core/auth"
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/service"
@@ -12,15 +13,18 @@ import (
 	"github.com/go-gost/x/config/loader"
 	auth_parser "github.com/go-gost/x/config/parsing/auth"
 	"github.com/go-gost/x/config/parsing/parser"
+	service_parser "github.com/go-gost/x/config/parsing/service"
 	xmetrics "github.com/go-gost/x/metrics"
 	metrics "github.com/go-gost/x/metrics/service"
 	"github.com/go-gost/x/registry"
 	xservice "github.com/go-gost/x/service"
+	"github.com/go-gost/x/socket"
 	"github.com/judwhite/go-svc"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -31,6 +35,8 @@ type program struct {
 	srvProfiling *http.Server
 
 	cancel context.CancelFunc
+
+	listenerMutex sync.Mutex
 }
 
 func (p *program) Init(env svc.Environment) error {
@@ -48,9 +54,15 @@ func (p *program) Init(env svc.Environment) error {
 }
 
 func (p *program) Start() error {
+	socket.SetListenerUpdater(p.updateListeners)
+
 	cfg, err := parser.Parse()
 	if err != nil {
-		return err
+		if cfgFile != socket.ConfigFile {
+			return err
+		}
+		fmt.Printf("⚠️ 解析 %s 失败，等待面板重新下发配置: %v\n", socket.ConfigFile, err)
+		cfg = &config.Config{}
 	}
 
 	if outputFormat != "" {
@@ -60,9 +72,8 @@ func (p *program) Start() error {
 		os.Exit(0)
 	}
 
-	config.Set(cfg)
-
-	if err := loader.Load(cfg); err != nil {
+	cfg, err = loadConfig(cfg)
+	if err != nil {
 		return err
 	}
 
@@ -83,66 +94,38 @@ func (p *program) Start() error {
 		}
 	}()
 
+	go xservice.StartLimitReporter(ctx)
+	go xservice.StartAccessLogReporter(ctx)
+
 	return nil
 }
 
 func (p *program) run(cfg *config.Config) error {
-	for _, svc := range registry.ServiceRegistry().GetAll() {
+	xservice.RegisterAccessLogRecorder()
+
+	paused := pausedServiceNames(cfg)
+	for name, svc := range registry.ServiceRegistry().GetAll() {
 		svc := svc
+		if paused[name] {
+			// 已暂停的服务保留注册但释放监听端口，等待面板的恢复命令
+			svc.Close()
+			continue
+		}
 		go func() {
 			svc.Serve()
 		}()
 	}
 
-	if p.srvApi != nil {
-		p.srvApi.Close()
-		p.srvApi = nil
-	}
-	if cfg.API != nil {
-		s, err := buildApiService(cfg.API)
-		if err != nil {
-			return err
-		}
-
-		p.srvApi = s
-
-		go func() {
-			defer s.Close()
-
-			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@api"})
-
-			log.Info("listening on ", s.Addr())
-			if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err)
-			}
-		}()
+	if err := p.runApi(cfg.API); err != nil {
+		return err
 	}
-
-	xmetrics.Enable(false)
-	if p.srvMetrics != nil {
-		p.srvMetrics.Close()
-		p.srvMetrics = nil
+	if err := p.runMetrics(cfg.Metrics); err != nil {
+		return err
 	}
-	if cfg.Metrics != nil && cfg.Metrics.Addr != "" {
-		s, err := buildMetricsService(cfg.Metrics)
-		if err != nil {
-			return err
-		}
 
-		p.srvMetrics = s
-
-		xmetrics.Enable(true)
-
-		go func() {
-			defer s.Close()
-
-			log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@metrics"})
-
-			log.Info("listening on ", s.Addr())
-			if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err)
-			}
-		}()
+	// 面板下发的日志级别和监听设置优先于配置文件
+	if err := socket.ApplyLocalSettings(); err != nil {
+		fmt.Printf("⚠️ 应用节点设置失败: %v\n", err)
 	}
 
 	if p.srvProfiling != nil {
@@ -174,6 +157,101 @@ func (p *program) run(cfg *config.Config) error {
 	return nil
 }
 
+// updateListeners 按面板下发的设置重建 Web API 和指标服务，参数为 nil 时保持不变
+func (p *program) updateListeners(api *config.APIConfig, metrics *config.MetricsConfig) error {
+	if api != nil {
+		if err := p.runApi(api); err != nil {
+			return err
+		}
+	}
+	if metrics != nil {
+		if err := p.runMetrics(metrics); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runApi 关闭已有的 Web API 服务并按配置重新启动，未配置地址时保持关闭
+func (p *program) runApi(cfg *config.APIConfig) error {
+	p.listenerMutex.Lock()
+	defer p.listenerMutex.Unlock()
+
+	if p.srvApi != nil {
+		p.srvApi.Close()
+		p.srvApi = nil
+	}
+	if cfg == nil || cfg.Addr == "" {
+		return nil
+	}
+
+	s, err := buildApiService(cfg)
+	if err != nil {
+		return err
+	}
+
+	p.srvApi = s
+
+	go func() {
+		defer s.Close()
+
+		log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@api"})
+
+		log.Info("listening on ", s.Addr())
+		if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err)
+		}
+	}()
+	return nil
+}
+
+// runMetrics 关闭已有的指标服务并按配置重新启动，未配置地址时保持关闭
+func (p *program) runMetrics(cfg *config.MetricsConfig) error {
+	p.listenerMutex.Lock()
+	defer p.listenerMutex.Unlock()
+
+	xmetrics.Enable(false)
+	if p.srvMetrics != nil {
+		p.srvMetrics.Close()
+		p.srvMetrics = nil
+	}
+	if cfg == nil || cfg.Addr == "" {
+		return nil
+	}
+
+	s, err := buildMetricsService(cfg)
+	if err != nil {
+		return err
+	}
+
+	p.srvMetrics = s
+
+	xmetrics.Enable(true)
+
+	go func() {
+		defer s.Close()
+
+		log := logger.Default().WithFields(map[string]any{"kind": "service", "service": "@metrics"})
+
+		log.Info("listening on ", s.Addr())
+		if err := s.Serve(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err)
+		}
+	}()
+	return nil
+}
+
+// pausedServiceNames 返回配置中被标记为暂停的服务
+func pausedServiceNames(cfg *config.Config) map[string]bool {
+	names := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		if svc != nil && svc.Metadata != nil && svc.Metadata["paused"] == true {
+			names[svc.Name] = true
+		}
+	}
+	return names
+}
+
 func (p *program) Stop() error {
 	if p.cancel != nil {
 		p.cancel()
@@ -224,9 +302,8 @@ func (p *program) reloadConfig() error {
 	if err != nil {
 		return err
 	}
-	config.Set(cfg)
-
-	if err := loader.Load(cfg); err != nil {
+	cfg, err = loadConfig(cfg)
+	if err != nil {
 		return err
 	}
 
@@ -237,6 +314,45 @@ func (p *program) reloadConfig() error {
 	return nil
 }
 
+// loadConfig 加载配置并返回实际生效的配置。
+// 从面板下发的配置文件恢复时逐个加载服务，跳过启动失败（如端口被占用）的服务，其余服务正常恢复，
+// 跳过的服务不写入生效配置，由面板对账后重新下发
+func loadConfig(cfg *config.Config) (*config.Config, error) {
+	if cfgFile != socket.ConfigFile {
+		config.Set(cfg)
+		return cfg, loader.Load(cfg)
+	}
+
+	loaded := *cfg
+	loaded.Services = nil
+	config.Set(&loaded)
+	if err := loader.Load(&loaded); err != nil {
+		// 服务以外的配置无法恢复时释放已启动的监听，以空配置启动
+		fmt.Printf("⚠️ 恢复 %s 失败，等待面板重新下发配置: %v\n", socket.ConfigFile, err)
+		for _, svc := range registry.ServiceRegistry().GetAll() {
+			svc.Close()
+		}
+		empty := &config.Config{}
+		config.Set(empty)
+		return empty, loader.Load(empty)
+	}
+
+	for _, svcCfg := range cfg.Services {
+		svc, err := service_parser.ParseService(svcCfg)
+		if err == nil && svc != nil {
+			if err = registry.ServiceRegistry().Register(svcCfg.Name, svc); err != nil {
+				svc.Close()
+			}
+		}
+		if err != nil {
+			fmt.Printf("⚠️ 恢复服务 %s 失败，已跳过: %v\n", svcCfg.Name, err)
+			continue
+		}
+		loaded.Services = append(loaded.Services, svcCfg)
+	}
+	return &loaded, nil
+}
+
 func buildApiService(cfg *config.APIConfig) (service.Service, error) {
 	var authers []auth.Authenticator
 	if auther := auth_parser.ParseAutherFromAuth(cfg.Auth); auther != nil {
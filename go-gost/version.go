@@ -2,4 +2,6 @@ package main
 
 var (
 	version = "3.1.0"
+	// agentVersion 节点程序版本，上报给面板用于升级管理，可通过 -ldflags "-X main.agentVersion=x.y.z" 覆盖
+	agentVersion = "1.2.4"
 )
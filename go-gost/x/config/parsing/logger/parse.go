@@ -27,7 +27,7 @@ func ParseLogger(cfg *config.LoggerConfig) logger.Logger {
 	case "none", "null":
 		return xlogger.Nop()
 	case "stdout":
-		out = os.Stdout
+		out = xlogger.Stdout()
 	case "stderr", "":
 		out = os.Stderr
 	default:
@@ -114,7 +114,6 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	var limiterScope string
 
 	enableStats := true
-	observerPeriod = 5 * time.Second
 
 	if cfg.Metadata != nil {
 		md := metadata.NewMetadata(cfg.Metadata)
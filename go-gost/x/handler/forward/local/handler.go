@@ -116,7 +116,9 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 		ro.InputBytes = pStats.Get(stats.KindInputBytes)
 		ro.OutputBytes = pStats.Get(stats.KindOutputBytes)
 		ro.Duration = time.Since(start)
-
+		if err := ro.Record(ctx, h.recorder.Recorder); err != nil {
+			h.options.Logger.Errorf("record: %v", err)
+		}
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
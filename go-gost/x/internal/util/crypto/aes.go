@@ -29,10 +29,25 @@ func NewAESCrypto(secret string) (*AESCrypto, error) {
 	}, nil
 }
 
+// NewAESCryptoFromKey 使用已派生的 32 字节密钥创建加密器（如会话密钥）
+func NewAESCryptoFromKey(key []byte) (*AESCrypto, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("密钥长度必须为32字节")
+	}
+	return &AESCrypto{
+		key: append([]byte(nil), key...),
+	}, nil
+}
+
 // Encrypt 加密数据
 // data: 要加密的原始数据
 // 返回: base64编码的加密数据
 func (a *AESCrypto) Encrypt(data []byte) (string, error) {
+	return a.EncryptWithAAD(data, nil)
+}
+
+// EncryptWithAAD 加密数据，aad 作为附加认证数据参与完整性校验但不加密
+func (a *AESCrypto) EncryptWithAAD(data []byte, aad []byte) (string, error) {
 	if len(data) == 0 {
 		return "", fmt.Errorf("待加密数据不能为空")
 	}
@@ -56,7 +71,7 @@ func (a *AESCrypto) Encrypt(data []byte) (string, error) {
 	}
 
 	// 加密数据
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
+	ciphertext := gcm.Seal(nil, nonce, data, aad)
 
 	// 组合 nonce + ciphertext
 	encrypted := append(nonce, ciphertext...)
@@ -69,6 +84,11 @@ func (a *AESCrypto) Encrypt(data []byte) (string, error) {
 // encryptedData: base64编码的加密数据
 // 返回: 解密后的原始数据
 func (a *AESCrypto) Decrypt(encryptedData string) ([]byte, error) {
+	return a.DecryptWithAAD(encryptedData, nil)
+}
+
+// DecryptWithAAD 解密数据并校验附加认证数据
+func (a *AESCrypto) DecryptWithAAD(encryptedData string, aad []byte) ([]byte, error) {
 	if encryptedData == "" {
 		return nil, fmt.Errorf("加密数据不能为空")
 	}
@@ -101,7 +121,7 @@ func (a *AESCrypto) Decrypt(encryptedData string) ([]byte, error) {
 	ciphertext := encrypted[nonceSize:]
 
 	// 解密数据
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		return nil, fmt.Errorf("解密失败: %v", err)
 	}
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EnvelopeVersion 带 nonce 和时间戳签名的加密信封版本
+const EnvelopeVersion = 2
+
+// DefaultReplayWindow 默认允许的时间偏差
+const DefaultReplayWindow = 5 * time.Minute
+
+var (
+	ErrReplay         = errors.New("重复的消息nonce，疑似重放")
+	ErrExpired        = errors.New("消息时间戳超出允许范围")
+	ErrLegacyEnvelope = errors.New("消息未携带签名的nonce和时间戳")
+)
+
+// Envelope 加密消息信封
+// v2 信封的 nonce 和 timestamp 作为 AES-GCM 的附加认证数据，篡改任一字段都会导致解密失败
+type Envelope struct {
+	Encrypted bool   `json:"encrypted"`
+	Version   int    `json:"v,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	Data      string `json:"data"`
+}
+
+// NewNonce 生成随机 nonce（16字节十六进制）
+func NewNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func envelopeAAD(timestamp int64, nonce string) []byte {
+	return []byte(strconv.FormatInt(timestamp, 10) + "|" + nonce)
+}
+
+// Seal 加密数据并封装为 v2 信封
+func (a *AESCrypto) Seal(data []byte) ([]byte, error) {
+	env := Envelope{
+		Encrypted: true,
+		Version:   EnvelopeVersion,
+		Nonce:     NewNonce(),
+		Timestamp: time.Now().Unix(),
+	}
+	encrypted, err := a.EncryptWithAAD(data, envelopeAAD(env.Timestamp, env.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	env.Data = encrypted
+	return json.Marshal(env)
+}
+
+// Open 校验并解密 v2 信封，guard 不为空时拒绝超出时间窗口或重复的 nonce
+func (a *AESCrypto) Open(env *Envelope, guard *ReplayGuard) ([]byte, error) {
+	if env.Version < EnvelopeVersion || env.Nonce == "" {
+		return nil, ErrLegacyEnvelope
+	}
+	if guard != nil && !guard.inWindow(env.Timestamp) {
+		return nil, ErrExpired
+	}
+
+	data, err := a.DecryptWithAAD(env.Data, envelopeAAD(env.Timestamp, env.Nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	// 解密成功后再登记 nonce，避免伪造消息污染缓存
+	if guard != nil {
+		if err := guard.Check(env.Nonce, env.Timestamp); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// DeriveSessionKey 由节点密钥和握手双方的 nonce 派生单次连接的会话密钥
+func DeriveSessionKey(secret, clientNonce, serverNonce string) []byte {
+	base := sha256.Sum256([]byte(secret))
+	mac := hmac.New(sha256.New, base[:])
+	mac.Write([]byte("flux-session|" + clientNonce + "|" + serverNonce))
+	return mac.Sum(nil)
+}
+
+// ReplayGuard 记录时间窗口内已使用的 nonce
+type ReplayGuard struct {
+	window    time.Duration
+	seen      map[string]int64
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+// NewReplayGuard 创建重放检测器
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	return &ReplayGuard{
+		window: window,
+		seen:   make(map[string]int64),
+	}
+}
+
+func (g *ReplayGuard) inWindow(timestamp int64) bool {
+	diff := time.Since(time.Unix(timestamp, 0))
+	return diff <= g.window && diff >= -g.window
+}
+
+// Check 检查时间戳是否在窗口内且 nonce 未被使用过，通过后登记该 nonce
+func (g *ReplayGuard) Check(nonce string, timestamp int64) error {
+	if !g.inWindow(timestamp) {
+		return ErrExpired
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(g.lastPrune) > g.window {
+		cutoff := now.Add(-g.window).Unix()
+		for n, ts := range g.seen {
+			if ts < cutoff {
+				delete(g.seen, n)
+			}
+		}
+		g.lastPrune = now
+	}
+
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplay
+	}
+	g.seen[nonce] = timestamp
+	return nil
+}
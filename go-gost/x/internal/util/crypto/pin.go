@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// VerifyCertPin 校验对端证书公钥指纹 (sha256(SPKI))；roots 不为空时同时校验证书链和主机名。
+// 未配置CA时握手只能证明对端持有叶子证书的私钥，因此只比对叶子证书；
+// 配置了CA时只比对校验通过的证书链中的证书，对端附带的其他证书不参与比对
+func VerifyCertPin(cs tls.ConnectionState, roots *x509.CertPool, pin []byte) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("面板未提供证书")
+	}
+
+	leaf := cs.PeerCertificates[0]
+	if roots == nil {
+		if matchPin(leaf, pin) {
+			return nil
+		}
+		return errors.New("面板证书指纹不匹配")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if matchPin(cert, pin) {
+				return nil
+			}
+		}
+	}
+	return errors.New("面板证书指纹不匹配")
+}
+
+// matchPin 证书公钥的 sha256 是否与指纹一致
+func matchPin(cert *x509.Certificate, pin []byte) bool {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return subtle.ConstantTimeCompare(sum[:], pin) == 1
+}
@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert 创建证书，parent 为空时为自签名证书
+func newTestCert(t *testing.T, name string, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func pinOf(c *testCert) []byte {
+	sum := sha256.Sum256(c.cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+func TestVerifyCertPinPinOnly(t *testing.T) {
+	panel := newTestCert(t, "panel.example.com", false, nil)
+	forged := newTestCert(t, "panel.example.com", false, nil)
+	pin := pinOf(panel)
+
+	testCases := []struct {
+		desc  string
+		certs []*x509.Certificate
+		ok    bool
+	}{
+		{desc: "pinned leaf", certs: []*x509.Certificate{panel.cert}, ok: true},
+		{desc: "forged leaf", certs: []*x509.Certificate{forged.cert}},
+		{desc: "forged leaf with the pinned cert appended", certs: []*x509.Certificate{forged.cert, panel.cert}},
+		{desc: "no certificate"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := VerifyCertPin(tls.ConnectionState{PeerCertificates: tc.certs, ServerName: "panel.example.com"}, nil, pin)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCertPinWithCA(t *testing.T) {
+	ca := newTestCert(t, "ca", true, nil)
+	leaf := newTestCert(t, "panel.example.com", false, ca)
+	other := newTestCert(t, "other", false, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	testCases := []struct {
+		desc       string
+		certs      []*x509.Certificate
+		serverName string
+		pin        []byte
+		ok         bool
+	}{
+		{desc: "pin on leaf", certs: []*x509.Certificate{leaf.cert}, serverName: "panel.example.com", pin: pinOf(leaf), ok: true},
+		{desc: "pin on CA", certs: []*x509.Certificate{leaf.cert}, serverName: "panel.example.com", pin: pinOf(ca), ok: true},
+		{desc: "pin on unverified extra cert", certs: []*x509.Certificate{leaf.cert, other.cert}, serverName: "panel.example.com", pin: pinOf(other)},
+		{desc: "wrong host name", certs: []*x509.Certificate{leaf.cert}, serverName: "evil.example.com", pin: pinOf(leaf)},
+		{desc: "untrusted leaf with the pinned CA appended", certs: []*x509.Certificate{other.cert, ca.cert}, serverName: "other", pin: pinOf(ca)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := VerifyCertPin(tls.ConnectionState{PeerCertificates: tc.certs, ServerName: tc.serverName}, roots, tc.pin)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
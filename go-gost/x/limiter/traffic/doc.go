@@ -0,0 +1,13 @@
+// Package traffic implements the traffic (bandwidth) limiter.
+//
+// Each limit is a line in the form of "KEY IN [OUT]", where KEY is "$" for the
+// whole service, "$$" for each connection, or an IP address or CIDR for clients,
+// and IN/OUT are rates in bytes per second such as "1MB" or "512KB".
+//
+// In addition to the upstream gost syntax, a rate may carry a burst size after
+// a slash, e.g. "$ 1MB/4MB 2MB/8MB" allows the service to send up to 4MB at once
+// while keeping an average input rate of 1MB/s. The burst is optional: when it
+// is missing, invalid, or smaller than the rate, the bucket size equals the rate,
+// which is the same behaviour as upstream gost. An invalid rate disables the
+// limit in that direction.
+package traffic
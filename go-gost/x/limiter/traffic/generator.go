@@ -5,14 +5,18 @@ import (
 )
 
 type limitGenerator struct {
-	in  int
-	out int
+	in       int
+	out      int
+	inBurst  int
+	outBurst int
 }
 
-func newLimitGenerator(in, out int) *limitGenerator {
+func newLimitGenerator(value limitValue) *limitGenerator {
 	return &limitGenerator{
-		in:  in,
-		out: out,
+		in:       value.in,
+		out:      value.out,
+		inBurst:  value.inBurst,
+		outBurst: value.outBurst,
 	}
 }
 
@@ -20,12 +24,12 @@ func (p *limitGenerator) In() limiter.Limiter {
 	if p == nil || p.in <= 0 {
 		return nil
 	}
-	return NewLimiter(p.in)
+	return NewBurstLimiter(p.in, p.inBurst)
 }
 
 func (p *limitGenerator) Out() limiter.Limiter {
 	if p == nil || p.out <= 0 {
 		return nil
 	}
-	return NewLimiter(p.out)
+	return NewBurstLimiter(p.out, p.outBurst)
 }
@@ -12,12 +12,34 @@ import (
 
 type llimiter struct {
 	limiter *rate.Limiter
+	burst   int
 }
 
 func NewLimiter(r int) limiter.Limiter {
+	return NewBurstLimiter(r, 0)
+}
+
+// NewBurstLimiter creates a limiter with rate r and bucket size b,
+// b defaults to r if it is less than r.
+func NewBurstLimiter(r, b int) limiter.Limiter {
+	if b < r {
+		b = 0
+	}
 	return &llimiter{
-		limiter: rate.NewLimiter(rate.Limit(r), r),
+		limiter: rate.NewLimiter(rate.Limit(r), max(r, b)),
+		burst:   b,
+	}
+}
+
+// setLimit updates the rate and burst of limiter lim.
+func setLimit(lim limiter.Limiter, r, b int) {
+	if l, ok := lim.(*llimiter); ok {
+		if b < r {
+			b = 0
+		}
+		l.burst = b
 	}
+	lim.Set(r)
 }
 
 func (l *llimiter) Wait(ctx context.Context, n int) int {
@@ -34,7 +56,7 @@ func (l *llimiter) Limit() int {
 
 func (l *llimiter) Set(n int) {
 	l.limiter.SetLimit(rate.Limit(n))
-	l.limiter.SetBurst(n)
+	l.limiter.SetBurst(max(n, l.burst))
 }
 
 func (l *llimiter) String() string {
@@ -76,8 +76,10 @@ func LoggerOption(logger logger.Logger) Option {
 }
 
 type limitValue struct {
-	in  int
-	out int
+	in       int
+	out      int
+	inBurst  int
+	outBurst int
 }
 
 type trafficLimiter struct {
@@ -313,11 +315,11 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 			if value.in <= 0 {
 				l.inLimits.Delete(ServiceLimitKey)
 			} else {
-				lim.Set(value.in)
+				setLimit(lim, value.in, value.inBurst)
 			}
 		} else {
 			if value.in > 0 {
-				l.inLimits.Set(ServiceLimitKey, NewLimiter(value.in), cache.NoExpiration)
+				l.inLimits.Set(ServiceLimitKey, NewBurstLimiter(value.in, value.inBurst), cache.NoExpiration)
 			}
 		}
 
@@ -326,11 +328,11 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 			if value.out <= 0 {
 				l.outLimits.Delete(ServiceLimitKey)
 			} else {
-				lim.Set(value.out)
+				setLimit(lim, value.out, value.outBurst)
 			}
 		} else {
 			if value.out > 0 {
-				l.outLimits.Set(ServiceLimitKey, NewLimiter(value.out), cache.NoExpiration)
+				l.outLimits.Set(ServiceLimitKey, NewBurstLimiter(value.out, value.outBurst), cache.NoExpiration)
 			}
 		}
 		delete(values, ServiceLimitKey)
@@ -340,19 +342,19 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 	{
 		value := values[ConnLimitKey]
 
-		var in, out int
+		var old limitGenerator
 		if v, _ := l.generators.Load(ConnLimitKey); v != nil {
-			in, out = v.(*limitGenerator).in, v.(*limitGenerator).out
+			old = *v.(*limitGenerator)
 		}
-		l.generators.Store(ConnLimitKey, newLimitGenerator(value.in, value.out))
+		l.generators.Store(ConnLimitKey, newLimitGenerator(value))
 
 		if value.in <= 0 {
 			l.connInLimits.Flush()
 		} else {
-			if in != value.in {
+			if old.in != value.in || old.inBurst != value.inBurst {
 				for _, item := range l.connInLimits.Items() {
 					if v := item.Object; v != nil {
-						v.(traffic.Limiter).Set(in)
+						setLimit(v.(traffic.Limiter), value.in, value.inBurst)
 					}
 				}
 			}
@@ -361,10 +363,10 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 		if value.out <= 0 {
 			l.connOutLimits.Flush()
 		} else {
-			if out != value.out {
+			if old.out != value.out || old.outBurst != value.outBurst {
 				for _, item := range l.connOutLimits.Items() {
 					if v := item.Object; v != nil {
-						v.(traffic.Limiter).Set(out)
+						setLimit(v.(traffic.Limiter), value.out, value.outBurst)
 					}
 				}
 			}
@@ -386,7 +388,7 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 			if _, ipNet, _ := net.ParseCIDR(key); ipNet != nil {
 				cidrGenerators.Insert(&cidrLimitEntry{
 					ipNet:     *ipNet,
-					generator: newLimitGenerator(value.in, value.out),
+					generator: newLimitGenerator(value),
 				})
 				continue
 			}
@@ -396,12 +398,12 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 				if value.in <= 0 {
 					l.inLimits.Delete(key)
 				} else {
-					lim.Set(value.in)
+					setLimit(lim, value.in, value.inBurst)
 				}
 				delete(inLimits, key)
 			} else {
 				if value.in > 0 {
-					l.inLimits.Set(key, NewLimiter(value.in), cache.NoExpiration)
+					l.inLimits.Set(key, NewBurstLimiter(value.in, value.inBurst), cache.NoExpiration)
 				}
 			}
 
@@ -410,12 +412,12 @@ func (l *trafficLimiter) reload(ctx context.Context) error {
 				if value.out <= 0 {
 					l.outLimits.Delete(key)
 				} else {
-					lim.Set(value.out)
+					setLimit(lim, value.out, value.outBurst)
 				}
 				delete(outLimits, key)
 			} else {
 				if value.out > 0 {
-					l.outLimits.Set(key, NewLimiter(value.out), cache.NoExpiration)
+					l.outLimits.Set(key, NewBurstLimiter(value.out, value.outBurst), cache.NoExpiration)
 				}
 			}
 		}
@@ -470,11 +472,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 	values = make(map[string]limitValue)
 
 	for _, v := range l.options.limits {
-		key, in, out := l.parseLimit(v)
+		key, value := l.parseLimit(v)
 		if key == "" {
 			continue
 		}
-		values[key] = limitValue{in: in, out: out}
+		values[key] = value
 	}
 
 	if l.options.fileLoader != nil {
@@ -484,11 +486,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 				l.options.logger.Warnf("file loader: %v", er)
 			}
 			for _, s := range list {
-				key, in, out := l.parseLimit(l.parseLine(s))
+				key, value := l.parseLimit(l.parseLine(s))
 				if key == "" {
 					continue
 				}
-				values[key] = limitValue{in: in, out: out}
+				values[key] = value
 			}
 		} else {
 			r, er := l.options.fileLoader.Load(ctx)
@@ -497,11 +499,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 			}
 			patterns, _ := l.parsePatterns(r)
 			for _, s := range patterns {
-				key, in, out := l.parseLimit(l.parseLine(s))
+				key, value := l.parseLimit(l.parseLine(s))
 				if key == "" {
 					continue
 				}
-				values[key] = limitValue{in: in, out: out}
+				values[key] = value
 			}
 		}
 	}
@@ -512,11 +514,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 				l.options.logger.Warnf("redis loader: %v", er)
 			}
 			for _, s := range list {
-				key, in, out := l.parseLimit(l.parseLine(s))
+				key, value := l.parseLimit(l.parseLine(s))
 				if key == "" {
 					continue
 				}
-				values[key] = limitValue{in: in, out: out}
+				values[key] = value
 			}
 		} else {
 			r, er := l.options.redisLoader.Load(ctx)
@@ -525,11 +527,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 			}
 			patterns, _ := l.parsePatterns(r)
 			for _, s := range patterns {
-				key, in, out := l.parseLimit(l.parseLine(s))
+				key, value := l.parseLimit(l.parseLine(s))
 				if key == "" {
 					continue
 				}
-				values[key] = limitValue{in: in, out: out}
+				values[key] = value
 			}
 		}
 	}
@@ -540,11 +542,11 @@ func (l *trafficLimiter) load(ctx context.Context) (values map[string]limitValue
 		}
 		patterns, _ := l.parsePatterns(r)
 		for _, s := range patterns {
-			key, in, out := l.parseLimit(l.parseLine(s))
+			key, value := l.parseLimit(l.parseLine(s))
 			if key == "" {
 				continue
 			}
-			values[key] = limitValue{in: in, out: out}
+			values[key] = value
 		}
 	}
 
@@ -575,7 +577,9 @@ func (l *trafficLimiter) parseLine(s string) string {
 	return strings.TrimSpace(s)
 }
 
-func (l *trafficLimiter) parseLimit(s string) (key string, in, out int) {
+// parseLimit parses a limit line in the form of "KEY IN [OUT]",
+// each rate may carry an optional burst size, e.g. "$ 1MB/4MB 2MB/8MB".
+func (l *trafficLimiter) parseLimit(s string) (key string, value limitValue) {
 	s = strings.Replace(s, "\t", " ", -1)
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -593,18 +597,30 @@ func (l *trafficLimiter) parseLimit(s string) (key string, in, out int) {
 	}
 
 	key = ss[0]
-	if v, _ := units.ParseBase2Bytes(ss[1]); v > 0 {
-		in = int(v)
-	}
+	value.in, value.inBurst = parseRate(ss[1])
 	if len(ss) > 2 {
-		if v, _ := units.ParseBase2Bytes(ss[2]); v > 0 {
-			out = int(v)
-		}
+		value.out, value.outBurst = parseRate(ss[2])
 	}
 
 	return
 }
 
+// parseRate parses a rate with an optional burst size, e.g. "1MB" or "1MB/4MB".
+// The burst is dropped if the rate is invalid or the burst is not larger than the rate.
+func parseRate(s string) (rate, burst int) {
+	r, b, _ := strings.Cut(s, "/")
+	if v, _ := units.ParseBase2Bytes(r); v > 0 {
+		rate = int(v)
+	}
+	if rate == 0 || b == "" {
+		return
+	}
+	if v, _ := units.ParseBase2Bytes(b); int(v) > rate {
+		burst = int(v)
+	}
+	return
+}
+
 func (l *trafficLimiter) Close() error {
 	l.cancelFunc()
 	if l.options.fileLoader != nil {
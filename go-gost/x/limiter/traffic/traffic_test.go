@@ -0,0 +1,107 @@
+package traffic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		line  string
+		key   string
+		value limitValue
+	}{
+		{
+			desc:  "in only",
+			line:  "$ 1MB",
+			key:   "$",
+			value: limitValue{in: 1 << 20},
+		},
+		{
+			desc:  "in and out",
+			line:  "$$ 1MB 512KB",
+			key:   "$$",
+			value: limitValue{in: 1 << 20, out: 512 << 10},
+		},
+		{
+			desc:  "in and out with burst",
+			line:  "$ 1MB/4MB\t2MB/8MB",
+			key:   "$",
+			value: limitValue{in: 1 << 20, inBurst: 4 << 20, out: 2 << 20, outBurst: 8 << 20},
+		},
+		{
+			desc:  "burst on one direction",
+			line:  "192.168.1.0/24 1MB 2MB/8MB",
+			key:   "192.168.1.0/24",
+			value: limitValue{in: 1 << 20, out: 2 << 20, outBurst: 8 << 20},
+		},
+		{
+			desc:  "empty burst",
+			line:  "$ 1MB/",
+			key:   "$",
+			value: limitValue{in: 1 << 20},
+		},
+		{
+			desc:  "invalid burst keeps the rate",
+			line:  "$ 1MB/abc",
+			key:   "$",
+			value: limitValue{in: 1 << 20},
+		},
+		{
+			desc:  "burst not larger than the rate",
+			line:  "$ 4MB/1MB",
+			key:   "$",
+			value: limitValue{in: 4 << 20},
+		},
+		{
+			desc:  "invalid rate disables the limit",
+			line:  "$ abc/4MB 1MB",
+			key:   "$",
+			value: limitValue{out: 1 << 20},
+		},
+		{
+			desc: "missing rate",
+			line: "$",
+		},
+		{
+			desc: "empty line",
+			line: "   ",
+		},
+	}
+
+	l := &trafficLimiter{}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			key, value := l.parseLimit(tc.line)
+			assert.Equal(t, tc.key, key)
+			assert.Equal(t, tc.value, value)
+		})
+	}
+}
+
+func TestBurstLimiter(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		rate  int
+		burst int
+		want  int
+	}{
+		{desc: "no burst", rate: 1024, burst: 0, want: 1024},
+		{desc: "burst larger than rate", rate: 1024, burst: 4096, want: 4096},
+		{desc: "burst smaller than rate", rate: 1024, burst: 512, want: 1024},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			lim := NewBurstLimiter(tc.rate, tc.burst).(*llimiter)
+			assert.Equal(t, tc.rate, lim.Limit())
+			assert.Equal(t, tc.want, lim.limiter.Burst())
+
+			// 调整速率后保留突发大小
+			lim.Set(tc.rate * 2)
+			assert.Equal(t, max(tc.rate*2, tc.want), lim.limiter.Burst())
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// historySize 内存中保留的最近日志条数
+const historySize = 1000
+
+// Record 一条结构化日志记录
+type Record struct {
+	Time    int64             `json:"time"` // 毫秒时间戳
+	Level   string            `json:"level"`
+	Kind    string            `json:"kind,omitempty"`
+	Service string            `json:"service,omitempty"`
+	Message string            `json:"msg"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// recorder 环形缓冲区及实时订阅者
+type recorder struct {
+	mu     sync.RWMutex
+	buf    []Record
+	next   int
+	full   bool
+	subs   map[int]chan Record
+	nextID int
+}
+
+var std = &recorder{
+	buf:  make([]Record, historySize),
+	subs: make(map[int]chan Record),
+}
+
+func (r *recorder) add(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf[r.next] = rec
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	// 订阅者处理不过来时丢弃，不阻塞日志输出
+	for _, ch := range r.subs {
+		select {
+		case ch <- rec:
+		default:
+		}
+	}
+}
+
+// Recent 返回最近不超过 n 条满足条件的日志，按时间先后排列
+func Recent(n int, match func(Record) bool) []Record {
+	std.mu.RLock()
+	defer std.mu.RUnlock()
+
+	size := std.next
+	if std.full {
+		size = len(std.buf)
+	}
+
+	var records []Record
+	for i := 1; i <= size && len(records) < n; i++ {
+		rec := std.buf[(std.next-i+len(std.buf))%len(std.buf)]
+		if match == nil || match(rec) {
+			records = append(records, rec)
+		}
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records
+}
+
+// Subscribe 订阅新产生的日志，返回订阅 ID 和接收通道
+func Subscribe(size int) (int, <-chan Record) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+
+	std.nextID++
+	ch := make(chan Record, size)
+	std.subs[std.nextID] = ch
+	return std.nextID, ch
+}
+
+// Unsubscribe 取消订阅
+func Unsubscribe(id int) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+
+	delete(std.subs, id)
+}
+
+// MatchService 判断日志所属服务是否匹配名称，名称也可以是服务名去掉协议后缀的前缀（如 1_2_3 匹配 1_2_3_tcp）
+func MatchService(name, service string) bool {
+	return service == name || strings.HasPrefix(service, name+"_")
+}
+
+// captureHook 将 logrus 输出的日志写入环形缓冲区
+type captureHook struct{}
+
+func (captureHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (captureHook) Fire(e *logrus.Entry) error {
+	rec := Record{
+		Time:    e.Time.UnixMilli(),
+		Level:   e.Level.String(),
+		Message: e.Message,
+	}
+	for k, v := range e.Data {
+		switch k {
+		case "kind":
+			rec.Kind = fmt.Sprint(v)
+		case "service":
+			rec.Service = fmt.Sprint(v)
+		default:
+			if rec.Fields == nil {
+				rec.Fields = make(map[string]string, len(e.Data))
+			}
+			rec.Fields[k] = fmt.Sprint(v)
+		}
+	}
+	std.add(rec)
+	return nil
+}
+
+var (
+	stdoutOnce sync.Once
+	stdout     = os.Stdout
+)
+
+// Stdout 返回接管前的标准输出，日志等需要直接写终端的输出应使用它以免被重复采集
+func Stdout() *os.File {
+	return stdout
+}
+
+// CaptureStdout 接管标准输出，将程序直接打印的内容逐行写入环形缓冲区后再原样输出
+func CaptureStdout() error {
+	var err error
+	stdoutOnce.Do(func() {
+		var r, w *os.File
+		r, w, err = os.Pipe()
+		if err != nil {
+			return
+		}
+		os.Stdout = w
+
+		go func() {
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(make([]byte, maxStdoutLine), 1024*1024)
+			scanner.Split(scanStdoutLines)
+			for scanner.Scan() {
+				line := scanner.Text()
+				stdout.WriteString(line + "\n")
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				std.add(Record{
+					Time:    time.Now().UnixMilli(),
+					Level:   string(stdoutLevel(line)),
+					Kind:    "agent",
+					Message: line,
+				})
+			}
+			// 读取出错时不再采集，但仍需持续转发，否则管道写满后所有打印都会阻塞
+			if err := scanner.Err(); err != nil {
+				fmt.Fprintf(stdout, "⚠️ 停止采集标准输出: %v\n", err)
+				io.Copy(stdout, r)
+			}
+		}()
+	})
+	return err
+}
+
+// maxStdoutLine 采集的单行最大长度，超过时按该长度拆分为多行
+const maxStdoutLine = 64 * 1024
+
+// scanStdoutLines 按行拆分标准输出，超长的行拆分为多段，避免扫描因行过长而中止
+func scanStdoutLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	advance, token, err = bufio.ScanLines(data, atEOF)
+	if advance == 0 && token == nil && err == nil && len(data) >= maxStdoutLine {
+		return maxStdoutLine, data[:maxStdoutLine], nil
+	}
+	return advance, token, err
+}
+
+// stdoutLevel 根据节点程序输出的提示符号推断日志级别
+func stdoutLevel(line string) logger.LogLevel {
+	switch {
+	case strings.HasPrefix(line, "❌"):
+		return logger.ErrorLevel
+	case strings.HasPrefix(line, "⚠️"):
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
+
+// levelOverride 服务临时日志级别
+type levelOverride struct {
+	level  logrus.Level
+	expire time.Time
+}
+
+// ServiceLevel 服务临时日志级别信息
+type ServiceLevel struct {
+	Service string `json:"service"`
+	Level   string `json:"level"`
+	Expire  int64  `json:"expire"` // 毫秒时间戳
+}
+
+var (
+	overrideMu      sync.RWMutex
+	overrides       = make(map[string]levelOverride)
+	elevatedLoggers sync.Map // *logrus.Logger -> 放开级别的副本
+)
+
+// SetServiceLevel 在 d 时间内将服务的日志级别临时调整为 level，d 为 0 时恢复默认级别
+func SetServiceLevel(service string, level logger.LogLevel, d time.Duration) error {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+
+	pruneOverrides()
+	if d <= 0 {
+		delete(overrides, service)
+		return nil
+	}
+	lvl, err := logrus.ParseLevel(string(level))
+	if err != nil {
+		return fmt.Errorf("无效的日志级别: %s", level)
+	}
+	overrides[service] = levelOverride{level: lvl, expire: time.Now().Add(d)}
+	return nil
+}
+
+// ServiceLevels 返回当前生效的服务临时日志级别
+func ServiceLevels() []ServiceLevel {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+
+	now := time.Now()
+	var levels []ServiceLevel
+	for name, o := range overrides {
+		if now.After(o.expire) {
+			continue
+		}
+		levels = append(levels, ServiceLevel{
+			Service: name,
+			Level:   o.level.String(),
+			Expire:  o.expire.UnixMilli(),
+		})
+	}
+	return levels
+}
+
+// pruneOverrides 清理已过期的临时级别，调用方需持有写锁
+func pruneOverrides() {
+	now := time.Now()
+	for name, o := range overrides {
+		if now.After(o.expire) {
+			delete(overrides, name)
+		}
+	}
+	if len(overrides) == 0 {
+		elevatedLoggers.Range(func(k, _ any) bool {
+			elevatedLoggers.Delete(k)
+			return true
+		})
+	}
+}
+
+// serviceLevelEnabled 判断服务的临时级别是否允许输出该级别的日志
+func serviceLevelEnabled(service string, level logrus.Level) bool {
+	if service == "" {
+		return false
+	}
+
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+
+	if len(overrides) == 0 {
+		return false
+	}
+	now := time.Now()
+	for name, o := range overrides {
+		if o.level >= level && now.Before(o.expire) && MatchService(name, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// elevated 返回与 e 输出相同、但不受原级别限制的 Entry
+func elevated(e *logrus.Entry) *logrus.Entry {
+	v, ok := elevatedLoggers.Load(e.Logger)
+	if !ok {
+		base := e.Logger
+		v, _ = elevatedLoggers.LoadOrStore(base, &logrus.Logger{
+			Out:          base.Out,
+			Hooks:        base.Hooks,
+			Formatter:    base.Formatter,
+			ReportCaller: base.ReportCaller,
+			Level:        logrus.TraceLevel,
+			ExitFunc:     base.ExitFunc,
+		})
+	}
+	return &logrus.Entry{
+		Logger:  v.(*logrus.Logger),
+		Data:    e.Data,
+		Context: e.Context,
+	}
+}
+
+// SetLevel 调整日志记录器的级别，由同一记录器派生的服务日志同时生效
+func SetLevel(l logger.Logger, level logger.LogLevel) error {
+	lvl, err := logrus.ParseLevel(string(level))
+	if err != nil {
+		return fmt.Errorf("无效的日志级别: %s", level)
+	}
+	ll, ok := l.(*logrusLogger)
+	if !ok {
+		return fmt.Errorf("当前日志记录器不支持调整级别")
+	}
+	ll.logger.Logger.SetLevel(lvl)
+	return nil
+}
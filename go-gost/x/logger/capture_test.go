@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanStdoutLines(t *testing.T) {
+	long := strings.Repeat("x", maxStdoutLine*2+10)
+	input := "first\n" + long + "\nlast\n"
+
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Buffer(make([]byte, maxStdoutLine), 1024*1024)
+	scanner.Split(scanStdoutLines)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, []string{
+		"first",
+		long[:maxStdoutLine],
+		long[maxStdoutLine : 2*maxStdoutLine],
+		long[2*maxStdoutLine:],
+		"last",
+	}, lines)
+}
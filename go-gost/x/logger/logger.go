@@ -44,7 +44,8 @@ func LevelOption(level logger.LogLevel) Option {
 }
 
 type logrusLogger struct {
-	logger *logrus.Entry
+	logger  *logrus.Entry
+	service string
 }
 
 func NewLogger(opts ...Option) logger.Logger {
@@ -83,6 +84,7 @@ func NewLogger(opts ...Option) logger.Logger {
 	default:
 		log.SetLevel(logrus.InfoLevel)
 	}
+	log.AddHook(captureHook{})
 
 	l := &logrusLogger{
 		logger: logrus.NewEntry(log),
@@ -96,8 +98,13 @@ func NewLogger(opts ...Option) logger.Logger {
 
 // WithFields adds new fields to log.
 func (l *logrusLogger) WithFields(fields map[string]any) logger.Logger {
+	service := l.service
+	if v, ok := fields["service"].(string); ok {
+		service = v
+	}
 	return &logrusLogger{
-		logger: l.logger.WithFields(logrus.Fields(fields)),
+		logger:  l.logger.WithFields(logrus.Fields(fields)),
+		service: service,
 	}
 }
 
@@ -169,20 +176,29 @@ func (l *logrusLogger) GetLevel() logger.LogLevel {
 
 func (l *logrusLogger) IsLevelEnabled(level logger.LogLevel) bool {
 	lvl, _ := logrus.ParseLevel(string(level))
-	return l.logger.Logger.IsLevelEnabled(lvl)
+	return l.logger.Logger.IsLevelEnabled(lvl) || serviceLevelEnabled(l.service, lvl)
+}
+
+// entry returns the entry used to log at level, bypassing the logger level
+// while the service's log level is temporarily raised.
+func (l *logrusLogger) entry(level logrus.Level) *logrus.Entry {
+	if !l.logger.Logger.IsLevelEnabled(level) && serviceLevelEnabled(l.service, level) {
+		return elevated(l.logger)
+	}
+	return l.logger
 }
 
 func (l *logrusLogger) log(level logrus.Level, args ...any) {
-	lg := l.logger
-	if l.logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
+	lg := l.entry(level)
+	if lg.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		lg = lg.WithField("caller", l.caller(3))
 	}
 	lg.Log(level, args...)
 }
 
 func (l *logrusLogger) logf(level logrus.Level, format string, args ...any) {
-	lg := l.logger
-	if l.logger.Logger.IsLevelEnabled(logrus.DebugLevel) {
+	lg := l.entry(level)
+	if lg.Logger.IsLevelEnabled(logrus.DebugLevel) {
 		lg = lg.WithField("caller", l.caller(3))
 	}
 	lg.Logf(level, format, args...)
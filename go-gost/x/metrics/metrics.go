@@ -25,6 +25,8 @@ const (
 	MetricServiceHandlerErrorsCounter metrics.MetricName = "gost_service_handler_errors_total"
 	// Total chain connect errors. Labels: host, chain, node.
 	MetricChainErrorsCounter metrics.MetricName = "gost_chain_errors_total"
+	// Total rejected control channel messages (replayed, expired or unsigned). Labels: host, reason.
+	MetricControlMessagesRejectedCounter metrics.MetricName = "gost_control_messages_rejected_total"
 )
 
 var (
@@ -63,6 +63,12 @@ func NewMetrics() metrics.Metrics {
 					Help: "Total chain errors",
 				},
 				[]string{"host", "chain", "node"}),
+			MetricControlMessagesRejectedCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricControlMessagesRejectedCounter),
+					Help: "Total rejected control channel messages",
+				},
+				[]string{"host", "reason"}),
 		},
 		histograms: map[metrics.MetricName]*prometheus.HistogramVec{
 			MetricServiceRequestsDurationObserver: prometheus.NewHistogramVec(
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/recorder"
+	xrecorder "github.com/go-gost/x/recorder"
+	"github.com/go-gost/x/registry"
+)
+
+const (
+	// AccessLogRecorderName 连接日志记录器名，服务通过 recorders 引用
+	AccessLogRecorderName = "access_log"
+
+	accessLogBufferSize = 10000 // 本地最多缓存的日志条数，超出后丢弃
+	accessLogBatchSize  = 500   // 单次上报的最大条数
+)
+
+var accessLogReportURL string
+
+// AccessLogItem 连接日志项（压缩格式）
+type AccessLogItem struct {
+	N string `json:"n"`           // 服务名
+	W string `json:"w"`           // 网络类型 tcp/udp
+	C string `json:"c"`           // 客户端IP
+	H string `json:"h"`           // 实际连接的目标地址
+	I uint64 `json:"i"`           // 入站字节数
+	O uint64 `json:"o"`           // 出站字节数
+	E string `json:"e,omitempty"` // 错误信息
+	S int64  `json:"s"`           // 开始时间（毫秒）
+	T int64  `json:"t"`           // 结束时间（毫秒）
+}
+
+var (
+	accessLogs        []AccessLogItem
+	accessLogsDropped int64
+	accessLogsMutex   sync.Mutex
+)
+
+// accessLogRecorder 将服务处理记录转换为连接日志并缓存，等待批量上报
+type accessLogRecorder struct{}
+
+func (r *accessLogRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	var ro xrecorder.HandlerRecorderObject
+	if err := json.Unmarshal(b, &ro); err != nil {
+		return err
+	}
+
+	item := AccessLogItem{
+		N: ro.Service,
+		W: ro.Network,
+		C: ro.ClientIP,
+		H: ro.Host,
+		I: ro.InputBytes,
+		O: ro.OutputBytes,
+		E: ro.Err,
+		S: ro.Time.UnixMilli(),
+		T: ro.Time.Add(ro.Duration).UnixMilli(),
+	}
+
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
+
+	if len(accessLogs) >= accessLogBufferSize {
+		accessLogsDropped++
+		return nil
+	}
+	accessLogs = append(accessLogs, item)
+	return nil
+}
+
+// RegisterAccessLogRecorder 注册连接日志记录器（配置重载会清空记录器注册表，需在每次加载后调用）
+func RegisterAccessLogRecorder() {
+	registry.RecorderRegistry().Unregister(AccessLogRecorderName)
+	registry.RecorderRegistry().Register(AccessLogRecorderName, &accessLogRecorder{})
+}
+
+// takeAccessLogs 取出最多 n 条待上报日志
+func takeAccessLogs(n int) []AccessLogItem {
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
+
+	if n > len(accessLogs) {
+		n = len(accessLogs)
+	}
+	items := make([]AccessLogItem, n)
+	copy(items, accessLogs[:n])
+	accessLogs = accessLogs[n:]
+
+	if accessLogsDropped > 0 {
+		fmt.Printf("⚠️ 连接日志缓存已满，丢弃 %d 条\n", accessLogsDropped)
+		accessLogsDropped = 0
+	}
+	return items
+}
+
+// restoreAccessLogs 上报失败时把日志放回缓存头部（超出缓存上限的部分丢弃）
+func restoreAccessLogs(items []AccessLogItem) {
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
+
+	room := accessLogBufferSize - len(accessLogs)
+	if room <= 0 {
+		accessLogsDropped += int64(len(items))
+		return
+	}
+	if len(items) > room {
+		accessLogsDropped += int64(len(items) - room)
+		items = items[:room]
+	}
+	accessLogs = append(append([]AccessLogItem{}, items...), accessLogs...)
+}
+
+// sendAccessLogReport 发送连接日志到HTTP接口
+func sendAccessLogReport(ctx context.Context, items []AccessLogItem) (bool, error) {
+	jsonData, err := json.Marshal(items)
+	if err != nil {
+		return false, fmt.Errorf("序列化日志数据失败: %v", err)
+	}
+
+	// 如果有加密器，则封装为带 nonce 和时间戳签名的加密信封
+	requestBody := jsonData
+	if httpAESCrypto != nil {
+		if sealed, err := httpAESCrypto.Seal(jsonData); err != nil {
+			fmt.Printf("⚠️ 加密连接日志失败，发送原始数据: %v\n", err)
+		} else {
+			requestBody = sealed
+		}
+	}
+
+	req, err := newPanelRequest(ctx, accessLogReportURL, requestBody, "GOST-AccessLog-Reporter/1.0")
+	if err != nil {
+		return false, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	client := newPanelHTTPClient(10 * time.Second)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("发送HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("HTTP响应错误: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	var responseBytes bytes.Buffer
+	if _, err := responseBytes.ReadFrom(resp.Body); err != nil {
+		return false, fmt.Errorf("读取响应内容失败: %v", err)
+	}
+
+	responseText := strings.TrimSpace(responseBytes.String())
+	if responseText != "ok" {
+		return false, fmt.Errorf("服务器响应: %s (期望: ok)", responseText)
+	}
+	return true, nil
+}
+
+// StartAccessLogReporter 启动连接日志定时上报器（每10秒上报一次）
+func StartAccessLogReporter(ctx context.Context) {
+	if accessLogReportURL == "" {
+		fmt.Printf("⚠️ 连接日志上报URL未设置，跳过定时上报\n")
+		return
+	}
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for {
+				items := takeAccessLogs(accessLogBatchSize)
+				if len(items) == 0 {
+					break
+				}
+				if _, err := sendAccessLogReport(ctx, items); err != nil {
+					fmt.Printf("❌ 连接日志上报失败: %v\n", err)
+					restoreAccessLogs(items)
+					break
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
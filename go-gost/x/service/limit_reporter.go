@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LimitHitConn = "conn" // 并发连接数限制
+	LimitHitRate = "rate" // 新建连接速率限制
+)
+
+var limitReportURL string
+
+// LimitReportItem 限制命中报告项（压缩格式）
+type LimitReportItem struct {
+	N string `json:"n"` // 限流器名（与服务名一致）
+	C int64  `json:"c"` // 并发连接数限制命中次数
+	R int64  `json:"r"` // 新建连接速率限制命中次数
+}
+
+var (
+	limitHits      = make(map[string]*LimitReportItem)
+	limitHitsMutex sync.Mutex
+)
+
+// RecordLimitHit 记录一次连接被限制
+func RecordLimitHit(name string, kind string) {
+	limitHitsMutex.Lock()
+	defer limitHitsMutex.Unlock()
+
+	item, ok := limitHits[name]
+	if !ok {
+		item = &LimitReportItem{N: name}
+		limitHits[name] = item
+	}
+	switch kind {
+	case LimitHitConn:
+		item.C++
+	case LimitHitRate:
+		item.R++
+	}
+}
+
+// takeLimitHits 取出并清空当前累计的命中次数
+func takeLimitHits() []LimitReportItem {
+	limitHitsMutex.Lock()
+	defer limitHitsMutex.Unlock()
+
+	items := make([]LimitReportItem, 0, len(limitHits))
+	for _, item := range limitHits {
+		items = append(items, *item)
+	}
+	limitHits = make(map[string]*LimitReportItem)
+	return items
+}
+
+// restoreLimitHits 上报失败时把命中次数放回累计中
+func restoreLimitHits(items []LimitReportItem) {
+	limitHitsMutex.Lock()
+	defer limitHitsMutex.Unlock()
+
+	for _, it := range items {
+		item, ok := limitHits[it.N]
+		if !ok {
+			item = &LimitReportItem{N: it.N}
+			limitHits[it.N] = item
+		}
+		item.C += it.C
+		item.R += it.R
+	}
+}
+
+// sendLimitReport 发送限制命中报告到HTTP接口
+func sendLimitReport(ctx context.Context, items []LimitReportItem) (bool, error) {
+	jsonData, err := json.Marshal(items)
+	if err != nil {
+		return false, fmt.Errorf("序列化报告数据失败: %v", err)
+	}
+
+	// 如果有加密器，则封装为带 nonce 和时间戳签名的加密信封
+	requestBody := jsonData
+	if httpAESCrypto != nil {
+		if sealed, err := httpAESCrypto.Seal(jsonData); err != nil {
+			fmt.Printf("⚠️ 加密限制报告失败，发送原始数据: %v\n", err)
+		} else {
+			requestBody = sealed
+		}
+	}
+
+	req, err := newPanelRequest(ctx, limitReportURL, requestBody, "GOST-Limit-Reporter/1.0")
+	if err != nil {
+		return false, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	client := newPanelHTTPClient(5 * time.Second)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("发送HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("HTTP响应错误: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	var responseBytes bytes.Buffer
+	if _, err := responseBytes.ReadFrom(resp.Body); err != nil {
+		return false, fmt.Errorf("读取响应内容失败: %v", err)
+	}
+
+	responseText := strings.TrimSpace(responseBytes.String())
+	if responseText != "ok" {
+		return false, fmt.Errorf("服务器响应: %s (期望: ok)", responseText)
+	}
+	return true, nil
+}
+
+// StartLimitReporter 启动限制命中定时上报器（每30秒上报一次）
+func StartLimitReporter(ctx context.Context) {
+	if limitReportURL == "" {
+		fmt.Printf("⚠️ 限制上报URL未设置，跳过定时上报\n")
+		return
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			items := takeLimitHits()
+			if len(items) == 0 {
+				continue
+			}
+			if _, err := sendLimitReport(ctx, items); err != nil {
+				fmt.Printf("❌ 限制命中上报失败: %v\n", err)
+				restoreLimitHits(items)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
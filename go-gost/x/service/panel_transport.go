@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gost/x/internal/util/crypto"
+)
+
+// PanelSecretHeader 节点密钥请求头，密钥不再出现在URL中
+const PanelSecretHeader = "X-Node-Secret"
+
+// PanelTLSOptions 节点与面板之间控制通道的传输配置
+type PanelTLSOptions struct {
+	Enabled bool   // 使用 wss/https 连接面板
+	CAFile  string // 自定义 CA 证书文件（PEM），为空时使用系统根证书
+	Pin     string // 面板证书公钥指纹 base64(sha256(SPKI))，可带 sha256/ 前缀；仅配置指纹时不校验证书链
+}
+
+var (
+	panelTLSConfig *tls.Config
+	panelSecret    string
+)
+
+// SetPanelTransport 设置与面板通信的传输方式，需在启动上报器之前调用
+func SetPanelTransport(opts PanelTLSOptions) error {
+	if !opts.Enabled {
+		panelTLSConfig = nil
+		return nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var roots *x509.CertPool
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return errors.New("CA证书中没有有效的证书")
+		}
+		cfg.RootCAs = roots
+	}
+
+	if opts.Pin != "" {
+		pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(opts.Pin, "sha256/"))
+		if err != nil || len(pin) != sha256.Size {
+			return errors.New("证书指纹格式错误，应为 base64 编码的 sha256 值")
+		}
+		// 证书链和主机名由下面的回调自行校验，以便在只配置指纹时支持自签名证书
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return crypto.VerifyCertPin(cs, roots, pin)
+		}
+	}
+
+	panelTLSConfig = cfg
+	return nil
+}
+
+// PanelTLSConfig 返回连接面板使用的TLS配置，未启用TLS时为nil
+func PanelTLSConfig() *tls.Config {
+	return panelTLSConfig
+}
+
+// PanelHTTPURL 构建面板HTTP接口地址
+func PanelHTTPURL(addr, path string) string {
+	if panelTLSConfig != nil {
+		return "https://" + addr + path
+	}
+	return "http://" + addr + path
+}
+
+// PanelWSURL 构建面板WebSocket地址
+func PanelWSURL(addr, path string) string {
+	if panelTLSConfig != nil {
+		return "wss://" + addr + path
+	}
+	return "ws://" + addr + path
+}
+
+// newPanelRequest 创建携带节点密钥的上报请求
+func newPanelRequest(ctx context.Context, url string, body []byte, userAgent string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(PanelSecretHeader, panelSecret)
+	return req, nil
+}
+
+// newPanelHTTPClient 创建访问面板的HTTP客户端
+func newPanelHTTPClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if panelTLSConfig != nil {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: panelTLSConfig,
+		}
+	}
+	return client
+}
@@ -357,13 +357,18 @@ func (s *defaultService) observeStats(ctx context.Context) {
 		return
 	}
 
-	d := s.options.observerPeriod
-	if d == 0 {
-		d = 5 * time.Second
-	}
-	if d < time.Second {
-		d = 1 * time.Second
+	// 未单独配置上报周期时使用面板下发的默认周期，调整后在下次上报时生效
+	period := func() time.Duration {
+		d := s.options.observerPeriod
+		if d == 0 {
+			d = ObserverPeriod()
+		}
+		if d < time.Second {
+			d = 1 * time.Second
+		}
+		return d
 	}
+	d := period()
 
 	var events []observer.Event
 
@@ -373,6 +378,10 @@ func (s *defaultService) observeStats(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
+			if nd := period(); nd != d {
+				d = nd
+				ticker.Reset(d)
+			}
 
 			// First, try to send any pending events
 			if len(events) > 0 {
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultConfigReportInterval  = 10 * time.Minute
+	defaultServiceObserverPeriod = 5 * time.Second
+)
+
+var (
+	configReportInterval  atomic.Int64
+	serviceObserverPeriod atomic.Int64
+)
+
+// SetConfigReportInterval 设置配置定时上报间隔，d 为 0 时使用默认的 10 分钟，运行中的上报器在下次上报后生效
+func SetConfigReportInterval(d time.Duration) {
+	configReportInterval.Store(int64(d))
+}
+
+// ConfigReportInterval 返回配置定时上报间隔
+func ConfigReportInterval() time.Duration {
+	if d := time.Duration(configReportInterval.Load()); d > 0 {
+		return d
+	}
+	return defaultConfigReportInterval
+}
+
+// SetObserverPeriod 设置未单独配置上报周期的服务的流量统计上报周期，d 为 0 时使用默认的 5 秒
+func SetObserverPeriod(d time.Duration) {
+	serviceObserverPeriod.Store(int64(d))
+}
+
+// ObserverPeriod 返回服务默认的流量统计上报周期
+func ObserverPeriod() time.Duration {
+	if d := time.Duration(serviceObserverPeriod.Load()); d > 0 {
+		return d
+	}
+	return defaultServiceObserverPeriod
+}
+
+// ProtocolBlock 返回当前的协议屏蔽开关
+func ProtocolBlock() (httpOn int, tlsOn int, socksOn int) {
+	return isHttp, isTls, isSocks
+}
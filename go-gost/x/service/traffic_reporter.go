@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-gost/core/observer/stats"
@@ -27,8 +28,12 @@ type TrafficReportItem struct {
 }
 
 func SetHTTPReportURL(addr string, secret string) {
-	httpReportURL = "http://" + addr + "/flow/upload?secret=" + secret
-	configReportURL = "http://" + addr + "/flow/config?secret=" + secret
+	// 密钥通过请求头携带，不再拼接到URL中
+	panelSecret = secret
+	httpReportURL = PanelHTTPURL(addr, "/flow/upload")
+	configReportURL = PanelHTTPURL(addr, "/flow/config")
+	limitReportURL = PanelHTTPURL(addr, "/flow/limit")
+	accessLogReportURL = PanelHTTPURL(addr, "/flow/access")
 
 	// 创建 AES 加密器
 	var err error
@@ -48,42 +53,22 @@ func sendTrafficReport(ctx context.Context, reportItems TrafficReportItem) (bool
 		return false, fmt.Errorf("序列化报告数据失败: %v", err)
 	}
 
-	var requestBody []byte
-
-	// 如果有加密器，则加密数据
+	// 如果有加密器，则封装为带 nonce 和时间戳签名的加密信封
+	requestBody := jsonData
 	if httpAESCrypto != nil {
-		encryptedData, err := httpAESCrypto.Encrypt(jsonData)
-		if err != nil {
+		if sealed, err := httpAESCrypto.Seal(jsonData); err != nil {
 			fmt.Printf("⚠️ 加密流量报告失败，发送原始数据: %v\n", err)
-			requestBody = jsonData
 		} else {
-			// 创建加密消息包装器
-			encryptedMessage := map[string]interface{}{
-				"encrypted": true,
-				"data":      encryptedData,
-				"timestamp": time.Now().Unix(),
-			}
-			requestBody, err = json.Marshal(encryptedMessage)
-			if err != nil {
-				fmt.Printf("⚠️ 序列化加密流量报告失败，发送原始数据: %v\n", err)
-				requestBody = jsonData
-			}
+			requestBody = sealed
 		}
-	} else {
-		requestBody = jsonData
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", httpReportURL, bytes.NewBuffer(requestBody))
+	req, err := newPanelRequest(ctx, httpReportURL, requestBody, "GOST-Traffic-Reporter/1.0")
 	if err != nil {
 		return false, fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "GOST-Traffic-Reporter/1.0")
-
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+	client := newPanelHTTPClient(5 * time.Second)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -124,42 +109,22 @@ func sendConfigReport(ctx context.Context) (bool, error) {
 		return false, fmt.Errorf("获取配置数据失败: %v", err)
 	}
 
-	var requestBody []byte
-
-	// 如果有加密器，则加密数据
+	// 如果有加密器，则封装为带 nonce 和时间戳签名的加密信封
+	requestBody := configData
 	if httpAESCrypto != nil {
-		encryptedData, err := httpAESCrypto.Encrypt(configData)
-		if err != nil {
+		if sealed, err := httpAESCrypto.Seal(configData); err != nil {
 			fmt.Printf("⚠️ 加密配置报告失败，发送原始数据: %v\n", err)
-			requestBody = configData
 		} else {
-			// 创建加密消息包装器
-			encryptedMessage := map[string]interface{}{
-				"encrypted": true,
-				"data":      encryptedData,
-				"timestamp": time.Now().Unix(),
-			}
-			requestBody, err = json.Marshal(encryptedMessage)
-			if err != nil {
-				fmt.Printf("⚠️ 序列化加密配置报告失败，发送原始数据: %v\n", err)
-				requestBody = configData
-			}
+			requestBody = sealed
 		}
-	} else {
-		requestBody = configData
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", configReportURL, bytes.NewBuffer(requestBody))
+	req, err := newPanelRequest(ctx, configReportURL, requestBody, "Config-Reporter/1.0")
 	if err != nil {
 		return false, fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Config-Reporter/1.0")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second, // 配置上报可以稍长一些
-	}
+	client := newPanelHTTPClient(10 * time.Second) // 配置上报可以稍长一些
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -188,17 +153,18 @@ func sendConfigReport(ctx context.Context) (bool, error) {
 	}
 }
 
-// StartConfigReporter 启动配置定时上报器（每10分钟上报一次）
+// StartConfigReporter 启动配置定时上报器（默认每10分钟上报一次，间隔可由面板调整）
 func StartConfigReporter(ctx context.Context) {
 	if configReportURL == "" {
 		fmt.Printf("⚠️ 配置上报URL未设置，跳过定时上报\n")
 		return
 	}
 
-	fmt.Printf("🚀 配置定时上报器已启动，每10分钟上报一次（WebSocket连接稳定后启动）\n")
+	interval := ConfigReportInterval()
+	fmt.Printf("🚀 配置定时上报器已启动，每%v上报一次（WebSocket连接稳定后启动）\n", interval)
+	configReporterStarted.Store(true)
 
-	// 创建10分钟定时器
-	ticker := time.NewTicker(10 * time.Minute)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	// 立即执行一次配置上报
@@ -215,6 +181,10 @@ func StartConfigReporter(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
+			if d := ConfigReportInterval(); d != interval {
+				interval = d
+				ticker.Reset(interval)
+			}
 			go func() {
 				success, err := sendConfigReport(ctx)
 				if err != nil {
@@ -231,6 +201,22 @@ func StartConfigReporter(ctx context.Context) {
 	}
 }
 
+// configReporterStarted 配置上报器是否已启动，启动前服务尚未加载完成，不应上报
+var configReporterStarted atomic.Bool
+
+// ReportConfigNow 立即上报一次配置，供面板在节点重连后对账
+func ReportConfigNow() {
+	if !configReporterStarted.Load() {
+		return
+	}
+
+	go func() {
+		if _, err := sendConfigReport(context.Background()); err != nil {
+			fmt.Printf("❌ 重连配置上报失败: %v\n", err)
+		}
+	}()
+}
+
 // serviceStatus 接口定义
 type serviceStatus interface {
 	Status() *Status
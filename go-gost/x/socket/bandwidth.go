@@ -0,0 +1,553 @@
+package socket
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/service"
+	xchain "github.com/go-gost/x/chain"
+	"github.com/go-gost/x/config"
+	chainparser "github.com/go-gost/x/config/parsing/chain"
+	serviceparser "github.com/go-gost/x/config/parsing/service"
+)
+
+// 带宽测试参数限制，测试需要在面板的命令等待时间内完成
+const (
+	maxBandwidthTestDuration = 20
+	defaultUDPBandwidth      = 10 // Mbps
+	maxUDPBandwidth          = 1000
+	defaultUDPPacketSize     = 1200
+	bandwidthChunkSize       = 32 * 1024
+	bandwidthPingCount       = 4
+)
+
+// udpTestMagic UDP 测试报文头: magic(4) + seq(4) + 发送时间纳秒(8)
+var udpTestMagic = []byte("FXBW")
+
+// StartBandwidthServerRequest 在出口节点启动临时测试服务
+type StartBandwidthServerRequest struct {
+	Name     string `json:"name"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"` // 隧道协议，与隧道的远程服务一致
+	TTL      int    `json:"ttl"`      // 未收到停止命令时自动关闭的时间(秒)
+}
+
+// StopBandwidthServerRequest 停止临时测试服务
+type StopBandwidthServerRequest struct {
+	Name string `json:"name"`
+}
+
+// BandwidthServerStats 出口节点测得的接收统计
+type BandwidthServerStats struct {
+	TCPBytes      int64   `json:"tcpBytes"`
+	TCPBps        float64 `json:"tcpBps"`
+	UDPPackets    int64   `json:"udpPackets"`
+	UDPBytes      int64   `json:"udpBytes"`
+	UDPBps        float64 `json:"udpBps"`
+	UDPOutOfOrder int64   `json:"udpOutOfOrder"`
+	Jitter        float64 `json:"jitter"` // ms，RFC 3550 算法
+}
+
+// BandwidthTestRequest 在入口节点经隧道协议向出口节点发起测试
+type BandwidthTestRequest struct {
+	Addr       string `json:"addr"`   // 出口节点测试服务地址
+	Target     string `json:"target"` // 出口节点上的测试接收端地址
+	Protocol   string `json:"protocol"`
+	Interface  string `json:"interface,omitempty"`
+	Mode       string `json:"mode"`      // tcp, udp
+	Direction  string `json:"direction"` // upload: 入口->出口, download: 出口->入口 (仅 tcp)
+	Duration   int    `json:"duration"`  // 秒
+	Bandwidth  int    `json:"bandwidth"` // UDP 发送速率(Mbps)
+	PacketSize int    `json:"packetSize"`
+}
+
+// BandwidthTestResult 入口节点测得的结果
+type BandwidthTestResult struct {
+	Mode         string  `json:"mode"`
+	Direction    string  `json:"direction"`
+	Duration     float64 `json:"duration"` // 实际耗时(秒)
+	Bytes        int64   `json:"bytes"`
+	Bps          float64 `json:"bps"`
+	Latency      float64 `json:"latency"` // 经隧道的往返时延(ms)
+	Sent         int64   `json:"sent"`    // UDP 发送的报文数
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+}
+
+// bandwidthServer 临时测试服务及其接收端
+type bandwidthServer struct {
+	svc   service.Service
+	tcpLn net.Listener
+	udpPc net.PacketConn
+	timer *time.Timer
+
+	mutex       sync.Mutex
+	stats       BandwidthServerStats
+	tcpFirst    time.Time
+	tcpLast     time.Time
+	udpFirst    time.Time
+	udpLast     time.Time
+	udpMaxSeq   int64
+	lastTransit int64
+}
+
+var (
+	bandwidthServers      = make(map[string]*bandwidthServer)
+	bandwidthServersMutex sync.Mutex
+)
+
+// handleStartBandwidthServer 启动临时测试服务：按隧道协议监听的 relay 服务转发到本机的测试接收端。
+// 测试服务不注册到服务列表、不写入配置，也不挂载流量统计
+func (w *WebSocketReporter) handleStartBandwidthServer(data interface{}) (map[string]string, error) {
+	var req StartBandwidthServerRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return nil, err
+	}
+	if req.Name == "" || req.Port <= 0 || req.Port > 65535 || req.Protocol == "" {
+		return nil, errors.New("测试服务参数不完整")
+	}
+	if req.TTL <= 0 {
+		req.TTL = 60
+	}
+
+	bandwidthServersMutex.Lock()
+	defer bandwidthServersMutex.Unlock()
+	if _, exists := bandwidthServers[req.Name]; exists {
+		return nil, fmt.Errorf("测试服务 %s 已存在", req.Name)
+	}
+
+	server, err := listenBandwidthSink()
+	if err != nil {
+		return nil, err
+	}
+	sinkAddr := server.tcpLn.Addr().String()
+
+	cfg := &config.ServiceConfig{
+		Name:     req.Name,
+		Addr:     fmt.Sprintf(":%d", req.Port),
+		Handler:  &config.HandlerConfig{Type: "relay"},
+		Listener: &config.ListenerConfig{Type: req.Protocol},
+		Forwarder: &config.ForwarderConfig{
+			Nodes: []*config.ForwardNodeConfig{{Name: "sink", Addr: sinkAddr}},
+		},
+	}
+	svc, err := serviceparser.ParseService(cfg)
+	if err != nil {
+		server.closeSink()
+		return nil, fmt.Errorf("创建测试服务失败: %v", err)
+	}
+	server.svc = svc
+	go svc.Serve()
+
+	name := req.Name
+	server.timer = time.AfterFunc(time.Duration(req.TTL)*time.Second, func() {
+		if s := removeBandwidthServer(name); s != nil {
+			s.close()
+			fmt.Printf("⏱️ 测试服务 %s 超时自动关闭\n", name)
+		}
+	})
+	bandwidthServers[req.Name] = server
+
+	fmt.Printf("🚀 测试服务 %s 已启动，协议: %s，端口: %d\n", req.Name, req.Protocol, req.Port)
+	return map[string]string{"target": sinkAddr}, nil
+}
+
+// handleStopBandwidthServer 停止测试服务并返回接收统计
+func (w *WebSocketReporter) handleStopBandwidthServer(data interface{}) (BandwidthServerStats, error) {
+	var req StopBandwidthServerRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return BandwidthServerStats{}, err
+	}
+
+	server := removeBandwidthServer(req.Name)
+	if server == nil {
+		return BandwidthServerStats{}, fmt.Errorf("测试服务 %s 不存在", req.Name)
+	}
+	server.timer.Stop()
+	server.waitIdle(300*time.Millisecond, 2*time.Second)
+	server.close()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	stats := server.stats
+	if d := server.tcpLast.Sub(server.tcpFirst).Seconds(); d > 0 {
+		stats.TCPBps = float64(stats.TCPBytes*8) / d
+	}
+	if d := server.udpLast.Sub(server.udpFirst).Seconds(); d > 0 {
+		stats.UDPBps = float64(stats.UDPBytes*8) / d
+	}
+	return stats, nil
+}
+
+func removeBandwidthServer(name string) *bandwidthServer {
+	bandwidthServersMutex.Lock()
+	defer bandwidthServersMutex.Unlock()
+	server := bandwidthServers[name]
+	delete(bandwidthServers, name)
+	return server
+}
+
+// listenBandwidthSink 在本机回环地址上以同一端口监听 TCP 和 UDP 测试接收端
+func listenBandwidthSink() (*bandwidthServer, error) {
+	for i := 0; i < 5; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("启动测试接收端失败: %v", err)
+		}
+		pc, err := net.ListenPacket("udp", ln.Addr().String())
+		if err != nil {
+			ln.Close()
+			continue
+		}
+
+		server := &bandwidthServer{tcpLn: ln, udpPc: pc, udpMaxSeq: -1}
+		go server.acceptTCP()
+		go server.readUDP()
+		return server, nil
+	}
+	return nil, errors.New("启动测试接收端失败: 无可用端口")
+}
+
+// waitIdle 等待在途的测试数据到达，idle 时间内没有新数据或超过 max 时返回
+func (s *bandwidthServer) waitIdle(idle, max time.Duration) {
+	deadline := time.Now().Add(max)
+	for time.Now().Before(deadline) {
+		s.mutex.Lock()
+		last := s.tcpLast
+		if s.udpLast.After(last) {
+			last = s.udpLast
+		}
+		s.mutex.Unlock()
+		if time.Since(last) >= idle {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (s *bandwidthServer) closeSink() {
+	s.tcpLn.Close()
+	s.udpPc.Close()
+}
+
+func (s *bandwidthServer) close() {
+	if s.svc != nil {
+		s.svc.Close()
+	}
+	s.closeSink()
+}
+
+func (s *bandwidthServer) acceptTCP() {
+	for {
+		conn, err := s.tcpLn.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveTCP(conn)
+	}
+}
+
+// serveTCP 处理一个测试连接：先应答 ping，收到测试参数后进入收发阶段
+func (s *bandwidthServer) serveTCP(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(maxBandwidthTestDuration+10) * time.Second))
+
+	reader := bufio.NewReader(conn)
+	var header BandwidthTestRequest
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "ping" {
+			if _, err := conn.Write([]byte("pong\n")); err != nil {
+				return
+			}
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &header); err != nil {
+			return
+		}
+		break
+	}
+	if _, err := conn.Write([]byte("ok\n")); err != nil {
+		return
+	}
+
+	if header.Direction == "download" {
+		buf := make([]byte, bandwidthChunkSize)
+		deadline := time.Now().Add(time.Duration(header.Duration) * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := conn.Write(buf); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	buf := make([]byte, bandwidthChunkSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			now := time.Now()
+			s.mutex.Lock()
+			if s.tcpFirst.IsZero() {
+				s.tcpFirst = now
+			}
+			s.tcpLast = now
+			s.stats.TCPBytes += int64(n)
+			s.mutex.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readUDP 统计 UDP 测试报文的到达情况和抖动
+func (s *bandwidthServer) readUDP() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.udpPc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 16 || string(buf[:4]) != string(udpTestMagic) {
+			continue
+		}
+		now := time.Now()
+		seq := int64(binary.BigEndian.Uint32(buf[4:8]))
+		sentAt := int64(binary.BigEndian.Uint64(buf[8:16]))
+
+		s.mutex.Lock()
+		if s.udpFirst.IsZero() {
+			s.udpFirst = now
+		}
+		s.udpLast = now
+		s.stats.UDPPackets++
+		s.stats.UDPBytes += int64(n)
+		if seq < s.udpMaxSeq {
+			s.stats.UDPOutOfOrder++
+		} else {
+			s.udpMaxSeq = seq
+		}
+		// 两端时钟偏差在相邻报文的传输时间差中抵消
+		transit := now.UnixNano() - sentAt
+		if s.stats.UDPPackets > 1 {
+			d := transit - s.lastTransit
+			if d < 0 {
+				d = -d
+			}
+			s.stats.Jitter += (float64(d)/1e6 - s.stats.Jitter) / 16
+		}
+		s.lastTransit = transit
+		s.mutex.Unlock()
+	}
+}
+
+// handleBandwidthTest 经隧道协议连接出口节点的测试服务并执行测试
+func (w *WebSocketReporter) handleBandwidthTest(data interface{}) (BandwidthTestResult, error) {
+	var req BandwidthTestRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return BandwidthTestResult{}, err
+	}
+	if req.Addr == "" || req.Target == "" || req.Protocol == "" {
+		return BandwidthTestResult{}, errors.New("测试参数不完整")
+	}
+	if req.Mode != "udp" {
+		req.Mode = "tcp"
+	}
+	if req.Direction != "download" || req.Mode == "udp" {
+		req.Direction = "upload"
+	}
+	if req.Duration <= 0 {
+		req.Duration = 10
+	}
+	if req.Duration > maxBandwidthTestDuration {
+		req.Duration = maxBandwidthTestDuration
+	}
+
+	result := BandwidthTestResult{Mode: req.Mode, Direction: req.Direction}
+	router, err := newBandwidthRouter(req)
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result, nil
+	}
+
+	fmt.Printf("🔍 开始带宽测试: %s %s，协议: %s，目标: %s，时长: %ds\n", req.Mode, req.Direction, req.Protocol, req.Addr, req.Duration)
+	if req.Mode == "udp" {
+		err = runUDPBandwidthTest(router, req, &result)
+	} else {
+		err = runTCPBandwidthTest(router, req, &result)
+	}
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result, nil
+	}
+
+	fmt.Printf("✅ 带宽测试完成: %.2f Mbps，时延 %.2fms\n", result.Bps/1e6, result.Latency)
+	return result, nil
+}
+
+// newBandwidthRouter 构造与隧道相同的 relay 链路，不注册到链列表
+func newBandwidthRouter(req BandwidthTestRequest) (*xchain.Router, error) {
+	dialer := &config.DialerConfig{Type: req.Protocol}
+	if req.Protocol == "quic" {
+		dialer.Metadata = map[string]any{"keepAlive": true, "ttl": "10s"}
+	}
+	cfg := &config.ChainConfig{
+		Name: "bandwidth-test",
+		Hops: []*config.HopConfig{{
+			Name: "hop-bandwidth-test",
+			Nodes: []*config.NodeConfig{{
+				Name:      "node-bandwidth-test",
+				Addr:      req.Addr,
+				Interface: req.Interface,
+				Connector: &config.ConnectorConfig{Type: "relay"},
+				Dialer:    dialer,
+			}},
+		}},
+	}
+	c, err := chainparser.ParseChain(cfg, logger.Default())
+	if err != nil {
+		return nil, fmt.Errorf("创建测试链路失败: %v", err)
+	}
+	return xchain.NewRouter(chain.ChainRouterOption(c), chain.TimeoutRouterOption(10*time.Second)), nil
+}
+
+// runTCPBandwidthTest 先测量经隧道的往返时延，再按方向持续收发
+func runTCPBandwidthTest(router *xchain.Router, req BandwidthTestRequest, result *BandwidthTestResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Duration+10)*time.Second)
+	defer cancel()
+
+	conn, err := router.Dial(ctx, "tcp", req.Target)
+	if err != nil {
+		return fmt.Errorf("经隧道连接出口节点失败: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	reader := bufio.NewReader(conn)
+	var totalRTT time.Duration
+	for i := 0; i < bandwidthPingCount; i++ {
+		start := time.Now()
+		if _, err := conn.Write([]byte("ping\n")); err != nil {
+			return fmt.Errorf("发送测试报文失败: %v", err)
+		}
+		if line, err := reader.ReadString('\n'); err != nil || strings.TrimSpace(line) != "pong" {
+			return errors.New("出口节点测试服务无响应")
+		}
+		totalRTT += time.Since(start)
+	}
+	result.Latency = totalRTT.Seconds() * 1000 / bandwidthPingCount
+
+	header, _ := json.Marshal(BandwidthTestRequest{Direction: req.Direction, Duration: req.Duration})
+	if _, err := conn.Write(append(header, '\n')); err != nil {
+		return fmt.Errorf("发送测试参数失败: %v", err)
+	}
+	if line, err := reader.ReadString('\n'); err != nil || strings.TrimSpace(line) != "ok" {
+		return errors.New("出口节点拒绝测试")
+	}
+
+	buf := make([]byte, bandwidthChunkSize)
+	start := time.Now()
+	if req.Direction == "download" {
+		for {
+			n, err := reader.Read(buf)
+			result.Bytes += int64(n)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if result.Bytes == 0 {
+					return fmt.Errorf("接收测试数据失败: %v", err)
+				}
+				break
+			}
+		}
+	} else {
+		deadline := start.Add(time.Duration(req.Duration) * time.Second)
+		for time.Now().Before(deadline) {
+			n, err := conn.Write(buf)
+			result.Bytes += int64(n)
+			if err != nil {
+				return fmt.Errorf("发送测试数据失败: %v", err)
+			}
+		}
+	}
+
+	result.Duration = time.Since(start).Seconds()
+	if result.Duration > 0 {
+		result.Bps = float64(result.Bytes*8) / result.Duration
+	}
+	return nil
+}
+
+// runUDPBandwidthTest 按指定速率发送带序号和时间戳的 UDP 报文，丢包和抖动由出口节点统计
+func runUDPBandwidthTest(router *xchain.Router, req BandwidthTestRequest, result *BandwidthTestResult) error {
+	if req.Bandwidth <= 0 {
+		req.Bandwidth = defaultUDPBandwidth
+	}
+	if req.Bandwidth > maxUDPBandwidth {
+		req.Bandwidth = maxUDPBandwidth
+	}
+	if req.PacketSize < 16 || req.PacketSize > 1400 {
+		req.PacketSize = defaultUDPPacketSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := router.Dial(ctx, "udp", req.Target)
+	if err != nil {
+		return fmt.Errorf("经隧道连接出口节点失败: %v", err)
+	}
+	defer conn.Close()
+
+	packetsPerSecond := float64(req.Bandwidth) * 1e6 / 8 / float64(req.PacketSize)
+	packet := make([]byte, req.PacketSize)
+	copy(packet, udpTestMagic)
+
+	start := time.Now()
+	duration := time.Duration(req.Duration) * time.Second
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= duration {
+			break
+		}
+		// 按已用时间补齐应发送的报文数，避免逐包休眠造成的速率不足
+		expected := int64(elapsed.Seconds() * packetsPerSecond)
+		if result.Sent >= expected {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		for result.Sent < expected {
+			binary.BigEndian.PutUint32(packet[4:8], uint32(result.Sent))
+			binary.BigEndian.PutUint64(packet[8:16], uint64(time.Now().UnixNano()))
+			n, err := conn.Write(packet)
+			if err != nil {
+				return fmt.Errorf("发送测试数据失败: %v", err)
+			}
+			result.Sent++
+			result.Bytes += int64(n)
+		}
+	}
+
+	result.Duration = time.Since(start).Seconds()
+	result.Bps = float64(result.Bytes*8) / result.Duration
+	// 等待在途报文到达出口节点后再结束
+	time.Sleep(time.Second)
+	return nil
+}
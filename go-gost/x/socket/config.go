@@ -1,25 +1,49 @@
 package socket
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/go-gost/x/config"
 )
 
+// ConfigFile 面板下发的服务、链和限流器等配置的持久化文件，节点启动时据此恢复
+const ConfigFile = "gost.json"
+
+var saveMutex sync.Mutex
+
+// saveConfig 保存当前配置，先写临时文件再重命名，写入中断时不会损坏已有文件
 func saveConfig() {
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
 
-	file := "gost.json"
+	if err := writeConfigFile(ConfigFile); err != nil {
+		fmt.Printf("❌ 保存配置失败: %v\n", err)
+	}
+}
 
-	f, err := os.Create(file)
+func writeConfigFile(file string) error {
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err != nil {
-		return
+		return err
 	}
-	defer f.Close()
 
 	if err := config.Global().Write(f, "json"); err != nil {
-
-		return
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
 	}
 
-	return
+	return os.Rename(tmp, file)
 }
@@ -2,10 +2,14 @@ package socket
 
 import (
 	"errors"
+	"strings"
+
+	"github.com/go-gost/core/limiter/conn"
+	"github.com/go-gost/core/limiter/rate"
 	"github.com/go-gost/x/config"
 	parser "github.com/go-gost/x/config/parsing/limiter"
 	"github.com/go-gost/x/registry"
-	"strings"
+	"github.com/go-gost/x/service"
 )
 
 func createLimiter(req createLimiterRequest) error {
@@ -100,3 +104,217 @@ type updateLimiterRequest struct {
 type deleteLimiterRequest struct {
 	Limiter string `json:"limiter"`
 }
+
+func createConnLimiter(req createLimiterRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("climiter name is required")
+	}
+	req.Data.Name = name
+
+	if registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	v := &statsConnLimiter{name: name, ConnLimiter: parser.ParseConnLimiter(&req.Data)}
+
+	if err := registry.ConnLimiterRegistry().Register(name, v); err != nil {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.CLimiters = append(c.CLimiters, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateConnLimiter(req updateLimiterRequest) error {
+
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " not found")
+	}
+
+	req.Data.Name = name
+
+	v := &statsConnLimiter{name: name, ConnLimiter: parser.ParseConnLimiter(&req.Data)}
+
+	registry.ConnLimiterRegistry().Unregister(name)
+
+	if err := registry.ConnLimiterRegistry().Register(name, v); err != nil {
+		return errors.New("climiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.CLimiters {
+			if c.CLimiters[i].Name == name {
+				c.CLimiters[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteConnLimiter(req deleteLimiterRequest) error {
+
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.ConnLimiterRegistry().IsRegistered(name) {
+		return errors.New("climiter " + name + " not found")
+	}
+	registry.ConnLimiterRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		limiters := c.CLimiters
+		c.CLimiters = nil
+		for _, s := range limiters {
+			if s.Name == name {
+				continue
+			}
+			c.CLimiters = append(c.CLimiters, s)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func createRateLimiter(req createLimiterRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("rlimiter name is required")
+	}
+	req.Data.Name = name
+
+	if registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	v := &statsRateLimiter{name: name, RateLimiter: parser.ParseRateLimiter(&req.Data)}
+
+	if err := registry.RateLimiterRegistry().Register(name, v); err != nil {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.RLimiters = append(c.RLimiters, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateRateLimiter(req updateLimiterRequest) error {
+
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " not found")
+	}
+
+	req.Data.Name = name
+
+	v := &statsRateLimiter{name: name, RateLimiter: parser.ParseRateLimiter(&req.Data)}
+
+	registry.RateLimiterRegistry().Unregister(name)
+
+	if err := registry.RateLimiterRegistry().Register(name, v); err != nil {
+		return errors.New("rlimiter " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.RLimiters {
+			if c.RLimiters[i].Name == name {
+				c.RLimiters[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteRateLimiter(req deleteLimiterRequest) error {
+
+	name := strings.TrimSpace(req.Limiter)
+
+	if !registry.RateLimiterRegistry().IsRegistered(name) {
+		return errors.New("rlimiter " + name + " not found")
+	}
+	registry.RateLimiterRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		limiters := c.RLimiters
+		c.RLimiters = nil
+		for _, s := range limiters {
+			if s.Name == name {
+				continue
+			}
+			c.RLimiters = append(c.RLimiters, s)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// statsConnLimiter 统计连接数限制命中次数
+type statsConnLimiter struct {
+	name string
+	conn.ConnLimiter
+}
+
+func (l *statsConnLimiter) Limiter(key string) conn.Limiter {
+	lim := l.ConnLimiter.Limiter(key)
+	if lim == nil {
+		return nil
+	}
+	return &statsConnLimit{name: l.name, Limiter: lim}
+}
+
+type statsConnLimit struct {
+	name string
+	conn.Limiter
+}
+
+func (l *statsConnLimit) Allow(n int) bool {
+	ok := l.Limiter.Allow(n)
+	if !ok && n > 0 {
+		service.RecordLimitHit(l.name, service.LimitHitConn)
+	}
+	return ok
+}
+
+// statsRateLimiter 统计新建连接速率限制命中次数
+type statsRateLimiter struct {
+	name string
+	rate.RateLimiter
+}
+
+func (l *statsRateLimiter) Limiter(key string) rate.Limiter {
+	lim := l.RateLimiter.Limiter(key)
+	if lim == nil {
+		return nil
+	}
+	return &statsRateLimit{name: l.name, Limiter: lim}
+}
+
+type statsRateLimit struct {
+	name string
+	rate.Limiter
+}
+
+func (l *statsRateLimit) Allow(n int) bool {
+	ok := l.Limiter.Allow(n)
+	if !ok && n > 0 {
+		service.RecordLimitHit(l.name, service.LimitHitRate)
+	}
+	return ok
+}
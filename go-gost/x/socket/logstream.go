@@ -0,0 +1,252 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	xlogger "github.com/go-gost/x/logger"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTailLines    = 200
+	defaultTailDuration = 5 * time.Minute
+	maxTailDuration     = 30 * time.Minute
+	maxLogLevelDuration = 2 * time.Hour
+	tailFlushInterval   = 500 * time.Millisecond
+	tailBatchSize       = 100
+	maxTailMessageSize  = 4096 // 单条日志推送时截断的长度
+)
+
+// TailLogsRequest 日志查看请求
+type TailLogsRequest struct {
+	TailID   string `json:"tailId"`
+	Level    string `json:"level"`    // 最低级别，为空时不过滤
+	Service  string `json:"service"`  // 服务名或服务名前缀，为空时不过滤
+	Lines    int    `json:"lines"`    // 返回的历史条数
+	Follow   bool   `json:"follow"`   // 是否持续推送新日志
+	Duration int    `json:"duration"` // 持续推送的时长(秒)
+}
+
+// TailLogsResponse 日志查看响应，Lines 为缓冲区中的历史日志
+type TailLogsResponse struct {
+	Lines     []xlogger.Record       `json:"lines"`
+	Levels    []xlogger.ServiceLevel `json:"levels"`
+	Following bool                   `json:"following"`
+}
+
+// StopTailLogsRequest 停止日志推送请求
+type StopTailLogsRequest struct {
+	TailID string `json:"tailId"`
+}
+
+// SetServiceLogLevelRequest 临时调整服务日志级别请求，Duration 为 0 时恢复默认级别
+type SetServiceLogLevelRequest struct {
+	Service  string `json:"service"`
+	Level    string `json:"level"`
+	Duration int    `json:"duration"` // 秒
+}
+
+// LogsMessage 推送给面板的实时日志
+type LogsMessage struct {
+	Type string    `json:"type"`
+	Data LogsBatch `json:"data"`
+}
+
+// LogsBatch 一批实时日志，Done 表示推送结束
+type LogsBatch struct {
+	TailID string           `json:"tailId"`
+	Lines  []xlogger.Record `json:"lines"`
+	Done   bool             `json:"done,omitempty"`
+}
+
+// logTail 正在推送的日志会话
+type logTail struct {
+	cancel context.CancelFunc
+}
+
+// logTails 正在推送的日志会话
+var logTails sync.Map // tailId -> *logTail
+
+// handleTailLogs 返回缓冲区中的历史日志，需要时开始持续推送新日志
+func (w *WebSocketReporter) handleTailLogs(data interface{}) (TailLogsResponse, error) {
+	var req TailLogsRequest
+	if err := decodeLogRequest(data, &req); err != nil {
+		return TailLogsResponse{}, err
+	}
+	match, err := logMatcher(req.Level, req.Service)
+	if err != nil {
+		return TailLogsResponse{}, err
+	}
+
+	lines := req.Lines
+	if lines <= 0 {
+		lines = defaultTailLines
+	}
+	resp := TailLogsResponse{
+		Lines:  xlogger.Recent(lines, match),
+		Levels: xlogger.ServiceLevels(),
+	}
+	if !req.Follow {
+		return resp, nil
+	}
+	if req.TailID == "" {
+		return TailLogsResponse{}, fmt.Errorf("持续推送需要指定 tailId")
+	}
+
+	duration := time.Duration(req.Duration) * time.Second
+	if duration <= 0 {
+		duration = defaultTailDuration
+	}
+	if duration > maxTailDuration {
+		duration = maxTailDuration
+	}
+
+	ctx, cancel := context.WithTimeout(w.ctx, duration)
+	tail := &logTail{cancel: cancel}
+	if old, loaded := logTails.Swap(req.TailID, tail); loaded {
+		old.(*logTail).cancel()
+	}
+	go w.streamLogs(ctx, req.TailID, tail, match)
+
+	resp.Following = true
+	return resp, nil
+}
+
+// handleStopTailLogs 停止指定的日志推送
+func (w *WebSocketReporter) handleStopTailLogs(data interface{}) error {
+	var req StopTailLogsRequest
+	if err := decodeLogRequest(data, &req); err != nil {
+		return err
+	}
+	if tail, ok := logTails.LoadAndDelete(req.TailID); ok {
+		tail.(*logTail).cancel()
+	}
+	return nil
+}
+
+// handleSetServiceLogLevel 临时调整单个服务的日志级别，到期后自动恢复
+func (w *WebSocketReporter) handleSetServiceLogLevel(data interface{}) ([]xlogger.ServiceLevel, error) {
+	var req SetServiceLogLevelRequest
+	if err := decodeLogRequest(data, &req); err != nil {
+		return nil, err
+	}
+	if req.Service == "" {
+		return nil, fmt.Errorf("服务名不能为空")
+	}
+
+	duration := time.Duration(req.Duration) * time.Second
+	if duration > maxLogLevelDuration {
+		duration = maxLogLevelDuration
+	}
+	if err := xlogger.SetServiceLevel(req.Service, logger.LogLevel(req.Level), duration); err != nil {
+		return nil, err
+	}
+	if duration > 0 {
+		fmt.Printf("🔎 服务 %s 日志级别临时调整为 %s，%v 后恢复\n", req.Service, req.Level, duration)
+	} else {
+		fmt.Printf("🔎 服务 %s 日志级别已恢复\n", req.Service)
+	}
+	return xlogger.ServiceLevels(), nil
+}
+
+// streamLogs 订阅新日志并按批推送给面板，会话到期、被停止或连接断开时结束
+func (w *WebSocketReporter) streamLogs(ctx context.Context, tailID string, tail *logTail, match func(xlogger.Record) bool) {
+	id, records := xlogger.Subscribe(tailBatchSize * 10)
+	defer xlogger.Unsubscribe(id)
+	defer logTails.CompareAndDelete(tailID, tail)
+	defer tail.cancel()
+
+	ticker := time.NewTicker(tailFlushInterval)
+	defer ticker.Stop()
+
+	var batch []xlogger.Record
+	for {
+		select {
+		case <-ctx.Done():
+			w.sendLogs(LogsBatch{TailID: tailID, Lines: batch, Done: true})
+			return
+		case rec := <-records:
+			if !match(rec) {
+				continue
+			}
+			if len(rec.Message) > maxTailMessageSize {
+				rec.Message = rec.Message[:maxTailMessageSize] + "..."
+			}
+			batch = append(batch, rec)
+			if len(batch) < tailBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		// 连接断开后面板已不再等待该会话
+		if err := w.sendLogs(LogsBatch{TailID: tailID, Lines: batch}); err != nil {
+			return
+		}
+		batch = nil
+	}
+}
+
+// sendLogs 发送一批实时日志
+func (w *WebSocketReporter) sendLogs(batch LogsBatch) error {
+	if batch.Lines == nil {
+		batch.Lines = []xlogger.Record{}
+	}
+	jsonData, err := json.Marshal(LogsMessage{Type: "logs", Data: batch})
+	if err != nil {
+		return fmt.Errorf("序列化日志失败: %v", err)
+	}
+
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+
+	if w.conn == nil || !w.connected {
+		return fmt.Errorf("连接未建立")
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := w.conn.WriteMessage(websocket.TextMessage, w.sealMessage(jsonData)); err != nil {
+		w.connected = false
+		return fmt.Errorf("写入消息失败: %v", err)
+	}
+	return nil
+}
+
+// logMatcher 根据最低级别和服务名构造过滤条件
+func logMatcher(level, service string) (func(xlogger.Record) bool, error) {
+	threshold := logrus.TraceLevel
+	if level != "" {
+		lvl, err := logrus.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("无效的日志级别: %s", level)
+		}
+		threshold = lvl
+	}
+	return func(rec xlogger.Record) bool {
+		if service != "" && !xlogger.MatchService(service, rec.Service) {
+			return false
+		}
+		lvl, err := logrus.ParseLevel(rec.Level)
+		return err != nil || lvl <= threshold
+	}, nil
+}
+
+func decodeLogRequest(data interface{}, v interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化日志请求失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, v); err != nil {
+		return fmt.Errorf("解析日志请求失败: %v", err)
+	}
+	return nil
+}
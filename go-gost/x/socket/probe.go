@@ -0,0 +1,712 @@
+package socket
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// UdpProbeRequest UDP 探测请求
+type UdpProbeRequest struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Count    int    `json:"count"`
+	Timeout  int    `json:"timeout"`            // 每次探测的等待时间(毫秒)
+	Payload  string `json:"payload,omitempty"`  // 探测报文内容，为空时发送空报文
+	Resolver string `json:"resolver,omitempty"` // 解析域名使用的解析器，为空使用系统解析
+	Prefer   string `json:"prefer,omitempty"`   // 解析优先级: ipv4, ipv6
+}
+
+// UdpProbeResponse UDP 探测结果
+// Status: reply 收到回包, refused 目标返回端口不可达, no_reply 未收到任何响应（UDP 服务可能不回包）
+type UdpProbeResponse struct {
+	IP           string   `json:"ip"`
+	Port         int      `json:"port"`
+	Success      bool     `json:"success"`
+	Status       string   `json:"status"`
+	Sent         int      `json:"sent"`
+	Received     int      `json:"received"`
+	AverageTime  float64  `json:"averageTime"` // 平均往返时间(ms)
+	PacketLoss   float64  `json:"packetLoss"`  // 丢包率(%)
+	ResolvedIPs  []string `json:"resolvedIps,omitempty"`
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+}
+
+// HttpProbeRequest HTTP(S) 探测请求
+type HttpProbeRequest struct {
+	URL      string `json:"url"`
+	Timeout  int    `json:"timeout"`            // 整体超时(毫秒)
+	Insecure bool   `json:"insecure,omitempty"` // 跳过证书校验
+	Resolver string `json:"resolver,omitempty"`
+	Prefer   string `json:"prefer,omitempty"`
+}
+
+// HttpProbeResponse HTTP(S) 探测结果，各阶段耗时单位为毫秒
+type HttpProbeResponse struct {
+	URL          string  `json:"url"`
+	Success      bool    `json:"success"`
+	StatusCode   int     `json:"statusCode"`
+	RemoteAddr   string  `json:"remoteAddr,omitempty"`
+	TLSVersion   string  `json:"tlsVersion,omitempty"`
+	DNSTime      float64 `json:"dnsTime"`
+	ConnectTime  float64 `json:"connectTime"`
+	TLSTime      float64 `json:"tlsTime"`
+	TTFB         float64 `json:"ttfb"` // 发出请求到收到首字节
+	TotalTime    float64 `json:"totalTime"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+}
+
+// TracerouteRequest 路由追踪请求，Count 大于 1 时按 MTR 方式统计每一跳的丢包和延迟
+type TracerouteRequest struct {
+	IP       string `json:"ip"`
+	MaxHops  int    `json:"maxHops"`
+	Count    int    `json:"count"`   // 探测轮数
+	Timeout  int    `json:"timeout"` // 每轮等待回包的时间(毫秒)
+	Resolver string `json:"resolver,omitempty"`
+	Prefer   string `json:"prefer,omitempty"`
+}
+
+// TracerouteResponse 路由追踪结果
+type TracerouteResponse struct {
+	IP           string     `json:"ip"`
+	TargetIP     string     `json:"targetIp"`
+	Success      bool       `json:"success"`
+	Reached      bool       `json:"reached"` // 是否到达目标
+	Hops         []TraceHop `json:"hops"`
+	ErrorMessage string     `json:"errorMessage,omitempty"`
+	ResolvedIPs  []string   `json:"resolvedIps,omitempty"`
+}
+
+// TraceHop 单跳统计，未响应的跳 IP 为空
+type TraceHop struct {
+	TTL        int     `json:"ttl"`
+	IP         string  `json:"ip"`
+	Sent       int     `json:"sent"`
+	Received   int     `json:"received"`
+	PacketLoss float64 `json:"packetLoss"`
+	Best       float64 `json:"best"`
+	Worst      float64 `json:"worst"`
+	Average    float64 `json:"average"`
+	Last       float64 `json:"last"`
+}
+
+// DnsLookupRequest DNS 查询请求
+type DnsLookupRequest struct {
+	Host     string `json:"host"`
+	Type     string `json:"type"`               // A, AAAA, CNAME, MX, TXT, NS，默认 A
+	Server   string `json:"server,omitempty"`   // 指定 DNS 服务器 (host:port)，为空使用系统配置
+	Resolver string `json:"resolver,omitempty"` // 使用节点上注册的解析器，仅支持 A/AAAA
+	Timeout  int    `json:"timeout"`
+}
+
+// DnsLookupResponse DNS 查询结果
+type DnsLookupResponse struct {
+	Host         string   `json:"host"`
+	Type         string   `json:"type"`
+	Server       string   `json:"server,omitempty"`
+	Success      bool     `json:"success"`
+	Records      []string `json:"records"`
+	Time         float64  `json:"time"` // 查询耗时(ms)
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+}
+
+// decodeProbeRequest 将命令数据解析为具体的探测请求
+func decodeProbeRequest(data interface{}, v interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化探测数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, v); err != nil {
+		return fmt.Errorf("解析探测请求失败: %v", err)
+	}
+	return nil
+}
+
+// resolveProbeTarget 校验目标并按转发相同的解析器与优先级解析域名
+func resolveProbeTarget(host, resolver, prefer string, timeout time.Duration) (string, []string, error) {
+	if net.ParseIP(host) != nil {
+		return host, nil, nil
+	}
+	if !isValidHostname(host) {
+		return "", nil, errors.New("无效的IP地址或主机名")
+	}
+	ips, err := resolveHost(host, resolver, prefer, timeout)
+	if err != nil {
+		return "", nil, err
+	}
+	return ips[0], ips, nil
+}
+
+// handleUdpProbe 处理 UDP 探测命令
+func (w *WebSocketReporter) handleUdpProbe(data interface{}) (UdpProbeResponse, error) {
+	var req UdpProbeRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return UdpProbeResponse{}, err
+	}
+
+	response := UdpProbeResponse{IP: req.IP, Port: req.Port}
+	if req.Port <= 0 || req.Port > 65535 {
+		response.ErrorMessage = "无效的端口号，范围应为1-65535"
+		return response, nil
+	}
+	if req.Count <= 0 {
+		req.Count = 4
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = 2000
+	}
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+
+	ip, resolvedIPs, err := resolveProbeTarget(req.IP, req.Resolver, req.Prefer, timeout)
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	response.ResolvedIPs = resolvedIPs
+
+	target := net.JoinHostPort(ip, strconv.Itoa(req.Port))
+	fmt.Printf("🔍 开始UDP探测: %s，次数: %d，超时: %dms\n", target, req.Count, req.Timeout)
+
+	// 使用已连接的 UDP 套接字，目标端口不可达时读操作会返回 ECONNREFUSED
+	conn, err := net.DialTimeout("udp", target, timeout)
+	if err != nil {
+		response.ErrorMessage = fmt.Sprintf("创建UDP连接失败: %v", err)
+		return response, nil
+	}
+	defer conn.Close()
+
+	var totalTime float64
+	refused := false
+	buf := make([]byte, 2048)
+	for i := 0; i < req.Count; i++ {
+		start := time.Now()
+		if _, err := conn.Write([]byte(req.Payload)); err != nil {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				refused = true
+			}
+			response.Sent++
+			continue
+		}
+		response.Sent++
+
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if _, err := conn.Read(buf); err != nil {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				refused = true
+			}
+		} else {
+			response.Received++
+			totalTime += time.Since(start).Seconds() * 1000
+		}
+
+		if i < req.Count-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	response.PacketLoss = float64(response.Sent-response.Received) / float64(response.Sent) * 100
+	switch {
+	case response.Received > 0:
+		response.Status = "reply"
+		response.Success = true
+		response.AverageTime = totalTime / float64(response.Received)
+	case refused:
+		response.Status = "refused"
+		response.ErrorMessage = "目标端口不可达"
+	default:
+		response.Status = "no_reply"
+		response.ErrorMessage = "未收到UDP响应，目标服务可能不回包或被防火墙拦截"
+	}
+
+	fmt.Printf("✅ UDP探测完成: 状态 %s，收到 %d/%d\n", response.Status, response.Received, response.Sent)
+	return response, nil
+}
+
+// handleHttpProbe 处理 HTTP(S) 探测命令，只读取响应头和少量内容
+func (w *WebSocketReporter) handleHttpProbe(data interface{}) (HttpProbeResponse, error) {
+	var req HttpProbeRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return HttpProbeResponse{}, err
+	}
+
+	response := HttpProbeResponse{URL: req.URL}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		response.ErrorMessage = "无效的URL，仅支持 http 和 https"
+		return response, nil
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = 10000
+	}
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+
+	// 域名解析单独计时，使其与连接共用同一解析器和优先级
+	host := u.Hostname()
+	dnsStart := time.Now()
+	ip, _, err := resolveProbeTarget(host, req.Resolver, req.Prefer, timeout)
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	if net.ParseIP(host) == nil {
+		response.DNSTime = time.Since(dnsStart).Seconds() * 1000
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	dialAddr := net.JoinHostPort(ip, port)
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, dialAddr)
+		},
+		TLSClientConfig:   &tls.Config{ServerName: host, InsecureSkipVerify: req.Insecure},
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var connectStart, tlsStart, requestStart time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				response.ConnectTime = time.Since(connectStart).Seconds() * 1000
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				response.TLSTime = time.Since(tlsStart).Seconds() * 1000
+				response.TLSVersion = tls.VersionName(state.Version)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			response.RemoteAddr = info.Conn.RemoteAddr().String()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { requestStart = time.Now() },
+		GotFirstResponseByte: func() {
+			response.TTFB = time.Since(requestStart).Seconds() * 1000
+		},
+	}
+
+	httpReq, err := http.NewRequest(http.MethodGet, req.URL, nil)
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
+
+	fmt.Printf("🔍 开始HTTP探测: %s (%s)\n", req.URL, dialAddr)
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		response.ErrorMessage = fmt.Sprintf("请求失败: %v", err)
+		return response, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	response.TotalTime = response.DNSTime + time.Since(start).Seconds()*1000
+	response.StatusCode = resp.StatusCode
+	response.Success = resp.StatusCode < 500
+	if !response.Success {
+		response.ErrorMessage = fmt.Sprintf("服务端返回 %s", resp.Status)
+	}
+
+	fmt.Printf("✅ HTTP探测完成: %d，总耗时 %.2fms\n", response.StatusCode, response.TotalTime)
+	return response, nil
+}
+
+// handleTraceroute 处理路由追踪命令
+// 每一轮同时发出 TTL 为 1..MaxHops 的 ICMP Echo，在 Timeout 内收集超时和应答报文，
+// 总耗时约为 Count*Timeout，不受跳数影响
+func (w *WebSocketReporter) handleTraceroute(data interface{}) (TracerouteResponse, error) {
+	var req TracerouteRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return TracerouteResponse{}, err
+	}
+
+	response := TracerouteResponse{IP: req.IP, Hops: make([]TraceHop, 0)}
+	if req.MaxHops <= 0 || req.MaxHops > 64 {
+		req.MaxHops = 30
+	}
+	if req.Count <= 0 {
+		req.Count = 3
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = 2000
+	}
+	// 命令响应需要在面板的等待时间内返回
+	if req.Count*req.Timeout > 25000 {
+		req.Count = 25000 / req.Timeout
+		if req.Count == 0 {
+			req.Count, req.Timeout = 1, 25000
+		}
+	}
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+
+	ip, resolvedIPs, err := resolveProbeTarget(req.IP, req.Resolver, req.Prefer, timeout)
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	response.TargetIP = ip
+	response.ResolvedIPs = resolvedIPs
+
+	fmt.Printf("🔍 开始路由追踪: %s，最大跳数: %d，轮数: %d\n", ip, req.MaxHops, req.Count)
+	if err := traceroute(&response, net.ParseIP(ip), req.MaxHops, req.Count, timeout); err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	response.Success = true
+
+	fmt.Printf("✅ 路由追踪完成: %d 跳，到达目标: %v\n", len(response.Hops), response.Reached)
+	return response, nil
+}
+
+// traceProbe 已发出的探测报文
+type traceProbe struct {
+	ttl  int
+	sent time.Time
+}
+
+// traceroute 执行路由追踪，需要节点以 root 权限运行以使用原始套接字
+func traceroute(response *TracerouteResponse, dst net.IP, maxHops, rounds int, timeout time.Duration) error {
+	isV4 := dst.To4() != nil
+	network, listenAddr, proto := "ip4:icmp", "0.0.0.0", 1
+	var echoType icmp.Type = ipv4.ICMPTypeEcho
+	if !isV4 {
+		network, listenAddr, proto = "ip6:ipv6-icmp", "::", 58
+		echoType = ipv6.ICMPTypeEchoRequest
+	}
+
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		return fmt.Errorf("创建ICMP套接字失败（需要root权限）: %v", err)
+	}
+	defer conn.Close()
+
+	setTTL := func(ttl int) error {
+		if isV4 {
+			return conn.IPv4PacketConn().SetTTL(ttl)
+		}
+		return conn.IPv6PacketConn().SetHopLimit(ttl)
+	}
+
+	id := rand.Intn(0xffff)
+	hops := make([]TraceHop, maxHops)
+	rtts := make([][]float64, maxHops)
+	for i := range hops {
+		hops[i].TTL = i + 1
+	}
+	destTTL := 0
+	dstAddr := &net.IPAddr{IP: dst}
+
+	for round := 0; round < rounds; round++ {
+		probes := make(map[int]traceProbe)
+
+		for ttl := 1; ttl <= maxHops; ttl++ {
+			if destTTL > 0 && ttl > destTTL {
+				break
+			}
+			seq := round<<8 | ttl
+			msg := icmp.Message{
+				Type: echoType,
+				Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("flux-trace")},
+			}
+			b, err := msg.Marshal(nil)
+			if err != nil {
+				return fmt.Errorf("构造ICMP报文失败: %v", err)
+			}
+			if err := setTTL(ttl); err != nil {
+				return fmt.Errorf("设置TTL失败: %v", err)
+			}
+			probes[seq] = traceProbe{ttl: ttl, sent: time.Now()}
+			if _, err := conn.WriteTo(b, dstAddr); err != nil {
+				return fmt.Errorf("发送ICMP报文失败: %v", err)
+			}
+			hops[ttl-1].Sent++
+		}
+
+		deadline := time.Now().Add(timeout)
+		conn.SetReadDeadline(deadline)
+		buf := make([]byte, 1500)
+		for len(probes) > 0 {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				break // 本轮超时
+			}
+			received := time.Now()
+
+			msg, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil {
+				continue
+			}
+			seq, ok := matchTraceReply(msg, id, isV4)
+			if !ok {
+				continue
+			}
+			probe, exists := probes[seq]
+			if !exists {
+				continue
+			}
+			delete(probes, seq)
+
+			hop := &hops[probe.ttl-1]
+			hop.Received++
+			if addr, ok := peer.(*net.IPAddr); ok && hop.IP == "" {
+				hop.IP = addr.IP.String()
+			}
+			rtt := received.Sub(probe.sent).Seconds() * 1000
+			rtts[probe.ttl-1] = append(rtts[probe.ttl-1], rtt)
+			hop.Last = rtt
+
+			if msg.Type == ipv4.ICMPTypeEchoReply || msg.Type == ipv6.ICMPTypeEchoReply {
+				if destTTL == 0 || probe.ttl < destTTL {
+					destTTL = probe.ttl
+				}
+			}
+		}
+	}
+
+	last := maxHops
+	if destTTL > 0 {
+		last = destTTL
+		response.Reached = true
+	} else {
+		// 未到达目标时去掉末尾连续无响应的跳
+		for last > 0 && hops[last-1].Received == 0 {
+			last--
+		}
+	}
+	for i := 0; i < last; i++ {
+		hop := hops[i]
+		if hop.Sent > 0 {
+			hop.PacketLoss = float64(hop.Sent-hop.Received) / float64(hop.Sent) * 100
+		}
+		if len(rtts[i]) > 0 {
+			hop.Best, hop.Worst = rtts[i][0], rtts[i][0]
+			var total float64
+			for _, rtt := range rtts[i] {
+				total += rtt
+				if rtt < hop.Best {
+					hop.Best = rtt
+				}
+				if rtt > hop.Worst {
+					hop.Worst = rtt
+				}
+			}
+			hop.Average = total / float64(len(rtts[i]))
+		}
+		response.Hops = append(response.Hops, hop)
+	}
+	return nil
+}
+
+// matchTraceReply 从应答或超时报文中取出本次追踪的序号
+func matchTraceReply(msg *icmp.Message, id int, isV4 bool) (int, bool) {
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			return 0, false
+		}
+		if body.ID != id {
+			return 0, false
+		}
+		return body.Seq, true
+	case *icmp.TimeExceeded:
+		return matchQuotedEcho(body.Data, id, isV4)
+	case *icmp.DstUnreach:
+		return matchQuotedEcho(body.Data, id, isV4)
+	}
+	return 0, false
+}
+
+// matchQuotedEcho 解析 ICMP 差错报文中引用的原始 Echo 请求
+func matchQuotedEcho(data []byte, id int, isV4 bool) (int, bool) {
+	var inner []byte
+	if isV4 {
+		if len(data) < 20 || data[9] != 1 {
+			return 0, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < ihl+8 {
+			return 0, false
+		}
+		inner = data[ihl:]
+		if inner[0] != byte(ipv4.ICMPTypeEcho) {
+			return 0, false
+		}
+	} else {
+		if len(data) < 48 || data[6] != 58 {
+			return 0, false
+		}
+		inner = data[40:]
+		if inner[0] != byte(ipv6.ICMPTypeEchoRequest) {
+			return 0, false
+		}
+	}
+	if int(binary.BigEndian.Uint16(inner[4:6])) != id {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint16(inner[6:8])), true
+}
+
+// handleDnsLookup 处理 DNS 查询命令
+func (w *WebSocketReporter) handleDnsLookup(data interface{}) (DnsLookupResponse, error) {
+	var req DnsLookupRequest
+	if err := decodeProbeRequest(data, &req); err != nil {
+		return DnsLookupResponse{}, err
+	}
+
+	req.Type = strings.ToUpper(strings.TrimSpace(req.Type))
+	if req.Type == "" {
+		req.Type = "A"
+	}
+	response := DnsLookupResponse{Host: req.Host, Type: req.Type, Server: req.Server, Records: make([]string, 0)}
+	if !isValidHostname(req.Host) {
+		response.ErrorMessage = "无效的主机名"
+		return response, nil
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = 5000
+	}
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+
+	start := time.Now()
+	records, err := dnsLookup(req, timeout)
+	response.Time = time.Since(start).Seconds() * 1000
+	if err != nil {
+		response.ErrorMessage = err.Error()
+		return response, nil
+	}
+	response.Records = records
+	response.Success = true
+
+	fmt.Printf("✅ DNS查询完成: %s %s，%d 条记录 (%.2fms)\n", req.Type, req.Host, len(records), response.Time)
+	return response, nil
+}
+
+// dnsLookup 按记录类型查询
+func dnsLookup(req DnsLookupRequest, timeout time.Duration) ([]string, error) {
+	if req.Resolver != "" {
+		if req.Type != "A" && req.Type != "AAAA" {
+			return nil, errors.New("使用节点解析器时只支持 A 和 AAAA 记录")
+		}
+		prefer := "ipv4"
+		if req.Type == "AAAA" {
+			prefer = "ipv6"
+		}
+		ips, err := resolveHost(req.Host, req.Resolver, prefer, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return filterIPFamily(ips, req.Type == "AAAA"), nil
+	}
+
+	resolver := net.DefaultResolver
+	if req.Server != "" {
+		server := req.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var records []string
+	switch req.Type {
+	case "A", "AAAA":
+		network := "ip4"
+		if req.Type == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, req.Host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS查询失败: %v", err)
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, req.Host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS查询失败: %v", err)
+		}
+		records = append(records, cname)
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, req.Host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS查询失败: %v", err)
+		}
+		for _, mx := range mxs {
+			records = append(records, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, req.Host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS查询失败: %v", err)
+		}
+		records = append(records, txts...)
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, req.Host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS查询失败: %v", err)
+		}
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的记录类型: %s", req.Type)
+	}
+	return records, nil
+}
+
+// filterIPFamily 按地址族过滤解析结果
+func filterIPFamily(ips []string, v6 bool) []string {
+	result := make([]string, 0, len(ips))
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil || (ip.To4() == nil) != v6 {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package socket
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion 节点命令协议版本，新增或修改命令时递增
+const ProtocolVersion = 10
+
+// 握手时交换能力信息的请求/响应头
+const (
+	protocolHeader      = "X-Agent-Protocol"
+	commandsHeader      = "X-Agent-Commands"
+	featuresHeader      = "X-Agent-Features"
+	panelProtocolHeader = "X-Panel-Protocol"
+)
+
+// supportedCommands 节点支持的命令，需与 routeCommand 保持一致
+var supportedCommands = []string{
+	"AddService", "UpdateService", "DeleteService", "PauseService", "ResumeService",
+	"AddChains", "UpdateChains", "DeleteChains",
+	"AddLimiters", "UpdateLimiters", "DeleteLimiters",
+	"AddCLimiters", "UpdateCLimiters", "DeleteCLimiters",
+	"AddRLimiters", "UpdateRLimiters", "DeleteRLimiters",
+	"AddResolvers", "UpdateResolvers", "DeleteResolvers",
+	"TcpPing", "UdpProbe", "HttpProbe", "Traceroute", "DnsLookup",
+	"StartBandwidthServer", "StopBandwidthServer", "BandwidthTest",
+	"TailLogs", "StopTailLogs", "SetServiceLogLevel",
+	"UpgradeAgent",
+	"GetAgentSettings", "ApplyAgentSettings",
+	"SetProtocol",
+	"RotateSecret",
+}
+
+// supportedFeatures 节点支持的非命令类能力
+var supportedFeatures = []string{
+	"signed_envelope", // 会话密钥和带签名的加密信封
+	"access_log",      // 连接日志记录与上报
+	"config_restore",  // 重启后从本地配置恢复服务
+	"telemetry",       // 扩展遥测上报
+	"batch_chains",    // 链的增删改命令支持以列表批量下发
+	"create_paused",   // 添加服务时支持以暂停状态创建
+	"batch_config",    // 限流器和解析器的添加命令支持以列表批量下发
+}
+
+// setCapabilityHeaders 在握手请求中声明协议版本和支持的命令、能力
+func setCapabilityHeaders(header http.Header) {
+	header.Set(protocolHeader, strconv.Itoa(ProtocolVersion))
+	header.Set(commandsHeader, strings.Join(supportedCommands, ","))
+	header.Set(featuresHeader, strings.Join(supportedFeatures, ","))
+}
+
+// checkPanelProtocol 检查面板的协议版本，面板较旧时部分能力不会被使用
+func checkPanelProtocol(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	version, err := strconv.Atoi(resp.Header.Get(panelProtocolHeader))
+	if err != nil {
+		fmt.Printf("⚠️ 面板未声明协议版本，可能不支持能力协商\n")
+		return
+	}
+	if version < ProtocolVersion {
+		fmt.Printf("⚠️ 面板协议版本 %d 低于节点协议版本 %d\n", version, ProtocolVersion)
+	}
+}
@@ -0,0 +1,107 @@
+package socket
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-gost/x/config"
+	parser "github.com/go-gost/x/config/parsing/resolver"
+	"github.com/go-gost/x/registry"
+)
+
+func createResolver(req createResolverRequest) error {
+	name := strings.TrimSpace(req.Data.Name)
+	if name == "" {
+		return errors.New("resolver name is required")
+	}
+	req.Data.Name = name
+
+	if registry.ResolverRegistry().IsRegistered(name) {
+		return errors.New("resolver " + name + " already exists")
+	}
+
+	v, err := parser.ParseResolver(&req.Data)
+	if err != nil {
+		return errors.New("create resolver " + name + " failed: " + err.Error())
+	}
+
+	if err := registry.ResolverRegistry().Register(name, v); err != nil {
+		return errors.New("resolver " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		c.Resolvers = append(c.Resolvers, &req.Data)
+		return nil
+	})
+
+	return nil
+}
+
+func updateResolver(req updateResolverRequest) error {
+	name := strings.TrimSpace(req.Resolver)
+
+	if !registry.ResolverRegistry().IsRegistered(name) {
+		return errors.New("resolver " + name + " not found")
+	}
+
+	req.Data.Name = name
+
+	v, err := parser.ParseResolver(&req.Data)
+	if err != nil {
+		return errors.New("create resolver " + name + " failed: " + err.Error())
+	}
+
+	registry.ResolverRegistry().Unregister(name)
+
+	if err := registry.ResolverRegistry().Register(name, v); err != nil {
+		return errors.New("resolver " + name + " already exists")
+	}
+
+	config.OnUpdate(func(c *config.Config) error {
+		for i := range c.Resolvers {
+			if c.Resolvers[i].Name == name {
+				c.Resolvers[i] = &req.Data
+				break
+			}
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func deleteResolver(req deleteResolverRequest) error {
+	name := strings.TrimSpace(req.Resolver)
+
+	if !registry.ResolverRegistry().IsRegistered(name) {
+		return errors.New("resolver " + name + " not found")
+	}
+	registry.ResolverRegistry().Unregister(name)
+
+	config.OnUpdate(func(c *config.Config) error {
+		resolvers := c.Resolvers
+		c.Resolvers = nil
+		for _, s := range resolvers {
+			if s.Name == name {
+				continue
+			}
+			c.Resolvers = append(c.Resolvers, s)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+type createResolverRequest struct {
+	Data config.ResolverConfig `json:"data"`
+}
+
+type updateResolverRequest struct {
+	Resolver string                `json:"resolver"`
+	Data     config.ResolverConfig `json:"data"`
+}
+
+type deleteResolverRequest struct {
+	Resolver string `json:"resolver"`
+}
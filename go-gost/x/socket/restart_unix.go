@@ -0,0 +1,13 @@
+//go:build !windows
+
+package socket
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartAgent 以新的可执行文件替换当前进程（保持进程号不变，便于服务管理器追踪）
+func restartAgent(binary string) error {
+	return syscall.Exec(binary, os.Args, os.Environ())
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package socket
+
+import (
+	"os"
+	"os/exec"
+
+	xlogger "github.com/go-gost/x/logger"
+)
+
+// restartAgent 启动新进程后退出当前进程
+func restartAgent(binary string) error {
+	cmd := exec.Command(binary, os.Args[1:]...)
+	cmd.Stdout = xlogger.Stdout()
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
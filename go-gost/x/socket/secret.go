@@ -0,0 +1,81 @@
+package socket
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gost/x/internal/util/crypto"
+	"github.com/go-gost/x/service"
+)
+
+// handleRotateSecret 将面板下发的新密钥写入节点配置文件，响应发出后使用新密钥重新连接
+// 面板在宽限期内同时接受新旧密钥，写入失败时返回错误，面板据此恢复旧密钥
+func (w *WebSocketReporter) handleRotateSecret(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化密钥失败: %v", err)
+	}
+	var req struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return fmt.Errorf("解析密钥失败: %v", err)
+	}
+	secret := req.Secret
+	if len(secret) < 16 || strings.ContainsAny(secret, " \t\r\n") {
+		return fmt.Errorf("新密钥格式无效")
+	}
+
+	if err := writeAgentSecret(AgentConfigFile, secret); err != nil {
+		return fmt.Errorf("写入%s失败: %v", AgentConfigFile, err)
+	}
+	fmt.Printf("🔑 已保存面板下发的新密钥，即将重新连接\n")
+
+	// 本次响应仍使用当前连接的密钥加密，发出后再切换
+	time.AfterFunc(time.Second, func() {
+		w.applySecret(secret)
+		w.reconnect()
+	})
+	return nil
+}
+
+// applySecret 切换连接和 HTTP 上报使用的密钥
+func (w *WebSocketReporter) applySecret(secret string) {
+	aesCrypto, err := crypto.NewAESCrypto(secret)
+	if err != nil {
+		fmt.Printf("❌ 创建 AES 加密器失败: %v\n", err)
+		return
+	}
+
+	w.connMutex.Lock()
+	w.secret = secret
+	w.aesCrypto = aesCrypto
+	addr := w.reportAddr
+	w.connMutex.Unlock()
+
+	service.SetHTTPReportURL(addr, secret)
+}
+
+// writeAgentSecret 将密钥写入节点配置文件，保留其他字段
+func writeAgentSecret(path, secret string) error {
+	cfg := make(map[string]interface{})
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return err
+		}
+	}
+	cfg["secret"] = secret
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
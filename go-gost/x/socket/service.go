@@ -63,9 +63,13 @@ func createServices(req createServicesRequest) error {
 		registeredServices = append(registeredServices, ps.config.Name)
 	}
 
-	// 第三阶段：启动所有服务
+	// 第三阶段：启动所有服务，以暂停状态创建的服务保留注册但释放监听端口，等待恢复命令
 	for _, ps := range parsedServices {
 		if svc := registry.ServiceRegistry().Get(ps.config.Name); svc != nil {
+			if ps.config.Metadata != nil && ps.config.Metadata["paused"] == true {
+				svc.Close()
+				continue
+			}
 			go svc.Serve()
 		}
 	}
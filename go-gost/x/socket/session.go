@@ -0,0 +1,117 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+	"github.com/go-gost/x/internal/util/crypto"
+	xmetrics "github.com/go-gost/x/metrics"
+)
+
+// sessionNonceHeader 握手时双方交换 nonce 的请求/响应头，用于派生会话密钥
+const sessionNonceHeader = "X-Session-Nonce"
+
+// rejectedMessages 控制通道累计拒绝的消息数，随系统信息上报
+var rejectedMessages atomic.Int64
+
+// setupSession 根据握手响应建立会话密钥；面板不支持会话时退回到节点密钥加密
+func (w *WebSocketReporter) setupSession(clientNonce string, resp *http.Response) {
+	w.sessionCrypto = nil
+	w.replayGuard = nil
+
+	if resp == nil {
+		return
+	}
+	serverNonce := resp.Header.Get(sessionNonceHeader)
+	if serverNonce == "" {
+		fmt.Printf("⚠️ 面板不支持会话密钥，使用兼容模式\n")
+		return
+	}
+
+	sessionCrypto, err := crypto.NewAESCryptoFromKey(crypto.DeriveSessionKey(w.secret, clientNonce, serverNonce))
+	if err != nil {
+		fmt.Printf("❌ 创建会话加密器失败: %v\n", err)
+		return
+	}
+	w.sessionCrypto = sessionCrypto
+	w.replayGuard = crypto.NewReplayGuard(crypto.DefaultReplayWindow)
+	fmt.Printf("🔐 会话密钥协商成功\n")
+}
+
+// sealMessage 加密待发送的消息，优先使用会话密钥的签名信封
+func (w *WebSocketReporter) sealMessage(data []byte) []byte {
+	if w.sessionCrypto != nil {
+		sealed, err := w.sessionCrypto.Seal(data)
+		if err != nil {
+			fmt.Printf("⚠️ 加密失败，发送原始数据: %v\n", err)
+			return data
+		}
+		return sealed
+	}
+
+	if w.aesCrypto == nil {
+		return data
+	}
+	encryptedData, err := w.aesCrypto.Encrypt(data)
+	if err != nil {
+		fmt.Printf("⚠️ 加密失败，发送原始数据: %v\n", err)
+		return data
+	}
+	messageData, err := json.Marshal(crypto.Envelope{
+		Encrypted: true,
+		Data:      encryptedData,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Printf("⚠️ 序列化加密消息失败，发送原始数据: %v\n", err)
+		return data
+	}
+	return messageData
+}
+
+// openMessage 解密收到的消息；建立会话后只接受带签名且未重放的信封
+func (w *WebSocketReporter) openMessage(message []byte) ([]byte, error) {
+	var env crypto.Envelope
+	isEnvelope := json.Unmarshal(message, &env) == nil && env.Encrypted
+
+	if w.sessionCrypto != nil {
+		if !isEnvelope {
+			return nil, crypto.ErrLegacyEnvelope
+		}
+		return w.sessionCrypto.Open(&env, w.replayGuard)
+	}
+
+	if !isEnvelope {
+		return message, nil
+	}
+	if w.aesCrypto == nil {
+		return nil, errors.New("没有可用的解密器")
+	}
+	return w.aesCrypto.Decrypt(env.Data)
+}
+
+// rejectMessage 记录被拒绝的控制消息
+func rejectMessage(err error) {
+	reason := "invalid"
+	switch {
+	case errors.Is(err, crypto.ErrReplay):
+		reason = "replay"
+	case errors.Is(err, crypto.ErrExpired):
+		reason = "expired"
+	case errors.Is(err, crypto.ErrLegacyEnvelope):
+		reason = "unsigned"
+	}
+
+	rejectedMessages.Add(1)
+	if counter := xmetrics.GetCounter(
+		xmetrics.MetricControlMessagesRejectedCounter,
+		metrics.Labels{"reason": reason}); counter != nil {
+		counter.Inc()
+	}
+	fmt.Printf("🚫 拒绝控制消息 (%s): %v\n", reason, err)
+}
@@ -0,0 +1,338 @@
+package socket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/config"
+	xlogger "github.com/go-gost/x/logger"
+	"github.com/go-gost/x/service"
+)
+
+// AgentConfigFile 节点程序配置文件，面板下发的节点设置与连接信息一同保存在其中
+const AgentConfigFile = "config.json"
+
+// defaultPingInterval 默认的系统信息上报间隔
+const defaultPingInterval = 2 * time.Second
+
+// AgentSettings 面板管理的节点设置，数值为 0 或字符串为空时使用默认值
+type AgentSettings struct {
+	Addrs          []string          `json:"addrs"`          // 面板地址列表，连接失败时依次切换
+	PingInterval   int               `json:"pingInterval"`   // 系统信息上报间隔(秒)，默认 2
+	ConfigInterval int               `json:"configInterval"` // 配置上报间隔(秒)，默认 600
+	ObserverPeriod int               `json:"observerPeriod"` // 服务流量统计上报周期(秒)，默认 5
+	LogLevel       string            `json:"logLevel"`
+	Metrics        *ListenerSettings `json:"metrics,omitempty"` // 为 nil 时沿用配置文件
+	API            *ListenerSettings `json:"api,omitempty"`     // 为 nil 时沿用配置文件
+	Http           int               `json:"http"`
+	Tls            int               `json:"tls"`
+	Socks          int               `json:"socks"`
+}
+
+// ListenerSettings 指标或 Web API 服务的监听设置，Addr 为空时关闭
+type ListenerSettings struct {
+	Addr     string `json:"addr"`
+	Path     string `json:"path,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+var (
+	settingsMutex sync.RWMutex
+	agentSettings AgentSettings
+
+	// listenerUpdater 由主程序注册，按设置重建指标和 Web API 服务，参数为 nil 时保持不变
+	listenerUpdater func(api *config.APIConfig, metrics *config.MetricsConfig) error
+)
+
+// SetListenerUpdater 注册指标和 Web API 服务的重建函数
+func SetListenerUpdater(updater func(api *config.APIConfig, metrics *config.MetricsConfig) error) {
+	listenerUpdater = updater
+}
+
+// LoadAgentSettings 从节点配置文件读取面板下发的设置，并应用上报间隔等无需等待服务启动的部分
+func LoadAgentSettings(path string) (AgentSettings, error) {
+	var s AgentSettings
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("读取节点设置失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("解析节点设置失败: %v", err)
+	}
+	if err := s.validate(); err != nil {
+		return AgentSettings{}, err
+	}
+
+	settingsMutex.Lock()
+	agentSettings = s
+	settingsMutex.Unlock()
+
+	service.SetConfigReportInterval(time.Duration(s.ConfigInterval) * time.Second)
+	service.SetObserverPeriod(time.Duration(s.ObserverPeriod) * time.Second)
+	return s, nil
+}
+
+// ApplyLocalSettings 在服务加载完成后应用日志级别和监听设置，面板下发的设置优先于配置文件
+func ApplyLocalSettings() error {
+	settingsMutex.RLock()
+	s := agentSettings
+	settingsMutex.RUnlock()
+
+	if s.LogLevel != "" {
+		if err := xlogger.SetLevel(logger.Default(), logger.LogLevel(s.LogLevel)); err != nil {
+			return err
+		}
+	}
+	return applyListeners(s)
+}
+
+// handleGetAgentSettings 返回节点当前生效的设置
+func (w *WebSocketReporter) handleGetAgentSettings() AgentSettings {
+	settingsMutex.RLock()
+	s := agentSettings
+	settingsMutex.RUnlock()
+
+	w.connMutex.Lock()
+	s.Addrs = append([]string(nil), w.addrs...)
+	s.PingInterval = int(w.pingInterval / time.Second)
+	w.connMutex.Unlock()
+
+	s.ConfigInterval = int(service.ConfigReportInterval() / time.Second)
+	s.ObserverPeriod = int(service.ObserverPeriod() / time.Second)
+	s.LogLevel = string(logger.Default().GetLevel())
+	s.Http, s.Tls, s.Socks = service.ProtocolBlock()
+	return s
+}
+
+// handleApplyAgentSettings 校验并应用面板下发的设置，成功后写入节点配置文件
+func (w *WebSocketReporter) handleApplyAgentSettings(data interface{}) (AgentSettings, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return AgentSettings{}, fmt.Errorf("序列化节点设置失败: %v", err)
+	}
+	var s AgentSettings
+	if err := json.Unmarshal(jsonData, &s); err != nil {
+		return AgentSettings{}, fmt.Errorf("解析节点设置失败: %v", err)
+	}
+	if err := s.validate(); err != nil {
+		return AgentSettings{}, err
+	}
+
+	// 先应用可能失败的部分，失败时不修改其他设置
+	if s.LogLevel != "" {
+		if err := xlogger.SetLevel(logger.Default(), logger.LogLevel(s.LogLevel)); err != nil {
+			return AgentSettings{}, err
+		}
+	}
+	if err := applyListeners(s); err != nil {
+		return AgentSettings{}, fmt.Errorf("应用监听设置失败: %v", err)
+	}
+
+	service.SetConfigReportInterval(time.Duration(s.ConfigInterval) * time.Second)
+	service.SetObserverPeriod(time.Duration(s.ObserverPeriod) * time.Second)
+	service.SetProtocolBlock(s.Http, s.Tls, s.Socks)
+	reconnect := w.applyConnectionSettings(s)
+
+	settingsMutex.Lock()
+	agentSettings = s
+	settingsMutex.Unlock()
+
+	if err := writeAgentSettings(AgentConfigFile, s); err != nil {
+		return AgentSettings{}, fmt.Errorf("写入%s失败: %v", AgentConfigFile, err)
+	}
+	fmt.Printf("⚙️ 已应用面板下发的节点设置\n")
+
+	if reconnect {
+		// 当前面板地址已不在列表中，响应发出后断开并按新列表重连
+		time.AfterFunc(time.Second, w.reconnect)
+	}
+	return w.handleGetAgentSettings(), nil
+}
+
+// applyConnectionSettings 更新面板地址列表和上报间隔，返回是否需要重连
+func (w *WebSocketReporter) applyConnectionSettings(s AgentSettings) bool {
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+
+	w.pingInterval = defaultPingInterval
+	if s.PingInterval > 0 {
+		w.pingInterval = time.Duration(s.PingInterval) * time.Second
+	}
+
+	if len(s.Addrs) == 0 {
+		return false
+	}
+	w.addrs = append([]string(nil), s.Addrs...)
+	for _, addr := range w.addrs {
+		if addr == w.addr {
+			return false
+		}
+	}
+	w.addr = w.addrs[0]
+	return true
+}
+
+// nextAddr 连接失败后切换到下一个面板地址
+func (w *WebSocketReporter) nextAddr() {
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+
+	if len(w.addrs) < 2 {
+		return
+	}
+	next := w.addrs[0]
+	for i, addr := range w.addrs {
+		if addr == w.addr {
+			next = w.addrs[(i+1)%len(w.addrs)]
+			break
+		}
+	}
+	if next != w.addr {
+		fmt.Printf("🔀 切换面板地址: %s -> %s\n", w.addr, next)
+		w.addr = next
+	}
+}
+
+// reconnect 断开当前连接，由主循环重新连接
+func (w *WebSocketReporter) reconnect() {
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+
+	if w.conn != nil {
+		w.conn.Close()
+	}
+	w.connected = false
+}
+
+// applyListeners 按设置重建指标和 Web API 服务
+func applyListeners(s AgentSettings) error {
+	if s.API == nil && s.Metrics == nil {
+		return nil
+	}
+	if listenerUpdater == nil {
+		return fmt.Errorf("节点程序不支持调整监听设置")
+	}
+
+	var apiCfg *config.APIConfig
+	if s.API != nil {
+		apiCfg = &config.APIConfig{
+			Addr:       s.API.Addr,
+			PathPrefix: s.API.Path,
+		}
+		if s.API.Username != "" {
+			apiCfg.Auth = &config.AuthConfig{Username: s.API.Username, Password: s.API.Password}
+		}
+	}
+	var metricsCfg *config.MetricsConfig
+	if s.Metrics != nil {
+		metricsCfg = &config.MetricsConfig{
+			Addr: s.Metrics.Addr,
+			Path: s.Metrics.Path,
+		}
+		if s.Metrics.Username != "" {
+			metricsCfg.Auth = &config.AuthConfig{Username: s.Metrics.Username, Password: s.Metrics.Password}
+		}
+	}
+	return listenerUpdater(apiCfg, metricsCfg)
+}
+
+// validate 校验设置取值
+func (s *AgentSettings) validate() error {
+	for i, addr := range s.Addrs {
+		addr = strings.TrimSpace(addr)
+		if err := validateHostPort(addr); err != nil {
+			return fmt.Errorf("面板地址 %q 无效: %v", addr, err)
+		}
+		s.Addrs[i] = addr
+	}
+	if s.PingInterval < 0 || s.PingInterval > 60 {
+		return fmt.Errorf("系统信息上报间隔应为1-60秒")
+	}
+	if s.ConfigInterval != 0 && (s.ConfigInterval < 60 || s.ConfigInterval > 86400) {
+		return fmt.Errorf("配置上报间隔应为60-86400秒")
+	}
+	if s.ObserverPeriod < 0 || s.ObserverPeriod > 300 {
+		return fmt.Errorf("流量统计上报周期应为1-300秒")
+	}
+	switch logger.LogLevel(s.LogLevel) {
+	case "", logger.TraceLevel, logger.DebugLevel, logger.InfoLevel, logger.WarnLevel, logger.ErrorLevel:
+	default:
+		return fmt.Errorf("无效的日志级别: %s", s.LogLevel)
+	}
+	if s.Metrics != nil && s.Metrics.Addr != "" {
+		if err := validateHostPort(s.Metrics.Addr); err != nil {
+			return fmt.Errorf("指标监听地址无效: %v", err)
+		}
+	}
+	if s.API != nil && s.API.Addr != "" {
+		if err := validateHostPort(s.API.Addr); err != nil {
+			return fmt.Errorf("Web API 监听地址无效: %v", err)
+		}
+		// Web API 可修改节点配置，必须启用认证
+		if s.API.Username == "" || s.API.Password == "" {
+			return fmt.Errorf("启用 Web API 时必须设置用户名和密码")
+		}
+	}
+	for name, v := range map[string]int{"http": s.Http, "tls": s.Tls, "socks": s.Socks} {
+		if v != 0 && v != 1 {
+			return fmt.Errorf("%s 取值必须为0或1", name)
+		}
+	}
+	return nil
+}
+
+// validateHostPort 校验 host:port 格式的地址
+func validateHostPort(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("端口无效")
+	}
+	return nil
+}
+
+// writeAgentSettings 将设置合并写入节点配置文件，保留连接密钥等其他字段
+func writeAgentSettings(path string, s AgentSettings) error {
+	cfg := make(map[string]interface{})
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		cfg[k] = v
+	}
+	if len(s.Addrs) > 0 {
+		// 主地址同步写入 addr，回退到旧版本节点程序时仍可连接
+		cfg["addr"] = s.Addrs[0]
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,218 @@
+package socket
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// TelemetryVersion 扩展遥测消息的格式版本，字段有不兼容变化时递增
+const TelemetryVersion = 1
+
+// telemetryInterval 扩展遥测的上报间隔，采集连接表等开销较大，低于系统信息的上报频率
+const telemetryInterval = 60 * time.Second
+
+// TelemetryMessage 扩展遥测消息
+type TelemetryMessage struct {
+	Type string    `json:"type"` // 固定为 telemetry
+	Data Telemetry `json:"data"`
+}
+
+// Telemetry 扩展遥测数据
+type Telemetry struct {
+	Version    int              `json:"v"`
+	Time       int64            `json:"time"` // 采集时间（秒）
+	Load1      float64          `json:"load1"`
+	Load5      float64          `json:"load5"`
+	Load15     float64          `json:"load15"`
+	Disks      []DiskUsage      `json:"disks"`
+	TCPStates  map[string]int   `json:"tcp_states"` // 按状态统计的 TCP 连接数
+	Interfaces []InterfaceStats `json:"interfaces"`
+	Conntrack  *ConntrackUsage  `json:"conntrack,omitempty"` // 未加载 nf_conntrack 时为空
+	FDs        *FDUsage         `json:"fds,omitempty"`
+}
+
+// DiskUsage 分区使用情况
+type DiskUsage struct {
+	Path        string  `json:"path"`
+	Fstype      string  `json:"fstype"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// InterfaceStats 网卡累计流量
+type InterfaceStats struct {
+	Name        string `json:"name"`
+	BytesRecv   uint64 `json:"rx"`
+	BytesSent   uint64 `json:"tx"`
+	PacketsRecv uint64 `json:"rx_packets"`
+	PacketsSent uint64 `json:"tx_packets"`
+	Errin       uint64 `json:"rx_errors"`
+	Errout      uint64 `json:"tx_errors"`
+}
+
+// ConntrackUsage 连接跟踪表使用情况
+type ConntrackUsage struct {
+	Count int64 `json:"count"`
+	Max   int64 `json:"max"`
+}
+
+// FDUsage 节点进程的文件描述符使用情况
+type FDUsage struct {
+	Open  int32  `json:"open"`
+	Limit uint64 `json:"limit"`
+}
+
+// collectTelemetry 采集扩展遥测数据
+func collectTelemetry() Telemetry {
+	t := Telemetry{
+		Version:    TelemetryVersion,
+		Time:       time.Now().Unix(),
+		Disks:      getDiskUsage(),
+		TCPStates:  getTCPStates(),
+		Interfaces: getInterfaceStats(),
+		Conntrack:  getConntrackUsage(),
+		FDs:        getFDUsage(),
+	}
+
+	if avg, err := load.Avg(); err == nil {
+		t.Load1, t.Load5, t.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+	return t
+}
+
+// getDiskUsage 获取物理分区的使用情况，同一设备只统计一次
+func getDiskUsage() []DiskUsage {
+	usages := make([]DiskUsage, 0)
+
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return usages
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Device] {
+			continue
+		}
+		seen[p.Device] = true
+
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		usages = append(usages, DiskUsage{
+			Path:        p.Mountpoint,
+			Fstype:      p.Fstype,
+			Total:       usage.Total,
+			Used:        usage.Used,
+			UsedPercent: usage.UsedPercent,
+		})
+	}
+	return usages
+}
+
+// getTCPStates 按状态统计 TCP 连接数（含 IPv6）
+func getTCPStates() map[string]int {
+	states := make(map[string]int)
+
+	conns, err := psnet.ConnectionsWithoutUids("tcp")
+	if err != nil {
+		return states
+	}
+	for _, c := range conns {
+		states[c.Status]++
+	}
+	return states
+}
+
+// getInterfaceStats 获取各非回环网卡的累计流量
+func getInterfaceStats() []InterfaceStats {
+	stats := make([]InterfaceStats, 0)
+
+	ioCounters, err := psnet.IOCounters(true)
+	if err != nil {
+		return stats
+	}
+	for _, io := range ioCounters {
+		if strings.HasPrefix(io.Name, "lo") {
+			continue
+		}
+		stats = append(stats, InterfaceStats{
+			Name:        io.Name,
+			BytesRecv:   io.BytesRecv,
+			BytesSent:   io.BytesSent,
+			PacketsRecv: io.PacketsRecv,
+			PacketsSent: io.PacketsSent,
+			Errin:       io.Errin,
+			Errout:      io.Errout,
+		})
+	}
+	return stats
+}
+
+// getConntrackUsage 获取连接跟踪表使用情况，系统不支持时返回 nil
+func getConntrackUsage() *ConntrackUsage {
+	filters, err := psnet.FilterCounters()
+	if err != nil || len(filters) == 0 {
+		return nil
+	}
+	return &ConntrackUsage{
+		Count: filters[0].ConnTrackCount,
+		Max:   filters[0].ConnTrackMax,
+	}
+}
+
+// getFDUsage 获取节点进程打开的文件描述符数量及上限
+func getFDUsage() *FDUsage {
+	p, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return nil
+	}
+	open, err := p.NumFDs()
+	if err != nil {
+		return nil
+	}
+
+	usage := &FDUsage{Open: open}
+	if limits, err := p.Rlimit(); err == nil {
+		for _, l := range limits {
+			if l.Resource == process.RLIMIT_NOFILE {
+				usage.Limit = l.Soft
+				break
+			}
+		}
+	}
+	return usage
+}
+
+// sendTelemetry 发送扩展遥测
+func (w *WebSocketReporter) sendTelemetry(t Telemetry) error {
+	jsonData, err := json.Marshal(TelemetryMessage{Type: "telemetry", Data: t})
+	if err != nil {
+		return fmt.Errorf("序列化遥测数据失败: %v", err)
+	}
+
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+
+	if w.conn == nil || !w.connected {
+		return fmt.Errorf("连接未建立")
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := w.conn.WriteMessage(websocket.TextMessage, w.sealMessage(jsonData)); err != nil {
+		w.connected = false
+		return fmt.Errorf("写入消息失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package socket
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	upgradeStateFile      = "upgrade.json"
+	upgradeMaxSize        = 200 << 20 // 升级包最大 200MB
+	upgradeDefaultTimeout = 120       // 新版本连上面板的默认期限（秒）
+	upgradeMaxAttempts    = 3         // 新版本启动失败的最大次数，超过后回滚
+)
+
+var upgradePublicKey ed25519.PublicKey
+
+// SetUpgradePublicKey 设置校验升级包签名的 ed25519 公钥（base64），未设置时拒绝升级
+func SetUpgradePublicKey(key string) error {
+	if key == "" {
+		upgradePublicKey = nil
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return errors.New("升级公钥格式错误，应为 base64 编码的 ed25519 公钥")
+	}
+	upgradePublicKey = ed25519.PublicKey(b)
+	return nil
+}
+
+// UpgradeRequest 升级请求
+type UpgradeRequest struct {
+	Version   string `json:"version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`    // 二进制文件的 sha256（hex）
+	Signature string `json:"signature"` // 对清单 "version|os|arch|sha256" 的 ed25519 签名（base64）
+	Timeout   int    `json:"timeout"`   // 新版本需在该时间内连上面板，否则回滚（秒）
+}
+
+// upgradeState 升级过程状态，新版本启动后据此确认或回滚
+type upgradeState struct {
+	Version  string `json:"version"`  // 目标版本
+	Previous string `json:"previous"` // 升级前版本
+	Binary   string `json:"binary"`   // 当前可执行文件路径
+	Backup   string `json:"backup"`   // 旧版本备份路径
+	Timeout  int    `json:"timeout"`
+	Attempts int    `json:"attempts"` // 新版本已启动次数
+}
+
+// handleUpgradeAgent 下载并校验新版本，原子替换当前可执行文件后重启
+func (w *WebSocketReporter) handleUpgradeAgent(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化升级数据失败: %v", err)
+	}
+
+	var req UpgradeRequest
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return fmt.Errorf("解析升级请求失败: %v", err)
+	}
+
+	if upgradePublicKey == nil {
+		return errors.New("节点未配置升级公钥，拒绝升级")
+	}
+	if req.URL == "" || req.SHA256 == "" || req.Signature == "" || req.Version == "" {
+		return errors.New("升级请求缺少版本、下载地址、校验值或签名")
+	}
+	if compareVersion(req.Version, w.version) <= 0 {
+		return fmt.Errorf("目标版本 %s 不高于当前版本 %s，拒绝升级", req.Version, w.version)
+	}
+	if (req.OS != "" && req.OS != runtime.GOOS) || (req.Arch != "" && req.Arch != runtime.GOARCH) {
+		return fmt.Errorf("升级包平台 %s/%s 与节点 %s/%s 不符", req.OS, req.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+
+	digest, err := hex.DecodeString(req.SHA256)
+	if err != nil || len(digest) != sha256.Size {
+		return errors.New("sha256 校验值格式错误")
+	}
+	// 签名覆盖版本和平台，清单使用节点自身的平台，防止签名被挪用到其他平台或旧版本的升级包
+	manifest := upgradeManifest(req.Version, runtime.GOOS, runtime.GOARCH, req.SHA256)
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil || !ed25519.Verify(upgradePublicKey, manifest, signature) {
+		return errors.New("升级包签名校验失败")
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	newBinary := binary + ".new"
+	if err := downloadUpgrade(req.URL, newBinary, digest); err != nil {
+		os.Remove(newBinary)
+		return err
+	}
+
+	// 先备份旧版本再替换，两次 rename 均为原子操作
+	backup := binary + ".bak"
+	if err := os.Rename(binary, backup); err != nil {
+		os.Remove(newBinary)
+		return fmt.Errorf("备份当前版本失败: %v", err)
+	}
+	if err := os.Rename(newBinary, binary); err != nil {
+		os.Rename(backup, binary)
+		os.Remove(newBinary)
+		return fmt.Errorf("替换可执行文件失败: %v", err)
+	}
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = upgradeDefaultTimeout
+	}
+	state := upgradeState{
+		Version:  req.Version,
+		Previous: w.version,
+		Binary:   binary,
+		Backup:   backup,
+		Timeout:  timeout,
+	}
+	if err := saveUpgradeState(&state); err != nil {
+		os.Rename(backup, binary)
+		return fmt.Errorf("保存升级状态失败: %v", err)
+	}
+
+	fmt.Printf("⬆️ 已升级到版本 %s，即将重启\n", req.Version)
+	// 先回复面板再重启
+	time.AfterFunc(2*time.Second, func() {
+		if err := restartAgent(binary); err != nil {
+			fmt.Printf("❌ 重启失败，回滚到旧版本: %v\n", err)
+			rollbackUpgrade(&state)
+		}
+	})
+	return nil
+}
+
+// upgradeManifest 生成升级包签名清单 "version|os|arch|sha256"，sha256 为小写 hex
+func upgradeManifest(version, goos, goarch, digest string) []byte {
+	return []byte(strings.Join([]string{version, goos, goarch, strings.ToLower(digest)}, "|"))
+}
+
+// compareVersion 按点分隔的数字比较版本号，忽略前缀 v 和 - 之后的后缀，缺少的段视为 0
+func compareVersion(a, b string) int {
+	as, bs := versionParts(a), versionParts(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(s)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// downloadUpgrade 下载升级包并校验 sha256
+func downloadUpgrade(url, path string, digest []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("创建下载请求失败: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载升级包失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载升级包失败: HTTP %d", resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("创建升级文件失败: %v", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(resp.Body, upgradeMaxSize+1))
+	if err != nil {
+		return fmt.Errorf("写入升级文件失败: %v", err)
+	}
+	if n > upgradeMaxSize {
+		return errors.New("升级包超过大小限制")
+	}
+	if !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), hex.EncodeToString(digest)) {
+		return errors.New("升级包 sha256 校验失败")
+	}
+	return f.Sync()
+}
+
+func saveUpgradeState(state *upgradeState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := upgradeStateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, upgradeStateFile)
+}
+
+func loadUpgradeState() (*upgradeState, error) {
+	b, err := os.ReadFile(upgradeStateFile)
+	if err != nil {
+		return nil, err
+	}
+	var state upgradeState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// rollbackUpgrade 恢复旧版本并重启
+func rollbackUpgrade(state *upgradeState) {
+	fmt.Printf("↩️ 回滚到版本 %s\n", state.Previous)
+	if err := os.Rename(state.Backup, state.Binary); err != nil {
+		fmt.Printf("❌ 回滚失败: %v\n", err)
+		return
+	}
+	os.Remove(upgradeStateFile)
+	if err := restartAgent(state.Binary); err != nil {
+		fmt.Printf("❌ 回滚后重启失败: %v\n", err)
+	}
+}
+
+// checkPendingUpgrade 检查未确认的升级：新版本在期限内连上面板则确认，否则回滚
+func (w *WebSocketReporter) checkPendingUpgrade() {
+	state, err := loadUpgradeState()
+	if err != nil {
+		return
+	}
+
+	// 以旧版本运行（如升级后手动恢复）时直接清理状态
+	if state.Version != w.version {
+		os.Remove(upgradeStateFile)
+		return
+	}
+
+	state.Attempts++
+	if state.Attempts > upgradeMaxAttempts {
+		rollbackUpgrade(state)
+		return
+	}
+	saveUpgradeState(state)
+
+	go func() {
+		deadline := time.Now().Add(time.Duration(state.Timeout) * time.Second)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+				if w.isConnected() {
+					os.Remove(state.Backup)
+					os.Remove(upgradeStateFile)
+					fmt.Printf("✅ 升级到版本 %s 已确认\n", state.Version)
+					return
+				}
+				if time.Now().After(deadline) {
+					rollbackUpgrade(state)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// isConnected 当前是否已连上面板
+func (w *WebSocketReporter) isConnected() bool {
+	w.connMutex.Lock()
+	defer w.connMutex.Unlock()
+	return w.connected
+}
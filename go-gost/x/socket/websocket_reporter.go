@@ -5,9 +5,13 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync" // 新增：用于管理连接状态的互斥锁
@@ -15,6 +19,8 @@ import (
 
 	"github.com/go-gost/x/config"
 	"github.com/go-gost/x/internal/util/crypto"
+	xlogger "github.com/go-gost/x/logger"
+	"github.com/go-gost/x/registry"
 	"github.com/go-gost/x/service"
 	"github.com/gorilla/websocket"
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -31,6 +37,7 @@ type SystemInfo struct {
 	BytesTransmitted uint64  `json:"bytes_transmitted"` // 发送字节数
 	CPUUsage         float64 `json:"cpu_usage"`         // CPU使用率（百分比）
 	MemoryUsage      float64 `json:"memory_usage"`      // 内存使用率（百分比）
+	RejectedMessages int64   `json:"rejected_messages"` // 累计拒绝的控制消息数（重放、过期或未签名）
 }
 
 // NetworkStats 网络统计信息
@@ -70,19 +77,22 @@ type TcpPingRequest struct {
 	IP        string `json:"ip"`
 	Port      int    `json:"port"`
 	Count     int    `json:"count"`
-	Timeout   int    `json:"timeout"` // 超时时间(毫秒)
+	Timeout   int    `json:"timeout"`            // 超时时间(毫秒)
+	Resolver  string `json:"resolver,omitempty"` // 解析域名使用的解析器，为空使用系统解析
+	Prefer    string `json:"prefer,omitempty"`   // 解析优先级: ipv4, ipv6
 	RequestId string `json:"requestId,omitempty"`
 }
 
 // TcpPingResponse TCP ping响应结构体
 type TcpPingResponse struct {
-	IP           string  `json:"ip"`
-	Port         int     `json:"port"`
-	Success      bool    `json:"success"`
-	AverageTime  float64 `json:"averageTime"` // 平均连接时间(ms)
-	PacketLoss   float64 `json:"packetLoss"`  // 连接失败率(%)
-	ErrorMessage string  `json:"errorMessage,omitempty"`
-	RequestId    string  `json:"requestId,omitempty"`
+	IP           string   `json:"ip"`
+	Port         int      `json:"port"`
+	Success      bool     `json:"success"`
+	AverageTime  float64  `json:"averageTime"`           // 平均连接时间(ms)
+	PacketLoss   float64  `json:"packetLoss"`            // 连接失败率(%)
+	ResolvedIPs  []string `json:"resolvedIps,omitempty"` // 域名解析结果
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+	RequestId    string   `json:"requestId,omitempty"`
 }
 
 type WebSocketReporter struct {
@@ -97,9 +107,14 @@ type WebSocketReporter struct {
 	ctx            context.Context
 	cancel         context.CancelFunc
 	connected      bool
-	connecting     bool              // 新增：正在连接状态
-	connMutex      sync.Mutex        // 新增：连接状态锁
-	aesCrypto      *crypto.AESCrypto // 新增：AES加密器
+	connecting     bool                // 新增：正在连接状态
+	connMutex      sync.Mutex          // 新增：连接状态锁
+	aesCrypto      *crypto.AESCrypto   // 新增：AES加密器
+	sessionCrypto  *crypto.AESCrypto   // 本次连接协商的会话密钥加密器
+	replayGuard    *crypto.ReplayGuard // 本次连接的重放检测器
+
+	addrs      []string // 面板地址列表，连接失败时依次切换
+	reportAddr string   // HTTP 上报当前使用的面板地址
 }
 
 // NewWebSocketReporter 创建一个新的WebSocket报告器
@@ -157,6 +172,7 @@ func (w *WebSocketReporter) run() {
 			if needConnect {
 				if err := w.connect(); err != nil {
 					fmt.Printf("❌ WebSocket连接失败: %v，%v后重试\n", err, w.reconnectTime)
+					w.nextAddr()
 					select {
 					case <-time.After(w.reconnectTime):
 						continue
@@ -213,18 +229,29 @@ func (w *WebSocketReporter) connect() error {
 	}
 
 	// 使用最新的配置重新构建 URL
-	currentURL := "ws://" + w.addr + "/system-info?type=1&secret=" + w.secret + "&version=" + w.version +
-		"&http=" + strconv.Itoa(cfg.Http) + "&tls=" + strconv.Itoa(cfg.Tls) + "&socks=" + strconv.Itoa(cfg.Socks)
+	currentURL := service.PanelWSURL(w.addr, "/system-info?type=1&version="+w.version+
+		"&http="+strconv.Itoa(cfg.Http)+"&tls="+strconv.Itoa(cfg.Tls)+"&socks="+strconv.Itoa(cfg.Socks)+
+		"&os="+runtime.GOOS+"&arch="+runtime.GOARCH)
 
 	u, err := url.Parse(currentURL)
 	if err != nil {
 		return fmt.Errorf("解析URL失败: %v", err)
 	}
 
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = 10 * time.Second
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:  service.PanelTLSConfig(),
+	}
+
+	// 密钥通过请求头携带，避免出现在URL和访问日志中
+	header := http.Header{}
+	header.Set(service.PanelSecretHeader, w.secret)
+	clientNonce := crypto.NewNonce()
+	header.Set(sessionNonceHeader, clientNonce)
+	setCapabilityHeaders(header)
 
-	conn, _, err := dialer.Dial(u.String(), nil)
+	conn, resp, err := dialer.Dial(u.String(), header)
 	if err != nil {
 		return fmt.Errorf("连接WebSocket失败: %v", err)
 	}
@@ -237,6 +264,8 @@ func (w *WebSocketReporter) connect() error {
 
 	w.conn = conn
 	w.connected = true
+	w.setupSession(clientNonce, resp)
+	checkPanelProtocol(resp)
 
 	// 设置关闭处理器来检测连接状态
 	w.conn.SetCloseHandler(func(code int, text string) error {
@@ -247,6 +276,15 @@ func (w *WebSocketReporter) connect() error {
 	})
 
 	fmt.Printf("✅ WebSocket连接建立成功 (http=%d, tls=%d, socks=%d)\n", cfg.Http, cfg.Tls, cfg.Socks)
+
+	// 切换到备用面板地址后，HTTP 上报同步使用该地址
+	if w.addr != w.reportAddr {
+		service.SetHTTPReportURL(w.addr, w.secret)
+		w.reportAddr = w.addr
+	}
+
+	// 重连后立即上报配置，由面板对账缺失的服务和暂停状态
+	service.ReportConfigNow()
 	return nil
 }
 
@@ -267,8 +305,13 @@ func (w *WebSocketReporter) handleConnection() {
 	go w.receiveMessages()
 
 	// 主发送循环
-	ticker := time.NewTicker(w.pingInterval)
+	w.connMutex.Lock()
+	interval := w.pingInterval
+	w.connMutex.Unlock()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	telemetryTicker := time.NewTicker(telemetryInterval)
+	defer telemetryTicker.Stop()
 
 	for {
 		select {
@@ -278,11 +321,16 @@ func (w *WebSocketReporter) handleConnection() {
 			// 检查连接状态
 			w.connMutex.Lock()
 			isConnected := w.connected
+			pingInterval := w.pingInterval
 			w.connMutex.Unlock()
 
 			if !isConnected {
 				return
 			}
+			if pingInterval != interval {
+				interval = pingInterval
+				ticker.Reset(interval)
+			}
 
 			// 获取系统信息并发送
 			sysInfo := w.collectSystemInfo()
@@ -290,6 +338,11 @@ func (w *WebSocketReporter) handleConnection() {
 				fmt.Printf("❌ 发送系统信息失败: %v，准备重连\n", err)
 				return
 			}
+		case <-telemetryTicker.C:
+			if err := w.sendTelemetry(collectTelemetry()); err != nil {
+				fmt.Printf("❌ 发送遥测数据失败: %v，准备重连\n", err)
+				return
+			}
 		}
 	}
 }
@@ -306,6 +359,7 @@ func (w *WebSocketReporter) collectSystemInfo() SystemInfo {
 		BytesTransmitted: networkStats.BytesTransmitted,
 		CPUUsage:         cpuInfo.Usage,
 		MemoryUsage:      memoryInfo.Usage,
+		RejectedMessages: rejectedMessages.Load(),
 	}
 }
 
@@ -324,30 +378,7 @@ func (w *WebSocketReporter) sendSystemInfo(sysInfo SystemInfo) error {
 		return fmt.Errorf("序列化系统信息失败: %v", err)
 	}
 
-	var messageData []byte
-
-	// 如果有加密器，则加密数据
-	if w.aesCrypto != nil {
-		encryptedData, err := w.aesCrypto.Encrypt(jsonData)
-		if err != nil {
-			fmt.Printf("⚠️ 加密失败，发送原始数据: %v\n", err)
-			messageData = jsonData
-		} else {
-			// 创建加密消息包装器
-			encryptedMessage := map[string]interface{}{
-				"encrypted": true,
-				"data":      encryptedData,
-				"timestamp": time.Now().Unix(),
-			}
-			messageData, err = json.Marshal(encryptedMessage)
-			if err != nil {
-				fmt.Printf("⚠️ 序列化加密消息失败，发送原始数据: %v\n", err)
-				messageData = jsonData
-			}
-		}
-	} else {
-		messageData = jsonData
-	}
+	messageData := w.sealMessage(jsonData)
 
 	// 设置写入超时
 	w.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
@@ -400,30 +431,18 @@ func (w *WebSocketReporter) receiveMessages() {
 func (w *WebSocketReporter) handleReceivedMessage(messageType int, message []byte) {
 	switch messageType {
 	case websocket.TextMessage:
-		// 先检查是否是加密消息
-		var encryptedWrapper struct {
-			Encrypted bool   `json:"encrypted"`
-			Data      string `json:"data"`
-			Timestamp int64  `json:"timestamp"`
-		}
-
-		// 尝试解析为加密消息格式
-		if err := json.Unmarshal(message, &encryptedWrapper); err == nil && encryptedWrapper.Encrypted {
-			if w.aesCrypto != nil {
-				// 解密数据
-				decryptedData, err := w.aesCrypto.Decrypt(encryptedWrapper.Data)
-				if err != nil {
-					fmt.Printf("❌ 解密失败: %v\n", err)
-					w.sendErrorResponse("DecryptError", fmt.Sprintf("解密失败: %v", err))
-					return
-				}
-				message = decryptedData
-			} else {
-				fmt.Printf("❌ 收到加密消息但没有加密器\n")
-				w.sendErrorResponse("NoDecryptor", "没有可用的解密器")
-				return
+		// 解密并校验消息（会话模式下拒绝未签名、过期或重放的消息）
+		opened, err := w.openMessage(message)
+		if err != nil {
+			rejectMessage(err)
+			// 重放、过期或未签名的消息直接丢弃，不做任何回应
+			if !errors.Is(err, crypto.ErrReplay) && !errors.Is(err, crypto.ErrExpired) && !errors.Is(err, crypto.ErrLegacyEnvelope) {
+				w.sendErrorResponse("DecryptError", fmt.Sprintf("解密失败: %v", err))
 			}
+			return
 		}
+		message = opened
+
 		// 先尝试解析是否是压缩消息
 		var compressedMsg struct {
 			Type       string          `json:"type"`
@@ -494,7 +513,62 @@ func (w *WebSocketReporter) routeCommand(cmd CommandMessage) {
 		return
 	}
 
-	fmt.Println("🔔 收到命令: ", string(jsonBytes))
+	// 标准输出会进入日志缓冲区和实时日志流，打印前隐去密钥、密码等敏感字段
+	fmt.Println("🔔 收到命令: ", string(redactSecrets(jsonBytes)))
+
+	// 耗时较长的诊断在独立的 goroutine 中执行，完成后再回复，避免阻塞消息读取和其他命令
+	if asyncCommands[cmd.Type] {
+		go w.executeCommand(cmd)
+		return
+	}
+	w.executeCommand(cmd)
+}
+
+// sensitiveFields 日志中需要隐去取值的字段
+var sensitiveFields = map[string]bool{
+	"secret":   true,
+	"password": true,
+}
+
+// redactSecrets 将 JSON 中敏感字段的取值替换为 ***，解析失败时不输出原文
+func redactSecrets(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte("<无法解析>")
+	}
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return []byte("<无法解析>")
+	}
+	return b
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if sensitiveFields[strings.ToLower(k)] {
+				v[k] = "***"
+				continue
+			}
+			v[k] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return v
+}
+
+// asyncCommands 需要异步执行的命令，执行时间可达数十秒
+var asyncCommands = map[string]bool{
+	"BandwidthTest": true,
+	"Traceroute":    true,
+}
+
+// executeCommand 执行命令并回复面板
+func (w *WebSocketReporter) executeCommand(cmd CommandMessage) {
 	var err error
 	var response CommandResponse
 
@@ -541,6 +615,39 @@ func (w *WebSocketReporter) routeCommand(cmd CommandMessage) {
 		err = w.handleDeleteLimiter(cmd.Data)
 		response.Type = "DeleteLimitersResponse"
 
+	// 连接数限流器相关命令
+	case "AddCLimiters":
+		err = w.handleAddConnLimiter(cmd.Data)
+		response.Type = "AddCLimitersResponse"
+	case "UpdateCLimiters":
+		err = w.handleUpdateConnLimiter(cmd.Data)
+		response.Type = "UpdateCLimitersResponse"
+	case "DeleteCLimiters":
+		err = w.handleDeleteConnLimiter(cmd.Data)
+		response.Type = "DeleteCLimitersResponse"
+
+	// 速率限流器相关命令
+	case "AddRLimiters":
+		err = w.handleAddRateLimiter(cmd.Data)
+		response.Type = "AddRLimitersResponse"
+	case "UpdateRLimiters":
+		err = w.handleUpdateRateLimiter(cmd.Data)
+		response.Type = "UpdateRLimitersResponse"
+	case "DeleteRLimiters":
+		err = w.handleDeleteRateLimiter(cmd.Data)
+		response.Type = "DeleteRLimitersResponse"
+
+	// 解析器相关命令
+	case "AddResolvers":
+		err = w.handleAddResolver(cmd.Data)
+		response.Type = "AddResolversResponse"
+	case "UpdateResolvers":
+		err = w.handleUpdateResolver(cmd.Data)
+		response.Type = "UpdateResolversResponse"
+	case "DeleteResolvers":
+		err = w.handleDeleteResolver(cmd.Data)
+		response.Type = "DeleteResolversResponse"
+
 	// TCP Ping 诊断命令
 	case "TcpPing":
 		var tcpPingResult TcpPingResponse
@@ -548,11 +655,85 @@ func (w *WebSocketReporter) routeCommand(cmd CommandMessage) {
 		response.Type = "TcpPingResponse"
 		response.Data = tcpPingResult
 
+	// 远程诊断命令
+	case "UdpProbe":
+		var udpProbeResult UdpProbeResponse
+		udpProbeResult, err = w.handleUdpProbe(cmd.Data)
+		response.Type = "UdpProbeResponse"
+		response.Data = udpProbeResult
+	case "HttpProbe":
+		var httpProbeResult HttpProbeResponse
+		httpProbeResult, err = w.handleHttpProbe(cmd.Data)
+		response.Type = "HttpProbeResponse"
+		response.Data = httpProbeResult
+	case "Traceroute":
+		var tracerouteResult TracerouteResponse
+		tracerouteResult, err = w.handleTraceroute(cmd.Data)
+		response.Type = "TracerouteResponse"
+		response.Data = tracerouteResult
+	case "DnsLookup":
+		var dnsLookupResult DnsLookupResponse
+		dnsLookupResult, err = w.handleDnsLookup(cmd.Data)
+		response.Type = "DnsLookupResponse"
+		response.Data = dnsLookupResult
+
+	// 隧道带宽测试
+	case "StartBandwidthServer":
+		var serverResult map[string]string
+		serverResult, err = w.handleStartBandwidthServer(cmd.Data)
+		response.Type = "StartBandwidthServerResponse"
+		response.Data = serverResult
+	case "StopBandwidthServer":
+		var serverStats BandwidthServerStats
+		serverStats, err = w.handleStopBandwidthServer(cmd.Data)
+		response.Type = "StopBandwidthServerResponse"
+		response.Data = serverStats
+	case "BandwidthTest":
+		var bandwidthResult BandwidthTestResult
+		bandwidthResult, err = w.handleBandwidthTest(cmd.Data)
+		response.Type = "BandwidthTestResponse"
+		response.Data = bandwidthResult
+
+	// 实时日志
+	case "TailLogs":
+		var tailResult TailLogsResponse
+		tailResult, err = w.handleTailLogs(cmd.Data)
+		response.Type = "TailLogsResponse"
+		response.Data = tailResult
+	case "StopTailLogs":
+		err = w.handleStopTailLogs(cmd.Data)
+		response.Type = "StopTailLogsResponse"
+	case "SetServiceLogLevel":
+		var levels []xlogger.ServiceLevel
+		levels, err = w.handleSetServiceLogLevel(cmd.Data)
+		response.Type = "SetServiceLogLevelResponse"
+		response.Data = levels
+
+	// 节点自升级
+	case "UpgradeAgent":
+		err = w.handleUpgradeAgent(cmd.Data)
+		response.Type = "UpgradeAgentResponse"
+
+	// 节点设置
+	case "GetAgentSettings":
+		response.Type = "GetAgentSettingsResponse"
+		response.Data = w.handleGetAgentSettings()
+	case "ApplyAgentSettings":
+		var settings AgentSettings
+		settings, err = w.handleApplyAgentSettings(cmd.Data)
+		response.Type = "ApplyAgentSettingsResponse"
+		response.Data = settings
+
 	// Protocol blocking switches
 	case "SetProtocol":
 		err = w.handleSetProtocol(cmd.Data)
 		response.Type = "SetProtocolResponse"
 
+	// 密钥轮换
+	case "RotateSecret":
+		err = w.handleRotateSecret(cmd.Data)
+		response.Type = "RotateSecretResponse"
+
 	default:
 		err = fmt.Errorf("未知命令类型: %s", cmd.Type)
 		response.Type = "UnknownCommandResponse"
@@ -658,6 +839,12 @@ func (w *WebSocketReporter) handleResumeService(data interface{}) error {
 	return resumeServices(req)
 }
 
+// isJSONArray 判断命令数据是否为列表
+func isJSONArray(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '['
+}
+
 // Chain 命令处理函数
 func (w *WebSocketReporter) handleAddChain(data interface{}) error {
 	jsonData, err := json.Marshal(data)
@@ -665,6 +852,20 @@ func (w *WebSocketReporter) handleAddChain(data interface{}) error {
 		return fmt.Errorf("序列化数据失败: %v", err)
 	}
 
+	// 端口段转发的全部链通过一条命令以列表下发
+	if isJSONArray(jsonData) {
+		var chains []config.ChainConfig
+		if err := json.Unmarshal(jsonData, &chains); err != nil {
+			return fmt.Errorf("解析链配置失败: %v", err)
+		}
+		for _, chainConfig := range chains {
+			if err := createChain(createChainRequest{Data: chainConfig}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	var chainConfig config.ChainConfig
 	if err := json.Unmarshal(jsonData, &chainConfig); err != nil {
 		return fmt.Errorf("解析链配置失败: %v", err)
@@ -680,6 +881,29 @@ func (w *WebSocketReporter) handleUpdateChain(data interface{}) error {
 		return fmt.Errorf("序列化数据失败: %v", err)
 	}
 
+	// 批量更新的格式为 {"data": [...]}，不存在的链直接创建
+	var batchReq struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(jsonData, &batchReq) == nil && isJSONArray(batchReq.Data) {
+		var chains []config.ChainConfig
+		if err := json.Unmarshal(batchReq.Data, &chains); err != nil {
+			return fmt.Errorf("解析链配置失败: %v", err)
+		}
+		for _, chainConfig := range chains {
+			name := strings.TrimSpace(chainConfig.Name)
+			if registry.ChainRegistry().IsRegistered(name) {
+				err = updateChain(updateChainRequest{Chain: name, Data: chainConfig})
+			} else {
+				err = createChain(createChainRequest{Data: chainConfig})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	// 对于更新操作，Java端发送的格式可能是: {"chain": "name", "data": {...}}
 	var updateReq struct {
 		Chain string             `json:"chain"`
@@ -710,6 +934,22 @@ func (w *WebSocketReporter) handleDeleteChain(data interface{}) error {
 		return fmt.Errorf("序列化数据失败: %v", err)
 	}
 
+	// 批量删除的格式为 {"chains": [...]}，节点上已不存在的链跳过
+	var batchReq struct {
+		Chains []string `json:"chains"`
+	}
+	if json.Unmarshal(jsonData, &batchReq) == nil && len(batchReq.Chains) > 0 {
+		for _, name := range batchReq.Chains {
+			if !registry.ChainRegistry().IsRegistered(strings.TrimSpace(name)) {
+				continue
+			}
+			if err := deleteChain(deleteChainRequest{Chain: name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	// 删除操作可能是: {"chain": "name"} 或者直接是链名称字符串
 	var deleteReq deleteChainRequest
 
@@ -794,6 +1034,188 @@ func (w *WebSocketReporter) handleDeleteLimiter(data interface{}) error {
 	return deleteLimiter(deleteReq)
 }
 
+// ConnLimiter / RateLimiter 命令处理函数
+func (w *WebSocketReporter) handleAddConnLimiter(data interface{}) error {
+	reqs, err := parseCreateLimiterRequests(data)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if err := createConnLimiter(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WebSocketReporter) handleUpdateConnLimiter(data interface{}) error {
+	req, err := parseUpdateLimiterRequest(data)
+	if err != nil {
+		return err
+	}
+	return updateConnLimiter(req)
+}
+
+func (w *WebSocketReporter) handleDeleteConnLimiter(data interface{}) error {
+	req, err := parseDeleteLimiterRequest(data)
+	if err != nil {
+		return err
+	}
+	return deleteConnLimiter(req)
+}
+
+func (w *WebSocketReporter) handleAddRateLimiter(data interface{}) error {
+	reqs, err := parseCreateLimiterRequests(data)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if err := createRateLimiter(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WebSocketReporter) handleUpdateRateLimiter(data interface{}) error {
+	req, err := parseUpdateLimiterRequest(data)
+	if err != nil {
+		return err
+	}
+	return updateRateLimiter(req)
+}
+
+func (w *WebSocketReporter) handleDeleteRateLimiter(data interface{}) error {
+	req, err := parseDeleteLimiterRequest(data)
+	if err != nil {
+		return err
+	}
+	return deleteRateLimiter(req)
+}
+
+// parseCreateLimiterRequest 解析 {"name": "...", "limits": [...]} 格式的限流器配置
+func parseCreateLimiterRequest(data interface{}) (createLimiterRequest, error) {
+	var req createLimiterRequest
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return req, fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, &req.Data); err != nil {
+		return req, fmt.Errorf("解析限流器配置失败: %v", err)
+	}
+	return req, nil
+}
+
+// parseCreateLimiterRequests 解析单个或以列表批量下发的限流器配置
+func parseCreateLimiterRequests(data interface{}) ([]createLimiterRequest, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if !isJSONArray(jsonData) {
+		req, err := parseCreateLimiterRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		return []createLimiterRequest{req}, nil
+	}
+
+	var limiters []config.LimiterConfig
+	if err := json.Unmarshal(jsonData, &limiters); err != nil {
+		return nil, fmt.Errorf("解析限流器配置失败: %v", err)
+	}
+	reqs := make([]createLimiterRequest, 0, len(limiters))
+	for _, limiter := range limiters {
+		reqs = append(reqs, createLimiterRequest{Data: limiter})
+	}
+	return reqs, nil
+}
+
+// parseUpdateLimiterRequest 解析 {"limiter": "name", "data": {...}} 格式的更新请求
+func parseUpdateLimiterRequest(data interface{}) (updateLimiterRequest, error) {
+	var req updateLimiterRequest
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return req, fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return req, fmt.Errorf("解析限流器配置失败: %v", err)
+	}
+	if req.Limiter == "" {
+		req.Limiter = req.Data.Name
+	}
+	return req, nil
+}
+
+// parseDeleteLimiterRequest 解析 {"limiter": "name"} 格式的删除请求
+func parseDeleteLimiterRequest(data interface{}) (deleteLimiterRequest, error) {
+	var req deleteLimiterRequest
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return req, fmt.Errorf("序列化数据失败: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return req, fmt.Errorf("解析限流器删除请求失败: %v", err)
+	}
+	return req, nil
+}
+
+func (w *WebSocketReporter) handleAddResolver(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+
+	// 批量导入的转发的解析器通过一条命令以列表下发
+	if isJSONArray(jsonData) {
+		var resolvers []config.ResolverConfig
+		if err := json.Unmarshal(jsonData, &resolvers); err != nil {
+			return fmt.Errorf("解析解析器配置失败: %v", err)
+		}
+		for _, resolverConfig := range resolvers {
+			if err := createResolver(createResolverRequest{Data: resolverConfig}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var req createResolverRequest
+	if err := json.Unmarshal(jsonData, &req.Data); err != nil {
+		return fmt.Errorf("解析解析器配置失败: %v", err)
+	}
+	return createResolver(req)
+}
+
+func (w *WebSocketReporter) handleUpdateResolver(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+
+	var req updateResolverRequest
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return fmt.Errorf("解析解析器配置失败: %v", err)
+	}
+	if req.Resolver == "" {
+		req.Resolver = req.Data.Name
+	}
+	return updateResolver(req)
+}
+
+func (w *WebSocketReporter) handleDeleteResolver(data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+
+	var req deleteResolverRequest
+	if err := json.Unmarshal(jsonData, &req); err != nil {
+		return fmt.Errorf("解析解析器删除请求失败: %v", err)
+	}
+	return deleteResolver(req)
+}
+
 // handleSetProtocol 处理设置屏蔽协议的命令
 func (w *WebSocketReporter) handleSetProtocol(data interface{}) error {
 	jsonData, err := json.Marshal(data)
@@ -847,23 +1269,15 @@ func (w *WebSocketReporter) handleSetProtocol(data interface{}) error {
 func updateLocalConfigJSON(httpVal int, tlsVal int, socksVal int) error {
 	path := "config.json"
 
-	// 读取现有配置
-	type LocalConfig struct {
-		Addr   string `json:"addr"`
-		Secret string `json:"secret"`
-		Http   int    `json:"http"`
-		Tls    int    `json:"tls"`
-		Socks  int    `json:"socks"`
-	}
-
-	var cfg LocalConfig
+	// 读取现有配置，保留其他字段（如传输加密配置）
+	cfg := make(map[string]interface{})
 	if b, err := os.ReadFile(path); err == nil {
 		_ = json.Unmarshal(b, &cfg)
 	}
 
-	cfg.Http = httpVal
-	cfg.Tls = tlsVal
-	cfg.Socks = socksVal
+	cfg["http"] = httpVal
+	cfg["tls"] = tlsVal
+	cfg["socks"] = socksVal
 
 	// 写回
 	data, err := json.MarshalIndent(cfg, "", "  ")
@@ -926,30 +1340,7 @@ func (w *WebSocketReporter) sendResponse(response CommandResponse) {
 		return
 	}
 
-	var messageData []byte
-
-	// 如果有加密器，则加密数据
-	if w.aesCrypto != nil {
-		encryptedData, err := w.aesCrypto.Encrypt(jsonData)
-		if err != nil {
-			fmt.Printf("⚠️ 加密响应失败，发送原始数据: %v\n", err)
-			messageData = jsonData
-		} else {
-			// 创建加密消息包装器
-			encryptedMessage := map[string]interface{}{
-				"encrypted": true,
-				"data":      encryptedData,
-				"timestamp": time.Now().Unix(),
-			}
-			messageData, err = json.Marshal(encryptedMessage)
-			if err != nil {
-				fmt.Printf("⚠️ 序列化加密响应失败，发送原始数据: %v\n", err)
-				messageData = jsonData
-			}
-		}
-	} else {
-		messageData = jsonData
-	}
+	messageData := w.sealMessage(jsonData)
 
 	// 检查消息大小，如果超过10MB则记录警告
 	if len(messageData) > 10*1024*1024 {
@@ -1043,7 +1434,7 @@ func getMemoryInfo() MemoryInfo {
 func StartWebSocketReporterWithConfig(addr string, secret string, http int, tls int, socks int, version string) *WebSocketReporter {
 
 	// 构建初始 WebSocket URL
-	fullURL := "ws://" + addr + "/system-info?type=1&secret=" + secret + "&version=" + version + "&http=" + strconv.Itoa(http) + "&tls=" + strconv.Itoa(tls) + "&socks=" + strconv.Itoa(socks)
+	fullURL := service.PanelWSURL(addr, "/system-info?type=1&version="+version+"&http="+strconv.Itoa(http)+"&tls="+strconv.Itoa(tls)+"&socks="+strconv.Itoa(socks)+"&os="+runtime.GOOS+"&arch="+runtime.GOARCH)
 
 	fmt.Printf("🔗 WebSocket连接URL: %s\n", fullURL)
 
@@ -1052,6 +1443,19 @@ func StartWebSocketReporterWithConfig(addr string, secret string, http int, tls
 	reporter.addr = addr
 	reporter.secret = secret
 	reporter.version = version
+	reporter.reportAddr = addr
+	reporter.addrs = []string{addr}
+
+	// 面板下发过设置时使用其中的地址列表和上报间隔
+	settingsMutex.RLock()
+	if len(agentSettings.Addrs) > 0 {
+		reporter.addrs = append([]string(nil), agentSettings.Addrs...)
+	}
+	if agentSettings.PingInterval > 0 {
+		reporter.pingInterval = time.Duration(agentSettings.PingInterval) * time.Second
+	}
+	settingsMutex.RUnlock()
+	reporter.checkPendingUpgrade()
 	reporter.Start()
 	return reporter
 }
@@ -1099,12 +1503,30 @@ func (w *WebSocketReporter) handleTcpPing(data interface{}) (TcpPingResponse, er
 	}
 
 	// 执行TCP ping操作
-	avgTime, packetLoss, err := tcpPingHost(req.IP, req.Port, req.Count, req.Timeout)
+	// 域名先按转发相同的解析器与优先级解析
+	ip := req.IP
+	var resolvedIPs []string
+	if net.ParseIP(req.IP) == nil {
+		resolvedIPs, err = resolveHost(req.IP, req.Resolver, req.Prefer, time.Duration(req.Timeout)*time.Millisecond)
+		if err != nil {
+			return TcpPingResponse{
+				IP:           req.IP,
+				Port:         req.Port,
+				Success:      false,
+				ErrorMessage: err.Error(),
+				RequestId:    req.RequestId,
+			}, nil
+		}
+		ip = resolvedIPs[0]
+	}
+
+	avgTime, packetLoss, err := tcpPingHost(ip, req.Port, req.Count, req.Timeout)
 
 	response := TcpPingResponse{
-		IP:        req.IP,
-		Port:      req.Port,
-		RequestId: req.RequestId,
+		IP:          req.IP,
+		Port:        req.Port,
+		ResolvedIPs: resolvedIPs,
+		RequestId:   req.RequestId,
 	}
 
 	if err != nil {
@@ -1132,32 +1554,6 @@ func tcpPingHost(ip string, port int, count int, timeoutMs int) (float64, float6
 
 	fmt.Printf("🔍 开始TCP ping测试: %s，次数: %d，超时: %dms\n", target, count, timeoutMs)
 
-	// 如果是域名，先解析一次DNS，避免每次连接都重新解析导致延迟累加
-	if net.ParseIP(ip) == nil {
-		// 是域名，需要解析
-		fmt.Printf("🔍 检测到域名，正在解析DNS...\n")
-		dnsStart := time.Now()
-
-		addrs, err := net.LookupHost(ip)
-		dnsDuration := time.Since(dnsStart)
-
-		if err != nil {
-			return 0, 100.0, fmt.Errorf("DNS解析失败: %v", err)
-		}
-		if len(addrs) == 0 {
-			return 0, 100.0, fmt.Errorf("DNS解析未返回任何IP地址")
-		}
-
-		fmt.Printf("✅ DNS解析完成 (%.2fms)，解析到 %d 个IP: %v\n",
-			dnsDuration.Seconds()*1000, len(addrs), addrs)
-
-		// 使用第一个解析到的IP进行测试
-		target = net.JoinHostPort(addrs[0], fmt.Sprintf("%d", port))
-		fmt.Printf("🎯 使用IP地址进行测试: %s\n", target)
-	} else {
-		fmt.Printf("🎯 使用IP地址进行测试: %s\n", target)
-	}
-
 	for i := 0; i < count; i++ {
 		start := time.Now()
 
@@ -1193,6 +1589,57 @@ func tcpPingHost(ip string, port int, count int, timeoutMs int) (float64, float6
 	return avgTime, packetLoss, nil
 }
 
+// resolveHost 解析域名，指定解析器时使用节点上注册的解析器，否则使用系统解析，
+// 结果按 prefer 排序 (默认IPv4优先)
+func resolveHost(host, resolverName, prefer string, timeout time.Duration) ([]string, error) {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dnsStart := time.Now()
+
+	var ips []net.IP
+	if resolverName != "" {
+		r := registry.ResolverRegistry().Get(resolverName)
+		resolved, err := r.Resolve(ctx, "ip", host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS解析失败 (解析器 %s): %v", resolverName, err)
+		}
+		ips = resolved
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS解析失败: %v", err)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("DNS解析未返回任何IP地址")
+	}
+
+	preferV6 := prefer == "ipv6" || prefer == "ip6"
+	sort.SliceStable(ips, func(i, j int) bool {
+		iv4, jv4 := ips[i].To4() != nil, ips[j].To4() != nil
+		if preferV6 {
+			return !iv4 && jv4
+		}
+		return iv4 && !jv4
+	})
+
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+
+	fmt.Printf("✅ DNS解析完成 (%.2fms)，解析到 %d 个IP: %v\n",
+		time.Since(dnsStart).Seconds()*1000, len(result), result)
+	return result, nil
+}
+
 // isValidHostname 验证主机名格式
 func isValidHostname(hostname string) bool {
 	if len(hostname) == 0 || len(hostname) > 253 {